@@ -0,0 +1,211 @@
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// maxRequestSize bounds the size of the messages submitted to the REST
+// API, attachments included.
+const maxRequestSize = 32 << 20
+
+// apiMessage is the JSON representation of a message submitted to the
+// REST API.
+type apiMessage struct {
+	From        string              `json:"from"`
+	Sender      string              `json:"sender"`
+	ReplyTo     string              `json:"reply_to"`
+	To          []string            `json:"to"`
+	Cc          []string            `json:"cc"`
+	Bcc         []string            `json:"bcc"`
+	Subject     string              `json:"subject"`
+	MessageID   string              `json:"message_id"`
+	InReplyTo   string              `json:"in_reply_to"`
+	References  []string            `json:"references"`
+	Priority    string              `json:"priority"`
+	Campaign    string              `json:"campaign"`
+	Headers     map[string][]string `json:"headers"`
+	Parts       []apiPart           `json:"parts"`
+	Attachments []apiAttachment     `json:"attachments"`
+}
+
+type apiPart struct {
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+// apiAttachment holds its content base64 encoded, as encoding/json does
+// for byte slices.
+type apiAttachment struct {
+	Filename           string `json:"filename"`
+	ContentDisposition string `json:"content_disposition"`
+	ContentID          string `json:"content_id"`
+	Content            []byte `json:"content"`
+}
+
+func (am *apiMessage) mail() (*Mail, error) {
+	m := &Mail{
+		From:       am.From,
+		Sender:     am.Sender,
+		ReplyTo:    am.ReplyTo,
+		To:         am.To,
+		Cc:         am.Cc,
+		Bcc:        am.Bcc,
+		Subject:    am.Subject,
+		MessageID:  am.MessageID,
+		InReplyTo:  am.InReplyTo,
+		References: am.References,
+		Campaign:   am.Campaign,
+	}
+
+	if am.Priority != "" {
+		var err error
+		if m.Priority, err = ParsePriority(am.Priority); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(am.Headers) > 0 {
+		m.Header = make(textproto.MIMEHeader, len(am.Headers))
+		for k, v := range am.Headers {
+			m.Header[textproto.CanonicalMIMEHeaderKey(k)] = v
+		}
+	}
+
+	for _, p := range am.Parts {
+		part := Part{ContentType: p.ContentType, Content: []byte(p.Content)}
+		if part.ContentType == "" {
+			part.ContentType = "text/plain"
+			part.Params = map[string]string{"charset": "utf-8"}
+		}
+		m.Parts = append(m.Parts, part)
+	}
+
+	for _, a := range am.Attachments {
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:           a.Filename,
+			ContentDisposition: a.ContentDisposition,
+			ContentID:          a.ContentID,
+			Content:            a.Content,
+		})
+	}
+
+	return m, nil
+}
+
+type api struct {
+	queue *Queue
+}
+
+// NewHandler returns an http.Handler exposing q as a REST API:
+//
+//	POST /messages       queue the JSON message of the body
+//	GET  /messages/{id}  return the Status of a message
+//	GET  /stats          return the QueueStats of the queue
+//
+// Messages failing Lint with errors are rejected with status 422 and the
+// list of issues.
+func NewHandler(q *Queue) http.Handler {
+	return &api{queue: q}
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == "/messages":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		a.post(w, r)
+
+	case strings.HasPrefix(path, "/messages/") && !strings.Contains(path[len("/messages/"):], "/"):
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		a.get(w, path[len("/messages/"):])
+
+	case path == "/stats":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		apiJSON(w, http.StatusOK, a.queue.Stats())
+
+	default:
+		apiError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (a *api) post(w http.ResponseWriter, r *http.Request) {
+	var am apiMessage
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&am); err != nil {
+		apiError(w, http.StatusBadRequest, fmt.Sprintf("invalid message: %v", err))
+		return
+	}
+
+	m, err := am.mail()
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if issues := Lint(m); HasErrors(issues) {
+		apiJSON(w, http.StatusUnprocessableEntity, struct {
+			Error  string  `json:"error"`
+			Issues []Issue `json:"issues"`
+		}{"invalid message", issues})
+		return
+	}
+
+	id, err := a.queue.Enqueue(m)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s, err := a.queue.Status(id)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/messages/"+id)
+	apiJSON(w, http.StatusAccepted, s)
+}
+
+func (a *api) get(w http.ResponseWriter, id string) {
+	s, err := a.queue.Status(id)
+	if err == ErrUnknownMessage {
+		apiError(w, http.StatusNotFound, "unknown message")
+		return
+	} else if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiJSON(w, http.StatusOK, s)
+}
+
+func apiError(w http.ResponseWriter, code int, msg string) {
+	apiJSON(w, code, struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+func apiJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,178 @@
+package postman
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// apiRequest sends a request to a handler of q, and returns its status
+// and its decoded JSON body.
+func apiRequest(t *testing.T, q *Queue, method, path, body string) (int, http.Header, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	NewHandler(q).ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: Content-Type: %s", method, path, ct)
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("%s %s: %v: %s", method, path, err, rec.Body)
+	}
+	return rec.Code, rec.Header(), v
+}
+
+// queuedMails returns the messages waiting in q.
+func queuedMails(q *Queue) []*Mail {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var msgs []*Mail
+	for _, lane := range q.lanes {
+		for _, e := range lane {
+			msgs = append(msgs, e.m)
+		}
+	}
+	return msgs
+}
+
+func TestAPIPost(t *testing.T) {
+	q := NewQueue(&outboxTransport{})
+
+	code, header, v := apiRequest(t, q, "POST", "/messages", `{
+		"from": "Sender <sender@example.com>",
+		"to": ["rcpt@example.com"],
+		"bcc": ["bcc@example.com"],
+		"subject": "Hello",
+		"message_id": "<m1@postman.test>",
+		"priority": "urgent",
+		"campaign": "spring",
+		"headers": {"x-entity-ref-id": ["ref"]},
+		"parts": [{"content": "Hello"}, {"content_type": "text/html", "content": "<p>Hello</p>"}],
+		"attachments": [{"filename": "a.txt", "content": "YXR0YWNobWVudA=="}]
+	}`)
+
+	if code != http.StatusAccepted {
+		t.Fatalf("status %d: %v", code, v)
+	}
+	if loc := header.Get("Location"); loc != "/messages/m1@postman.test" {
+		t.Errorf("Location: %s", loc)
+	}
+	if v["id"] != "m1@postman.test" || v["state"] != "queued" {
+		t.Errorf("got status %v", v)
+	}
+
+	msgs := queuedMails(q)
+	if len(msgs) != 1 {
+		t.Fatalf("%d messages queued, want 1", len(msgs))
+	}
+
+	want := &Mail{
+		From:      "Sender <sender@example.com>",
+		To:        []string{"rcpt@example.com"},
+		Bcc:       []string{"bcc@example.com"},
+		Subject:   "Hello",
+		MessageID: "<m1@postman.test>",
+		Priority:  PriorityUrgent,
+		Campaign:  "spring",
+		Header:    textproto.MIMEHeader{"X-Entity-Ref-Id": {"ref"}},
+		Parts: []Part{
+			// Parts are UTF-8 plain text by default.
+			{ContentType: "text/plain", Params: map[string]string{"charset": "utf-8"}, Content: []byte("Hello")},
+			{ContentType: "text/html", Content: []byte("<p>Hello</p>")},
+		},
+		Attachments: []Attachment{{Filename: "a.txt", Content: []byte("attachment")}},
+	}
+	if !reflect.DeepEqual(msgs[0], want) {
+		t.Errorf("queued %+v, want %+v", msgs[0], want)
+	}
+
+	code, _, v = apiRequest(t, q, "GET", "/messages/m1@postman.test", "")
+	if code != http.StatusOK || v["state"] != "queued" {
+		t.Errorf("GET: status %d: %v", code, v)
+	}
+}
+
+func TestAPIStats(t *testing.T) {
+	q := NewQueue(&outboxTransport{})
+	m := benchMail(1)
+	m.Priority = PriorityUrgent
+	if _, err := q.Enqueue(m); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, v := apiRequest(t, q, "GET", "/stats", "")
+	want := map[string]interface{}{
+		"urgent":     1.0,
+		"normal":     0.0,
+		"non_urgent": 0.0,
+		"deferred":   0.0,
+		"in_flight":  0.0,
+	}
+	if code != http.StatusOK || !reflect.DeepEqual(v, want) {
+		t.Errorf("got %d, %v, want %v", code, v, want)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		err    string
+	}{
+		{"bad address", "POST", "/messages", `{"from": "sender@example.com", "to": ["rcpt@"], "subject": "Hello", "parts": [{"content": "Hello"}]}`,
+			http.StatusUnprocessableEntity, "invalid message"},
+		{"missing From", "POST", "/messages", `{"to": ["rcpt@example.com"], "parts": [{"content": "Hello"}]}`,
+			http.StatusUnprocessableEntity, "invalid message"},
+		{"unknown field", "POST", "/messages", `{"form": "sender@example.com"}`,
+			http.StatusBadRequest, `invalid message: json: unknown field "form"`},
+		{"bad priority", "POST", "/messages", `{"from": "sender@example.com", "priority": "asap"}`,
+			http.StatusBadRequest, ""},
+		{"oversize body", "POST", "/messages", `{"subject": "` + strings.Repeat("a", maxRequestSize) + `"}`,
+			http.StatusBadRequest, "invalid message: http: request body too large"},
+		{"unknown message", "GET", "/messages/unknown@postman.test", "", http.StatusNotFound, "unknown message"},
+		{"list", "GET", "/messages", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{"delete", "DELETE", "/messages/m1@postman.test", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{"other path", "GET", "/other", "", http.StatusNotFound, "not found"},
+		{"post stats", "POST", "/stats", "", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+
+	for _, tt := range tests {
+		q := NewQueue(&outboxTransport{})
+
+		code, _, v := apiRequest(t, q, tt.method, tt.path, tt.body)
+		if code != tt.code {
+			t.Errorf("%s: status %d, want %d: %v", tt.name, code, tt.code, v)
+		}
+		if msg, _ := v["error"].(string); msg == "" || (tt.err != "" && msg != tt.err) {
+			t.Errorf("%s: error %q, want %q", tt.name, msg, tt.err)
+		}
+		if n := len(queuedMails(q)); n != 0 {
+			t.Errorf("%s: %d messages queued", tt.name, n)
+		}
+	}
+
+	// The issues of messages failing Lint are listed.
+	q := NewQueue(&outboxTransport{})
+	_, _, v := apiRequest(t, q, "POST", "/messages", tests[0].body)
+	issues, _ := v["issues"].([]interface{})
+	found := false
+	for _, i := range issues {
+		i, _ := i.(map[string]interface{})
+		found = found || (i["severity"] == "error" && i["field"] == "To")
+	}
+	if !found {
+		t.Errorf("issues %v, want an error on To", issues)
+	}
+}
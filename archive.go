@@ -0,0 +1,385 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// archiveSchema creates the tables of an Archive in a SQLite database.
+const archiveSchema = `
+CREATE TABLE IF NOT EXISTS postman_archive (
+    id         TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL,
+    sent       TIMESTAMP NOT NULL,
+    mail_from  TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    campaign   TEXT NOT NULL,
+    state      TEXT NOT NULL,
+    error      TEXT NOT NULL,
+    response   TEXT NOT NULL,
+    message    BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS postman_archive_sent ON postman_archive (sent);
+CREATE INDEX IF NOT EXISTS postman_archive_message_id ON postman_archive (message_id);
+CREATE INDEX IF NOT EXISTS postman_archive_campaign ON postman_archive (campaign, sent);
+CREATE TABLE IF NOT EXISTS postman_archive_recipients (
+    archive_id TEXT NOT NULL REFERENCES postman_archive (id) ON DELETE CASCADE,
+    address    TEXT NOT NULL COLLATE NOCASE,
+    state      TEXT NOT NULL,
+    error      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS postman_archive_recipients_address ON postman_archive_recipients (address);
+CREATE TABLE IF NOT EXISTS postman_suppressions (
+    address    TEXT PRIMARY KEY COLLATE NOCASE,
+    reason     TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    created    TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS postman_quotas (
+    sender       TEXT NOT NULL,
+    window_start TIMESTAMP NOT NULL,
+    messages     INTEGER NOT NULL,
+    bytes        INTEGER NOT NULL,
+    PRIMARY KEY (sender, window_start)
+);
+`
+
+// Archive keeps a copy of every message sent, along with the outcome of
+// its delivery, in a SQLite database opened by the caller with the
+// driver of their choice.
+type Archive struct {
+	DB *sql.DB
+
+	// ErrorLog receives the errors of the archive itself, which do not
+	// fail deliveries. Nil means the standard logger.
+	ErrorLog *log.Logger
+}
+
+// ArchivedMessage is a message recorded in an Archive.
+type ArchivedMessage struct {
+	ID         string
+	MessageID  string
+	Sent       time.Time
+	From       string
+	Recipients []string
+	Subject    string
+	Campaign   string
+
+	// State is StateDelivered or the failure state of the delivery,
+	// then updated by ApplyDSN and ApplyFeedbackReport.
+	State State
+
+	// Error is the delivery error of failed messages.
+	Error string
+
+	// Response is the reply of the server accepting the message, when the
+	// transport reports it.
+	Response string
+
+	// Raw is the message as rendered by postman. Search does not load
+	// it.
+	Raw []byte
+}
+
+// Mail parses the raw message.
+func (am *ArchivedMessage) Mail() (*Mail, error) {
+	return ParseMail(bytes.NewReader(am.Raw))
+}
+
+// Init creates the tables of the archive if they do not exist.
+func (a *Archive) Init(ctx context.Context) error {
+	for _, stmt := range strings.Split(archiveSchema, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := a.DB.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transport returns a transport sending messages through t and recording
+// them in the archive. Messages are recorded as rendered before t sends
+// them, without the fields it adds, such as X-Mailer or DKIM-Signature,
+// and with other MIME boundaries if it renders them again: set the
+// Archive of a Client instead to record them as it sends them.
+func (a *Archive) Transport(t Transport) Transport {
+	return &archiveTransport{archive: a, transport: t}
+}
+
+type archiveTransport struct {
+	archive   *Archive
+	transport Transport
+}
+
+func (t *archiveTransport) Send(m *Mail) error {
+	_, err := t.Submit(m)
+	return err
+}
+
+func (t *archiveTransport) Submit(m *Mail) (string, error) {
+	mm := *m
+
+	// Fix the generated fields so that the copy archived is the message
+	// sent.
+	if mm.Date.IsZero() {
+		mm.Date = time.Now()
+	}
+
+	if mm.MessageID == "" {
+		id, err := genMsgID()
+		if err != nil {
+			return "", err
+		}
+		mm.MessageID = id
+	}
+
+	raw, err := mm.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	var reply string
+	if s, ok := t.transport.(Submitter); ok {
+		reply, err = s.Submit(&mm)
+	} else {
+		err = t.transport.Send(&mm)
+	}
+
+	if _, aerr := t.archive.Record(context.Background(), &mm, raw, reply, err); aerr != nil {
+		t.archive.logf("postman: archive: %v", aerr)
+	}
+
+	return reply, err
+}
+
+// Record adds to the archive the message m, rendered as raw, with the
+// reply of the server or the delivery error. It returns the identifier
+// of the record.
+func (a *Archive) Record(ctx context.Context, m *Mail, raw []byte, reply string, sendErr error) (string, error) {
+	rcpts, err := m.Recipients()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := genID()
+	if err != nil {
+		return "", err
+	}
+
+	state, errMsg := StateDelivered, ""
+	if sendErr != nil {
+		state, errMsg = StateFailed, sendErr.Error()
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO postman_archive (id, message_id, sent, mail_from, subject, campaign, state, error, response, message) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, angleBracket(m.MessageID), date.UTC(), m.From, m.Subject, m.Campaign, string(state), errMsg, reply, raw)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rcpt := range rcpts {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO postman_archive_recipients (archive_id, address, state, error) VALUES (?, ?, ?, ?)",
+			id, rcpt, string(state), errMsg)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// Get returns the archived message with the given identifier, or
+// ErrUnknownMessage.
+func (a *Archive) Get(ctx context.Context, id string) (*ArchivedMessage, error) {
+	var (
+		am    = ArchivedMessage{ID: id}
+		state string
+	)
+
+	err := a.DB.QueryRowContext(ctx,
+		"SELECT message_id, sent, mail_from, subject, campaign, state, error, response, message FROM postman_archive WHERE id = ?", id).
+		Scan(&am.MessageID, &am.Sent, &am.From, &am.Subject, &am.Campaign, &state, &am.Error, &am.Response, &am.Raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownMessage
+	} else if err != nil {
+		return nil, err
+	}
+	am.State = State(state)
+
+	if am.Recipients, err = a.recipients(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return &am, nil
+}
+
+// DefaultSearchLimit is the number of messages per page returned by
+// Search when the query does not set a limit.
+const DefaultSearchLimit = 50
+
+// ArchiveQuery selects messages of an Archive. Zero fields match every
+// message.
+type ArchiveQuery struct {
+	// Recipient matches the messages sent to the address, ignoring case.
+	Recipient string
+
+	// Subject matches the messages whose subject contains it.
+	Subject string
+
+	// Since and Until bound the sending date, Until being excluded.
+	Since, Until time.Time
+
+	State    State
+	Campaign string
+
+	// Limit and Offset select a page of the results, the most recent
+	// messages first.
+	Limit, Offset int
+}
+
+// ArchivePage is a page of the results of Search.
+type ArchivePage struct {
+	Messages []ArchivedMessage
+
+	// Total is the number of messages matching the query, on every page.
+	Total int
+}
+
+// Search returns the messages matching q, without their raw content.
+func (a *Archive) Search(ctx context.Context, q ArchiveQuery) (*ArchivePage, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if q.Recipient != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM postman_archive_recipients r WHERE r.archive_id = a.id AND r.address = ?)")
+		args = append(args, q.Recipient)
+	}
+
+	if q.Subject != "" {
+		where = append(where, `a.subject LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(q.Subject)+"%")
+	}
+
+	if !q.Since.IsZero() {
+		where = append(where, "a.sent >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	if !q.Until.IsZero() {
+		where = append(where, "a.sent < ?")
+		args = append(args, q.Until.UTC())
+	}
+
+	if q.State != "" {
+		where = append(where, "a.state = ?")
+		args = append(args, string(q.State))
+	}
+
+	if q.Campaign != "" {
+		where = append(where, "a.campaign = ?")
+		args = append(args, q.Campaign)
+	}
+
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var page ArchivePage
+
+	err := a.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM postman_archive a"+cond, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	rows, err := a.DB.QueryContext(ctx,
+		"SELECT a.id, a.message_id, a.sent, a.mail_from, a.subject, a.campaign, a.state, a.error, a.response FROM postman_archive a"+
+			cond+" ORDER BY a.sent DESC, a.id LIMIT ? OFFSET ?",
+		append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			am    ArchivedMessage
+			state string
+		)
+		err := rows.Scan(&am.ID, &am.MessageID, &am.Sent, &am.From, &am.Subject, &am.Campaign, &state, &am.Error, &am.Response)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		am.State = State(state)
+		page.Messages = append(page.Messages, am)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range page.Messages {
+		am := &page.Messages[i]
+		if am.Recipients, err = a.recipients(ctx, am.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (a *Archive) recipients(ctx context.Context, id string) ([]string, error) {
+	rows, err := a.DB.QueryContext(ctx,
+		"SELECT address FROM postman_archive_recipients WHERE archive_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rcpts []string
+	for rows.Next() {
+		var rcpt string
+		if err := rows.Scan(&rcpt); err != nil {
+			return nil, err
+		}
+		rcpts = append(rcpts, rcpt)
+	}
+
+	return rcpts, rows.Err()
+}
+
+func (a *Archive) logf(format string, args ...interface{}) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,337 @@
+package postman
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveRow is a row of the postman_archive table of a stubArchive.
+type archiveRow struct {
+	id, msgID, from, subject, campaign string
+	state, err, response               string
+	sent                               time.Time
+	message                            []byte
+}
+
+// stubArchive is the database of an Archive, kept in memory.
+type stubArchive struct {
+	stubDB
+	rows  []*archiveRow
+	rcpts map[string][]string
+}
+
+func newStubArchive() *stubArchive {
+	db := &stubArchive{rcpts: make(map[string][]string)}
+
+	db.handle("INSERT INTO postman_archive (", func(args []driver.Value) (*stubResult, error) {
+		db.rows = append(db.rows, &archiveRow{
+			id:       args[0].(string),
+			msgID:    args[1].(string),
+			sent:     args[2].(time.Time),
+			from:     args[3].(string),
+			subject:  args[4].(string),
+			campaign: args[5].(string),
+			state:    args[6].(string),
+			err:      args[7].(string),
+			response: args[8].(string),
+			message:  args[9].([]byte),
+		})
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("INSERT INTO postman_archive_recipients ", func(args []driver.Value) (*stubResult, error) {
+		id := args[0].(string)
+		db.rcpts[id] = append(db.rcpts[id], args[1].(string))
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("SELECT message_id, sent, mail_from, subject, campaign, state, error, response, message FROM postman_archive WHERE id = ?", func(args []driver.Value) (*stubResult, error) {
+		res := &stubResult{columns: []string{"message_id", "sent", "mail_from", "subject", "campaign", "state", "error", "response", "message"}}
+		for _, r := range db.rows {
+			if r.id == args[0].(string) {
+				res.rows = append(res.rows, []driver.Value{r.msgID, r.sent, r.from, r.subject, r.campaign, r.state, r.err, r.response, r.message})
+			}
+		}
+		return res, nil
+	})
+
+	db.handle("SELECT address FROM postman_archive_recipients WHERE archive_id = ?", func(args []driver.Value) (*stubResult, error) {
+		res := &stubResult{columns: []string{"address"}}
+		for _, rcpt := range db.rcpts[args[0].(string)] {
+			res.rows = append(res.rows, []driver.Value{rcpt})
+		}
+		return res, nil
+	})
+
+	return db
+}
+
+// archived returns the messages recorded in db.
+func (db *stubArchive) archived(t *testing.T, a *Archive) []*ArchivedMessage {
+	t.Helper()
+
+	db.mu.Lock()
+	var ids []string
+	for _, r := range db.rows {
+		ids = append(ids, r.id)
+	}
+	db.mu.Unlock()
+
+	var msgs []*ArchivedMessage
+	for _, id := range ids {
+		am, err := a.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, am)
+	}
+	return msgs
+}
+
+func TestArchiveRecord(t *testing.T) {
+	db := newStubArchive()
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	ctx := context.Background()
+	m := benchMail(1)
+	m.Campaign = "spring"
+
+	id, err := a.Record(ctx, m, []byte("raw"), "250 2.0.0 Ok: queued as 4F2A1C0A3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am, err := a.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ArchivedMessage{
+		ID:         id,
+		MessageID:  "<bench@postman.test>",
+		Sent:       m.Date,
+		From:       "Sender <sender@example.com>",
+		Recipients: []string{"to0@example.com", "cc0@example.com"},
+		Subject:    "Benchmark",
+		Campaign:   "spring",
+		State:      StateDelivered,
+		Response:   "250 2.0.0 Ok: queued as 4F2A1C0A3",
+		Raw:        []byte("raw"),
+	}
+	if !reflect.DeepEqual(am, want) {
+		t.Errorf("got %+v, want %+v", am, want)
+	}
+
+	id, err = a.Record(ctx, m, []byte("raw"), "", errors.New("550 5.1.1 unknown user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if am, err := a.Get(ctx, id); err != nil || am.State != StateFailed || am.Error != "550 5.1.1 unknown user" {
+		t.Errorf("failed message: got %+v, %v", am, err)
+	}
+
+	if _, err := a.Get(ctx, "unknown"); err != ErrUnknownMessage {
+		t.Errorf("unknown message: got %v, want %v", err, ErrUnknownMessage)
+	}
+}
+
+// TestClientArchive checks that a client records the messages as it
+// sends them, with their fields added by the client and their signature.
+func TestClientArchive(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newStubArchive()
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	c := NewClient(s.Addr, WithArchive(a), WithDKIM(&DKIMSigner{Domain: "example.com", Selector: "s", Key: key}))
+	c.Mailer = "postman-test"
+	defer c.Close()
+
+	m := benchMail(1)
+	m.Bcc = []string{"bcc@example.com"}
+	if err := c.Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := benchMail(1).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SendRaw("sender@example.com", []string{"raw@example.com"}, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	sent := s.Messages()
+	archived := db.archived(t, a)
+	if len(sent) != 2 || len(archived) != 2 {
+		t.Fatalf("%d messages sent and %d archived, want 2", len(sent), len(archived))
+	}
+
+	rcpts := [][]string{
+		{"to0@example.com", "cc0@example.com", "bcc@example.com"},
+		{"raw@example.com"},
+	}
+	for i, am := range archived {
+		if got := strings.Replace(string(am.Raw), "\r\n", "\n", -1); got != sent[i].Data {
+			t.Errorf("message %d: archived\n%s\nsent\n%s", i, got, sent[i].Data)
+		}
+		if !strings.HasPrefix(string(am.Raw), "DKIM-Signature: ") {
+			t.Errorf("message %d archived without its signature", i)
+		}
+		if am.Subject != "Benchmark" || am.MessageID != "<bench@postman.test>" || am.State != StateDelivered || am.Response == "" {
+			t.Errorf("message %d archived as %+v", i, am)
+		}
+		if !reflect.DeepEqual(am.Recipients, rcpts[i]) {
+			t.Errorf("message %d archived for %q, want %q", i, am.Recipients, rcpts[i])
+		}
+	}
+	if !strings.Contains(string(archived[0].Raw), "\r\nX-Mailer: postman-test\r\n") {
+		t.Errorf("message archived without the mailer of the client:\n%s", archived[0].Raw)
+	}
+}
+
+// TestArchiveTransport checks that a transport recording messages
+// records them with the fields it generates, as they are sent.
+func TestArchiveTransport(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	db := newStubArchive()
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	c := NewClient(s.Addr, WithMailer(""))
+	defer c.Close()
+
+	m := benchMail(1)
+	m.Date, m.MessageID = time.Time{}, ""
+	if err := a.Transport(c).Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	sent := s.Messages()
+	archived := db.archived(t, a)
+	if len(sent) != 1 || len(archived) != 1 {
+		t.Fatalf("%d messages sent and %d archived, want 1", len(sent), len(archived))
+	}
+
+	got, err := archived[0].Mail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseMail(strings.NewReader(sent[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MessageID == "" || got.MessageID != want.MessageID || !got.Date.Equal(want.Date) {
+		t.Errorf("archived %s of %s, sent %s of %s", got.MessageID, got.Date, want.MessageID, want.Date)
+	}
+	// The date of the record is the one of the message, rendered to the
+	// second.
+	if am := archived[0]; am.MessageID != got.MessageID || !am.Sent.Truncate(time.Second).Equal(got.Date) {
+		t.Errorf("recorded %s of %s", am.MessageID, am.Sent)
+	}
+}
+
+func TestArchiveSearch(t *testing.T) {
+	sent := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	const columns = "a.id, a.message_id, a.sent, a.mail_from, a.subject, a.campaign, a.state, a.error, a.response"
+
+	tests := []struct {
+		name  string
+		query ArchiveQuery
+		cond  string
+		args  []driver.Value
+	}{
+		{"every message", ArchiveQuery{}, "", []driver.Value{}},
+		{"recipient", ArchiveQuery{Recipient: "rcpt@example.com"},
+			" WHERE EXISTS (SELECT 1 FROM postman_archive_recipients r WHERE r.archive_id = a.id AND r.address = ?)",
+			[]driver.Value{"rcpt@example.com"}},
+		{"subject", ArchiveQuery{Subject: `100% _off_ \o/`},
+			` WHERE a.subject LIKE ? ESCAPE '\'`,
+			[]driver.Value{`%100\% \_off\_ \\o/%`}},
+		{"every field", ArchiveQuery{Since: sent, Until: sent.Add(time.Hour), State: StateBounced, Campaign: "spring"},
+			" WHERE a.sent >= ? AND a.sent < ? AND a.state = ? AND a.campaign = ?",
+			[]driver.Value{sent, sent.Add(time.Hour), "bounced", "spring"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count, search []driver.Value
+
+			db := new(stubDB)
+			db.handle("SELECT COUNT(*) FROM postman_archive a"+tt.cond, func(args []driver.Value) (*stubResult, error) {
+				count = args
+				return &stubResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(3)}}}, nil
+			})
+			db.handle("SELECT "+columns+" FROM postman_archive a"+tt.cond+" ORDER BY a.sent DESC, a.id LIMIT ? OFFSET ?", func(args []driver.Value) (*stubResult, error) {
+				search = args
+				return &stubResult{
+					columns: strings.Split(columns, ", "),
+					rows: [][]driver.Value{
+						{"1", "<m1@postman.test>", sent, "sender@example.com", "Spring sale", "spring", "bounced", "550 5.1.1 unknown user", ""},
+					},
+				}, nil
+			})
+			db.handle("SELECT address FROM postman_archive_recipients WHERE archive_id = ?", func(args []driver.Value) (*stubResult, error) {
+				return &stubResult{columns: []string{"address"}, rows: [][]driver.Value{{"rcpt@example.com"}}}, nil
+			})
+
+			a := &Archive{DB: db.open()}
+			defer a.DB.Close()
+
+			q := tt.query
+			q.Offset = 50
+			page, err := a.Search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := &ArchivePage{
+				Messages: []ArchivedMessage{{
+					ID:         "1",
+					MessageID:  "<m1@postman.test>",
+					Sent:       sent,
+					From:       "sender@example.com",
+					Recipients: []string{"rcpt@example.com"},
+					Subject:    "Spring sale",
+					Campaign:   "spring",
+					State:      StateBounced,
+					Error:      "550 5.1.1 unknown user",
+				}},
+				Total: 3,
+			}
+			if !reflect.DeepEqual(page, want) {
+				t.Errorf("got %+v, want %+v", page, want)
+			}
+
+			if !reflect.DeepEqual(count, tt.args) {
+				t.Errorf("counted with %v, want %v", count, tt.args)
+			}
+			// Pages hold DefaultSearchLimit messages by default.
+			if wantArgs := append(tt.args, int64(DefaultSearchLimit), int64(50)); !reflect.DeepEqual(search, wantArgs) {
+				t.Errorf("searched with %v, want %v", search, wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package postman
+
+import (
+	"errors"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// LoginAuth returns an smtp.Auth implementing the LOGIN mechanism, still
+// the only one offered by some servers. Like smtp.PlainAuth, it refuses
+// to send credentials over an unencrypted connection to a remote host.
+func LoginAuth(username, password, host string) smtp.Auth {
+	return &loginAuth{username: username, password: password, host: host}
+}
+
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch prompt := strings.ToLower(string(fromServer)); {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	}
+
+	return nil, errors.New("unexpected server challenge")
+}
+
+// XOAuth2Auth returns an smtp.Auth implementing the XOAUTH2 mechanism of
+// Gmail and Outlook.com, authenticating username with the OAuth 2.0 access
+// tokens returned by token. The function is called on each
+// authentication, refresh being set once the server rejected the previous
+// token, typically because it expired: a new token must then be fetched
+// rather than taken from a cache. A Client using it re-authenticates and
+// retries a message once when the server rejects the session as no longer
+// authenticated.
+func XOAuth2Auth(username string, token func(refresh bool) (string, error)) smtp.Auth {
+	return &xoauth2Auth{username: username, token: token}
+}
+
+type xoauth2Auth struct {
+	username string
+	token    func(refresh bool) (string, error)
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	a.mu.Lock()
+	refresh := a.expired
+	a.expired = false
+	a.mu.Unlock()
+
+	token, err := a.token(refresh)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	// A challenge is an error, given as JSON, to which the client sends
+	// an empty response before the server fails the authentication.
+	return []byte{}, nil
+}
+
+// expire forces the next authentication to refresh the token.
+func (a *xoauth2Auth) expire() {
+	a.mu.Lock()
+	a.expired = true
+	a.mu.Unlock()
+}
+
+// authExpired reports whether err is a reply of the server refusing the
+// credentials of the session, such as an expired token.
+func authExpired(err error) bool {
+	e, ok := err.(*textproto.Error)
+	return ok && (e.Code == 454 || e.Code == 535)
+}
+
+// expireAuth marks the tokens of the authentication of the client as
+// expired, and reports whether it has any.
+func (c *Client) expireAuth() bool {
+	a, ok := c.Auth.(interface{ expire() })
+	if ok {
+		a.expire()
+	}
+	return ok
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,83 @@
+package postman
+
+import (
+	"strings"
+)
+
+// autoReplySubjects are the subject prefixes of the automatic replies of
+// common mail clients and servers, lower cased.
+var autoReplySubjects = []string{
+	"auto:",
+	"autoreply:",
+	"auto reply:",
+	"auto-reply:",
+	"auto response:",
+	"automatic reply:",
+	"out of office:",
+	"out of the office:",
+	"out of office reply:",
+	"out of office autoreply:",
+	"autosvar:",
+	"automatisk svar:",
+	"abwesend:",
+	"abwesenheitsnotiz:",
+	"automatische antwort:",
+	"réponse automatique:",
+	"absence:",
+	"respuesta automática:",
+	"risposta automatica:",
+	"automatisch antwoord:",
+	"afwezig:",
+	"resposta automática:",
+}
+
+// autoReplyFields are the header fields only set by automatic replies.
+var autoReplyFields = []string{
+	"X-Autoreply",
+	"X-Autorespond",
+	"X-Autoreply-From",
+	"X-Mail-Autoreply",
+}
+
+// IsAutoReply reports whether m, as returned by ParseMail, looks like an
+// automatic reply, such as the response of a vacation responder, which
+// reply processing should not act upon: it is marked as automatic by
+// its Auto-Submitted field (RFC 3834 section 5), one of the fields
+// mail clients and servers set on automatic replies, or a Precedence of
+// bulk, junk or auto_reply, or its subject starts as the automatic
+// replies of common mail clients do.
+func (m *Mail) IsAutoReply() bool {
+	h := m.Header
+
+	if v := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); v != "" && !strings.HasPrefix(v, "no") {
+		return true
+	}
+
+	for _, name := range autoReplyFields {
+		if _, ok := h[name]; ok {
+			return true
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(h.Get("Precedence"))) {
+	case "bulk", "junk", "auto_reply":
+		return true
+	}
+
+	// Set by Exchange on its out of office replies.
+	for _, v := range strings.Split(h.Get("X-Auto-Response-Suppress"), ",") {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "all", "oof", "autoreply":
+			return true
+		}
+	}
+
+	subject := strings.ToLower(strings.TrimSpace(m.Subject))
+	for _, prefix := range autoReplySubjects {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package postman
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsAutoReply(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"reply", "Subject: Re: Hello\r\n", false},
+		{"auto-replied", "Auto-Submitted: auto-replied\r\nSubject: Re: Hello\r\n", true},
+		{"auto-generated", "Auto-Submitted: Auto-Generated (vacation)\r\n", true},
+		{"not automatic", "Auto-Submitted: no\r\nSubject: Re: Hello\r\n", false},
+		{"vendor field", "X-Autoreply: yes\r\n", true},
+		{"autoresponder", "X-Autorespond: Out of office\r\n", true},
+		{"bulk", "Precedence: bulk\r\n", true},
+		{"list", "Precedence: list\r\n", false},
+		{"exchange", "X-Auto-Response-Suppress: DR, OOF\r\n", true},
+		{"exchange delivery reports", "X-Auto-Response-Suppress: DR\r\n", false},
+		{"subject", "Subject: Out of Office: Hello\r\n", true},
+		{"localized subject", "Subject: =?utf-8?q?R=C3=A9ponse_automatique=3A?= Hello\r\n", true},
+		{"subject mentioning it", "Subject: Re: Auto reply settings\r\n", false},
+	}
+
+	for _, tt := range tests {
+		raw := "From: rcpt@example.com\r\nTo: sender@example.com\r\n" + tt.header + "\r\nI am away.\r\n"
+		m, err := ParseMail(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.IsAutoReply(); got != tt.want {
+			t.Errorf("%s: IsAutoReply = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
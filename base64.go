@@ -0,0 +1,91 @@
+package postman
+
+import (
+	"bufio"
+	"encoding/base64"
+	"sync"
+)
+
+const (
+	// Encoded lines are limited to 76 characters (RFC 2045 section
+	// 6.8), which is exactly 57 bytes of input.
+	base64LineLength = 76
+	base64ChunkSize  = base64LineLength / 4 * 3
+)
+
+// base64Writer encodes its input in 57-byte chunks straight into 76
+// character CRLF terminated lines, without encoding the whole content
+// first and splitting it afterwards.
+type base64Writer struct {
+	w   *bufio.Writer
+	in  [base64ChunkSize]byte
+	n   int
+	out [base64LineLength + 2]byte
+}
+
+var base64Pool = sync.Pool{
+	New: func() interface{} {
+		return new(base64Writer)
+	},
+}
+
+func getBase64Writer(w *bufio.Writer) *base64Writer {
+	bw := base64Pool.Get().(*base64Writer)
+	bw.w = w
+	bw.n = 0
+	return bw
+}
+
+func putBase64Writer(bw *base64Writer) {
+	bw.w = nil
+	base64Pool.Put(bw)
+}
+
+func (bw *base64Writer) Write(p []byte) (int, error) {
+	written := len(p)
+
+	if bw.n > 0 {
+		k := copy(bw.in[bw.n:], p)
+		bw.n += k
+		p = p[k:]
+		if bw.n < base64ChunkSize {
+			return written, nil
+		}
+		bw.n = 0
+		if err := bw.line(bw.in[:]); err != nil {
+			return 0, err
+		}
+	}
+
+	for len(p) >= base64ChunkSize {
+		if err := bw.line(p[:base64ChunkSize]); err != nil {
+			return 0, err
+		}
+		p = p[base64ChunkSize:]
+	}
+
+	bw.n = copy(bw.in[:], p)
+
+	return written, nil
+}
+
+// Close encodes the remaining buffered input, if any. It does not close
+// the underlying writer.
+func (bw *base64Writer) Close() error {
+	if bw.n == 0 {
+		return nil
+	}
+
+	n := bw.n
+	bw.n = 0
+	return bw.line(bw.in[:n])
+}
+
+func (bw *base64Writer) line(p []byte) error {
+	n := base64.StdEncoding.EncodedLen(len(p))
+	base64.StdEncoding.Encode(bw.out[:n], p)
+	bw.out[n] = '\r'
+	bw.out[n+1] = '\n'
+	_, err := bw.w.Write(bw.out[:n+2])
+	return err
+}
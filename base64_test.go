@@ -0,0 +1,95 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// naiveBase64 encodes p whole, then splits the encoding into lines.
+func naiveBase64(w *bufio.Writer, p []byte) {
+	enc := base64.StdEncoding.EncodeToString(p)
+	for len(enc) > base64LineLength {
+		w.WriteString(enc[:base64LineLength])
+		w.WriteString("\r\n")
+		enc = enc[base64LineLength:]
+	}
+	if enc != "" {
+		w.WriteString(enc)
+		w.WriteString("\r\n")
+	}
+}
+
+func TestBase64Writer(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 1, 56, 57, 58, 114, 1000, 4096} {
+		content := make([]byte, size)
+		r.Read(content)
+
+		var want bytes.Buffer
+		ww := bufio.NewWriter(&want)
+		naiveBase64(ww, content)
+		ww.Flush()
+
+		// Write the content in chunks of every size, to cover the
+		// buffering of partial lines.
+		for _, chunk := range []int{1, 3, 56, 57, 100, size + 1} {
+			var got bytes.Buffer
+			gw := bufio.NewWriter(&got)
+			bw := getBase64Writer(gw)
+			for p := content; len(p) > 0; {
+				n := chunk
+				if n > len(p) {
+					n = len(p)
+				}
+				if k, err := bw.Write(p[:n]); err != nil || k != n {
+					t.Fatalf("Write = %d, %v, want %d", k, err, n)
+				}
+				p = p[n:]
+			}
+			if err := bw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			putBase64Writer(bw)
+			gw.Flush()
+
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("size %d, chunks of %d: got %q, want %q", size, chunk, got.Bytes(), want.Bytes())
+			}
+		}
+	}
+}
+
+func benchmarkBase64(b *testing.B, encode func(w *bufio.Writer, p []byte)) {
+	content := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	w := bufio.NewWriter(ioutil.Discard)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encode(w, content)
+	}
+}
+
+func BenchmarkBase64Writer(b *testing.B) {
+	benchmarkBase64(b, func(w *bufio.Writer, p []byte) {
+		bw := getBase64Writer(w)
+		// Attachments are copied from their reader in 32 KiB reads.
+		for len(p) > 32<<10 {
+			bw.Write(p[:32<<10])
+			p = p[32<<10:]
+		}
+		bw.Write(p)
+		bw.Close()
+		putBase64Writer(bw)
+	})
+}
+
+func BenchmarkBase64Naive(b *testing.B) {
+	benchmarkBase64(b, naiveBase64)
+}
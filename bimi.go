@@ -0,0 +1,238 @@
+package postman
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultBIMISelector is the selector of the BIMI record of the messages
+// without a BIMI-Selector field.
+const DefaultBIMISelector = "default"
+
+// BIMISelector returns the value of the BIMI-Selector field pointing
+// mailbox providers at the BIMI record published under selector, for the
+// messages of a domain to display different logos. The field must be
+// signed with DKIM, as it is by default.
+func BIMISelector(selector string) string {
+	return "v=BIMI1; s=" + selector
+}
+
+// DMARCRecord is the DMARC policy of a domain (RFC 7489 section 6.3).
+type DMARCRecord struct {
+	// Domain is the domain publishing the record: the one it was looked
+	// up for, or the parent domain it is inherited from.
+	Domain string
+
+	// Policy is the p= tag, "none", "quarantine" or "reject", and
+	// SubdomainPolicy the sp= tag, applying to the subdomains of Domain
+	// and defaulting to Policy.
+	Policy          string
+	SubdomainPolicy string
+
+	// Percent is the share of the messages the policy applies to, the
+	// pct= tag.
+	Percent int
+
+	// StrictDKIM is set when the DKIM signatures must be made by the
+	// author domain itself rather than by a domain of the same
+	// organization, the adkim=s tag.
+	StrictDKIM bool
+}
+
+// LookupDMARC returns the DMARC record applying to domain: its own, or
+// the one of its closest parent domain publishing one, which stands for
+// its organizational domain. It returns nil if there is none.
+func LookupDMARC(domain string) (*DMARCRecord, error) {
+	for d := domain; d != ""; d = parentDomain(d) {
+		tags, err := lookupTags("_dmarc."+d, "DMARC1")
+		if err != nil {
+			return nil, fmt.Errorf("dmarc: %v", err)
+		}
+		if tags == nil {
+			continue
+		}
+
+		r := &DMARCRecord{
+			Domain:          d,
+			Policy:          strings.ToLower(tags["p"]),
+			SubdomainPolicy: strings.ToLower(tags["sp"]),
+			Percent:         100,
+			StrictDKIM:      strings.ToLower(tags["adkim"]) == "s",
+		}
+
+		if r.SubdomainPolicy == "" {
+			r.SubdomainPolicy = r.Policy
+		}
+
+		if pct, ok := tags["pct"]; ok {
+			if r.Percent, err = strconv.Atoi(pct); err != nil {
+				return nil, fmt.Errorf("dmarc: invalid pct= tag %q", pct)
+			}
+		}
+
+		return r, nil
+	}
+
+	return nil, nil
+}
+
+// policy returns the policy of r for the messages of domain.
+func (r *DMARCRecord) policy(domain string) string {
+	if strings.EqualFold(domain, r.Domain) {
+		return r.Policy
+	}
+	return r.SubdomainPolicy
+}
+
+// BIMIRecord is the BIMI assertion record of a domain, published under
+// a selector of its _bimi zone.
+type BIMIRecord struct {
+	Domain string
+
+	// Location is the HTTPS URL of the SVG logo, the l= tag. Empty means
+	// the domain declines to display one.
+	Location string
+
+	// Authority is the HTTPS URL of the Verified Mark Certificate of the
+	// logo, the a= tag.
+	Authority string
+}
+
+// LookupBIMI returns the BIMI record of domain under selector, or the
+// one of its closest parent domain publishing one. It returns nil if
+// there is none.
+func LookupBIMI(selector, domain string) (*BIMIRecord, error) {
+	for d := domain; d != ""; d = parentDomain(d) {
+		tags, err := lookupTags(selector+"._bimi."+d, "BIMI1")
+		if err != nil {
+			return nil, fmt.Errorf("bimi: %v", err)
+		}
+		if tags != nil {
+			return &BIMIRecord{Domain: d, Location: tags["l"], Authority: tags["a"]}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckBIMI reports why mailbox providers would not display the BIMI
+// logo of the author domain of the messages from the given address,
+// signed with DKIM by signingDomains: a DMARC policy at enforcement, a
+// DKIM signature aligned with the author domain and a BIMI record under
+// selector, or DefaultBIMISelector if empty, are required. It returns no
+// issue when the prerequisites are met.
+func CheckBIMI(from, selector string, signingDomains []string) []Issue {
+	var l linter
+
+	domain, ok := addressDomain(from)
+	if !ok {
+		l.errorf("From", "invalid address %q", from)
+		return l
+	}
+
+	if selector == "" {
+		selector = DefaultBIMISelector
+	}
+
+	dmarc, err := LookupDMARC(domain)
+	switch {
+	case err != nil:
+		l.errorf("DMARC", "%v", err)
+	case dmarc == nil:
+		l.errorf("DMARC", "no policy published for %s", domain)
+	default:
+		if p := dmarc.policy(domain); p != "quarantine" && p != "reject" {
+			l.errorf("DMARC", "policy %q of %s is not at enforcement, quarantine or reject is required", p, domain)
+		}
+		if dmarc.Percent < 100 {
+			l.errorf("DMARC", "policy of %s applies to %d%% of the messages, not all of them", domain, dmarc.Percent)
+		}
+	}
+
+	aligned := false
+	for _, d := range signingDomains {
+		if dmarc != nil && dmarc.StrictDKIM {
+			aligned = aligned || strings.EqualFold(d, domain)
+		} else {
+			aligned = aligned || inDomain(domain, d)
+		}
+	}
+	if !aligned {
+		l.errorf("DKIM-Signature", "no signature aligned with %s", domain)
+	}
+
+	bimi, err := LookupBIMI(selector, domain)
+	switch {
+	case err != nil:
+		l.errorf("BIMI", "%v", err)
+	case bimi == nil:
+		l.errorf("BIMI", "no record published for %s under selector %q", domain, selector)
+	case bimi.Location == "":
+		l.errorf("BIMI", "the record of %s declines to display a logo", bimi.Domain)
+	case !strings.HasPrefix(strings.ToLower(bimi.Location), "https://"):
+		l.errorf("BIMI", "logo %s is not served over HTTPS", bimi.Location)
+	case bimi.Authority == "":
+		l.warnf("BIMI", "no mark certificate for %s, which most mailbox providers require", bimi.Domain)
+	}
+
+	return l
+}
+
+// LintBIMI checks the BIMI prerequisites of m, as CheckBIMI does, for the
+// identity and the DKIM keys the client would send it with.
+func (c *Client) LintBIMI(m *Mail) []Issue {
+	mm := *m
+	if id := c.identity(&mm); id != nil {
+		id.apply(&mm)
+	}
+
+	var domains []string
+	for _, s := range c.signers(&mm) {
+		domains = append(domains, s.Domain)
+	}
+
+	return CheckBIMI(mm.From, bimiSelector(mm.Header.Get("BIMI-Selector")), domains)
+}
+
+// bimiSelector returns the selector of a BIMI-Selector field value.
+func bimiSelector(v string) string {
+	tags, err := parseTags(v)
+	if err != nil {
+		return ""
+	}
+	return tags["s"]
+}
+
+// lookupTags returns the tags of the TXT record of name starting with the
+// given version tag, or nil if there is none.
+func lookupTags(name, version string) (map[string]string, error) {
+	txts, err := lookupTXT(name)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, txt := range txts {
+		tags, err := parseTags(txt)
+		if err == nil && strings.EqualFold(tags["v"], version) {
+			return tags, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// parentDomain returns the parent of domain, or an empty string when it
+// is a top level domain or the child of one, which cannot stand for an
+// organization.
+func parentDomain(domain string) string {
+	i := strings.IndexByte(domain, '.')
+	if i < 0 || strings.IndexByte(domain[i+1:], '.') < 0 {
+		return ""
+	}
+	return domain[i+1:]
+}
@@ -0,0 +1,149 @@
+package postman
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTXT makes the TXT lookups return records, by name, for the rest
+// of a test.
+func withTXT(records map[string][]string) func() {
+	lookup := lookupTXT
+	lookupTXT = func(name string) ([]string, error) {
+		if txts, ok := records[name]; ok {
+			return txts, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return func() { lookupTXT = lookup }
+}
+
+func TestBIMISelectorField(t *testing.T) {
+	s := rfc8463Key(t)
+	s.Domain = "customer.example"
+
+	c := NewClient("localhost:25")
+	c.Identities = []*Identity{{Domain: "customer.example", BIMISelector: "brand", DKIM: []*DKIMSigner{s}}}
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		// Field names are case insensitive; textproto capitalizes them.
+		{"identity", "", "Bimi-Selector: v=BIMI1; s=brand\r\n"},
+		{"explicit", BIMISelector("spring"), "Bimi-Selector: v=BIMI1; s=spring\r\n"},
+	}
+
+	for _, tt := range tests {
+		m := &Mail{
+			Date:      time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC),
+			MessageID: "<m1@postman.test>",
+			From:      "news@customer.example",
+			To:        []string{"rcpt@example.com"},
+			Subject:   "Hello",
+			Parts:     []Part{{ContentType: "text/plain", Content: []byte("Hello")}},
+		}
+		m.Header = map[string][]string{"X-Entity-Ref-Id": {"ref"}}
+		if tt.header != "" {
+			m.Header["Bimi-Selector"] = []string{tt.header}
+		}
+		header := map[string][]string{}
+		for k, v := range m.Header {
+			header[k] = v
+		}
+
+		prepared, err := c.Prepare(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := prepared.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(raw), "Bimi-Selector: "); n != 1 || !strings.Contains(string(raw), tt.want) {
+			t.Errorf("%s: %d BIMI-Selector fields in\n%s\nwant %q", tt.name, n, raw, tt.want)
+		}
+		if !reflect.DeepEqual(map[string][]string(m.Header), header) {
+			t.Errorf("%s: header of the message changed to %v", tt.name, m.Header)
+		}
+
+		// The field is signed.
+		signed := signDKIM(t, s, string(raw))
+		if !strings.Contains(signed[:strings.Index(signed, "\r\nDate: ")], ":BIMI-Selector") {
+			t.Errorf("%s: BIMI-Selector not signed:\n%s", tt.name, signed)
+		}
+	}
+}
+
+func TestCheckBIMI(t *testing.T) {
+	const (
+		enforced = "v=DMARC1; p=quarantine"
+		logo     = "v=BIMI1; l=https://customer.example/logo.svg; a=https://customer.example/vmc.pem"
+	)
+
+	tests := []struct {
+		name    string
+		records map[string][]string
+		signers []string
+		issues  []string
+	}{
+		{
+			"prerequisites met",
+			map[string][]string{"_dmarc.customer.example": {enforced}, "default._bimi.customer.example": {logo}},
+			[]string{"customer.example"},
+			nil,
+		},
+		{
+			// Records are inherited from the organizational domain.
+			"parent records",
+			map[string][]string{"_dmarc.customer.example": {"v=DMARC1; p=none; sp=reject"}, "default._bimi.customer.example": {logo}},
+			[]string{"customer.example"},
+			nil,
+		},
+		{
+			"no records",
+			nil,
+			nil,
+			[]string{
+				"error: DMARC: no policy published for news.customer.example",
+				"error: DKIM-Signature: no signature aligned with news.customer.example",
+				`error: BIMI: no record published for news.customer.example under selector "default"`,
+			},
+		},
+		{
+			"relaxed policy",
+			map[string][]string{"_dmarc.customer.example": {"v=DMARC1; p=reject; sp=none; pct=50; adkim=s"}, "default._bimi.customer.example": {"v=BIMI1; l=http://customer.example/logo.svg"}},
+			[]string{"customer.example"},
+			[]string{
+				`error: DMARC: policy "none" of news.customer.example is not at enforcement, quarantine or reject is required`,
+				"error: DMARC: policy of news.customer.example applies to 50% of the messages, not all of them",
+				"error: DKIM-Signature: no signature aligned with news.customer.example",
+				"error: BIMI: logo http://customer.example/logo.svg is not served over HTTPS",
+			},
+		},
+		{
+			"no certificate",
+			map[string][]string{"_dmarc.customer.example": {enforced}, "default._bimi.customer.example": {"v=BIMI1; l=https://customer.example/logo.svg"}},
+			[]string{"example.net", "customer.example"},
+			[]string{"warning: BIMI: no mark certificate for customer.example, which most mailbox providers require"},
+		},
+	}
+
+	for _, tt := range tests {
+		restore := withTXT(tt.records)
+		var issues []string
+		for _, i := range CheckBIMI("News <news@news.customer.example>", "", tt.signers) {
+			issues = append(issues, i.String())
+		}
+		restore()
+
+		if !reflect.DeepEqual(issues, tt.issues) {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(issues, "\n"), strings.Join(tt.issues, "\n"))
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// mimeHeader appends the top level MIME fields of the message to fields
+// and returns the boundary to use for the body, if it is multipart.
+func (m *Mail) mimeHeader(fields []headerField) ([]headerField, string, error) {
+	if m.Body != nil {
+		fields = append(fields, headerField{name: "MIME-Version", value: "1.0"})
+		return m.Body.fields(fields)
+	}
+
+	if len(m.Parts) == 0 && len(m.Attachments) == 0 && len(m.Digest) == 0 {
+		return fields, "", nil
+	}
+
+	fields = append(fields, headerField{name: "MIME-Version", value: "1.0"})
+
+	if len(m.Attachments) == 0 && len(m.Digest) == 0 && len(m.Parts) == 1 {
+		pfields, err := partHeader(&m.Parts[0])
+		if err != nil {
+			return nil, "", err
+		}
+		return append(fields, pfields...), "", nil
+	}
+
+	boundary, err := genBoundary()
+	if err != nil {
+		return nil, "", err
+	}
+
+	subtype := "mixed"
+	switch {
+	case len(m.Attachments) == 0 && len(m.Digest) == 0:
+		subtype = "alternative"
+	case len(m.Attachments) == 0 && len(m.Parts) == 0:
+		subtype = "digest"
+	}
+
+	fields = append(fields, headerField{
+		name:  "Content-Type",
+		value: multipartType(subtype, boundary),
+	})
+
+	return fields, boundary, nil
+}
+
+// writeBody writes the body of the message. Parts are alternative
+// representations of the same content; the digest and attachments are
+// mixed with them.
+func (m *Mail) writeBody(w *bufio.Writer, boundary string) error {
+	if m.Body != nil {
+		return m.Body.writeContent(w, boundary)
+	}
+
+	if boundary == "" {
+		if len(m.Parts) == 1 {
+			return writePartContent(w, &m.Parts[0])
+		}
+		return nil
+	}
+
+	if len(m.Attachments) == 0 && len(m.Digest) == 0 {
+		return writeAlternative(w, boundary, m.Parts)
+	}
+
+	if len(m.Attachments) == 0 && len(m.Parts) == 0 {
+		return writeDigest(w, boundary, m.Digest)
+	}
+
+	hw := headerWriter{w: w}
+
+	switch len(m.Parts) {
+	case 0:
+	case 1:
+		writeBoundary(w, boundary)
+		if err := writePartHeader(&hw, &m.Parts[0]); err != nil {
+			return err
+		}
+		w.WriteString("\r\n")
+		if err := writePartContent(w, &m.Parts[0]); err != nil {
+			return err
+		}
+	default:
+		inner, err := genBoundary()
+		if err != nil {
+			return err
+		}
+
+		writeBoundary(w, boundary)
+		hw.field("Content-Type", multipartType("alternative", inner))
+		w.WriteString("\r\n")
+		if err := writeAlternative(w, inner, m.Parts); err != nil {
+			return err
+		}
+	}
+
+	if len(m.Digest) > 0 {
+		inner, err := genBoundary()
+		if err != nil {
+			return err
+		}
+
+		writeBoundary(w, boundary)
+		hw.field("Content-Type", multipartType("digest", inner))
+		w.WriteString("\r\n")
+		if err := writeDigest(w, inner, m.Digest); err != nil {
+			return err
+		}
+	}
+
+	for i := range m.Attachments {
+		writeBoundary(w, boundary)
+		if err := writeAttachment(w, &m.Attachments[i]); err != nil {
+			return err
+		}
+	}
+
+	writeCloseBoundary(w, boundary)
+
+	return nil
+}
+
+func writeAlternative(w *bufio.Writer, boundary string, parts []Part) error {
+	hw := headerWriter{w: w}
+
+	for i := range parts {
+		writeBoundary(w, boundary)
+		if err := writePartHeader(&hw, &parts[i]); err != nil {
+			return err
+		}
+		w.WriteString("\r\n")
+		if err := writePartContent(w, &parts[i]); err != nil {
+			return err
+		}
+	}
+
+	writeCloseBoundary(w, boundary)
+
+	return nil
+}
+
+// writeLines writes the content of a part, ending its lines with CRLF
+// whether they end with CRLF, a bare LF, as in Go string literals, or a
+// bare CR: SMTP servers reject or mangle bare line endings.
+func writeLines(w io.Writer, content []byte) {
+	for len(content) > 0 {
+		i := bytes.IndexAny(content, "\r\n")
+		if i < 0 {
+			w.Write(content)
+			return
+		}
+
+		w.Write(content[:i])
+		w.Write(crlf)
+
+		if content[i] == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			i++
+		}
+		content = content[i+1:]
+	}
+}
+
+var crlf = []byte("\r\n")
+
+// maxLineOctets is the limit of the length of lines, excluding the CRLF
+// (RFC 5321 section 4.5.3.1.6).
+const maxLineOctets = 998
+
+// textEncoding returns the Content-Transfer-Encoding of text content:
+// none when its lines are 7bit and short enough to be sent as they are,
+// quoted-printable when few of its octets need to be escaped, as in Latin
+// text, and base64 otherwise, as in text in other scripts. 8bit content
+// is not sent as it is since relays to servers without 8BITMIME would
+// have to convert it.
+func textEncoding(content []byte) string {
+	var escaped, line int
+	long := false
+	for _, c := range content {
+		switch {
+		case c == '\r' || c == '\n':
+			line = 0
+			continue
+		case c >= 0x80 || c == 0 || c == '=':
+			escaped++
+		}
+		if line++; line > maxLineOctets {
+			long = true
+		}
+	}
+
+	switch {
+	case escaped == 0 && !long:
+		return ""
+	case escaped == 0 || bytes.IndexByte(content, 0) < 0 && escaped*6 < len(content):
+		// Each escaped octet takes 3: quoted-printable is shorter
+		// than base64 while less than a sixth of them are.
+		return "quoted-printable"
+	}
+	return "base64"
+}
+
+// writeText writes text content with the encoding chosen by textEncoding.
+// Its lines end with CRLF before being encoded.
+func writeText(w *bufio.Writer, content []byte) error {
+	switch textEncoding(content) {
+	case "quoted-printable":
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write(content); err != nil {
+			return err
+		}
+		return qw.Close()
+
+	case "base64":
+		bw := getBase64Writer(w)
+		defer putBase64Writer(bw)
+
+		writeLines(bw, content)
+		return bw.Close()
+	}
+
+	writeLines(w, content)
+	return nil
+}
+
+// writePartContent writes the content of a part: text as by writeText,
+// and anything else, such as the images the parser keeps as parts, as an
+// entity of its media type rather than as lines.
+func writePartContent(w *bufio.Writer, p *Part) error {
+	return p.Entity().writeContent(w, "")
+}
+
+func writePartHeader(hw *headerWriter, p *Part) error {
+	fields, err := partHeader(p)
+	if err != nil {
+		return err
+	}
+
+	for i := range fields {
+		hw.write(&fields[i])
+	}
+
+	return hw.err
+}
+
+// MediaType returns the media type of the part and its parameters,
+// merging those given in ContentType and in Params.
+func (p *Part) MediaType() (string, map[string]string, error) {
+	return mediaType(p.ContentType, p.Params)
+}
+
+// mediaType merges the parameters given after a media type with params,
+// which take precedence. The map returned is always a new one.
+func mediaType(ctype string, params map[string]string) (string, map[string]string, error) {
+	mediatype := strings.ToLower(strings.TrimSpace(ctype))
+	merged := make(map[string]string, len(params))
+
+	if strings.IndexByte(ctype, ';') >= 0 {
+		var err error
+		if mediatype, merged, err = mime.ParseMediaType(ctype); err != nil {
+			return "", nil, fmt.Errorf("postman: invalid content type %q: %v", ctype, err)
+		}
+	}
+
+	for k, v := range params {
+		merged[strings.ToLower(k)] = v
+	}
+
+	return mediatype, merged, nil
+}
+
+// defaultCharset declares the charset of text content which is not ASCII
+// and has none, as UTF-8 if it is valid UTF-8.
+func defaultCharset(mediatype string, params map[string]string, content []byte) {
+	if strings.HasPrefix(mediatype, "text/") && params["charset"] == "" && !is7bit(content) && utf8.Valid(content) {
+		params["charset"] = "utf-8"
+	}
+}
+
+func partHeader(p *Part) ([]headerField, error) {
+	mediatype, params, err := p.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	defaultCharset(mediatype, params, p.Content)
+
+	ctype := mime.FormatMediaType(mediatype, params)
+	if ctype == "" {
+		return nil, fmt.Errorf("postman: invalid content type %q", mediatype)
+	}
+
+	fields := []headerField{{name: "Content-Type", value: ctype}}
+
+	if cte := p.Entity().transferEncoding(); cte != "" {
+		fields = append(fields, headerField{
+			name:  "Content-Transfer-Encoding",
+			value: cte,
+		})
+	}
+
+	return fields, nil
+}
+
+func writeAttachment(w *bufio.Writer, a *Attachment) error {
+	if a.Content == nil && a.File == "" && a.URL != "" {
+		return fmt.Errorf("postman: attachment %s not fetched", a.URL)
+	}
+
+	var content io.Reader = bytes.NewReader(a.Content)
+	if a.Content == nil && a.File != "" {
+		f, err := os.Open(a.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		content = f
+	}
+
+	hw := headerWriter{w: w}
+
+	ctype := mime.TypeByExtension(filepath.Ext(a.Filename))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	disposition := a.ContentDisposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	encoding := a.ContentTransfertEncoding
+	if encoding == "" {
+		encoding = "base64"
+	}
+
+	if a.Filename != "" {
+		hw.field("Content-Type", formatMediaType(ctype, "name", a.Filename))
+		hw.field("Content-Disposition", formatMediaType(disposition, "filename", a.Filename))
+	} else {
+		hw.field("Content-Type", ctype)
+		hw.field("Content-Disposition", disposition)
+	}
+
+	if a.ContentID != "" {
+		hw.field("Content-ID", "<"+a.ContentID+">")
+	}
+
+	hw.field("Content-Transfer-Encoding", encoding)
+	if hw.err != nil {
+		return hw.err
+	}
+	w.WriteString("\r\n")
+
+	// Any other encoding means the content is already encoded by the
+	// caller.
+	if encoding != "base64" {
+		_, err := io.Copy(w, content)
+		return err
+	}
+
+	bw := getBase64Writer(w)
+	defer putBase64Writer(bw)
+
+	if _, err := io.Copy(bw, content); err != nil {
+		return err
+	}
+
+	return bw.Close()
+}
+
+func writeBoundary(w *bufio.Writer, boundary string) {
+	w.WriteString("\r\n--")
+	w.WriteString(boundary)
+	w.WriteString("\r\n")
+}
+
+func writeCloseBoundary(w *bufio.Writer, boundary string) {
+	w.WriteString("\r\n--")
+	w.WriteString(boundary)
+	w.WriteString("--\r\n")
+}
+
+func multipartType(subtype, boundary string) string {
+	return formatMediaType("multipart/"+subtype, "boundary", boundary)
+}
+
+func formatMediaType(t, param, value string) string {
+	s := mime.FormatMediaType(t, map[string]string{param: value})
+	if s == "" {
+		// FormatMediaType rejects invalid types; keep the caller's value
+		// rather than dropping the field.
+		return t
+	}
+	return s
+}
+
+func genBoundary() (string, error) {
+	var b [15]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b[:]), nil
+}
+
+func is7bit(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestTextEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", ""},
+		{"ascii", "Hello,\r\nWorld!\r\n", ""},
+		{"longest line", strings.Repeat("a", 998) + "\r\n", ""},
+		{"long line", strings.Repeat("a", 999) + "\r\n", "quoted-printable"},
+		{"short lines", strings.Repeat(strings.Repeat("a", 900)+"\n", 3), ""},
+		{"latin", "Bonjour, voilà un exemple de texte en français.", "quoted-printable"},
+		{"latin-1", "Caf\xe9 cr\xe8me, s'il vous pla\xeet.", "quoted-printable"},
+		{"equal signs", "a = b\r\n", "quoted-printable"},
+		{"cjk", "こんにちは、世界", "base64"},
+		{"NUL", "Hello\x00 World, with more text than escapes", "base64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := textEncoding([]byte(tt.content)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartHeaderCharset(t *testing.T) {
+	tests := []struct {
+		name  string
+		part  Part
+		ctype string
+		lint  bool // whether Lint warns about the charset
+	}{
+		{"ascii", Part{ContentType: "text/plain", Content: []byte("Hello")}, "text/plain", false},
+		{"utf-8", Part{ContentType: "text/plain", Content: []byte("Voilà")}, "text/plain; charset=utf-8", false},
+		{"declared", Part{ContentType: "text/html; charset=iso-8859-1", Content: []byte("Voil\xe0")}, "text/html; charset=iso-8859-1", false},
+		{"not utf-8", Part{ContentType: "text/plain", Content: []byte("Voil\xe0")}, "text/plain", true},
+		{"not text", Part{ContentType: "application/json", Content: []byte(`"Voilà"`)}, "application/json", false},
+	}
+
+	for _, tt := range tests {
+		fields, err := partHeader(&tt.part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fields[0].value != tt.ctype {
+			t.Errorf("%s: Content-Type: %s, want %s", tt.name, fields[0].value, tt.ctype)
+		}
+
+		warned := false
+		for _, i := range Lint(&Mail{From: "sender@example.com", To: []string{"rcpt@example.com"}, Parts: []Part{tt.part}}) {
+			warned = warned || (i.Field == "Content-Type" && strings.Contains(i.Message, "charset"))
+		}
+		if warned != tt.lint {
+			t.Errorf("%s: charset warning: %t, want %t", tt.name, warned, tt.lint)
+		}
+	}
+}
+
+func TestWriteTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    Part
+		want    string // content in its charset, with CRLF line endings
+		charset string
+	}{
+		{"ascii", Part{ContentType: "text/plain", Content: []byte("Hello\nWorld\n")}, "Hello\r\nWorld\r\n", ""},
+		{
+			"long line", Part{ContentType: "text/plain", Content: []byte(strings.Repeat("a", 2000) + "\n")},
+			strings.Repeat("a", 2000) + "\r\n", "",
+		},
+		{
+			"utf-8", Part{ContentType: "text/plain", Params: map[string]string{"charset": "utf-8"}, Content: []byte("Voilà\nà bientôt\n")},
+			"Voilà\r\nà bientôt\r\n", "utf-8",
+		},
+		// UTF-8 is declared when no charset is.
+		{
+			"utf-8 by default", Part{ContentType: "text/plain", Content: []byte("Voilà\n")},
+			"Voilà\r\n", "utf-8",
+		},
+		{
+			"not utf-8", Part{ContentType: "text/plain", Content: []byte("Voil\xe0\n")},
+			"Voil\xe0\r\n", "",
+		},
+		{
+			"iso-8859-1", Part{ContentType: "text/plain", Charset: "ISO-8859-1", Content: []byte("Voilà\n")},
+			"Voil\xe0\r\n", "ISO-8859-1",
+		},
+		{
+			"gb2312", Part{ContentType: "text/html", Charset: "GB2312", Content: []byte("<p>你好，世界</p>\n")},
+			"<p>\xc4\xe3\xba\xc3\xa3\xac\xca\xc0\xbd\xe7</p>\r\n", "GB2312",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A second part makes the message multipart, to cover both
+			// the top level and the nested parts.
+			for _, parts := range [][]Part{{tt.part}, {tt.part, {ContentType: "text/plain", Content: []byte("alt")}}} {
+				m := &Mail{From: "sender@example.com", Parts: parts}
+				raw, err := m.Bytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, line := range bytes.Split(raw, crlf) {
+					if len(line) > maxLineOctets {
+						t.Fatalf("line of %d octets", len(line))
+					}
+					if !is7bit(line) {
+						t.Fatalf("8bit line %q", line)
+					}
+				}
+
+				parsed, err := ParseMail(bytes.NewReader(raw))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(parsed.Parts) != len(parts) {
+					t.Fatalf("parsed %d parts, want %d", len(parsed.Parts), len(parts))
+				}
+				p := parsed.Parts[0]
+				if got := string(p.Content); got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+				if p.Params["charset"] != tt.charset {
+					t.Errorf("charset %q, want %q", p.Params["charset"], tt.charset)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteBody(b *testing.B) {
+	content := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	m := benchMail(1)
+	m.Attachments = []Attachment{{Filename: "data.bin", Content: content}}
+
+	w := bufio.NewWriter(ioutil.Discard)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := m.writeBody(w, "postman-benchmark-boundary"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestWriteBinaryPart checks that parts which are not text, as the parser
+// keeps inline images without file name, are not written as lines.
+func TestWriteBinaryPart(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	for _, parts := range [][]Part{
+		{{ContentType: "image/png", Content: png}},
+		{{ContentType: "text/html", Content: []byte(`<img src="cid:logo">`)}, {ContentType: "image/png", Content: png}},
+	} {
+		m := &Mail{From: "sender@example.com", Parts: parts}
+		raw, err := m.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(raw, []byte("Content-Type: image/png\r\nContent-Transfer-Encoding: base64\r\n")) {
+			t.Errorf("image not base64 encoded:\n%s", raw)
+		}
+
+		parsed, err := ParseMail(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := parsed.Parts[len(parsed.Parts)-1]; !bytes.Equal(p.Content, png) {
+			t.Errorf("got content %q, want %q", p.Content, png)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package postman
+
+import (
+	"io/ioutil"
+	"net/textproto"
+	"path/filepath"
+	"time"
+)
+
+// Builder composes a message step by step:
+//
+//	m, err := postman.New().
+//		From("Jane Doe <jane@example.com>").
+//		To("john@example.com").
+//		Subject("Monthly report").
+//		Text("The report is attached.").
+//		HTML("<p>The report is attached.</p>").
+//		AttachFile("report.pdf").
+//		Build()
+//
+// The first error met, such as a file which cannot be read, is returned
+// by Build.
+type Builder struct {
+	m   Mail
+	err error
+}
+
+// New returns a builder for a new message.
+func New() *Builder {
+	return new(Builder)
+}
+
+// Date sets the Date field; it defaults to the time the message is
+// sent.
+func (b *Builder) Date(t time.Time) *Builder {
+	b.m.Date = t
+	return b
+}
+
+// From sets the author of the message.
+func (b *Builder) From(addr string) *Builder {
+	b.m.From = addr
+	return b
+}
+
+// Sender sets the mailbox sending the message on behalf of its author.
+func (b *Builder) Sender(addr string) *Builder {
+	b.m.Sender = addr
+	return b
+}
+
+// ReplyTo sets the address replies are sent to.
+func (b *Builder) ReplyTo(addr string) *Builder {
+	b.m.ReplyTo = addr
+	return b
+}
+
+// To adds recipients; as Cc and Bcc, it may be called several times.
+func (b *Builder) To(addrs ...string) *Builder {
+	b.m.To = append(b.m.To, addrs...)
+	return b
+}
+
+// Cc adds carbon copy recipients.
+func (b *Builder) Cc(addrs ...string) *Builder {
+	b.m.Cc = append(b.m.Cc, addrs...)
+	return b
+}
+
+// Bcc adds blind carbon copy recipients, left out of the header.
+func (b *Builder) Bcc(addrs ...string) *Builder {
+	b.m.Bcc = append(b.m.Bcc, addrs...)
+	return b
+}
+
+// Subject sets the subject.
+func (b *Builder) Subject(s string) *Builder {
+	b.m.Subject = s
+	return b
+}
+
+// MessageID sets the Message-ID; one is generated when it is empty.
+func (b *Builder) MessageID(id string) *Builder {
+	b.m.MessageID = id
+	return b
+}
+
+// InReplyTo makes the message a reply to the message with the given
+// Message-ID, adding it to the references.
+func (b *Builder) InReplyTo(id string) *Builder {
+	b.m.InReplyTo = id
+	b.m.References = append(b.m.References, id)
+	return b
+}
+
+// References adds Message-IDs to the References field.
+func (b *Builder) References(ids ...string) *Builder {
+	b.m.References = append(b.m.References, ids...)
+	return b
+}
+
+// Priority sets the Priority field.
+func (b *Builder) Priority(p Priority) *Builder {
+	b.m.Priority = p
+	return b
+}
+
+// Campaign sets the campaign the message belongs to.
+func (b *Builder) Campaign(c string) *Builder {
+	b.m.Campaign = c
+	return b
+}
+
+// Header adds a field to the header.
+func (b *Builder) Header(key, value string) *Builder {
+	if b.m.Header == nil {
+		b.m.Header = make(textproto.MIMEHeader)
+	}
+	b.m.Header.Add(key, value)
+	return b
+}
+
+// Envelope sets the reverse path and the recipients of the message,
+// overriding the ones derived from its header. The other options of the
+// envelope, such as DeliverBy, are kept.
+func (b *Builder) Envelope(mailFrom string, rcptTo ...string) *Builder {
+	b.m.Envelope.MailFrom = mailFrom
+	b.m.Envelope.RcptTo = rcptTo
+	return b
+}
+
+// DeliverBy sets the delivery deadline of the message, notifying the
+// sender of delays if notify is set.
+func (b *Builder) DeliverBy(d time.Duration, notify bool) *Builder {
+	b.m.Envelope.DeliverBy = d
+	b.m.Envelope.DeliverByNotify = notify
+	return b
+}
+
+// RequireTLS makes the delivery of the message fail rather than go over
+// an unencrypted connection.
+func (b *Builder) RequireTLS() *Builder {
+	b.m.Envelope.RequireTLS = true
+	return b
+}
+
+// Text adds a text/plain part.
+func (b *Builder) Text(s string) *Builder {
+	return b.Part(Part{
+		ContentType: "text/plain",
+		Params:      map[string]string{"charset": "utf-8"},
+		Content:     []byte(s),
+	})
+}
+
+// HTML adds a text/html part.
+func (b *Builder) HTML(s string) *Builder {
+	return b.Part(Part{
+		ContentType: "text/html",
+		Params:      map[string]string{"charset": "utf-8"},
+		Content:     []byte(s),
+	})
+}
+
+// Part adds an alternative representation of the content.
+func (b *Builder) Part(p Part) *Builder {
+	b.m.Parts = append(b.m.Parts, p)
+	return b
+}
+
+// Template sets the subject and adds the parts produced by t with data.
+func (b *Builder) Template(t *Template, data interface{}) *Builder {
+	if b.err == nil {
+		b.err = t.Execute(&b.m, data)
+	}
+	return b
+}
+
+// Attach adds an attachment.
+func (b *Builder) Attach(filename string, content []byte) *Builder {
+	b.m.Attachments = append(b.m.Attachments, Attachment{
+		Filename: filename,
+		Content:  content,
+	})
+	return b
+}
+
+// AttachFile adds the file at path as an attachment.
+func (b *Builder) AttachFile(path string) *Builder {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	return b.Attach(filepath.Base(path), content)
+}
+
+// Inline adds an inline attachment, referred to by the HTML part as
+// "cid:" followed by contentID.
+func (b *Builder) Inline(filename, contentID string, content []byte) *Builder {
+	b.m.Attachments = append(b.m.Attachments, Attachment{
+		Filename:           filename,
+		ContentDisposition: "inline",
+		ContentID:          contentID,
+		Content:            content,
+	})
+	return b
+}
+
+// Body sets the MIME tree of the body, replacing parts and attachments.
+func (b *Builder) Body(e *Entity) *Builder {
+	b.m.Body = e
+	return b
+}
+
+// Build returns the message, or a *LintError if Lint finds errors in it.
+// The builder may be reused to build variants of the message.
+func (b *Builder) Build() (*Mail, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	m := b.m
+	m.To = append([]string(nil), b.m.To...)
+	m.Cc = append([]string(nil), b.m.Cc...)
+	m.Bcc = append([]string(nil), b.m.Bcc...)
+	m.References = append([]string(nil), b.m.References...)
+	m.Envelope.RcptTo = append([]string(nil), b.m.Envelope.RcptTo...)
+	m.Parts = append([]Part(nil), b.m.Parts...)
+	m.Attachments = append([]Attachment(nil), b.m.Attachments...)
+
+	if b.m.Header != nil {
+		m.Header = make(textproto.MIMEHeader, len(b.m.Header))
+		for k, v := range b.m.Header {
+			m.Header[k] = append([]string(nil), v...)
+		}
+	}
+
+	if issues := Lint(&m); HasErrors(issues) {
+		return nil, &LintError{Issues: issues}
+	}
+
+	return &m, nil
+}
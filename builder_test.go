@@ -0,0 +1,140 @@
+package postman
+
+import (
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	date := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	m, err := New().
+		Date(date).
+		From("Sender <sender@example.com>").
+		ReplyTo("reply@example.com").
+		To("to1@example.com").
+		To("to2@example.com").
+		Cc("cc@example.com").
+		Bcc("bcc@example.com").
+		Subject("Hello").
+		MessageID("<m2@postman.test>").
+		References("<m0@postman.test>").
+		InReplyTo("<m1@postman.test>").
+		Priority(PriorityUrgent).
+		Campaign("spring").
+		Header("X-Entity-Ref-ID", "ref").
+		Text("Hello").
+		HTML("<p>Hello <img src=\"cid:logo\"></p>").
+		Attach("a.txt", []byte("attachment")).
+		Inline("logo.png", "logo", []byte("png")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utf8 := map[string]string{"charset": "utf-8"}
+	want := &Mail{
+		Date:       date,
+		From:       "Sender <sender@example.com>",
+		ReplyTo:    "reply@example.com",
+		To:         []string{"to1@example.com", "to2@example.com"},
+		Cc:         []string{"cc@example.com"},
+		Bcc:        []string{"bcc@example.com"},
+		Subject:    "Hello",
+		MessageID:  "<m2@postman.test>",
+		InReplyTo:  "<m1@postman.test>",
+		References: []string{"<m0@postman.test>", "<m1@postman.test>"},
+		Priority:   PriorityUrgent,
+		Campaign:   "spring",
+		Header:     textproto.MIMEHeader{"X-Entity-Ref-Id": {"ref"}},
+		Parts: []Part{
+			{ContentType: "text/plain", Params: utf8, Content: []byte("Hello")},
+			{ContentType: "text/html", Params: utf8, Content: []byte("<p>Hello <img src=\"cid:logo\"></p>")},
+		},
+		Attachments: []Attachment{
+			{Filename: "a.txt", Content: []byte("attachment")},
+			{Filename: "logo.png", ContentDisposition: "inline", ContentID: "logo", Content: []byte("png")},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", m, want)
+	}
+}
+
+// TestBuilderEnvelope checks that the options of the envelope do not
+// depend on the order of the calls.
+func TestBuilderEnvelope(t *testing.T) {
+	want := Envelope{
+		MailFrom:        "bounces@example.com",
+		RcptTo:          []string{"rcpt@example.com"},
+		DeliverBy:       time.Hour,
+		DeliverByNotify: true,
+		RequireTLS:      true,
+	}
+
+	builders := map[string]*Builder{
+		"envelope first": New().Envelope("bounces@example.com", "rcpt@example.com").DeliverBy(time.Hour, true).RequireTLS(),
+		"envelope last":  New().DeliverBy(time.Hour, true).RequireTLS().Envelope("bounces@example.com", "rcpt@example.com"),
+		"envelope twice": New().Envelope("other@example.com").RequireTLS().DeliverBy(time.Hour, true).Envelope("bounces@example.com", "rcpt@example.com"),
+	}
+
+	for name, b := range builders {
+		m, err := b.From("sender@example.com").Text("Hello").Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m.Envelope, want) {
+			t.Errorf("%s: got %+v, want %+v", name, m.Envelope, want)
+		}
+	}
+}
+
+// TestBuilderReuse checks that the messages built do not share their
+// lists with the builder.
+func TestBuilderReuse(t *testing.T) {
+	b := New().From("sender@example.com").To("to1@example.com").Header("X-Batch", "1").Text("Hello")
+
+	m1, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m1.To[0] = "changed@example.com"
+	m1.Header.Set("X-Batch", "changed")
+
+	m2, err := b.To("to2@example.com").Header("X-Batch", "2").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"to1@example.com", "to2@example.com"}; !reflect.DeepEqual(m2.To, want) {
+		t.Errorf("To: %q, want %q", m2.To, want)
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(m2.Header["X-Batch"], want) {
+		t.Errorf("X-Batch: %q, want %q", m2.Header["X-Batch"], want)
+	}
+	if len(m1.To) != 1 || len(m1.Parts) != 1 {
+		t.Errorf("first message changed: %+v", m1)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	// The first error is returned.
+	missing := filepath.Join(os.TempDir(), "postman-missing.pdf")
+	_, err := New().From("sender@example.com").To("rcpt@example.com").
+		AttachFile(missing).
+		AttachFile("other-missing.pdf").
+		Build()
+	if pe, ok := err.(*os.PathError); !ok || pe.Path != missing {
+		t.Errorf("missing file: got %v", err)
+	}
+
+	_, err = New().To("rcpt@example.com").Text("Hello").Build()
+	le, ok := err.(*LintError)
+	if !ok || len(le.Issues) == 0 || le.Issues[0].Field != "From" {
+		t.Errorf("missing From: got %v", err)
+	}
+}
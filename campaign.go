@@ -0,0 +1,266 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CampaignReport aggregates the outcomes of the messages of a campaign,
+// counted per recipient.
+type CampaignReport struct {
+	Campaign string `json:"campaign"`
+	Messages int    `json:"messages"`
+
+	// Sent is the number of recipients the messages were sent to.
+	Sent int `json:"sent"`
+
+	// Delivered counts the recipients which complained too, since the
+	// message reached them.
+	Delivered  int `json:"delivered"`
+	Deferred   int `json:"deferred"`
+	Bounced    int `json:"bounced"`
+	Complained int `json:"complained"`
+	Failed     int `json:"failed"`
+}
+
+// Outcome is the outcome of the delivery of a message to a recipient.
+type Outcome struct {
+	MessageID string    `json:"message_id"`
+	Recipient string    `json:"recipient"`
+	Sent      time.Time `json:"sent"`
+	State     State     `json:"state"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ApplyDSN updates the outcomes recorded for the recipients d reports
+// on, and the state of their message as Tracker.ApplyDSN does.
+func (a *Archive) ApplyDSN(ctx context.Context, d *DSN) error {
+	if d.OriginalMessageID == "" {
+		return fmt.Errorf("postman: DSN without original Message-ID")
+	}
+
+	msgID := "<" + d.OriginalMessageID + ">"
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range d.Recipients {
+		rcpt := &d.Recipients[i]
+
+		errMsg := ""
+		if rcpt.Action == "failed" || rcpt.Action == "delayed" {
+			errMsg = rcpt.describe()
+		}
+
+		err := a.updateRecipients(ctx, tx, msgID, rcpt.FinalRecipient, rcpt.state(), errMsg)
+		if err != nil {
+			return err
+		}
+	}
+
+	state, errMsg := d.state()
+	_, err = tx.ExecContext(ctx,
+		"UPDATE postman_archive SET state = ?, error = ? WHERE message_id = ?",
+		string(state), errMsg, msgID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplyFeedbackReport marks the recipients f reports on as complained, or
+// every recipient of the message if the report does not name them.
+// Other feedback than abuse and fraud is ignored.
+func (a *Archive) ApplyFeedbackReport(ctx context.Context, f *FeedbackReport) error {
+	if f.OriginalMessageID == "" {
+		return fmt.Errorf("postman: feedback report without original Message-ID")
+	}
+
+	if f.FeedbackType != "abuse" && f.FeedbackType != "fraud" {
+		return nil
+	}
+
+	msgID := "<" + f.OriginalMessageID + ">"
+	errMsg := f.FeedbackType + " report from " + f.UserAgent
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rcpts := f.OriginalRcptTo
+	if len(rcpts) == 0 {
+		rcpts = []string{""}
+	}
+
+	for _, rcpt := range rcpts {
+		rcpt = strings.Trim(strings.TrimSpace(rcpt), "<>")
+		if err := a.updateRecipients(ctx, tx, msgID, rcpt, StateComplained, errMsg); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE postman_archive SET state = ?, error = ? WHERE message_id = ?",
+		string(StateComplained), errMsg, msgID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateRecipients sets the outcome of the delivery of a message to rcpt,
+// or to every recipient if rcpt is empty.
+func (a *Archive) updateRecipients(ctx context.Context, tx *sql.Tx, msgID, rcpt string, state State, errMsg string) error {
+	q := "UPDATE postman_archive_recipients SET state = ?, error = ? WHERE archive_id IN (SELECT id FROM postman_archive WHERE message_id = ?)"
+	args := []interface{}{string(state), errMsg, msgID}
+
+	if rcpt != "" {
+		q += " AND address = ?"
+		args = append(args, rcpt)
+	}
+
+	_, err := tx.ExecContext(ctx, q, args...)
+	return err
+}
+
+// CampaignReport returns the aggregated outcomes of a campaign.
+func (a *Archive) CampaignReport(ctx context.Context, campaign string) (*CampaignReport, error) {
+	r := CampaignReport{Campaign: campaign}
+
+	err := a.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM postman_archive WHERE campaign = ?", campaign).Scan(&r.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.DB.QueryContext(ctx,
+		"SELECT r.state, COUNT(*) FROM postman_archive_recipients r JOIN postman_archive a ON a.id = r.archive_id WHERE a.campaign = ? GROUP BY r.state",
+		campaign)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			state string
+			n     int
+		)
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+
+		r.Sent += n
+
+		switch State(state) {
+		case StateDelivered:
+			r.Delivered += n
+		case StateDeferred:
+			r.Deferred += n
+		case StateBounced:
+			r.Bounced += n
+		case StateComplained:
+			r.Complained += n
+			r.Delivered += n
+		default:
+			r.Failed += n
+		}
+	}
+
+	return &r, rows.Err()
+}
+
+// ExportCampaign writes the outcome for every recipient of a campaign to
+// w, as CSV with a header line if format is "csv", or as a JSON array of
+// Outcome if it is "json".
+func (a *Archive) ExportCampaign(ctx context.Context, w io.Writer, campaign, format string) error {
+	var write func(*Outcome) error
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		defer cw.Flush()
+
+		if err := cw.Write([]string{"message_id", "recipient", "sent", "state", "error"}); err != nil {
+			return err
+		}
+
+		write = func(o *Outcome) error {
+			return cw.Write([]string{o.MessageID, o.Recipient, o.Sent.Format(time.RFC3339), string(o.State), o.Error})
+		}
+
+	case "json":
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+
+		n := 0
+		write = func(o *Outcome) error {
+			buf.Reset()
+			if n == 0 {
+				buf.WriteString("[\n")
+			} else {
+				buf.WriteString(",\n")
+			}
+			n++
+
+			if err := enc.Encode(o); err != nil {
+				return err
+			}
+			buf.Truncate(buf.Len() - 1)
+
+			_, err := w.Write(buf.Bytes())
+			return err
+		}
+
+		defer func() {
+			if n == 0 {
+				io.WriteString(w, "[]\n")
+			} else {
+				io.WriteString(w, "\n]\n")
+			}
+		}()
+
+	default:
+		return fmt.Errorf("postman: unknown export format %q", format)
+	}
+
+	rows, err := a.DB.QueryContext(ctx,
+		"SELECT a.message_id, r.address, a.sent, r.state, r.error FROM postman_archive_recipients r JOIN postman_archive a ON a.id = r.archive_id WHERE a.campaign = ? ORDER BY a.sent, r.address",
+		campaign)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			o     Outcome
+			state string
+		)
+		if err := rows.Scan(&o.MessageID, &o.Recipient, &o.Sent, &state, &o.Error); err != nil {
+			return err
+		}
+		o.State = State(state)
+
+		if err := write(&o); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,167 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// campaignDB returns a database holding the outcomes of the recipients
+// of the "spring" campaign.
+func campaignDB() *stubDB {
+	sent := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	db := new(stubDB)
+	db.handle("SELECT COUNT(*) FROM postman_archive WHERE campaign = ?", func(args []driver.Value) (*stubResult, error) {
+		return &stubResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(3)}}}, nil
+	})
+	db.handle("SELECT r.state, COUNT(*) FROM postman_archive_recipients r JOIN postman_archive a ON a.id = r.archive_id WHERE a.campaign = ? GROUP BY r.state", func(args []driver.Value) (*stubResult, error) {
+		return &stubResult{
+			columns: []string{"state", "count"},
+			rows: [][]driver.Value{
+				{"delivered", int64(5)},
+				{"deferred", int64(1)},
+				{"bounced", int64(2)},
+				{"complained", int64(1)},
+				{"failed", int64(1)},
+			},
+		}, nil
+	})
+	db.handle("SELECT a.message_id, r.address, a.sent, r.state, r.error FROM postman_archive_recipients r JOIN postman_archive a ON a.id = r.archive_id WHERE a.campaign = ? ORDER BY a.sent, r.address", func(args []driver.Value) (*stubResult, error) {
+		return &stubResult{
+			columns: []string{"message_id", "address", "sent", "state", "error"},
+			rows: [][]driver.Value{
+				{"<m1@postman.test>", "a@example.com", sent, "delivered", ""},
+				{"<m1@postman.test>", "b@example.com", sent, "bounced", `b@example.com: 5.1.1 smtp; 550 "unknown"`},
+			},
+		}, nil
+	})
+
+	return db
+}
+
+func TestCampaignReport(t *testing.T) {
+	a := &Archive{DB: campaignDB().open()}
+	defer a.DB.Close()
+
+	r, err := a.CampaignReport(context.Background(), "spring")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Complaints count as deliveries too.
+	want := &CampaignReport{
+		Campaign:   "spring",
+		Messages:   3,
+		Sent:       10,
+		Delivered:  6,
+		Deferred:   1,
+		Bounced:    2,
+		Complained: 1,
+		Failed:     1,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+}
+
+func TestExportCampaign(t *testing.T) {
+	a := &Archive{DB: campaignDB().open()}
+	defer a.DB.Close()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", `message_id,recipient,sent,state,error
+<m1@postman.test>,a@example.com,2001-02-03T04:05:06Z,delivered,
+<m1@postman.test>,b@example.com,2001-02-03T04:05:06Z,bounced,"b@example.com: 5.1.1 smtp; 550 ""unknown"""
+`},
+		{"json", `[
+{"message_id":"<m1@postman.test>","recipient":"a@example.com","sent":"2001-02-03T04:05:06Z","state":"delivered"},
+{"message_id":"<m1@postman.test>","recipient":"b@example.com","sent":"2001-02-03T04:05:06Z","state":"bounced","error":"b@example.com: 5.1.1 smtp; 550 \"unknown\""}
+]
+`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := a.ExportCampaign(context.Background(), &buf, "spring", tt.format); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	if err := a.ExportCampaign(context.Background(), new(bytes.Buffer), "spring", "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// TestArchiveReports checks the outcomes updated from delivery status
+// notifications and feedback reports.
+func TestArchiveReports(t *testing.T) {
+	var stmts []string
+
+	db := new(stubDB)
+	for _, prefix := range []string{"UPDATE postman_archive_recipients ", "UPDATE postman_archive "} {
+		prefix := prefix
+		db.handle(prefix, func(args []driver.Value) (*stubResult, error) {
+			var s []string
+			for _, arg := range args {
+				s = append(s, arg.(string))
+			}
+			stmts = append(stmts, prefix+strings.Join(s, " | "))
+			return &stubResult{affected: 1}, nil
+		})
+	}
+
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	ctx := context.Background()
+	err := a.ApplyDSN(ctx, &DSN{
+		OriginalMessageID: "m1@postman.test",
+		Recipients: []DSNRecipient{
+			{FinalRecipient: "a@example.com", Action: "delivered", Status: "2.0.0"},
+			{FinalRecipient: "b@example.com", Action: "failed", Status: "5.1.1", DiagnosticCode: "smtp; 550 unknown"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.ApplyFeedbackReport(ctx, &FeedbackReport{
+		FeedbackType:      "abuse",
+		UserAgent:         "Mailbox/1.0",
+		OriginalMessageID: "m2@postman.test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other feedback is ignored.
+	if err := a.ApplyFeedbackReport(ctx, &FeedbackReport{FeedbackType: "not-spam", OriginalMessageID: "m3@postman.test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"UPDATE postman_archive_recipients delivered |  | <m1@postman.test> | a@example.com",
+		"UPDATE postman_archive_recipients bounced | b@example.com: 5.1.1 smtp; 550 unknown | <m1@postman.test> | b@example.com",
+		"UPDATE postman_archive bounced | b@example.com: 5.1.1 smtp; 550 unknown | <m1@postman.test>",
+		"UPDATE postman_archive_recipients complained | abuse report from Mailbox/1.0 | <m2@postman.test>",
+		"UPDATE postman_archive complained | abuse report from Mailbox/1.0 | <m2@postman.test>",
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(stmts, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := a.ApplyDSN(ctx, &DSN{}); err == nil {
+		t.Error("DSN without original Message-ID applied")
+	}
+}
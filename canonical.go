@@ -0,0 +1,207 @@
+package postman
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Canonicalization is a header and body canonicalization algorithm, as
+// defined for DKIM (RFC 6376 section 3.4). Canonical forms are stable
+// across the modifications MTAs commonly make in transit, which makes
+// them suitable to sign, hash or compare messages.
+type Canonicalization int
+
+const (
+	// CanonicalizationSimple tolerates almost no modification.
+	CanonicalizationSimple Canonicalization = iota
+
+	// CanonicalizationRelaxed tolerates whitespace replacement and
+	// header field line rewrapping.
+	CanonicalizationRelaxed
+)
+
+// ParseCanonicalization parses "simple" or "relaxed".
+func ParseCanonicalization(s string) (Canonicalization, error) {
+	switch strings.ToLower(s) {
+	case "simple":
+		return CanonicalizationSimple, nil
+	case "relaxed":
+		return CanonicalizationRelaxed, nil
+	}
+	return 0, fmt.Errorf("postman: unknown canonicalization %q", s)
+}
+
+func (c Canonicalization) String() string {
+	switch c {
+	case CanonicalizationSimple:
+		return "simple"
+	case CanonicalizationRelaxed:
+		return "relaxed"
+	}
+	return fmt.Sprintf("Canonicalization(%d)", int(c))
+}
+
+// Header returns the canonical form of a single header field. The field
+// is given as it appears in the message, folding included; the CRLF
+// ending it is optional. The result always ends with CRLF.
+func (c Canonicalization) Header(field string) string {
+	field = strings.TrimSuffix(field, "\r\n")
+
+	if c != CanonicalizationRelaxed {
+		return field + "\r\n"
+	}
+
+	name, value := field, ""
+	if i := strings.IndexByte(field, ':'); i >= 0 {
+		name, value = field[:i], field[i+1:]
+	}
+
+	var b strings.Builder
+	b.Grow(len(field) + 2)
+
+	b.WriteString(strings.ToLower(strings.TrimRight(name, " \t")))
+	b.WriteByte(':')
+	start := b.Len()
+
+	wsp := false
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; ch {
+		case '\r', '\n':
+		case ' ', '\t':
+			wsp = true
+		default:
+			if wsp && b.Len() > start {
+				b.WriteByte(' ')
+			}
+			wsp = false
+			b.WriteByte(ch)
+		}
+	}
+
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+// Body returns the canonical form of body.
+func (c Canonicalization) Body(body []byte) []byte {
+	var buf bytes.Buffer
+
+	bw := c.BodyWriter(&buf)
+	bw.Write(body)
+	bw.Close()
+
+	return buf.Bytes()
+}
+
+// BodyWriter returns a writer canonicalizing the body written to it into
+// w. Trailing empty lines are held back until more content is written,
+// so Close must be called to terminate the body. Close does not close w.
+func (c Canonicalization) BodyWriter(w io.Writer) io.WriteCloser {
+	return &bodyWriter{w: w, relaxed: c == CanonicalizationRelaxed}
+}
+
+type bodyWriter struct {
+	w       io.Writer
+	relaxed bool
+	line    []byte
+	empty   int
+	written bool
+	err     error
+}
+
+func (bw *bodyWriter) Write(p []byte) (int, error) {
+	if bw.err != nil {
+		return 0, bw.err
+	}
+
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			bw.line = append(bw.line, p...)
+			break
+		}
+
+		bw.line = append(bw.line, p[:i]...)
+		p = p[i+1:]
+
+		if err := bw.flushLine(); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func (bw *bodyWriter) Close() error {
+	if bw.err != nil {
+		return bw.err
+	}
+
+	if len(bw.line) > 0 {
+		if err := bw.flushLine(); err != nil {
+			return err
+		}
+	}
+
+	// An empty body is a single CRLF in simple canonicalization and
+	// stays empty in relaxed canonicalization (RFC 6376 errata 3192).
+	if !bw.written && !bw.relaxed {
+		_, bw.err = io.WriteString(bw.w, "\r\n")
+	}
+
+	return bw.err
+}
+
+func (bw *bodyWriter) flushLine() error {
+	line := bytes.TrimSuffix(bw.line, []byte{'\r'})
+	if bw.relaxed {
+		line = relaxLine(line)
+	}
+	bw.line = bw.line[:0]
+
+	if len(line) == 0 {
+		bw.empty++
+		return nil
+	}
+
+	for ; bw.empty > 0; bw.empty-- {
+		if _, bw.err = io.WriteString(bw.w, "\r\n"); bw.err != nil {
+			return bw.err
+		}
+	}
+
+	if _, bw.err = bw.w.Write(line); bw.err != nil {
+		return bw.err
+	}
+
+	_, bw.err = io.WriteString(bw.w, "\r\n")
+	bw.written = true
+
+	return bw.err
+}
+
+// relaxLine reduces whitespace sequences of line to a single space and
+// removes trailing whitespace, in place.
+func relaxLine(line []byte) []byte {
+	out := line[:0]
+	wsp := false
+
+	for _, ch := range line {
+		if ch == ' ' || ch == '\t' {
+			wsp = true
+			continue
+		}
+		if wsp {
+			out = append(out, ' ')
+			wsp = false
+		}
+		out = append(out, ch)
+	}
+
+	return out
+}
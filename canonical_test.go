@@ -0,0 +1,91 @@
+package postman
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCanonicalizationHeader(t *testing.T) {
+	tests := []struct {
+		c     Canonicalization
+		field string
+		want  string
+	}{
+		// RFC 6376 section 3.4.5.
+		{CanonicalizationSimple, "A: X\r\n", "A: X\r\n"},
+		{CanonicalizationSimple, "B : Y\t\r\n\tZ  \r\n", "B : Y\t\r\n\tZ  \r\n"},
+		{CanonicalizationRelaxed, "A: X\r\n", "a:X\r\n"},
+		{CanonicalizationRelaxed, "B : Y\t\r\n\tZ  \r\n", "b:Y Z\r\n"},
+
+		{CanonicalizationSimple, "Subject: no CRLF", "Subject: no CRLF\r\n"},
+		{CanonicalizationRelaxed, "Subject: no CRLF", "subject:no CRLF\r\n"},
+		{CanonicalizationRelaxed, "SUBJECT:\t  a \t b\t", "subject:a b\r\n"},
+		{CanonicalizationRelaxed, "To: a@example.com,\r\n b@example.com\r\n", "to:a@example.com, b@example.com\r\n"},
+		{CanonicalizationRelaxed, "Empty:\r\n", "empty:\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.Header(tt.field); got != tt.want {
+			t.Errorf("%s Header(%q) = %q, want %q", tt.c, tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizationBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		simple, relax string
+	}{
+		// RFC 6376 section 3.4.5.
+		{"example", " C \r\nD \t E\r\n\r\n\r\n", " C \r\nD \t E\r\n", " C\r\nD E\r\n"},
+
+		{"empty", "", "\r\n", ""},
+		{"empty lines only", "\r\n\r\n", "\r\n", ""},
+		{"whitespace lines", "a\r\n \r\n\t\r\n", "a\r\n \r\n\t\r\n", "a\r\n"},
+		{"inner empty lines", "a\r\n\r\n\r\nb\r\n", "a\r\n\r\n\r\nb\r\n", "a\r\n\r\n\r\nb\r\n"},
+		{"no final CRLF", "a\r\nb", "a\r\nb\r\n", "a\r\nb\r\n"},
+		{"bare LF", "a\nb\n\n", "a\r\nb\r\n", "a\r\nb\r\n"},
+		{"whitespace runs", "a  b\t\tc \t \r\n", "a  b\t\tc \t \r\n", "a b c\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []struct {
+				c    Canonicalization
+				want string
+			}{
+				{CanonicalizationSimple, tt.simple},
+				{CanonicalizationRelaxed, tt.relax},
+			} {
+				if got := c.c.Body([]byte(tt.body)); string(got) != c.want {
+					t.Errorf("%s: got %q, want %q", c.c, got, c.want)
+				}
+
+				// Writing byte by byte gives the same result.
+				var buf bytes.Buffer
+				bw := c.c.BodyWriter(&buf)
+				for i := 0; i < len(tt.body); i++ {
+					bw.Write([]byte{tt.body[i]})
+				}
+				if err := bw.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if got := buf.String(); got != c.want {
+					t.Errorf("%s, byte by byte: got %q, want %q", c.c, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCanonicalization(t *testing.T) {
+	for _, c := range []Canonicalization{CanonicalizationSimple, CanonicalizationRelaxed} {
+		if got, err := ParseCanonicalization(c.String()); err != nil || got != c {
+			t.Errorf("ParseCanonicalization(%q) = %s, %v", c, got, err)
+		}
+	}
+	if _, err := ParseCanonicalization("loose"); err == nil {
+		t.Error(`ParseCanonicalization("loose"): got no error`)
+	}
+}
@@ -0,0 +1,96 @@
+package postman
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// lookupCharset returns the encoding of a charset name, as registered by
+// IANA, such as "ISO-8859-1", "Shift_JIS" or "GB2312". Aliases used by
+// browsers are accepted too.
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	enc, err := ianaindex.MIME.Encoding(charset)
+	if err != nil || enc == nil {
+		enc, err = htmlindex.Get(charset)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("postman: unsupported charset %q", charset)
+	}
+	return enc, nil
+}
+
+// encodeParts returns m, or a copy of m if some of its parts have a
+// Charset, with the content of these parts converted from UTF-8 and the
+// charset set in their parameters.
+func (m *Mail) encodeParts() (*Mail, error) {
+	i := 0
+	for i < len(m.Parts) && m.Parts[i].Charset == "" {
+		i++
+	}
+	if i == len(m.Parts) {
+		return m, nil
+	}
+
+	cm := *m
+	cm.Parts = append([]Part(nil), m.Parts...)
+
+	for ; i < len(cm.Parts); i++ {
+		p := &cm.Parts[i]
+		if p.Charset == "" {
+			continue
+		}
+
+		if err := p.encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cm, nil
+}
+
+func (p *Part) encode() error {
+	mediatype, params, err := p.MediaType()
+	if err != nil {
+		return err
+	}
+
+	if p.Content, err = encodeCharset(p.Charset, p.Content); err != nil {
+		return err
+	}
+
+	params["charset"] = p.Charset
+	p.ContentType, p.Params = mediatype, params
+	p.Charset = ""
+
+	return nil
+}
+
+// encodeCharset converts UTF-8 content to charset.
+func encodeCharset(charset string, content []byte) ([]byte, error) {
+	switch {
+	case strings.EqualFold(charset, "utf-8"):
+		return content, nil
+
+	case strings.EqualFold(charset, "us-ascii"):
+		if !is7bit(content) {
+			return nil, fmt.Errorf("postman: cannot encode part in %s", charset)
+		}
+		return content, nil
+	}
+
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := enc.NewEncoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("postman: cannot encode part in %s: %v", charset, err)
+	}
+
+	return encoded, nil
+}
@@ -0,0 +1,1024 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const modulePath = "github.com/jobteaser/postman"
+
+// DefaultMailer is the X-Mailer value stamped by clients created with
+// NewClient: "postman", followed by the module version when the binary
+// was built with module support.
+var DefaultMailer = defaultMailer()
+
+// Transport delivers messages.
+type Transport interface {
+	Send(m *Mail) error
+}
+
+// TLSMode tells how a Client encrypts its connections.
+type TLSMode int
+
+const (
+	// TLSOpportunistic issues STARTTLS whenever the server supports it.
+	TLSOpportunistic TLSMode = iota
+
+	// TLSRequired issues STARTTLS and fails when the server does not
+	// support it.
+	TLSRequired
+
+	// TLSImplicit connects with TLS from the start, as on port 465 (RFC
+	// 8314 section 3.3).
+	TLSImplicit
+
+	// TLSDisabled never encrypts connections.
+	TLSDisabled
+)
+
+var tlsModes = []string{"opportunistic", "required", "implicit", "disabled"}
+
+// ParseTLSMode parses "opportunistic", "required", "implicit" or
+// "disabled".
+func ParseTLSMode(s string) (TLSMode, error) {
+	mode, err := parseEnum("TLS mode", append([]string{""}, tlsModes...), s)
+	return TLSMode(mode - 1), err
+}
+
+func (m TLSMode) String() string {
+	return enumString("TLSMode", append([]string{""}, tlsModes...), int(m)+1)
+}
+
+// RetryPolicy tells how a Client retries deliveries failing with a
+// temporary error: a network error or a 4yz reply.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, including the first
+	// one. Zero or one means no retry.
+	Attempts int
+
+	// Backoff is the delay before the first retry, doubled after each
+	// attempt.
+	Backoff time.Duration
+}
+
+// Client sends messages to an SMTP server. It implements Transport.
+//
+// A Client is safe for concurrent use by multiple goroutines, which share
+// its idle connections; each message is sent over a connection of its
+// own. Its fields must not be modified once it is in use.
+type Client struct {
+	// Addr is the address of the server, as host:port. IPv6 literals
+	// are enclosed in brackets, as in "[2001:db8::25]:25".
+	Addr string
+
+	// Dialer opens the connections to the server. Nil means a dialer
+	// with a 30 second timeout.
+	Dialer *net.Dialer
+
+	// FallbackDelay is how long a connection attempt to an address of
+	// the server runs before the next address is tried concurrently, as
+	// Happy Eyeballs (RFC 8305) does. Zero means DefaultFallbackDelay; a
+	// negative delay tries the addresses one after the other.
+	FallbackDelay time.Duration
+
+	// Auth authenticates the client after the session is encrypted, when
+	// set.
+	Auth smtp.Auth
+
+	// XClient, when set, is sent with the XCLIENT command before
+	// authenticating, for the server to apply its policies to the
+	// original client of the messages.
+	XClient *XClient
+
+	// ProxyProtocol is the version of the PROXY protocol header, 1 or 2,
+	// sent as soon as connections are established, as required by load
+	// balancers relaying them to the server. Zero sends none.
+	ProxyProtocol int
+
+	// TLSMode tells whether and how connections are encrypted.
+	TLSMode TLSMode
+
+	// TLSConfig is used to encrypt connections. Nil means a default
+	// configuration verifying the server name of Addr. TLS sessions are
+	// resumed across connections, with a cache kept by the client unless
+	// TLSConfig has its own ClientSessionCache or disables session
+	// tickets.
+	TLSConfig *tls.Config
+
+	// Pins restricts the servers accepted to those presenting a
+	// certificate, or a certificate of their chain, matching one of them:
+	// "sha256/" followed by the base64 encoded SHA-256 hash of its public
+	// key (SubjectPublicKeyInfo), or "cert-sha256/" followed by that of
+	// the whole certificate. The certificates are still verified against
+	// certificate authorities, unless TLSConfig sets InsecureSkipVerify:
+	// the pins then replace that verification, only the certificate of
+	// the server being checked.
+	Pins []string
+
+	// TLSPolicies are the TLS policies of destinations: the one of the
+	// server replaces TLSMode and Pins, while those of the recipients
+	// make the connections their messages are sent over stricter, but
+	// cannot pin certificates nor require DANE.
+	TLSPolicies TLSPolicyMap
+
+	// DNSSECResolver is the address of the DNSSEC validating resolver
+	// the TLSA records of DANE policies are looked up from. Empty means
+	// the first name server of /etc/resolv.conf.
+	DNSSECResolver string
+
+	// Mailer is stamped in the X-Mailer field of every message which
+	// does not define its own. Empty disables it.
+	Mailer string
+
+	// DKIM signs messages whose author domain is aligned with the
+	// signer domain. Several keys may be configured for a domain, with
+	// validity windows, to rotate them.
+	DKIM []*DKIMSigner
+
+	// Defaults are merged into every message sent, when set.
+	Defaults *Defaults
+
+	// Identities are the domains the client sends messages for, each
+	// with its own defaults and DKIM key.
+	Identities []*Identity
+
+	// PoolSize is the number of idle connections kept open to send the
+	// next messages. Zero closes the connection after each message.
+	PoolSize int
+
+	// Retry is the policy applied to temporary failures.
+	Retry RetryPolicy
+
+	// Quota limits the messages sent by every sender, unless their
+	// identity has a quota of its own. Quotas counts them; nil means a
+	// new QuotaCounter.
+	Quota  *Quota
+	Quotas QuotaStore
+
+	// Spool, when set, spools the attachments of large messages to
+	// temporary files while they are sent, removed once they are sent.
+	// The messages given are not changed.
+	Spool *Spool
+
+	// Suppressions, when set, are the addresses the messages are not
+	// sent to, including those delivered by a Queue or an Outbox
+	// through the client: they are left out of the envelope, and a
+	// message all the recipients of which are suppressed fails with
+	// ErrSuppressed.
+	Suppressions SuppressionStore
+
+	// Tracker records the delivery status of the messages sent, when
+	// set. NewClient sets a new one.
+	Tracker *Tracker
+
+	// Archive, when set, records the messages as they are sent, with
+	// their DKIM signatures, and the outcome of their delivery, including
+	// those delivered by a Queue or an Outbox through the client.
+	Archive *Archive
+
+	mu       sync.Mutex
+	idle     []*smtp.Client
+	sessions tls.ClientSessionCache
+
+	// open are the open connections, and whether they are
+	// authenticated.
+	open     map[*smtp.Client]bool
+	inFlight int
+	errors   errorCounter
+}
+
+// NewClient returns a client sending messages to the SMTP server at
+// addr, configured by opts.
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{Addr: addr, Mailer: DefaultMailer, Tracker: new(Tracker)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Submitter is implemented by the transports reporting the reply of the
+// server accepting a message, which usually includes the identifier it
+// was queued with, as in "2.0.0 Ok: queued as 4F2A1C0A3".
+type Submitter interface {
+	Transport
+	Submit(m *Mail) (string, error)
+}
+
+// RawSender is implemented by the transports sending messages rendered
+// beforehand, as those stored by an Outbox, as they are.
+type RawSender interface {
+	Transport
+	SendRaw(from string, rcpts []string, raw []byte) error
+}
+
+// Preparer is implemented by the transports completing the messages they
+// send, as Client does with its defaults and identities, for the messages
+// rendered beforehand, as those stored by an Outbox, to be completed the
+// same way.
+type Preparer interface {
+	Transport
+	Prepare(m *Mail) (*Mail, error)
+}
+
+// Send delivers m to the server. Temporary failures are retried
+// following the retry policy of the client.
+func (c *Client) Send(m *Mail) error {
+	_, err := c.Deliver(m)
+	return err
+}
+
+// Submit delivers m as Send does, and returns the reply of the server to
+// the message data.
+func (c *Client) Submit(m *Mail) (string, error) {
+	res, err := c.Deliver(m)
+	if res == nil {
+		return "", err
+	}
+	return res.Reply, err
+}
+
+// Deliver delivers m as Send does, and returns the replies of the server
+// to the message data. When the server supporting PRDR rejects the message
+// for all of its recipients, the result is returned along with the error.
+func (c *Client) Deliver(m *Mail) (*Result, error) {
+	c.begin()
+	res, err := c.deliver(m)
+	c.end(err)
+
+	return res, err
+}
+
+// SendRaw delivers raw, a message rendered as by Mail.Bytes, to rcpts as
+// it is: it is only signed by the DKIM signers aligned with its author.
+// Temporary failures are retried following the retry policy of the
+// client.
+func (c *Client) SendRaw(from string, rcpts []string, raw []byte) error {
+	c.begin()
+	err := c.sendRaw(from, rcpts, raw)
+	c.end(err)
+
+	return err
+}
+
+// Prepare returns a copy of m completed as the client completes the
+// messages it sends: with its defaults, the fields of the identity of m,
+// its mailer, and the Date and Message-ID fields. Messages rendered
+// beforehand and sent with SendRaw are otherwise sent as they are.
+func (c *Client) Prepare(m *Mail) (*Mail, error) {
+	return c.prepare(m)
+}
+
+// begin and end count the messages being sent, and their errors.
+func (c *Client) begin() {
+	c.mu.Lock()
+	c.inFlight++
+	c.mu.Unlock()
+}
+
+func (c *Client) end(err error) {
+	c.mu.Lock()
+	c.inFlight--
+	if err != nil {
+		c.errors.add(err, time.Now())
+	}
+	c.mu.Unlock()
+}
+
+func (c *Client) sendRaw(from string, rcpts []string, raw []byte) error {
+	if len(rcpts) == 0 {
+		return errors.New("postman: no recipient")
+	}
+
+	rcpts, err := c.unsuppressed(rcpts)
+	if err != nil {
+		return err
+	}
+
+	// Only the fields finding the signers, the identity, the quota and
+	// the tracking identifier of the message are needed.
+	var m Mail
+	fields, _ := splitMessage(raw)
+	for i := range fields {
+		switch textproto.CanonicalMIMEHeaderKey(fields[i].name) {
+		case "From":
+			m.From = fields[i].value()
+		case "Sender":
+			m.Sender = fields[i].value()
+		case "Message-Id":
+			m.MessageID = fields[i].value()
+		}
+	}
+
+	id := TrackingID(m.MessageID)
+
+	if err := c.takeQuota(&m, int64(len(raw))); err != nil {
+		if c.Tracker != nil {
+			c.Tracker.done(id, err)
+		}
+		return err
+	}
+
+	p := &payload{raw: raw}
+	for _, s := range c.signers(&m) {
+		field, err := s.Sign(raw)
+		if err != nil {
+			return err
+		}
+		p.signatures = append(p.signatures, field...)
+	}
+
+	res, err := c.retry(id, from, rcpts, &m, p)
+	if c.Archive != nil {
+		// The fields of the message only found by parsing it are
+		// recorded too.
+		am, perr := ParseMail(bytes.NewReader(raw))
+		if perr != nil {
+			am = &m
+		}
+		c.archive(am, rcpts, p, res, err)
+	}
+	if c.Tracker != nil {
+		c.Tracker.done(id, err)
+	}
+
+	return err
+}
+
+func (c *Client) deliver(m *Mail) (*Result, error) {
+	m, err := c.prepare(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Spool != nil {
+		release, err := c.Spool.Spool(m)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	id := TrackingID(m.MessageID)
+
+	res, err := c.submit(id, m)
+	if c.Tracker != nil {
+		c.Tracker.done(id, err)
+	}
+
+	return res, err
+}
+
+// Status returns the delivery status of the message with the given
+// tracking identifier, as returned by TrackingID.
+func (c *Client) Status(id string) (Status, error) {
+	if c.Tracker == nil {
+		return Status{}, ErrUnknownMessage
+	}
+	return c.Tracker.Status(id)
+}
+
+func (c *Client) submit(id string, m *Mail) (*Result, error) {
+	from, err := m.ReversePath()
+	if err != nil {
+		return nil, err
+	}
+
+	rcpts, err := m.Recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	if rcpts, err = c.unsuppressed(rcpts); err != nil {
+		return nil, err
+	}
+
+	if err := c.takeQuota(m, m.size()); err != nil {
+		return nil, err
+	}
+
+	payload, err := c.sign(m)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		defer payload.close()
+	}
+
+	res, err := c.retry(id, from, rcpts, m, payload)
+	if c.Archive != nil {
+		c.archive(m, rcpts, payload, res, err)
+	}
+
+	return res, err
+}
+
+// archive records m, sent to rcpts as payload, with the result of its
+// delivery. The errors of the archive are logged rather than returned.
+func (c *Client) archive(m *Mail, rcpts []string, payload *payload, res *Result, sendErr error) {
+	raw, err := payload.bytes()
+	if err != nil {
+		c.Archive.logf("postman: archive: %v", err)
+		return
+	}
+
+	reply := ""
+	if res != nil {
+		reply = res.Reply
+	}
+
+	mm := *m
+	mm.Envelope.RcptTo = rcpts
+	if _, err := c.Archive.Record(context.Background(), &mm, raw, reply, sendErr); err != nil {
+		c.Archive.logf("postman: archive: %v", err)
+	}
+}
+
+// retry sends the message until it is delivered, fails permanently, or
+// the attempts of the retry policy are exhausted.
+func (c *Client) retry(id, from string, rcpts []string, m *Mail, payload *payload) (*Result, error) {
+	backoff := c.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		if c.Tracker != nil {
+			c.Tracker.attempt(id)
+		}
+
+		res, err := c.send(from, rcpts, m, payload)
+		if err == nil || attempt >= c.Retry.Attempts || !isTemporary(err) {
+			return res, err
+		}
+
+		if c.Tracker != nil {
+			c.Tracker.done(id, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close closes the idle connections of the client. Messages being sent
+// keep their connections, which are pooled again afterwards.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mu.Unlock()
+
+	var first error
+	for _, conn := range idle {
+		if err := c.quit(conn); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// prepare returns a copy of m with the fields which would otherwise be
+// generated at rendering time fixed, so that every attempt sends the
+// same message.
+func (c *Client) prepare(m *Mail) (*Mail, error) {
+	mm := *m
+
+	// The identity is found from the default From field.
+	if c.Defaults != nil {
+		c.Defaults.apply(&mm)
+	}
+
+	if id := c.identity(&mm); id != nil {
+		id.apply(&mm)
+	}
+
+	if mm.Mailer == "" {
+		mm.Mailer = c.Mailer
+	}
+
+	if mm.Date.IsZero() {
+		mm.Date = time.Now()
+	}
+
+	if mm.MessageID == "" {
+		id, err := genMsgID()
+		if err != nil {
+			return nil, err
+		}
+		mm.MessageID = id
+	}
+
+	return &mm, nil
+}
+
+// payload is a message rendered once for all its delivery attempts, to
+// be signed: in memory, or in a temporary file when its attachments are
+// spooled.
+type payload struct {
+	signatures []byte
+	raw        []byte
+	file       *os.File
+}
+
+func (p *payload) writeTo(w io.Writer) error {
+	if _, err := w.Write(p.signatures); err != nil {
+		return err
+	}
+
+	if p.file == nil {
+		_, err := w.Write(p.raw)
+		return err
+	}
+
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, p.file)
+	return err
+}
+
+// bytes returns the payload as it is sent.
+func (p *payload) bytes() ([]byte, error) {
+	var b bytes.Buffer
+	if err := p.writeTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// close removes the file of the payload, if any.
+func (p *payload) close() {
+	if p.file != nil {
+		p.file.Close()
+		os.Remove(p.file.Name())
+	}
+}
+
+// sign renders m and signs it with the currently valid DKIM signers
+// aligned with its author and those of its identity. It returns nil if
+// there is none and the message is not archived, in which case the
+// message is streamed as it is rendered.
+func (c *Client) sign(m *Mail) (*payload, error) {
+	signers := c.signers(m)
+	if len(signers) == 0 && c.Archive == nil {
+		return nil, nil
+	}
+
+	if c.Spool != nil && m.spooled() {
+		return c.Spool.sign(m, signers)
+	}
+
+	raw, err := m.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &payload{raw: raw}
+	for _, s := range signers {
+		field, err := s.Sign(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.signatures = append(p.signatures, field...)
+	}
+
+	return p, nil
+}
+
+// signers returns the DKIM signers of m.
+func (c *Client) signers(m *Mail) []*DKIMSigner {
+	var signers []*DKIMSigner
+	for _, s := range c.DKIM {
+		if s.aligned(m.From) {
+			signers = append(signers, s)
+		}
+	}
+
+	if id := c.identity(m); id != nil {
+		signers = append(signers, id.DKIM...)
+	}
+
+	return currentSigners(signers, time.Now())
+}
+
+func (c *Client) send(from string, rcpts []string, m *Mail, payload *payload) (*Result, error) {
+	base := c.basePolicy()
+	p, err := c.policy(m, rcpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *smtp.Client
+	if base.mode == TLSDisabled && p.mode != TLSDisabled {
+		// Encrypted connections are still good enough for any message
+		// afterwards, and are pooled.
+		conn, err = c.dialSession(p, true)
+	} else {
+		conn, err = c.conn()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.mode == TLSRequired && base.mode == TLSOpportunistic {
+		// Opportunistic sessions are not encrypted when the server does
+		// not support STARTTLS.
+		if _, ok := conn.TLSConnectionState(); !ok {
+			c.put(conn)
+			return nil, errors.New("postman: server does not support STARTTLS")
+		}
+	}
+
+	res, err := transaction(conn, from, rcpts, m, payload)
+	if authExpired(err) && c.expireAuth() {
+		// The session outlived the token it was authenticated with.
+		c.close(conn)
+		if conn, err = c.dialSession(p, true); err != nil {
+			return nil, err
+		}
+		res, err = transaction(conn, from, rcpts, m, payload)
+	}
+
+	if err != nil && !recoverable(err) {
+		c.close(conn)
+		return res, err
+	}
+
+	c.put(conn)
+
+	return res, err
+}
+
+// recoverable reports whether the session survives the failure of a
+// transaction, the server having rejected one of its commands: the
+// connection is reset before being reused. Other errors, such as network
+// or rendering errors, may leave the session in any state.
+func recoverable(err error) bool {
+	switch e := err.(type) {
+	case *textproto.Error:
+		// 421 means the server is closing the connection.
+		return e.Code != 421
+	case *DeliverByError:
+		return true
+	}
+	return false
+}
+
+// transaction sends a message and returns the replies of the server to
+// its data. The DATA command is handled here rather than by smtp.Client,
+// which discards them.
+func transaction(conn *smtp.Client, from string, rcpts []string, m *Mail, payload *payload) (*Result, error) {
+	var params []string
+	if m.Envelope.DeliverBy > 0 {
+		by, err := deliverByParam(conn, &m.Envelope)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, by)
+	}
+
+	prdr, _ := conn.Extension("PRDR")
+	if prdr {
+		params = append(params, "PRDR")
+	}
+
+	if err := mailFrom(conn, from, params...); err != nil {
+		return nil, err
+	}
+
+	for _, rcpt := range rcpts {
+		if err := conn.Rcpt(rcpt); err != nil {
+			return nil, err
+		}
+	}
+
+	text := conn.Text
+
+	id, err := text.Cmd("DATA")
+	if err != nil {
+		return nil, err
+	}
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(354)
+	text.EndResponse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	wc := newDataWriter(text.W)
+	if payload != nil {
+		err = payload.writeTo(wc)
+	} else {
+		_, err = m.WriteTo(wc)
+	}
+	if err != nil {
+		// Ending the data would have the server accept a truncated
+		// message: the error is not recoverable and the connection is
+		// dropped in the middle of the transaction, which aborts it.
+		return nil, err
+	}
+
+	if err := wc.Close(); err != nil {
+		return nil, err
+	}
+
+	if prdr {
+		return readPRDR(text, rcpts)
+	}
+
+	_, reply, err := text.ReadResponse(250)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Reply: reply}, nil
+}
+
+// mailFrom sends the MAIL command with the parameters smtp.Client adds for
+// the extensions of the server, followed by params.
+func mailFrom(conn *smtp.Client, from string, params ...string) error {
+	if strings.ContainsAny(from, "\r\n") {
+		return errors.New("smtp: A line must not contain CR or LF")
+	}
+
+	// Extension sends EHLO if it has not been yet.
+	var ext []string
+	if ok, _ := conn.Extension("8BITMIME"); ok {
+		ext = append(ext, "BODY=8BITMIME")
+	}
+	if ok, _ := conn.Extension("SMTPUTF8"); ok {
+		ext = append(ext, "SMTPUTF8")
+	}
+	params = append(ext, params...)
+
+	cmd := "MAIL FROM:<" + from + ">"
+	if len(params) > 0 {
+		cmd += " " + strings.Join(params, " ")
+	}
+
+	text := conn.Text
+
+	id, err := text.Cmd("%s", cmd)
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(250)
+	text.EndResponse(id)
+
+	return err
+}
+
+// conn returns an idle connection, or a new one when there is none left
+// in a usable state. Idle connections are reset with RSET, which aborts
+// any transaction left over by the previous message and checks that the
+// session is still alive.
+func (c *Client) conn() (*smtp.Client, error) {
+	for {
+		c.mu.Lock()
+		if len(c.idle) == 0 {
+			c.mu.Unlock()
+			return c.dial()
+		}
+		conn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		c.mu.Unlock()
+
+		if err := conn.Reset(); err == nil {
+			return conn, nil
+		}
+		c.close(conn)
+	}
+}
+
+func (c *Client) put(conn *smtp.Client) {
+	c.mu.Lock()
+	if len(c.idle) < c.PoolSize {
+		c.idle = append(c.idle, conn)
+		conn = nil
+	}
+	c.mu.Unlock()
+
+	if conn != nil {
+		c.quit(conn)
+	}
+}
+
+// opened records a new connection.
+func (c *Client) opened(conn *smtp.Client, authed bool) {
+	c.mu.Lock()
+	if c.open == nil {
+		c.open = make(map[*smtp.Client]bool)
+	}
+	c.open[conn] = authed
+	c.mu.Unlock()
+}
+
+// close closes a connection, without ending the session.
+func (c *Client) close(conn *smtp.Client) error {
+	c.mu.Lock()
+	delete(c.open, conn)
+	c.mu.Unlock()
+
+	return conn.Close()
+}
+
+// quit ends the session of a connection and closes it.
+func (c *Client) quit(conn *smtp.Client) error {
+	c.mu.Lock()
+	delete(c.open, conn)
+	c.mu.Unlock()
+
+	return conn.Quit()
+}
+
+func (c *Client) dial() (*smtp.Client, error) {
+	return c.dialSession(c.basePolicy(), true)
+}
+
+// dialSession opens a new session encrypted as p tells, authenticating it
+// again with a fresh token if reauth is set and the server rejects the
+// first one.
+func (c *Client) dialSession(p connPolicy, reauth bool) (*smtp.Client, error) {
+	host, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	nc, err := c.dialConn(context.Background(), c.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ProxyProtocol != 0 {
+		if err := writeProxyHeader(nc, c.ProxyProtocol); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	if p.mode == TLSImplicit {
+		cfg, err := c.tlsConfig(host, port, p)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		tc := tls.Client(nc, cfg)
+		if err := tc.Handshake(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+
+	conn, err := smtp.NewClient(nc, host)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	if err := c.startTLS(conn, host, port, p); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if c.XClient != nil {
+		if err := sendXClient(conn, c.XClient); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if c.Auth != nil {
+		auth := c.Auth
+		if a, ok := auth.(interface{ session() smtp.Auth }); ok {
+			// The mechanism keeps the state of the authentication.
+			auth = a.session()
+		}
+
+		if err := conn.Auth(auth); err != nil {
+			// smtp.Client ends the session when the authentication
+			// fails.
+			conn.Close()
+			if reauth && authExpired(err) && c.expireAuth() {
+				return c.dialSession(p, false)
+			}
+			return nil, err
+		}
+	}
+
+	c.opened(conn, c.Auth != nil)
+
+	return conn, nil
+}
+
+func (c *Client) startTLS(conn *smtp.Client, host, port string, p connPolicy) error {
+	if p.mode == TLSImplicit || p.mode == TLSDisabled {
+		return nil
+	}
+
+	if ok, _ := conn.Extension("STARTTLS"); !ok {
+		if p.mode == TLSRequired {
+			return errors.New("postman: server does not support STARTTLS")
+		}
+		return nil
+	}
+
+	cfg, err := c.tlsConfig(host, port, p)
+	if err != nil {
+		return err
+	}
+
+	return conn.StartTLS(cfg)
+}
+
+// tlsConfig returns the configuration encrypting connections to host as
+// p tells, which shares the session cache of the client, unless TLSConfig
+// has its own, so that reconnections resume the previous sessions rather
+// than going through full handshakes, and checks the pins and the TLSA
+// records. Strict connections have no session cache: resumed sessions are
+// not verified again.
+func (c *Client) tlsConfig(host, port string, p connPolicy) (*tls.Config, error) {
+	var cfg *tls.Config
+	if c.TLSConfig != nil {
+		cfg = c.TLSConfig.Clone()
+	} else {
+		cfg = &tls.Config{ServerName: host}
+	}
+
+	if cfg.ClientSessionCache == nil && !cfg.SessionTicketsDisabled {
+		c.mu.Lock()
+		if c.sessions == nil {
+			c.sessions = tls.NewLRUClientSessionCache(0)
+		}
+		cfg.ClientSessionCache = c.sessions
+		c.mu.Unlock()
+	}
+
+	if p.dane {
+		records, err := c.tlsaRecords(host, port)
+		if err != nil {
+			return nil, err
+		}
+
+		// The TLSA records replace the certificate authorities.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = daneVerifier(host, records, cfg.VerifyPeerCertificate)
+	}
+
+	if len(p.pins) > 0 {
+		cfg.VerifyPeerCertificate = pinVerifier(p.pins, cfg.VerifyPeerCertificate)
+	}
+
+	return cfg, nil
+}
+
+func isTemporary(err error) bool {
+	switch e := err.(type) {
+	case *textproto.Error:
+		return e.Code >= 400 && e.Code < 500
+	case net.Error:
+		return true
+	case *QuotaError:
+		return e.Temporary()
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func isPermanent(err error) bool {
+	e, ok := err.(*textproto.Error)
+	return ok && e.Code >= 500
+}
+
+func defaultMailer() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "postman"
+	}
+
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		version = ""
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				break
+			}
+		}
+	}
+
+	if version == "" || version == "(devel)" {
+		return "postman"
+	}
+
+	return "postman/" + version
+}
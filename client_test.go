@@ -0,0 +1,126 @@
+package postman
+
+import (
+	"fmt"
+	"net/textproto"
+	"sync"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	m := benchMail(2)
+	m.Bcc = []string{"bcc@example.com"}
+	if err := c.Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(msgs))
+	}
+
+	want := []string{"to0@example.com", "to1@example.com", "cc0@example.com", "cc1@example.com", "bcc@example.com"}
+	got := msgs[0]
+	if got.From != "sender@example.com" {
+		t.Errorf("MAIL FROM %q, want %q", got.From, "sender@example.com")
+	}
+	if len(got.Rcpts) != len(want) {
+		t.Fatalf("RCPT TO %q, want %q", got.Rcpts, want)
+	}
+	for i := range want {
+		if got.Rcpts[i] != want[i] {
+			t.Errorf("RCPT TO %q, want %q", got.Rcpts, want)
+			break
+		}
+	}
+}
+
+// TestSendConcurrent sends the same message from many goroutines over a
+// pool of connections; run it with -race.
+func TestSendConcurrent(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr,
+		WithPool(4),
+		WithDefaults(&Defaults{
+			Header:  textproto.MIMEHeader{"X-Default": {"yes"}},
+			Footers: []Footer{{Text: "-- \nFooter"}},
+		}),
+	)
+	defer c.Close()
+
+	m := benchMail(2)
+	m.MessageID = ""
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if i%2 == 0 {
+				errs <- c.Send(m)
+				return
+			}
+
+			mm := *m
+			mm.Subject = fmt.Sprintf("Message %d", i)
+			errs <- c.Send(&mm)
+			c.Stats()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if stats := c.Stats(); stats.InFlight != 0 || stats.Idle > 4 {
+		t.Errorf("Stats() = %+v, want no message in flight and at most 4 idle connections", stats)
+	}
+	if m.MessageID != "" || len(m.Header) != 0 || len(m.Parts[0].Content) != len(benchMail(2).Parts[0].Content) {
+		t.Error("Send modified the message")
+	}
+
+	c.Close()
+	s.Close()
+
+	if got := len(s.Messages()); got != n {
+		t.Errorf("server received %d messages, want %d", got, n)
+	}
+}
+
+func benchmarkSend(b *testing.B, n int) {
+	s := newTestServer(b)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	m := benchMail(n)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSend1(b *testing.B)   { benchmarkSend(b, 1) }
+func BenchmarkSend100(b *testing.B) { benchmarkSend(b, 100) }
@@ -0,0 +1,80 @@
+// Command postman composes and sends email messages.
+//
+// Usage:
+//
+//	postman <command> [flags]
+//
+// Run "postman <command> -h" for the flags of a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"send", "compose a message and deliver it", runSend},
+	{"preview", "compose a message and show it without sending", runPreview},
+	{"validate", "check a message before sending it", runValidate},
+	{"serve", "deliver the messages submitted to a REST API", runServe},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("postman: ")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := flag.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(flag.Args()[1:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	log.Printf("unknown command %q", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: postman <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// stringList is a flag which may be repeated or given a comma separated
+// list of values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jobteaser/postman"
+)
+
+func runPreview(args []string) error {
+	var cf composeFlags
+
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	cf.register(fs)
+	addr := fs.String("http", "", "serve the preview on `address` instead of writing it to stdout")
+	export := fs.String("o", "", "write a standalone HTML preview to `file` instead of writing the message to stdout")
+	fs.Parse(args)
+
+	m, err := cf.mail()
+	if err != nil {
+		return err
+	}
+
+	if *export != "" {
+		return m.ExportPreviewHTML(*export)
+	}
+
+	if *addr == "" {
+		_, err := m.WriteTo(os.Stdout)
+		return err
+	}
+
+	raw, err := m.String()
+	if err != nil {
+		return err
+	}
+
+	h, err := previewHandler(m, raw)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("preview available on http://%s/", *addr)
+
+	return http.ListenAndServe(*addr, h)
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Mail.Subject}}</title></head>
+<body>
+<table>
+<tr><th align="left">From</th><td>{{.Mail.From}}</td></tr>
+<tr><th align="left">To</th><td>{{range $i, $a := .Mail.To}}{{if $i}}, {{end}}{{$a}}{{end}}</td></tr>
+{{if .Mail.Cc}}<tr><th align="left">Cc</th><td>{{range $i, $a := .Mail.Cc}}{{if $i}}, {{end}}{{$a}}{{end}}</td></tr>{{end}}
+<tr><th align="left">Subject</th><td>{{.Mail.Subject}}</td></tr>
+{{range .Attachments}}<tr><th align="left">Attachment</th><td>{{.Name}} ({{.Size}} bytes)</td></tr>{{end}}
+</table>
+<p><a href="/raw">Message source</a></p>
+<hr>
+{{if .HTML}}<iframe sandbox srcdoc="{{.HTML}}" style="width:100%;height:80vh;border:0"></iframe>{{else}}<pre>{{.Text}}</pre>{{end}}
+</body>
+</html>
+`))
+
+func previewHandler(m *postman.Mail, raw string) (http.Handler, error) {
+	var text, html string
+	for _, p := range m.Parts {
+		switch {
+		case strings.HasPrefix(p.ContentType, "text/html"):
+			html = string(p.Content)
+		case text == "":
+			text = string(p.Content)
+		}
+	}
+
+	// The size of the attachments given with --attach is that of their
+	// file.
+	type attachment struct {
+		Name string
+		Size int64
+	}
+	attachments := make([]attachment, len(m.Attachments))
+	for i, a := range m.Attachments {
+		attachments[i] = attachment{Name: a.Filename, Size: int64(len(a.Content))}
+		if a.Content == nil && a.File != "" {
+			info, err := os.Stat(a.File)
+			if err != nil {
+				return nil, err
+			}
+			attachments[i].Size = info.Size()
+		}
+	}
+
+	var page bytes.Buffer
+	err := previewPage.Execute(&page, map[string]interface{}{
+		"Mail":        m,
+		"Attachments": attachments,
+		"Text":        text,
+		"HTML":        html,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	})
+
+	mux.HandleFunc("/raw", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(raw))
+	})
+
+	return mux, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	texttemplate "text/template"
+
+	"github.com/jobteaser/postman"
+)
+
+// composeFlags are the flags describing a message, shared by the
+// commands composing one.
+type composeFlags struct {
+	from        string
+	to, cc, bcc stringList
+	replyTo     string
+	subject     string
+	body        string
+	html        string
+	contentType string
+	attachments stringList
+	data        string
+}
+
+func (f *composeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.from, "from", "", "author `address`")
+	fs.Var(&f.to, "to", "recipient `address`es, repeatable")
+	fs.Var(&f.cc, "cc", "carbon copy `address`es, repeatable")
+	fs.Var(&f.bcc, "bcc", "blind carbon copy `address`es, repeatable")
+	fs.StringVar(&f.replyTo, "reply-to", "", "reply `address`")
+	fs.StringVar(&f.subject, "subject", "", "message subject")
+	fs.StringVar(&f.body, "body", "-", "text body `file`, - for stdin")
+	fs.StringVar(&f.html, "html", "", "HTML alternative body `file`")
+	fs.StringVar(&f.contentType, "content-type", "text/plain; charset=utf-8", "content type of the text body")
+	fs.Var(&f.attachments, "attach", "attachment `file`s, repeatable")
+	fs.StringVar(&f.data, "data", "", "JSON data `file`; subject and bodies are then templates executed with it")
+}
+
+func (f *composeFlags) mail() (*postman.Mail, error) {
+	m := &postman.Mail{
+		From:    f.from,
+		To:      f.to,
+		Cc:      f.cc,
+		Bcc:     f.bcc,
+		ReplyTo: f.replyTo,
+		Subject: f.subject,
+	}
+
+	body, err := readFile(f.body)
+	if err != nil {
+		return nil, err
+	}
+
+	var html []byte
+	if f.html != "" {
+		if html, err = readFile(f.html); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.data != "" {
+		if err := f.execute(m, body, html); err != nil {
+			return nil, err
+		}
+	} else {
+		m.Parts = append(m.Parts, postman.Part{ContentType: f.contentType, Content: body})
+
+		if html != nil {
+			m.Parts = append(m.Parts, postman.Part{
+				ContentType: "text/html",
+				Params:      map[string]string{"charset": "utf-8"},
+				Content:     html,
+			})
+		}
+	}
+
+	// Attachments are streamed from their files when the message is
+	// written rather than read in memory.
+	for _, path := range f.attachments {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s: is a directory", path)
+		}
+
+		m.Attachments = append(m.Attachments, postman.Attachment{
+			Filename: filepath.Base(path),
+			File:     path,
+		})
+	}
+
+	return m, nil
+}
+
+func (f *composeFlags) execute(m *postman.Mail, body, html []byte) error {
+	raw, err := ioutil.ReadFile(f.data)
+	if err != nil {
+		return err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%s: %v", f.data, err)
+	}
+
+	var t postman.Template
+
+	if t.Subject, err = texttemplate.New("subject").Parse(f.subject); err != nil {
+		return err
+	}
+
+	if t.Text, err = texttemplate.New("text").Parse(string(body)); err != nil {
+		return err
+	}
+
+	if html != nil {
+		if t.HTML, err = htmltemplate.New("html").Parse(string(html)); err != nil {
+			return err
+		}
+	}
+
+	return t.Execute(m, data)
+}
+
+// transportFlags are the flags configuring the delivery of messages.
+// They take precedence over the environment, which takes precedence
+// over the configuration file.
+type transportFlags struct {
+	fs       *flag.FlagSet
+	config   string
+	addr     string
+	username string
+	password string
+}
+
+func (f *transportFlags) register(fs *flag.FlagSet) {
+	f.fs = fs
+	fs.StringVar(&f.config, "config", os.Getenv(postman.EnvConfig), "client configuration `file`, defaults to $"+postman.EnvConfig)
+	fs.StringVar(&f.addr, "smtp", "localhost:25", "SMTP server `address`")
+	fs.StringVar(&f.username, "username", "", "SMTP username, enables authentication")
+	fs.StringVar(&f.password, "password", "", "SMTP password, better given as $"+postman.EnvPassword)
+}
+
+func (f *transportFlags) transport() (postman.Transport, error) {
+	cfg := &postman.Config{Host: "localhost"}
+
+	if f.config != "" {
+		var err error
+		if cfg, err = postman.LoadConfig(f.config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "smtp":
+			var host, port string
+			if host, port, err = net.SplitHostPort(f.addr); err == nil {
+				cfg.Host = host
+				cfg.Port, err = strconv.Atoi(port)
+			}
+		case "username", "password":
+			if cfg.Auth == nil {
+				cfg.Auth = new(postman.AuthConfig)
+			}
+			if fl.Name == "username" {
+				cfg.Auth.Username = f.username
+			} else {
+				cfg.Auth.Password = f.password
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("-smtp: %v", err)
+	}
+
+	return cfg.NewClient()
+}
+
+func runSend(args []string) error {
+	var (
+		cf composeFlags
+		tf transportFlags
+	)
+
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	cf.register(fs)
+	tf.register(fs)
+	fs.Parse(args)
+
+	m, err := cf.mail()
+	if err != nil {
+		return err
+	}
+
+	if m.From == "" {
+		return errors.New("missing -from")
+	}
+
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return errors.New("missing recipient")
+	}
+
+	t, err := tf.transport()
+	if err != nil {
+		return err
+	}
+
+	if c, ok := t.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	return t.Send(m)
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jobteaser/postman"
+	"github.com/jobteaser/postman/postmanpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	envAPIToken      = "POSTMAN_API_TOKEN"
+	envWebhookSecret = "POSTMAN_WEBHOOK_SECRET"
+)
+
+func runServe(args []string) error {
+	var tf transportFlags
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	tf.register(fs)
+	addr := fs.String("http", "localhost:8080", "listen `address` of the REST API")
+	workers := fs.Int("workers", 1, "number of messages delivered concurrently")
+	grpcAddr := fs.String("grpc", "", "also serve the gRPC service on `address`")
+	token := fs.String("token", os.Getenv(envAPIToken), "bearer token required from API clients, defaults to $"+envAPIToken)
+	webhook := fs.String("webhook", "", "post delivery events to `URL`, signed with $"+envWebhookSecret)
+	fs.Parse(args)
+
+	t, err := tf.transport()
+	if err != nil {
+		return err
+	}
+
+	if c, ok := t.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	q := postman.NewQueue(t)
+	q.Workers = *workers
+
+	if *webhook != "" {
+		wh := &postman.Webhook{URL: *webhook, Secret: []byte(os.Getenv(envWebhookSecret))}
+		defer wh.Follow(q.Tracker, func(err error) { log.Print(err) })()
+	}
+
+	h := postman.NewHandler(q)
+	if *token != "" {
+		h = requireToken(h, *token)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: h}
+
+	var gs *grpc.Server
+	if *grpcAddr != "" {
+		l, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+
+		var opts []grpc.ServerOption
+		if *token != "" {
+			opts = append(opts, grpc.UnaryInterceptor(unaryToken(*token)), grpc.StreamInterceptor(streamToken(*token)))
+		}
+
+		gs = grpc.NewServer(opts...)
+		postmanpb.RegisterPostmanServer(gs, postmanpb.NewServer(q))
+
+		log.Printf("serving gRPC on %s", *grpcAddr)
+		go gs.Serve(l)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		if gs != nil {
+			gs.Stop()
+		}
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Printf("serving on http://%s/messages", *addr)
+
+	err = srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+
+	cancel()
+	<-done
+
+	if n := q.Len(); n > 0 {
+		log.Printf("%d messages left unsent", n)
+	}
+
+	return err
+}
+
+func requireToken(h http.Handler, token string) http.Handler {
+	want := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func unaryToken(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamToken(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(v), []byte("Bearer "+token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
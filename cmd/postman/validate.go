@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jobteaser/postman"
+)
+
+var errInvalid = errors.New("invalid message")
+
+type report struct {
+	Valid  bool            `json:"valid"`
+	Issues []postman.Issue `json:"issues"`
+	DKIM   []dkimReport    `json:"dkim,omitempty"`
+	SPF    *spfReport      `json:"spf,omitempty"`
+}
+
+type dkimReport struct {
+	Domain   string `json:"domain,omitempty"`
+	Selector string `json:"selector,omitempty"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+type spfReport struct {
+	Domain string            `json:"domain"`
+	IP     string            `json:"ip"`
+	Result postman.SPFResult `json:"result"`
+	Error  string            `json:"error,omitempty"`
+}
+
+func runValidate(args []string) error {
+	var cf composeFlags
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: postman validate [flags] [file.eml]\n\n")
+		fs.PrintDefaults()
+	}
+	cf.register(fs)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	checkDKIM := fs.Bool("dkim", false, "verify DKIM signatures")
+	checkSPF := fs.Bool("spf", false, "check the SPF policy of the sender domain for -ip")
+	ip := fs.String("ip", "", "`address` the message is sent from, for -spf")
+	checkBIMI := fs.Bool("bimi", false, "check the BIMI prerequisites of the author domain")
+	fs.Parse(args)
+
+	var (
+		m   *postman.Mail
+		raw []byte
+		err error
+	)
+
+	if fs.NArg() > 0 {
+		if raw, err = ioutil.ReadFile(fs.Arg(0)); err != nil {
+			return err
+		}
+		if m, err = postman.ParseMail(strings.NewReader(string(raw))); err != nil {
+			return err
+		}
+	} else {
+		if m, err = cf.mail(); err != nil {
+			return err
+		}
+		if raw, err = m.Bytes(); err != nil {
+			return err
+		}
+	}
+
+	r := report{Issues: append(postman.Lint(m), postman.LintRaw(raw)...)}
+	r.Valid = !postman.HasErrors(r.Issues)
+
+	var signingDomains []string
+
+	if *checkDKIM || *checkBIMI {
+		results, err := postman.VerifyDKIM(raw)
+		if err != nil && *checkDKIM {
+			r.DKIM = append(r.DKIM, dkimReport{Error: err.Error()})
+			r.Valid = false
+		}
+		for _, res := range results {
+			if res.Err == nil {
+				signingDomains = append(signingDomains, res.Domain)
+			}
+			if !*checkDKIM {
+				continue
+			}
+			dr := dkimReport{Domain: res.Domain, Selector: res.Selector, Valid: res.Err == nil}
+			if res.Err != nil {
+				dr.Error = res.Err.Error()
+				r.Valid = false
+			}
+			r.DKIM = append(r.DKIM, dr)
+		}
+	}
+
+	if *checkBIMI {
+		var selector string
+		for _, tag := range strings.Split(m.Header.Get("BIMI-Selector"), ";") {
+			if tag = strings.TrimSpace(tag); strings.HasPrefix(tag, "s=") {
+				selector = strings.TrimSpace(tag[2:])
+			}
+		}
+		r.Issues = append(r.Issues, postman.CheckBIMI(m.From, selector, signingDomains)...)
+		r.Valid = r.Valid && !postman.HasErrors(r.Issues)
+	}
+
+	if *checkSPF {
+		sr, err := checkSender(m, *ip)
+		if err != nil {
+			return err
+		}
+		switch sr.Result {
+		case postman.SPFFail, postman.SPFSoftFail, postman.SPFPermError:
+			r.Valid = false
+		}
+		r.SPF = sr
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	} else {
+		printReport(&r)
+	}
+
+	if !r.Valid {
+		return errInvalid
+	}
+
+	return nil
+}
+
+func checkSender(m *postman.Mail, ip string) (*spfReport, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, errors.New("-spf requires a valid -ip")
+	}
+
+	from, err := m.ReversePath()
+	if err != nil {
+		return nil, err
+	}
+
+	domain := from[strings.LastIndexByte(from, '@')+1:]
+	if domain == "" {
+		return nil, errors.New("no sender domain to check")
+	}
+
+	result, err := postman.CheckSPF(addr, domain)
+
+	sr := &spfReport{Domain: domain, IP: addr.String(), Result: result}
+	if err != nil {
+		sr.Error = err.Error()
+	}
+
+	return sr, nil
+}
+
+func printReport(r *report) {
+	for _, i := range r.Issues {
+		fmt.Println(i)
+	}
+
+	for _, d := range r.DKIM {
+		switch {
+		case d.Valid:
+			fmt.Printf("dkim: %s (%s): pass\n", d.Domain, d.Selector)
+		case d.Domain != "":
+			fmt.Printf("dkim: %s (%s): %s\n", d.Domain, d.Selector, d.Error)
+		default:
+			fmt.Printf("dkim: %s\n", d.Error)
+		}
+	}
+
+	if r.SPF != nil {
+		fmt.Printf("spf: %s from %s: %s", r.SPF.Domain, r.SPF.IP, r.SPF.Result)
+		if r.SPF.Error != "" {
+			fmt.Printf(" (%s)", r.SPF.Error)
+		}
+		fmt.Println()
+	}
+
+	if r.Valid {
+		fmt.Println("valid")
+	}
+}
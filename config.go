@@ -0,0 +1,550 @@
+package postman
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of a Client, as loaded from a YAML file:
+//
+//	host: smtp.example.com
+//	port: 587
+//	tls: required
+//	tls_pins:
+//	  - sha256/jcF2kM3b4pLyzAS9rS8Ex5wF4xEuUoqMk7vJWQawrXk=
+//	tls_policies:
+//	  bank.example: required
+//	  .partner.example: required
+//	auth:
+//	  mechanism: plain
+//	  username: postman
+//	  password: secret
+//	pool_size: 4
+//	defaults:
+//	  reply_to: Support <support@example.com>
+//	  header:
+//	    X-Entity-Ref-ID: postman
+//	  footers:
+//	    - text: You receive this email as a customer of Example.
+//	      html: <p>You receive this email as a customer of Example.</p>
+//	proxy_protocol: 2
+//	retry:
+//	  attempts: 3
+//	  backoff: 1s
+//	dkim:
+//	  - domain: example.com
+//	    selector: s1
+//	    key_file: /etc/postman/s1.pem
+//	    not_after: 2021-07-01T00:00:00Z
+//	  - domain: example.com
+//	    selector: s2
+//	    key_file: /etc/postman/s2.pem
+//	    not_before: 2021-07-01T00:00:00Z
+//	identities:
+//	  - domain: customer.example
+//	    from: Customer <hello@customer.example>
+//	    mail_from: bounces+customer@example.com
+//	    dkim:
+//	      - selector: postman
+//	        key_file: /etc/postman/customer.pem
+type Config struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+
+	// TLS is one of "opportunistic", the default, "required",
+	// "implicit" or "disabled".
+	TLS string `yaml:"tls"`
+
+	// TLSPins are the pins of the certificate of the server, as
+	// Client.Pins describes; with TLSPinsOnly, the certificate is not
+	// verified against certificate authorities.
+	TLSPins     []string `yaml:"tls_pins"`
+	TLSPinsOnly bool     `yaml:"tls_pins_only"`
+
+	// TLSPolicies maps destinations to TLS policies, as ParseTLSPolicy
+	// parses them and Client.TLSPolicies describes. DNSSECResolver is the
+	// address of the resolver of DANE policies.
+	TLSPolicies    map[string]string `yaml:"tls_policies"`
+	DNSSECResolver string            `yaml:"dnssec_resolver"`
+
+	Auth *AuthConfig `yaml:"auth"`
+
+	// Mailer overrides DefaultMailer; "-" disables it.
+	Mailer string `yaml:"mailer"`
+
+	PoolSize int `yaml:"pool_size"`
+
+	// ProxyProtocol is the version of the PROXY protocol header sent on
+	// connections, 1 or 2; zero sends none.
+	ProxyProtocol int `yaml:"proxy_protocol"`
+
+	Retry RetryConfig `yaml:"retry"`
+
+	// Quota limits the messages of every sender, counted in memory.
+	Quota *QuotaConfig `yaml:"quota"`
+
+	// SpoolThreshold, if positive, is the total size of the attachments
+	// of a message, in bytes, above which they are spooled to temporary
+	// files in SpoolDir, or the default directory for temporary files.
+	SpoolThreshold int64  `yaml:"spool_threshold"`
+	SpoolDir       string `yaml:"spool_dir"`
+
+	Defaults *DefaultsConfig `yaml:"defaults"`
+
+	DKIM []DKIMConfig `yaml:"dkim"`
+
+	Identities []IdentityConfig `yaml:"identities"`
+}
+
+// AuthConfig configures the authentication of a Client.
+type AuthConfig struct {
+	// Mechanism is one of "plain", the default, "login", "cram-md5",
+	// "scram-sha-1" or "scram-sha-256".
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// DefaultsConfig configures the Defaults of a Client.
+type DefaultsConfig struct {
+	From    string            `yaml:"from"`
+	ReplyTo string            `yaml:"reply_to"`
+	Header  map[string]string `yaml:"header"`
+	Footers []FooterConfig    `yaml:"footers"`
+}
+
+// FooterConfig configures a Footer.
+type FooterConfig struct {
+	Text string `yaml:"text"`
+	HTML string `yaml:"html"`
+}
+
+func (d *DefaultsConfig) defaults() *Defaults {
+	defaults := &Defaults{From: d.From, ReplyTo: d.ReplyTo}
+
+	if len(d.Header) > 0 {
+		defaults.Header = make(textproto.MIMEHeader, len(d.Header))
+		for k, v := range d.Header {
+			defaults.Header.Set(k, v)
+		}
+	}
+
+	for _, f := range d.Footers {
+		defaults.Footers = append(defaults.Footers, Footer{Text: f.Text, HTML: f.HTML})
+	}
+
+	return defaults
+}
+
+// RetryConfig configures the RetryPolicy of a Client.
+type RetryConfig struct {
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+}
+
+// QuotaConfig configures a Quota.
+type QuotaConfig struct {
+	Messages int           `yaml:"messages"`
+	Bytes    int64         `yaml:"bytes"`
+	Window   time.Duration `yaml:"window"`
+	Defer    bool          `yaml:"defer"`
+}
+
+func (q *QuotaConfig) quota() (*Quota, error) {
+	if q.Window <= 0 {
+		return nil, fmt.Errorf("postman: quota without window")
+	}
+	return &Quota{Messages: q.Messages, Bytes: q.Bytes, Window: q.Window, Defer: q.Defer}, nil
+}
+
+// DKIMConfig configures a DKIMSigner.
+type DKIMConfig struct {
+	Domain   string `yaml:"domain"`
+	Selector string `yaml:"selector"`
+
+	// KeyFile is the path of the PEM encoded private key.
+	KeyFile string `yaml:"key_file"`
+
+	Headers []string `yaml:"headers"`
+
+	// Canonicalization is given as "header/body", e.g.
+	// "relaxed/simple"; the default is "relaxed/relaxed".
+	Canonicalization string `yaml:"canonicalization"`
+
+	// NotBefore and NotAfter are the validity window of the key, as
+	// RFC 3339 times, for keys to be rotated.
+	NotBefore time.Time `yaml:"not_before"`
+	NotAfter  time.Time `yaml:"not_after"`
+}
+
+// IdentityConfig configures an Identity.
+type IdentityConfig struct {
+	Domain   string `yaml:"domain"`
+	From     string `yaml:"from"`
+	Sender   string `yaml:"sender"`
+	MailFrom string `yaml:"mail_from"`
+
+	// BIMISelector is the selector of the BIMI record of the messages of
+	// the identity.
+	BIMISelector string `yaml:"bimi_selector"`
+
+	// Quota limits the messages of the identity instead of the quota of
+	// the client.
+	Quota *QuotaConfig `yaml:"quota"`
+
+	// DKIM are the keys of the identity; their domain defaults to the one
+	// of the identity.
+	DKIM []DKIMConfig `yaml:"dkim"`
+}
+
+// LoadConfig reads a YAML configuration file. Unknown keys, such as
+// mistyped ones, are errors rather than ignored.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("postman: %s: %v", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// NewClientFromConfig returns a client configured by the YAML file at
+// path.
+func NewClientFromConfig(path string) (*Client, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.NewClient()
+}
+
+// NewClient returns a client configured by cfg.
+func (cfg *Config) NewClient() (*Client, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("postman: missing host")
+	}
+
+	mode := TLSOpportunistic
+	if cfg.TLS != "" {
+		var err error
+		if mode, err = ParseTLSMode(cfg.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range cfg.TLSPins {
+		if _, err := parsePin(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.TLSPinsOnly && len(cfg.TLSPins) == 0 {
+		return nil, fmt.Errorf("postman: tls_pins_only without pins")
+	}
+
+	if cfg.ProxyProtocol < 0 || cfg.ProxyProtocol > 2 {
+		return nil, fmt.Errorf("postman: unknown PROXY protocol version %d", cfg.ProxyProtocol)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+		if mode == TLSImplicit {
+			port = 465
+		}
+	}
+
+	// IPv6 literals may be given with or without brackets.
+	host := strings.TrimSuffix(strings.TrimPrefix(cfg.Host, "["), "]")
+
+	var tlsConfig *tls.Config
+	if cfg.TLSPinsOnly {
+		tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: true}
+	}
+
+	c := NewClient(net.JoinHostPort(host, strconv.Itoa(port)),
+		WithTLS(mode, tlsConfig),
+		WithPins(cfg.TLSPins...),
+		WithPool(cfg.PoolSize),
+		WithProxyProtocol(cfg.ProxyProtocol),
+		WithRetry(RetryPolicy{Attempts: cfg.Retry.Attempts, Backoff: cfg.Retry.Backoff}))
+
+	if cfg.Quota != nil {
+		q, err := cfg.Quota.quota()
+		if err != nil {
+			return nil, err
+		}
+		c.Quota = q
+	}
+
+	for domain, s := range cfg.TLSPolicies {
+		p, err := ParseTLSPolicy(s)
+		if err != nil {
+			return nil, err
+		}
+		if c.TLSPolicies == nil {
+			c.TLSPolicies = make(TLSPolicyMap)
+		}
+		c.TLSPolicies[strings.ToLower(domain)] = p
+	}
+	c.DNSSECResolver = cfg.DNSSECResolver
+
+	if cfg.Defaults != nil {
+		c.Defaults = cfg.Defaults.defaults()
+	}
+
+	if cfg.SpoolThreshold > 0 {
+		c.Spool = &Spool{Threshold: cfg.SpoolThreshold, Dir: cfg.SpoolDir}
+	}
+
+	switch cfg.Mailer {
+	case "":
+	case "-":
+		c.Mailer = ""
+	default:
+		c.Mailer = cfg.Mailer
+	}
+
+	if a := cfg.Auth; a != nil {
+		switch strings.ToLower(a.Mechanism) {
+		case "", "plain":
+			c.Auth = smtp.PlainAuth("", a.Username, a.Password, host)
+		case "login":
+			c.Auth = LoginAuth(a.Username, a.Password, host)
+		case "cram-md5":
+			c.Auth = smtp.CRAMMD5Auth(a.Username, a.Password)
+		case "scram-sha-1":
+			c.Auth = ScramSHA1Auth(a.Username, a.Password)
+		case "scram-sha-256":
+			c.Auth = ScramSHA256Auth(a.Username, a.Password)
+		default:
+			return nil, fmt.Errorf("postman: unknown auth mechanism %q", a.Mechanism)
+		}
+	}
+
+	for _, d := range cfg.DKIM {
+		s, err := d.signer()
+		if err != nil {
+			return nil, err
+		}
+		c.DKIM = append(c.DKIM, s)
+	}
+
+	for _, i := range cfg.Identities {
+		id, err := i.identity()
+		if err != nil {
+			return nil, err
+		}
+		c.Identities = append(c.Identities, id)
+	}
+
+	return c, nil
+}
+
+func (i *IdentityConfig) identity() (*Identity, error) {
+	if i.Domain == "" {
+		return nil, fmt.Errorf("postman: identity without domain")
+	}
+
+	id := &Identity{
+		Domain:       i.Domain,
+		From:         i.From,
+		Sender:       i.Sender,
+		MailFrom:     i.MailFrom,
+		BIMISelector: i.BIMISelector,
+	}
+
+	if i.Quota != nil {
+		q, err := i.Quota.quota()
+		if err != nil {
+			return nil, err
+		}
+		id.Quota = q
+	}
+
+	for _, d := range i.DKIM {
+		if d.Domain == "" {
+			d.Domain = i.Domain
+		}
+
+		s, err := d.signer()
+		if err != nil {
+			return nil, err
+		}
+		id.DKIM = append(id.DKIM, s)
+	}
+
+	return id, nil
+}
+
+func (d *DKIMConfig) signer() (*DKIMSigner, error) {
+	if d.Domain == "" || d.Selector == "" {
+		return nil, fmt.Errorf("postman: DKIM key without domain or selector")
+	}
+
+	pem, err := ioutil.ReadFile(d.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseDKIMKey(pem)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, d.KeyFile)
+	}
+
+	c := d.Canonicalization
+	if c == "" {
+		c = "relaxed/relaxed"
+	}
+
+	hc, bc, err := parseDKIMCanonicalization(c)
+	if err != nil {
+		return nil, fmt.Errorf("postman: %v", err)
+	}
+
+	return &DKIMSigner{
+		Domain:                 d.Domain,
+		Selector:               d.Selector,
+		Key:                    key,
+		Headers:                d.Headers,
+		HeaderCanonicalization: hc,
+		BodyCanonicalization:   bc,
+		NotBefore:              d.NotBefore,
+		NotAfter:               d.NotAfter,
+	}, nil
+}
+
+// Environment variables read by LoadEnv.
+const (
+	EnvConfig         = "POSTMAN_CONFIG"
+	EnvHost           = "POSTMAN_SMTP_HOST"
+	EnvPort           = "POSTMAN_SMTP_PORT"
+	EnvTLS            = "POSTMAN_SMTP_TLS"
+	EnvAuthMechanism  = "POSTMAN_SMTP_AUTH"
+	EnvUsername       = "POSTMAN_SMTP_USERNAME"
+	EnvPassword       = "POSTMAN_SMTP_PASSWORD"
+	EnvMailer         = "POSTMAN_MAILER"
+	EnvPoolSize       = "POSTMAN_POOL_SIZE"
+	EnvProxyProtocol  = "POSTMAN_PROXY_PROTOCOL"
+	EnvRetryAttempts  = "POSTMAN_RETRY_ATTEMPTS"
+	EnvRetryBackoff   = "POSTMAN_RETRY_BACKOFF"
+	EnvDKIMDomain     = "POSTMAN_DKIM_DOMAIN"
+	EnvDKIMSelector   = "POSTMAN_DKIM_SELECTOR"
+	EnvDKIMKeyFile    = "POSTMAN_DKIM_KEY_FILE"
+	EnvDKIMCanonicals = "POSTMAN_DKIM_CANONICALIZATION"
+)
+
+// NewClientFromEnv returns a client configured by the environment. The
+// file named by POSTMAN_CONFIG is loaded first, if set, and the other
+// POSTMAN_ variables override its values.
+func NewClientFromEnv() (*Client, error) {
+	cfg := new(Config)
+
+	if path := os.Getenv(EnvConfig); path != "" {
+		var err error
+		if cfg, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	return cfg.NewClient()
+}
+
+// LoadEnv overrides the values of cfg with the POSTMAN_ variables set in
+// the environment. Setting POSTMAN_DKIM_KEY_FILE adds a DKIM key.
+func (cfg *Config) LoadEnv() error {
+	str := func(name string, v *string) {
+		if s, ok := os.LookupEnv(name); ok {
+			*v = s
+		}
+	}
+
+	num := func(name string, v *int) error {
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("postman: %s: %v", name, err)
+		}
+		*v = n
+		return nil
+	}
+
+	str(EnvHost, &cfg.Host)
+	str(EnvTLS, &cfg.TLS)
+	str(EnvMailer, &cfg.Mailer)
+
+	if err := num(EnvPort, &cfg.Port); err != nil {
+		return err
+	}
+
+	if err := num(EnvPoolSize, &cfg.PoolSize); err != nil {
+		return err
+	}
+
+	if err := num(EnvProxyProtocol, &cfg.ProxyProtocol); err != nil {
+		return err
+	}
+
+	if err := num(EnvRetryAttempts, &cfg.Retry.Attempts); err != nil {
+		return err
+	}
+
+	if s, ok := os.LookupEnv(EnvRetryBackoff); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("postman: %s: %v", EnvRetryBackoff, err)
+		}
+		cfg.Retry.Backoff = d
+	}
+
+	for _, name := range []string{EnvAuthMechanism, EnvUsername, EnvPassword} {
+		if _, ok := os.LookupEnv(name); ok && cfg.Auth == nil {
+			cfg.Auth = new(AuthConfig)
+		}
+	}
+
+	if cfg.Auth != nil {
+		str(EnvAuthMechanism, &cfg.Auth.Mechanism)
+		str(EnvUsername, &cfg.Auth.Username)
+		str(EnvPassword, &cfg.Auth.Password)
+	}
+
+	if path, ok := os.LookupEnv(EnvDKIMKeyFile); ok {
+		d := DKIMConfig{
+			Domain:           os.Getenv(EnvDKIMDomain),
+			Selector:         os.Getenv(EnvDKIMSelector),
+			KeyFile:          path,
+			Canonicalization: os.Getenv(EnvDKIMCanonicals),
+		}
+		cfg.DKIM = append(cfg.DKIM, d)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package postman
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a file in dir and returns its path.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg, err := LoadConfig(writeFile(t, dir, "postman.yaml", `
+host: smtp.example.com
+port: 587
+tls: required
+tls_pins:
+  - sha256/jcF2kM3b4pLyzAS9rS8Ex5wF4xEuUoqMk7vJWQawrXk=
+auth:
+  mechanism: plain
+  username: postman
+  password: secret
+defaults:
+  header:
+    X-Entity-Ref-ID: postman
+retry:
+  attempts: 3
+  backoff: 1s
+dkim:
+  - domain: example.com
+    selector: s2
+    key_file: /etc/postman/s2.pem
+    not_before: 2021-07-01T00:00:00Z
+identities:
+  - domain: customer.example
+    mail_from: bounces+customer@example.com
+    dkim:
+      - selector: postman
+        key_file: /etc/postman/customer.pem
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Host != "smtp.example.com" || cfg.Port != 587 || cfg.TLS != "required" || len(cfg.TLSPins) != 1 {
+		t.Errorf("got %+v", cfg)
+	}
+	if cfg.Auth == nil || cfg.Auth.Username != "postman" || cfg.Auth.Password != "secret" {
+		t.Errorf("auth: %+v", cfg.Auth)
+	}
+	if cfg.Defaults == nil || cfg.Defaults.Header["X-Entity-Ref-ID"] != "postman" {
+		t.Errorf("defaults: %+v", cfg.Defaults)
+	}
+	if cfg.Retry.Attempts != 3 || cfg.Retry.Backoff != time.Second {
+		t.Errorf("retry: %+v", cfg.Retry)
+	}
+	if len(cfg.DKIM) != 1 || !cfg.DKIM[0].NotBefore.Equal(time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("dkim: %+v", cfg.DKIM)
+	}
+	if len(cfg.Identities) != 1 || len(cfg.Identities[0].DKIM) != 1 || cfg.Identities[0].DKIM[0].Selector != "postman" {
+		t.Errorf("identities: %+v", cfg.Identities)
+	}
+
+	// An empty file is an empty configuration.
+	if cfg, err := LoadConfig(writeFile(t, dir, "empty.yaml", "")); err != nil || cfg.Host != "" {
+		t.Errorf("empty file: %+v, %v", cfg, err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"mistyped key", "host: smtp.example.com\ntls_pin:\n  - sha256/AAAA\n", "field tls_pin not found"},
+		{"mistyped nested key", "auth:\n  user: postman\n", "field user not found"},
+		{"wrong type", "port: submission\n", "cannot unmarshal"},
+	}
+
+	for _, tt := range tests {
+		path := writeFile(t, dir, "postman.yaml", tt.data)
+		_, err := LoadConfig(path)
+		if err == nil || !strings.HasPrefix(err.Error(), "postman: "+path+": ") || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
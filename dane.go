@@ -0,0 +1,377 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// tlsaRecord is a DNS TLSA record (RFC 6698).
+type tlsaRecord struct {
+	usage    uint8
+	selector uint8
+	matching uint8
+	data     []byte
+}
+
+// TLSA certificate usages.
+const (
+	tlsaPKIXTA = 0
+	tlsaPKIXEE = 1
+	tlsaDANETA = 2
+	tlsaDANEEE = 3
+)
+
+const dnsTypeTLSA = 52
+
+// lookupTLSA returns the TLSA records of name which resolver validated
+// with DNSSEC. It is a variable so that verification can be exercised
+// without DNSSEC.
+var lookupTLSA = queryTLSA
+
+// match reports whether cert is the one r designates, ignoring its
+// usage.
+func (r tlsaRecord) match(cert *x509.Certificate) bool {
+	var data []byte
+	switch r.selector {
+	case 0:
+		data = cert.Raw
+	case 1:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+
+	switch r.matching {
+	case 0:
+	case 1:
+		hash := sha256.Sum256(data)
+		data = hash[:]
+	case 2:
+		hash := sha512.Sum512(data)
+		data = hash[:]
+	default:
+		return false
+	}
+
+	return bytes.Equal(data, r.data)
+}
+
+// daneVerifier returns a VerifyPeerCertificate function accepting the
+// server at host when its certificate matches one of the records as RFC
+// 7672 describes, then calling next, if any. DANE-EE records designate
+// the certificate of the server, whose name and validity are then not
+// checked; DANE-TA records designate a certificate of its chain, which
+// the certificate of the server is verified against. PKIX records are
+// not used for SMTP.
+func daneVerifier(host string, records []tlsaRecord, next func([][]byte, [][]*x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		if len(certs) == 0 {
+			return errors.New("postman: server presented no certificate")
+		}
+
+		if !daneMatch(host, records, certs) {
+			return fmt.Errorf("postman: certificate of %s does not match its TLSA records", host)
+		}
+
+		if next != nil {
+			return next(rawCerts, verifiedChains)
+		}
+		return nil
+	}
+}
+
+func daneMatch(host string, records []tlsaRecord, certs []*x509.Certificate) bool {
+	for _, r := range records {
+		switch r.usage {
+		case tlsaDANEEE:
+			if r.match(certs[0]) {
+				return true
+			}
+
+		case tlsaDANETA:
+			for i, cert := range certs {
+				if !r.match(cert) {
+					continue
+				}
+
+				if i == 0 {
+					// The trust anchor is the certificate of the server
+					// itself.
+					if certs[0].VerifyHostname(host) == nil {
+						return true
+					}
+					continue
+				}
+
+				roots := x509.NewCertPool()
+				roots.AddCert(cert)
+				intermediates := x509.NewCertPool()
+				for _, c := range certs[1:i] {
+					intermediates.AddCert(c)
+				}
+
+				_, err := certs[0].Verify(x509.VerifyOptions{
+					DNSName:       host,
+					Roots:         roots,
+					Intermediates: intermediates,
+				})
+				if err == nil {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// tlsaRecords returns the usable TLSA records of the SMTP server at
+// host and port, looked up from the DNSSEC validating resolver of the
+// client.
+func (c *Client) tlsaRecords(host, port string) ([]tlsaRecord, error) {
+	resolver := c.DNSSECResolver
+	if resolver == "" {
+		var err error
+		if resolver, err = systemResolver(); err != nil {
+			return nil, err
+		}
+	}
+
+	name := "_" + port + "._tcp." + host
+	records, err := lookupTLSA(resolver, name)
+	if err != nil {
+		return nil, err
+	}
+
+	usable := records[:0]
+	for _, r := range records {
+		if r.usage == tlsaDANETA || r.usage == tlsaDANEEE {
+			usable = append(usable, r)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, fmt.Errorf("postman: no usable TLSA records for %s", name)
+	}
+
+	return usable, nil
+}
+
+// systemResolver returns the address of the first name server of
+// /etc/resolv.conf.
+func systemResolver() (string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("postman: no name server in /etc/resolv.conf")
+}
+
+// queryTLSA queries resolver for the TLSA records of name, over UDP then
+// over TCP if the response is truncated. The records are only returned
+// if the resolver authenticated them, setting the AD bit; the resolver
+// must therefore be trusted, and the path to it secure.
+func queryTLSA(resolver, name string) ([]tlsaRecord, error) {
+	query, id, err := dnsQuery(name, dnsTypeTLSA)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dnsExchange("udp", resolver, query)
+	if err == nil && len(resp) > 2 && resp[2]&0x02 != 0 {
+		// Truncated.
+		resp, err = dnsExchange("tcp", resolver, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTLSAResponse(resp, id, name)
+}
+
+// dnsQuery returns a recursive query for the records of name with type
+// qtype, asking for DNSSEC validation, and its identifier.
+func dnsQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(b[:])
+
+	// Header: RD and AD set, one question, one additional record.
+	q := []byte{b[0], b[1], 0x01, 0x20, 0, 1, 0, 0, 0, 0, 0, 1}
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("postman: invalid domain name %q", name)
+		}
+		q = append(q, byte(len(label)))
+		q = append(q, label...)
+	}
+	q = append(q, 0, byte(qtype>>8), byte(qtype), 0, 1)
+
+	// EDNS0 OPT record with a 1232 byte payload size and the DO bit.
+	q = append(q, 0, 0, 41, 0x04, 0xd0, 0, 0, 0x80, 0, 0, 0)
+
+	return q, id, nil
+}
+
+func dnsExchange(network, resolver string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, resolver, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+var errDNSFormat = errors.New("postman: invalid DNS response")
+
+func parseTLSAResponse(resp []byte, id uint16, name string) ([]tlsaRecord, error) {
+	if len(resp) < 12 || binary.BigEndian.Uint16(resp) != id || resp[2]&0x80 == 0 {
+		return nil, errDNSFormat
+	}
+
+	switch rcode := resp[3] & 0x0f; rcode {
+	case 0:
+	case 3:
+		return nil, fmt.Errorf("postman: no TLSA records for %s", name)
+	default:
+		return nil, fmt.Errorf("postman: DNS lookup of %s failed with rcode %d", name, rcode)
+	}
+
+	if resp[3]&0x20 == 0 {
+		return nil, fmt.Errorf("postman: TLSA records of %s are not authenticated with DNSSEC", name)
+	}
+
+	qdcount := binary.BigEndian.Uint16(resp[4:])
+	ancount := binary.BigEndian.Uint16(resp[6:])
+
+	off := 12
+	for i := 0; i < int(qdcount); i++ {
+		var err error
+		if off, err = skipDNSName(resp, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+
+	var records []tlsaRecord
+	for i := 0; i < int(ancount); i++ {
+		var err error
+		if off, err = skipDNSName(resp, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(resp) {
+			return nil, errDNSFormat
+		}
+		rtype := binary.BigEndian.Uint16(resp[off:])
+		rdlen := int(binary.BigEndian.Uint16(resp[off+8:]))
+		off += 10
+		if off+rdlen > len(resp) {
+			return nil, errDNSFormat
+		}
+
+		// Other records are the CNAME chain and the signatures.
+		if rtype == dnsTypeTLSA && rdlen >= 3 {
+			rdata := resp[off : off+rdlen]
+			records = append(records, tlsaRecord{
+				usage:    rdata[0],
+				selector: rdata[1],
+				matching: rdata[2],
+				data:     append([]byte(nil), rdata[3:]...),
+			})
+		}
+		off += rdlen
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("postman: no TLSA records for %s", name)
+	}
+
+	return records, nil
+}
+
+// skipDNSName returns the offset following the possibly compressed
+// domain name at off in msg.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errDNSFormat
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1, nil
+		case n&0xc0 == 0xc0:
+			if off+2 > len(msg) {
+				return 0, errDNSFormat
+			}
+			return off + 2, nil
+		case n&0xc0 != 0:
+			return 0, errDNSFormat
+		}
+		off += 1 + n
+	}
+}
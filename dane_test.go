@@ -0,0 +1,313 @@
+package postman
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert returns a certificate for name signed by parent, or
+// self-signed if parent is nil.
+func testCert(t *testing.T, name string, ca bool, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	if !ca {
+		tmpl.DNSNames = []string{name}
+	}
+
+	signer, signerKey := tmpl, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+	if parent != nil {
+		cert.Certificate = append(cert.Certificate, parent.Certificate...)
+	}
+	return cert
+}
+
+// handshake runs a TLS handshake between a client configured with cfg
+// and a server presenting cert.
+func handshake(cfg *tls.Config, cert tls.Certificate) error {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+
+	return tls.Client(client, cfg).Handshake()
+}
+
+func TestDANE(t *testing.T) {
+	ca := testCert(t, "Test CA", true, nil)
+	server := testCert(t, "mx.example.com", false, &ca)
+	other := testCert(t, "mx.example.com", false, nil)
+
+	spki := sha256.Sum256(server.Leaf.RawSubjectPublicKeyInfo)
+	eeRecord := tlsaRecord{usage: tlsaDANEEE, selector: 1, matching: 1, data: spki[:]}
+	taRecord := tlsaRecord{usage: tlsaDANETA, selector: 0, matching: 0, data: ca.Leaf.Raw}
+
+	tests := []struct {
+		name    string
+		host    string
+		records []tlsaRecord
+		err     error
+		ok      bool
+	}{
+		{"DANE-EE", "mx.example.com", []tlsaRecord{eeRecord}, nil, true},
+		// DANE-EE records designate the server whatever its name.
+		{"DANE-EE other name", "other.example.com", []tlsaRecord{eeRecord}, nil, true},
+		{"DANE-EE mismatch", "mx.example.com", []tlsaRecord{{usage: tlsaDANEEE, selector: 1, matching: 1, data: make([]byte, 32)}}, nil, false},
+		{"DANE-TA", "mx.example.com", []tlsaRecord{taRecord}, nil, true},
+		{"DANE-TA other name", "other.example.com", []tlsaRecord{taRecord}, nil, false},
+		{"PKIX only", "mx.example.com", []tlsaRecord{{usage: tlsaPKIXEE, selector: 1, matching: 1, data: spki[:]}}, nil, false},
+		{"lookup error", "mx.example.com", nil, errors.New("SERVFAIL"), false},
+	}
+
+	defer func(lookup func(string, string) ([]tlsaRecord, error)) { lookupTLSA = lookup }(lookupTLSA)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var looked string
+			lookupTLSA = func(resolver, name string) ([]tlsaRecord, error) {
+				looked = name
+				return append([]tlsaRecord(nil), tt.records...), tt.err
+			}
+
+			c := &Client{DNSSECResolver: "192.0.2.53:53"}
+			cfg, err := c.tlsConfig(tt.host, "25", connPolicy{mode: TLSRequired, dane: true})
+			if err == nil {
+				if looked != "_25._tcp."+tt.host {
+					t.Errorf("looked up %q", looked)
+				}
+				err = handshake(cfg, server)
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("got %v, want success: %t", err, tt.ok)
+			}
+		})
+	}
+
+	t.Run("other certificate", func(t *testing.T) {
+		lookupTLSA = func(resolver, name string) ([]tlsaRecord, error) {
+			return []tlsaRecord{eeRecord, taRecord}, nil
+		}
+
+		c := &Client{DNSSECResolver: "192.0.2.53:53"}
+		cfg, err := c.tlsConfig("mx.example.com", "25", connPolicy{mode: TLSRequired, dane: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(cfg, other); err == nil {
+			t.Error("certificate matching no record accepted")
+		}
+	})
+}
+
+func TestTLSAMatch(t *testing.T) {
+	cert := testCert(t, "mx.example.com", false, nil).Leaf
+	other := testCert(t, "mx.example.com", false, nil).Leaf
+
+	certSHA256 := sha256.Sum256(cert.Raw)
+	certSHA512 := sha512.Sum512(cert.Raw)
+	spkiSHA256 := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	spkiSHA512 := sha512.Sum512(cert.RawSubjectPublicKeyInfo)
+
+	tests := []struct {
+		name     string
+		selector uint8
+		matching uint8
+		data     []byte
+		ok       bool
+	}{
+		{"certificate", 0, 0, cert.Raw, true},
+		{"certificate SHA-256", 0, 1, certSHA256[:], true},
+		{"certificate SHA-512", 0, 2, certSHA512[:], true},
+		{"public key", 1, 0, cert.RawSubjectPublicKeyInfo, true},
+		{"public key SHA-256", 1, 1, spkiSHA256[:], true},
+		{"public key SHA-512", 1, 2, spkiSHA512[:], true},
+		{"selector mismatch", 0, 1, spkiSHA256[:], false},
+		{"matching type mismatch", 1, 2, spkiSHA256[:], false},
+		{"truncated", 1, 1, spkiSHA256[:16], false},
+		{"unknown selector", 2, 0, cert.Raw, false},
+		{"unknown matching type", 1, 3, spkiSHA256[:], false},
+	}
+
+	for _, tt := range tests {
+		r := tlsaRecord{usage: tlsaDANEEE, selector: tt.selector, matching: tt.matching, data: tt.data}
+		if got := r.match(cert); got != tt.ok {
+			t.Errorf("%s: match = %t, want %t", tt.name, got, tt.ok)
+		}
+		if r.match(other) {
+			t.Errorf("%s: other certificate matched", tt.name)
+		}
+	}
+}
+
+// tlsaResponse returns the response to query with the given TLSA records,
+// authenticated or not.
+func tlsaResponse(query []byte, ad bool, records ...tlsaRecord) []byte {
+	// The question, without the OPT record.
+	resp := append([]byte(nil), query[:len(query)-11]...)
+	resp[2] |= 0x80
+	resp[3] = 0
+	if ad {
+		resp[3] |= 0x20
+	}
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(records)))
+	binary.BigEndian.PutUint16(resp[10:], 0)
+
+	for _, r := range records {
+		rdata := append([]byte{r.usage, r.selector, r.matching}, r.data...)
+		resp = append(resp, 0xc0, 12, 0, dnsTypeTLSA, 0, 1, 0, 0, 0x0e, 0x10)
+		resp = append(resp, byte(len(rdata)>>8), byte(len(rdata)))
+		resp = append(resp, rdata...)
+	}
+
+	return resp
+}
+
+func TestParseTLSAResponse(t *testing.T) {
+	const name = "_25._tcp.mx.example.com"
+
+	query, id, err := dnsQuery(name, dnsTypeTLSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := tlsaRecord{usage: tlsaDANEEE, selector: 1, matching: 1, data: make([]byte, 32)}
+
+	records, err := parseTLSAResponse(tlsaResponse(query, true, record), id, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].usage != tlsaDANEEE || len(records[0].data) != 32 {
+		t.Errorf("got %+v", records)
+	}
+
+	if _, err := parseTLSAResponse(tlsaResponse(query, false, record), id, name); err == nil {
+		t.Error("records not authenticated with DNSSEC accepted")
+	}
+	if _, err := parseTLSAResponse(tlsaResponse(query, true, record), id+1, name); err == nil {
+		t.Error("response to another query accepted")
+	}
+
+	resp := tlsaResponse(query, true, record)
+	if _, err := parseTLSAResponse(resp[:len(resp)-10], id, name); err != errDNSFormat {
+		t.Errorf("truncated response: got %v, want %v", err, errDNSFormat)
+	}
+
+	// Malformed responses.
+	tests := []struct {
+		name string
+		edit func(resp []byte) []byte
+	}{
+		{"short header", func(resp []byte) []byte { return resp[:11] }},
+		{"query", func(resp []byte) []byte { resp[2] &^= 0x80; return resp }},
+		{"invalid label", func(resp []byte) []byte { resp[12] = 0x80; return resp }},
+		{"label past the end", func(resp []byte) []byte { resp[12] = 63; return resp[:40] }},
+		{"truncated pointer", func(resp []byte) []byte { return resp[:len(resp)-len(record.data)-15] }},
+		{"truncated record header", func(resp []byte) []byte { return resp[:len(resp)-len(record.data)-5] }},
+		{"truncated data", func(resp []byte) []byte { return resp[:len(resp)-1] }},
+		{"missing answer", func(resp []byte) []byte { resp[7] = 2; return resp }},
+	}
+	for _, tt := range tests {
+		resp := tt.edit(tlsaResponse(query, true, record))
+		if _, err := parseTLSAResponse(resp, id, name); err != errDNSFormat {
+			t.Errorf("%s: got %v, want %v", tt.name, err, errDNSFormat)
+		}
+	}
+
+	// Failed lookups.
+	for _, tt := range []struct {
+		name  string
+		rcode byte
+		want  string
+	}{
+		{"NXDOMAIN", 3, "postman: no TLSA records for " + name},
+		{"SERVFAIL", 2, "postman: DNS lookup of " + name + " failed with rcode 2"},
+	} {
+		resp := tlsaResponse(query, true)
+		resp[3] |= tt.rcode
+		if _, err := parseTLSAResponse(resp, id, name); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Records other than TLSA ones, as CNAME and RRSIG records, are
+	// skipped, as are TLSA records too short to hold their fields.
+	resp = tlsaResponse(query, true, record, tlsaRecord{usage: tlsaDANETA, selector: 0, matching: 1, data: make([]byte, 32)})
+	// The type of the second record, following its name pointer.
+	resp[len(resp)-12-3-32+3] = 46
+	if records, err := parseTLSAResponse(resp, id, name); err != nil || len(records) != 1 || records[0].usage != tlsaDANEEE {
+		t.Errorf("got %+v, %v, want the first record only", records, err)
+	}
+
+	// A TLSA record of two bytes.
+	short := tlsaResponse(query, true, tlsaRecord{})
+	short[len(short)-4] = 2
+	if _, err := parseTLSAResponse(short[:len(short)-1], id, name); err == nil || err.Error() != "postman: no TLSA records for "+name {
+		t.Errorf("short record: got %v", err)
+	}
+	if _, err := parseTLSAResponse(tlsaResponse(query, true), id, name); err == nil || err.Error() != "postman: no TLSA records for "+name {
+		t.Errorf("no records: got %v", err)
+	}
+}
+
+func TestDNSQuery(t *testing.T) {
+	query, id, err := dnsQuery("_25._tcp.mx.example.com.", dnsTypeTLSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]byte{byte(id >> 8), byte(id), 0x01, 0x20, 0, 1, 0, 0, 0, 0, 0, 1}, "\x03_25\x04_tcp\x02mx\x07example\x03com\x00"...)
+	want = append(want, 0, dnsTypeTLSA, 0, 1)
+	want = append(want, 0, 0, 41, 0x04, 0xd0, 0, 0, 0x80, 0, 0, 0)
+	if !bytes.Equal(query, want) {
+		t.Errorf("got %x, want %x", query, want)
+	}
+
+	for _, name := range []string{"", "mx..example.com", strings.Repeat("a", 64) + ".example.com"} {
+		if _, _, err := dnsQuery(name, dnsTypeTLSA); err == nil {
+			t.Errorf("dnsQuery(%q): got no error", name)
+		}
+	}
+}
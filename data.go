@@ -0,0 +1,85 @@
+package postman
+
+import (
+	"bufio"
+	"errors"
+)
+
+// dataWriter writes the content of a DATA command (RFC 5321 section
+// 4.5.2): lines starting with a dot get another one, every line ends with
+// CRLF, whether it was given with CRLF, a bare LF or a bare CR, and Close
+// terminates the content with a line holding a single dot.
+type dataWriter struct {
+	w     *bufio.Writer
+	state int
+}
+
+const (
+	dataBeginLine = iota // 0 so that the zero value starts a line
+	dataCR               // a CR was written as CRLF, an LF may follow
+	dataLine
+	dataClosed
+)
+
+var errDataClosed = errors.New("postman: write to closed DATA content")
+
+func newDataWriter(w *bufio.Writer) *dataWriter {
+	return &dataWriter{w: w}
+}
+
+func (d *dataWriter) Write(p []byte) (int, error) {
+	if d.state == dataClosed {
+		return 0, errDataClosed
+	}
+
+	for n, c := range p {
+		switch c {
+		case '\r':
+			if _, err := d.w.WriteString("\r\n"); err != nil {
+				return n, err
+			}
+			d.state = dataCR
+
+		case '\n':
+			if d.state != dataCR {
+				if _, err := d.w.WriteString("\r\n"); err != nil {
+					return n, err
+				}
+			}
+			d.state = dataBeginLine
+
+		default:
+			if c == '.' && d.state != dataLine {
+				if err := d.w.WriteByte('.'); err != nil {
+					return n, err
+				}
+			}
+			if err := d.w.WriteByte(c); err != nil {
+				return n, err
+			}
+			d.state = dataLine
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close ends the last line if needed, writes the terminating dot and
+// flushes the underlying writer.
+func (d *dataWriter) Close() error {
+	if d.state == dataClosed {
+		return errDataClosed
+	}
+
+	end := ".\r\n"
+	if d.state == dataLine {
+		end = "\r\n.\r\n"
+	}
+	d.state = dataClosed
+
+	if _, err := d.w.WriteString(end); err != nil {
+		return err
+	}
+
+	return d.w.Flush()
+}
@@ -0,0 +1,132 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDataWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"empty", nil, ".\r\n"},
+		{"line", []string{"Hello\r\n"}, "Hello\r\n.\r\n"},
+		{"unterminated line", []string{"Hello"}, "Hello\r\n.\r\n"},
+		{"bare LF", []string{"a\nb\n"}, "a\r\nb\r\n.\r\n"},
+		{"bare CR", []string{"a\rb\r"}, "a\r\nb\r\n.\r\n"},
+		{"CR and CRLF", []string{"a\r\r\nb"}, "a\r\n\r\nb\r\n.\r\n"},
+		{"leading dot", []string{".hidden\r\n"}, "..hidden\r\n.\r\n"},
+		{"dot line", []string{"a\r\n.\r\nb\r\n"}, "a\r\n..\r\nb\r\n.\r\n"},
+		{"dot after bare LF", []string{"a\n.b"}, "a\r\n..b\r\n.\r\n"},
+		{"dot after bare CR", []string{"a\r.b"}, "a\r\n..b\r\n.\r\n"},
+		{"inner dot", []string{"a.b\r\n"}, "a.b\r\n.\r\n"},
+		{"dot across writes", []string{"a\r\n", ".b"}, "a\r\n..b\r\n.\r\n"},
+		{"CRLF across writes", []string{"a\r", "\nb"}, "a\r\nb\r\n.\r\n"},
+		{"dot after split CRLF", []string{"a\r", "\n", "."}, "a\r\n..\r\n.\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newDataWriter(bufio.NewWriter(&buf))
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(s) {
+					t.Fatalf("Write(%q) = %d, want %d", s, n, len(s))
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataWriterClosed(t *testing.T) {
+	var buf bytes.Buffer
+	w := newDataWriter(bufio.NewWriter(&buf))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("late")); err != errDataClosed {
+		t.Errorf("Write after Close: got %v, want %v", err, errDataClosed)
+	}
+	if err := w.Close(); err != errDataClosed {
+		t.Errorf("second Close: got %v, want %v", err, errDataClosed)
+	}
+	if got := buf.String(); got != ".\r\n" {
+		t.Errorf("got %q, want %q", got, ".\r\n")
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("write failed")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestDataWriterError(t *testing.T) {
+	// The bufio.Writer is smaller than the content so that the error of
+	// the underlying writer surfaces in Write.
+	w := newDataWriter(bufio.NewWriterSize(&failingWriter{n: 20}, 16))
+
+	_, err := w.Write([]byte(strings.Repeat("line\r\n", 10)))
+	if err == nil {
+		t.Fatal("Write: got no error")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close: got no error")
+	}
+}
+
+// TestTransactionRenderError checks that a message failing to render
+// midway is not delivered truncated: the data is not terminated and the
+// server discards the transaction.
+func TestTransactionRenderError(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	m := &Mail{
+		From:        "sender@example.com",
+		To:          []string{"rcpt@example.com"},
+		Subject:     "Truncated",
+		Parts:       []Part{{ContentType: "text/plain", Content: []byte("Hello")}},
+		Attachments: []Attachment{{Filename: "missing.txt", File: "testdata/missing.txt"}},
+	}
+	if err := c.Send(m); err == nil {
+		t.Fatal("Send: got no error")
+	}
+
+	// Wait for the server to notice the dropped connection.
+	c.Close()
+	s.Close()
+
+	if got := s.Messages(); len(got) != 0 {
+		t.Errorf("server received %d messages, want none", len(got))
+	}
+	if got := s.Aborted(); got != 1 {
+		t.Errorf("server aborted %d transactions, want 1", got)
+	}
+}
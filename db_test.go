@@ -0,0 +1,129 @@
+package postman
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// stubDB is a database/sql driver standing in for the databases of the
+// tests: queries are run by the handler of the first prefix they start
+// with, one at a time. Transactions are not isolated; their statements
+// apply as they are run.
+type stubDB struct {
+	mu       sync.Mutex
+	handlers []stubHandler
+}
+
+type stubHandler struct {
+	prefix string
+	run    func(args []driver.Value) (*stubResult, error)
+}
+
+// stubResult is the outcome of a query: the rows it returns, or the
+// number of rows it changed.
+type stubResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// handle registers run for the queries starting with prefix.
+func (db *stubDB) handle(prefix string, run func(args []driver.Value) (*stubResult, error)) {
+	db.handlers = append(db.handlers, stubHandler{prefix: prefix, run: run})
+}
+
+// open returns a *sql.DB running its queries against db.
+func (db *stubDB) open() *sql.DB {
+	return sql.OpenDB(db)
+}
+
+func (db *stubDB) run(query string, args []driver.Value) (*stubResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	for _, h := range db.handlers {
+		if strings.HasPrefix(query, h.prefix) {
+			return h.run(args)
+		}
+	}
+
+	return nil, fmt.Errorf("stub: unexpected query %q", query)
+}
+
+func (db *stubDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &stubConn{db: db}, nil
+}
+
+func (db *stubDB) Driver() driver.Driver {
+	return stubDriver{}
+}
+
+type stubDriver struct{}
+
+func (stubDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("stub: use stubDB.open")
+}
+
+type stubConn struct {
+	db *stubDB
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{db: c.db, query: query}, nil
+}
+
+func (c *stubConn) Close() error              { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct {
+	db    *stubDB
+	query string
+}
+
+func (s *stubStmt) Close() error  { return nil }
+func (s *stubStmt) NumInput() int { return -1 }
+
+func (s *stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	res, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (s *stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	res, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &stubRows{res: res}, nil
+}
+
+type stubRows struct {
+	res *stubResult
+	i   int
+}
+
+func (r *stubRows) Columns() []string { return r.res.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.i])
+	r.i++
+	return nil
+}
@@ -0,0 +1,131 @@
+package postman
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+)
+
+// Defaults are values a Client merges into every message it sends,
+// unless the message defines its own, for applications not to repeat
+// them in each of their messages.
+type Defaults struct {
+	From    string
+	ReplyTo string
+
+	// Header fields, such as X-Entity-Ref-ID or List-Unsubscribe, are
+	// added to the messages which have none of the same name.
+	Header textproto.MIMEHeader
+
+	// Footers, such as a signature followed by compliance disclaimers,
+	// are appended in order to the text parts of the messages which do
+	// not set OmitFooter. Messages given as a MIME tree with Body are
+	// left alone.
+	Footers []Footer
+}
+
+// Footer is appended to the text/plain parts of a message as Text, and
+// to its text/html parts as HTML, before the closing body tag if any.
+// Empty contents leave the parts of their type alone.
+type Footer struct {
+	Text string
+	HTML string
+}
+
+// apply merges the defaults into m, whose header and parts are shared
+// with the original message and are therefore copied before being
+// changed.
+func (d *Defaults) apply(m *Mail) {
+	if m.From == "" {
+		m.From = d.From
+	}
+
+	if m.ReplyTo == "" {
+		m.ReplyTo = d.ReplyTo
+	}
+
+	var h textproto.MIMEHeader
+	for k, v := range d.Header {
+		if len(m.Header[textproto.CanonicalMIMEHeaderKey(k)]) > 0 {
+			continue
+		}
+		if h == nil {
+			h = make(textproto.MIMEHeader, len(m.Header)+len(d.Header))
+			for k, v := range m.Header {
+				h[k] = v
+			}
+		}
+		for _, s := range v {
+			h.Add(k, s)
+		}
+	}
+	if h != nil {
+		m.Header = h
+	}
+
+	if len(d.Footers) == 0 || m.OmitFooter || m.Body != nil {
+		return
+	}
+
+	parts := make([]Part, len(m.Parts))
+	for i, p := range m.Parts {
+		switch partMediaType(p) {
+		case "text/plain":
+			p.Content = appendTextFooters(p.Content, d.Footers)
+		case "text/html":
+			p.Content = appendHTMLFooters(p.Content, d.Footers)
+		}
+		parts[i] = p
+	}
+	m.Parts = parts
+}
+
+// partMediaType returns the media type of p, lower cased, without its
+// parameters.
+func partMediaType(p Part) string {
+	t := p.ContentType
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = t[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+func appendTextFooters(content []byte, footers []Footer) []byte {
+	out := append([]byte(nil), content...)
+	for _, f := range footers {
+		if f.Text == "" {
+			continue
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		if len(out) > 0 {
+			out = append(out, '\n')
+		}
+		out = append(out, f.Text...)
+	}
+	return out
+}
+
+func appendHTMLFooters(content []byte, footers []Footer) []byte {
+	var html []byte
+	for _, f := range footers {
+		html = append(html, f.HTML...)
+	}
+	if len(html) == 0 {
+		return content
+	}
+
+	end := len(content)
+	for i := len(content) - len("</body>"); i >= 0; i-- {
+		if bytes.EqualFold(content[i:i+len("</body>")], []byte("</body>")) {
+			end = i
+			break
+		}
+	}
+
+	out := make([]byte, 0, len(content)+len(html))
+	out = append(out, content[:end]...)
+	out = append(out, html...)
+	return append(out, content[end:]...)
+}
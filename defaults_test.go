@@ -0,0 +1,130 @@
+package postman
+
+import (
+	"net/textproto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// defaultsClient returns a client merging a From field, header fields and
+// two footers into messages.
+func defaultsClient() *Client {
+	c := NewClient("127.0.0.1:0")
+	c.Defaults = &Defaults{
+		From:    "Example <noreply@example.com>",
+		ReplyTo: "Support <support@example.com>",
+		Header: textproto.MIMEHeader{
+			"X-Entity-Ref-Id":  {"postman"},
+			"List-Unsubscribe": {"<mailto:unsubscribe@example.com>"},
+		},
+		Footers: []Footer{
+			{Text: "-- \nThe Example team\n", HTML: "<p>The Example team</p>"},
+			{Text: "You receive this email as a customer of Example.\n"},
+		},
+	}
+	return c
+}
+
+func TestDefaults(t *testing.T) {
+	c := defaultsClient()
+
+	m := &Mail{
+		To:         []string{"john@example.com"},
+		Date:       time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC),
+		MessageID:  "<defaults@postman.test>",
+		Subject:    "Hello",
+		OmitMailer: true,
+		Header:     textproto.MIMEHeader{"List-Unsubscribe": {"<https://example.com/unsubscribe>"}},
+		Parts:      []Part{{ContentType: "text/plain", Content: []byte("Hello John")}},
+	}
+
+	mm, err := c.prepare(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mm.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields of the message are kept, the footers separated from the
+	// content and from each other by an empty line.
+	const want = "Date: Sat, 03 Feb 2001 04:05:06 +0000\r\n" +
+		"From: Example <noreply@example.com>\r\n" +
+		"Reply-To: Support <support@example.com>\r\n" +
+		"To: john@example.com\r\n" +
+		"Message-ID: <defaults@postman.test>\r\n" +
+		"Subject: Hello\r\n" +
+		"List-Unsubscribe: <https://example.com/unsubscribe>\r\n" +
+		"X-Entity-Ref-Id: postman\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Hello John\r\n" +
+		"\r\n" +
+		"-- \r\n" +
+		"The Example team\r\n" +
+		"\r\n" +
+		"You receive this email as a customer of Example.\r\n"
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+
+	if m.From != "" || len(m.Header) != 1 || string(m.Parts[0].Content) != "Hello John" {
+		t.Errorf("message modified: %+v", m)
+	}
+}
+
+func TestDefaultsFooters(t *testing.T) {
+	c := defaultsClient()
+
+	m := &Mail{
+		From: "Jane <jane@example.com>",
+		To:   []string{"john@example.com"},
+		Parts: []Part{
+			{ContentType: "text/plain; charset=utf-8", Content: []byte("Hello John\n")},
+			{ContentType: "text/html", Content: []byte("<html><body><p>Hello John</p></BODY></html>")},
+			{ContentType: "text/html", Content: []byte("<p>Hello John</p>")},
+			{ContentType: "text/calendar", Content: []byte("BEGIN:VCALENDAR")},
+		},
+	}
+
+	mm, err := c.prepare(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HTML footers go before the closing body tag, or at the end.
+	want := []string{
+		"Hello John\n\n-- \nThe Example team\n\nYou receive this email as a customer of Example.\n",
+		"<html><body><p>Hello John</p><p>The Example team</p></BODY></html>",
+		"<p>Hello John</p><p>The Example team</p>",
+		"BEGIN:VCALENDAR",
+	}
+	var got []string
+	for _, p := range mm.Parts {
+		got = append(got, string(p.Content))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if mm.From != m.From {
+		t.Errorf("From: got %q, want %q", mm.From, m.From)
+	}
+	if string(m.Parts[0].Content) != "Hello John\n" {
+		t.Errorf("part modified: %q", m.Parts[0].Content)
+	}
+
+	// The footers are left out on request, and of MIME trees.
+	m.OmitFooter = true
+	if mm, err := c.prepare(m); err != nil || !reflect.DeepEqual(mm.Parts, m.Parts) {
+		t.Errorf("OmitFooter: got %+v, %v", mm.Parts, err)
+	}
+
+	body := &Entity{ContentType: "text/plain", Content: []byte("Hello John")}
+	mm, err = c.prepare(&Mail{From: m.From, To: m.To, Body: body})
+	if err != nil || mm.Body != body || string(body.Content) != "Hello John" {
+		t.Errorf("Body: got %+v, %v", mm.Body, err)
+	}
+}
@@ -0,0 +1,51 @@
+package postman
+
+import (
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeliverByError is returned for messages whose delivery deadline the
+// server cannot honor.
+type DeliverByError struct {
+	DeliverBy time.Duration
+
+	// Minimum is the shortest deadline the server accepts, or zero if it
+	// does not support DELIVERBY.
+	Minimum time.Duration
+}
+
+func (e *DeliverByError) Error() string {
+	if e.Minimum == 0 {
+		return "postman: server does not support DELIVERBY"
+	}
+	return fmt.Sprintf("postman: delivery deadline %v shorter than the %v the server requires", e.DeliverBy, e.Minimum)
+}
+
+// deliverByParam returns the BY parameter of the MAIL command for e.
+func deliverByParam(conn *smtp.Client, e *Envelope) (string, error) {
+	ok, params := conn.Extension("DELIVERBY")
+	if !ok {
+		return "", &DeliverByError{DeliverBy: e.DeliverBy}
+	}
+
+	// Deadlines are given in seconds, rounded up so that the server
+	// never gets less time than asked.
+	seconds := int64((e.DeliverBy + time.Second - 1) / time.Second)
+
+	if min, err := strconv.ParseInt(strings.TrimSpace(params), 10, 64); err == nil && min > 0 {
+		if !e.DeliverByNotify && seconds < min {
+			return "", &DeliverByError{DeliverBy: e.DeliverBy, Minimum: time.Duration(min) * time.Second}
+		}
+	}
+
+	mode := "R"
+	if e.DeliverByNotify {
+		mode = "N"
+	}
+
+	return "BY=" + strconv.FormatInt(seconds, 10) + ";" + mode, nil
+}
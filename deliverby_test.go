@@ -0,0 +1,82 @@
+package postman
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// mailCommands returns the MAIL commands received by s.
+func mailCommands(s *testServer) []string {
+	var cmds []string
+	for _, cmd := range s.Commands() {
+		if strings.HasPrefix(cmd, "MAIL ") {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+func TestClientDeliverBy(t *testing.T) {
+	tests := []struct {
+		name   string
+		ext    []string
+		by     time.Duration
+		notify bool
+		want   string
+		err    *DeliverByError
+	}{
+		{"return", []string{"DELIVERBY 60"}, 90 * time.Second, false, "MAIL FROM:<sender@example.com> BODY=8BITMIME BY=90;R", nil},
+		{"rounded up", []string{"DELIVERBY"}, 1500 * time.Millisecond, false, "MAIL FROM:<sender@example.com> BODY=8BITMIME BY=2;R", nil},
+		{"notify", []string{"DELIVERBY 60"}, 10 * time.Second, true, "MAIL FROM:<sender@example.com> BODY=8BITMIME BY=10;N", nil},
+		{"with PRDR", []string{"DELIVERBY", "PRDR"}, time.Minute, false, "MAIL FROM:<sender@example.com> BODY=8BITMIME BY=60;R PRDR", nil},
+		{"below the minimum", []string{"DELIVERBY 60"}, 10 * time.Second, false, "", &DeliverByError{DeliverBy: 10 * time.Second, Minimum: time.Minute}},
+		{"unsupported", nil, time.Minute, false, "", &DeliverByError{DeliverBy: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, withExtensions(tt.ext...))
+			defer s.Close()
+
+			c := NewClient(s.Addr, WithPool(1))
+			defer c.Close()
+
+			m := benchMail(1)
+			m.Envelope.DeliverBy, m.Envelope.DeliverByNotify = tt.by, tt.notify
+
+			err := c.Send(m)
+			if tt.err != nil {
+				derr, ok := err.(*DeliverByError)
+				if !ok || *derr != *tt.err {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+
+				// The session survives the error.
+				if err := c.Send(benchMail(1)); err != nil {
+					t.Fatalf("next message: %v", err)
+				}
+				c.Close()
+				s.Close()
+
+				if cmds := s.Commands(); len(cmds) == 0 || strings.Count(strings.Join(cmds, "\n"), "EHLO") != 1 {
+					t.Errorf("session not reused: %q", cmds)
+				}
+				if cmds := mailCommands(s); len(cmds) != 1 || strings.Contains(cmds[0], "BY=") {
+					t.Errorf("MAIL commands %q, want one without BY", cmds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.Close()
+			s.Close()
+
+			if cmds := mailCommands(s); len(cmds) != 1 || cmds[0] != tt.want {
+				t.Errorf("got %q, want %q", cmds, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package postman
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DefaultFallbackDelay is the delay between the connection attempts to
+// the addresses of a server, as recommended by RFC 8305.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// dialConn connects to addr. When its host has several addresses, they
+// are tried as RFC 8305 describes: interleaved by family, starting with
+// the one preferred by the resolver, a new attempt starting whenever the
+// previous one fails or has not succeeded after the fallback delay. The
+// first connection established wins.
+func (c *Client) dialConn(ctx context.Context, addr string) (net.Conn, error) {
+	d := c.Dialer
+	if d == nil {
+		d = &net.Dialer{Timeout: 30 * time.Second}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil || c.FallbackDelay < 0 {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := interleaveFamilies(ips)
+	if len(addrs) == 1 {
+		return d.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+	}
+
+	delay := c.FallbackDelay
+	if delay == 0 {
+		delay = DefaultFallbackDelay
+	}
+
+	for i := range addrs {
+		addrs[i] = net.JoinHostPort(addrs[i], port)
+	}
+
+	return dialParallel(ctx, d, addrs, delay)
+}
+
+// dialParallel connects to the first of addrs to answer, starting a new
+// attempt whenever the previous one fails or has not succeeded after
+// delay.
+func dialParallel(ctx context.Context, d *net.Dialer, addrs []string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	// Buffered so that the attempts still running when one succeeds do
+	// not block.
+	results := make(chan result, len(addrs))
+
+	next, pending := 0, 0
+	start := func() {
+		a := addrs[next]
+		next++
+		pending++
+
+		go func() {
+			conn, err := d.DialContext(ctx, "tcp", a)
+			results <- result{conn, err}
+		}()
+	}
+
+	start()
+
+	var first error
+	for pending > 0 {
+		// Every event starts a new attempt, if any is left, so the delay
+		// always runs from the last one.
+		var (
+			timer    *time.Timer
+			fallback <-chan time.Time
+		)
+		if next < len(addrs) {
+			timer = time.NewTimer(delay)
+			fallback = timer.C
+		}
+
+		select {
+		case r := <-results:
+			pending--
+
+			if r.err == nil {
+				if timer != nil {
+					timer.Stop()
+				}
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+
+			if first == nil {
+				first = r.err
+			}
+
+			if next < len(addrs) {
+				start()
+			}
+
+		case <-fallback:
+			start()
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	return nil, first
+}
+
+// interleaveFamilies returns the addresses alternating between IPv6 and
+// IPv4, starting with the family of the first one.
+func interleaveFamilies(ips []net.IPAddr) []string {
+	var first, second []string
+	for _, ip := range ips {
+		s := ip.String()
+		if (ip.IP.To4() == nil) == (ips[0].IP.To4() == nil) {
+			first = append(first, s)
+		} else {
+			second = append(second, s)
+		}
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+
+	return addrs
+}
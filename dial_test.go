@@ -0,0 +1,102 @@
+package postman
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	var ips []net.IPAddr
+	for _, s := range []string{"2001:db8::1", "2001:db8::2", "2001:db8::3", "192.0.2.1", "192.0.2.2"} {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(s)})
+	}
+
+	got := interleaveFamilies(ips)
+	want := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "2001:db8::3"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// TestDialParallel checks that a connection is established to the
+// reachable address of a server whose first address is not, whether it
+// refuses connections or does not answer.
+func TestDialParallel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// An address refusing connections.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := closed.Addr().String()
+	closed.Close()
+
+	t.Run("refused", func(t *testing.T) {
+		begin := time.Now()
+		conn, err := dialParallel(context.Background(), &net.Dialer{}, []string{refused, l.Addr().String()}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		// The failure starts the next attempt without waiting for the
+		// delay.
+		if d := time.Since(begin); d > 10*time.Second {
+			t.Errorf("connected after %s", d)
+		}
+	})
+
+	t.Run("unanswered", func(t *testing.T) {
+		// Connections to the first address hang until the test ends.
+		hang := make(chan struct{})
+		defer close(hang)
+
+		d := &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+			if address == refused {
+				<-hang
+			}
+			return nil
+		}}
+
+		const delay = 50 * time.Millisecond
+		begin := time.Now()
+		conn, err := dialParallel(context.Background(), d, []string{refused, l.Addr().String()}, delay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		if d := time.Since(begin); d < delay || d > 10*time.Second {
+			t.Errorf("connected after %s, want after the delay of %s", d, delay)
+		}
+	})
+
+	t.Run("all unreachable", func(t *testing.T) {
+		if _, err := dialParallel(context.Background(), &net.Dialer{}, []string{refused, refused}, time.Minute); err == nil {
+			t.Error("got no error")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/mail"
+)
+
+// NewDigest returns a message enclosing msgs as a multipart/digest entity
+// (RFC 2046 section 5.1.5), as mailing lists send in digest mode. The
+// header and the parts of the digest are those of header; if it has no
+// part, a table of contents listing the subject and author of every
+// message is added:
+//
+//	Topics:
+//
+//	   1. Release schedule (Jane Doe)
+//	   2. Re: Release schedule (john@example.com)
+func NewDigest(header *Mail, msgs ...*Mail) *Mail {
+	m := *header
+	m.Digest = append(append([]*Mail(nil), header.Digest...), msgs...)
+
+	if len(m.Parts) == 0 {
+		var buf bytes.Buffer
+		buf.WriteString("Topics:\r\n\r\n")
+		for i, msg := range m.Digest {
+			fmt.Fprintf(&buf, "%4d. %s (%s)\r\n", i+1, msg.Subject, author(msg))
+		}
+
+		m.Parts = []Part{{
+			ContentType: "text/plain",
+			Params:      map[string]string{"charset": "utf-8"},
+			Content:     buf.Bytes(),
+		}}
+	}
+
+	return &m
+}
+
+// author returns the name of the author of a message, or its address.
+func author(m *Mail) string {
+	a, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return m.From
+	}
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Address
+}
+
+// writeDigest writes the body of a multipart/digest entity.
+func writeDigest(w *bufio.Writer, boundary string, msgs []*Mail) error {
+	hw := headerWriter{w: w}
+
+	for _, msg := range msgs {
+		content, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+
+		writeBoundary(w, boundary)
+
+		// message/rfc822 is the default in a digest, but some clients
+		// only show the parts declaring it.
+		hw.field("Content-Type", "message/rfc822")
+		if !is7bit(content) {
+			hw.field("Content-Transfer-Encoding", "8bit")
+		}
+		w.WriteString("\r\n")
+		w.Write(content)
+	}
+
+	writeCloseBoundary(w, boundary)
+
+	return nil
+}
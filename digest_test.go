@@ -0,0 +1,172 @@
+package postman
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// digestMail returns a digest of two messages.
+func digestMail() *Mail {
+	date := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	return NewDigest(
+		&Mail{Date: date, MessageID: "<digest@postman.test>", From: "list@example.com", To: []string{"rcpt@example.com"}, Subject: "Digest"},
+		&Mail{Date: date, MessageID: "<m1@postman.test>", From: "Jane Doe <jane@example.com>", Subject: "Release schedule",
+			Parts: []Part{{ContentType: "text/plain", Content: []byte("Hello")}}},
+		&Mail{Date: date, MessageID: "<m2@postman.test>", From: "john@example.com", Subject: "Re: Release schedule",
+			Parts: []Part{{ContentType: "text/plain", Content: []byte("Hello again")}}},
+	)
+}
+
+// multipartReader returns a reader of the parts of a multipart entity with
+// the given Content-Type, checking its media type.
+func multipartReader(t *testing.T, ctype, want string, r io.Reader) *multipart.Reader {
+	t.Helper()
+
+	mediatype, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediatype != want || params["boundary"] == "" {
+		t.Fatalf("Content-Type: %s, want %s with a boundary", ctype, want)
+	}
+	return multipart.NewReader(r, params["boundary"])
+}
+
+func TestNewDigest(t *testing.T) {
+	m := digestMail()
+
+	if len(m.Digest) != 2 || m.Subject != "Digest" {
+		t.Fatalf("got %+v", m)
+	}
+
+	const toc = "Topics:\r\n\r\n   1. Release schedule (Jane Doe)\r\n   2. Re: Release schedule (john@example.com)\r\n"
+	if len(m.Parts) != 1 || string(m.Parts[0].Content) != toc {
+		t.Errorf("got parts %+v, want\n%s", m.Parts, toc)
+	}
+
+	// The parts of the header are kept.
+	header := &Mail{From: "list@example.com", Parts: []Part{{ContentType: "text/plain", Content: []byte("Intro")}}}
+	if m := NewDigest(header, m.Digest...); len(m.Parts) != 1 || string(m.Parts[0].Content) != "Intro" {
+		t.Errorf("got parts %+v", m.Parts)
+	}
+}
+
+func TestDigestRender(t *testing.T) {
+	b, err := digestMail().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The table of contents and the digest are parts of a mixed body.
+	mixed := multipartReader(t, msg.Header.Get("Content-Type"), "multipart/mixed", msg.Body)
+
+	p, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctype := p.Header.Get("Content-Type"); ctype != "text/plain; charset=utf-8" {
+		t.Errorf("table of contents: Content-Type: %s", ctype)
+	}
+
+	p, err = mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := multipartReader(t, p.Header.Get("Content-Type"), "multipart/digest", p)
+
+	subjects := []string{"Release schedule", "Re: Release schedule"}
+	for i := 0; ; i++ {
+		p, err := digest.NextPart()
+		if err == io.EOF {
+			if i != len(subjects) {
+				t.Errorf("%d messages in the digest, want %d", i, len(subjects))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The default Content-Type of digest parts is declared.
+		if ctype := p.Header.Get("Content-Type"); ctype != "message/rfc822" {
+			t.Errorf("message %d: Content-Type: %s", i, ctype)
+		}
+		if cte := p.Header.Get("Content-Transfer-Encoding"); cte != "" {
+			t.Errorf("message %d: Content-Transfer-Encoding: %s", i, cte)
+		}
+
+		enclosed, err := mail.ReadMessage(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := enclosed.Header.Get("Subject"); i < len(subjects) && s != subjects[i] {
+			t.Errorf("message %d: Subject: %s, want %s", i, s, subjects[i])
+		}
+	}
+
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Errorf("after the digest: %v, want the closing boundary", err)
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	// Digest parts are messages when they have no Content-Type.
+	const raw = "From: list@example.com\r\n" +
+		"Subject: Digest\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/digest; boundary=b1\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"\r\n" +
+		"From: jane@example.com\r\n" +
+		"Subject: Release schedule\r\n" +
+		"\r\n" +
+		"Hello\r\n" +
+		"--b1\r\n" +
+		"Content-Type: message/rfc822\r\n" +
+		"\r\n" +
+		"From: john@example.com\r\n" +
+		"Subject: Re: Release schedule\r\n" +
+		"\r\n" +
+		"Hello again\r\n" +
+		"--b1--\r\n"
+
+	m, err := ParseMail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Digest) != 2 || m.Digest[0].Subject != "Release schedule" || m.Digest[1].Subject != "Re: Release schedule" {
+		t.Fatalf("got digest %+v", m.Digest)
+	}
+	if content := m.Digest[1].Parts[0].Content; string(content) != "Hello again" {
+		t.Errorf("got content %q", content)
+	}
+
+	// Rendered digests parse back.
+	b, err := digestMail().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = ParseMail(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Digest) != 2 || m.Digest[0].MessageID != "<m1@postman.test>" || len(m.Parts) != 1 {
+		t.Errorf("got %+v", m)
+	}
+	if content := m.Digest[0].Parts[0].Content; string(content) != "Hello" {
+		t.Errorf("got content %q", content)
+	}
+}
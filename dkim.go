@@ -0,0 +1,642 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lookupTXT resolves DNS TXT records. It is a variable so that
+// verification can run against fixed records.
+var lookupTXT = net.LookupTXT
+
+// DKIMResult is the outcome of the verification of one DKIM-Signature
+// field.
+type DKIMResult struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector"`
+
+	// Err is nil when the signature is valid.
+	Err error `json:"-"`
+}
+
+// rawField is a header field as it appears in a message, folding and
+// terminating CRLF included.
+type rawField struct {
+	name string
+	raw  string
+}
+
+// splitMessage splits a raw message into its header fields and its body.
+// Bare LF line endings are read as CRLF.
+func splitMessage(raw []byte) ([]rawField, []byte) {
+	var fields []rawField
+
+	for len(raw) > 0 {
+		i := bytes.IndexByte(raw, '\n')
+		if i < 0 {
+			i = len(raw) - 1
+		}
+
+		line := bytes.TrimSuffix(raw[:i+1], []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		raw = raw[i+1:]
+
+		if len(line) == 0 {
+			break
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += string(line) + "\r\n"
+			continue
+		}
+
+		name := string(line)
+		if j := strings.IndexByte(name, ':'); j >= 0 {
+			name = name[:j]
+		}
+
+		fields = append(fields, rawField{
+			name: strings.TrimSpace(name),
+			raw:  string(line) + "\r\n",
+		})
+	}
+
+	return fields, raw
+}
+
+// value returns the unfolded value of the field.
+func (f *rawField) value() string {
+	v := f.raw[strings.IndexByte(f.raw, ':')+1:]
+	v = strings.Replace(v, "\r\n", "", -1)
+	return strings.TrimSpace(v)
+}
+
+// parseTags parses a tag=value list, as used by DKIM-Signature fields
+// and DKIM key records (RFC 6376 section 3.2). Whitespace is removed.
+func parseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	for _, spec := range strings.Split(s, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		i := strings.IndexByte(spec, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("malformed tag %q", spec)
+		}
+
+		name := strings.TrimSpace(spec[:i])
+		if _, ok := tags[name]; ok {
+			return nil, fmt.Errorf("duplicate tag %q", name)
+		}
+
+		tags[name] = strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '\t', '\r', '\n':
+				return -1
+			}
+			return r
+		}, spec[i+1:])
+	}
+
+	return tags, nil
+}
+
+// parseDKIMCanonicalization parses the c= tag of a signature.
+func parseDKIMCanonicalization(s string) (Canonicalization, Canonicalization, error) {
+	if s == "" {
+		return CanonicalizationSimple, CanonicalizationSimple, nil
+	}
+
+	hs, bs := s, "simple"
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		hs, bs = s[:i], s[i+1:]
+	}
+
+	hc, err := ParseCanonicalization(hs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bc, err := ParseCanonicalization(bs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return hc, bc, nil
+}
+
+// VerifyDKIM verifies every DKIM-Signature field of a raw message,
+// looking up the public keys in DNS. It returns an error only when the
+// message has no signature.
+func VerifyDKIM(raw []byte) ([]DKIMResult, error) {
+	return verifyDKIM(raw, lookupDKIMKey)
+}
+
+// VerifyDKIMKeys verifies a raw message as VerifyDKIM does, using the
+// public keys of the given signers, whatever their validity window, for
+// the signatures made with their domain and selector: messages signed
+// with a retired key still verify once its record is removed from DNS.
+func VerifyDKIMKeys(raw []byte, keys []*DKIMSigner) ([]DKIMResult, error) {
+	return verifyDKIM(raw, func(selector, domain, keyType string) (crypto.PublicKey, error) {
+		for _, s := range keys {
+			if !strings.EqualFold(s.Domain, domain) || !strings.EqualFold(s.Selector, selector) {
+				continue
+			}
+			if t, _ := s.keyType(); t != keyType {
+				return nil, fmt.Errorf("dkim: %s key for a %s signature", t, keyType)
+			}
+			return s.Key.Public(), nil
+		}
+		return lookupDKIMKey(selector, domain, keyType)
+	})
+}
+
+func verifyDKIM(raw []byte, lookup dkimKeyLookup) ([]DKIMResult, error) {
+	fields, body := splitMessage(raw)
+
+	var results []DKIMResult
+	for i := range fields {
+		if !strings.EqualFold(fields[i].name, "DKIM-Signature") {
+			continue
+		}
+
+		results = append(results, verifySignature(fields, i, body, lookup))
+	}
+
+	if len(results) == 0 {
+		return nil, errors.New("postman: no DKIM signature")
+	}
+
+	return results, nil
+}
+
+// dkimKeyLookup returns the public key of the given type published by a
+// domain under a selector.
+type dkimKeyLookup func(selector, domain, keyType string) (crypto.PublicKey, error)
+
+func verifySignature(fields []rawField, sig int, body []byte, lookup dkimKeyLookup) DKIMResult {
+	var res DKIMResult
+
+	tags, err := parseTags(fields[sig].value())
+	if err != nil {
+		res.Err = fmt.Errorf("dkim: %v", err)
+		return res
+	}
+
+	res.Domain, res.Selector = tags["d"], tags["s"]
+
+	for _, name := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if tags[name] == "" {
+			res.Err = fmt.Errorf("dkim: missing %s= tag", name)
+			return res
+		}
+	}
+
+	if tags["v"] != "1" {
+		res.Err = fmt.Errorf("dkim: unsupported version %q", tags["v"])
+		return res
+	}
+
+	if x := tags["x"]; x != "" {
+		exp, err := strconv.ParseInt(x, 10, 64)
+		if err != nil || time.Now().Unix() > exp {
+			res.Err = errors.New("dkim: signature expired")
+			return res
+		}
+	}
+
+	signed := strings.Split(tags["h"], ":")
+
+	hasFrom := false
+	for _, name := range signed {
+		if strings.EqualFold(name, "From") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		res.Err = errors.New("dkim: From is not signed")
+		return res
+	}
+
+	hc, bc, err := parseDKIMCanonicalization(tags["c"])
+	if err != nil {
+		res.Err = fmt.Errorf("dkim: %v", err)
+		return res
+	}
+
+	newHash, cryptoHash, keyType, err := dkimAlgorithm(tags["a"])
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	canonical := bc.Body(body)
+	if l := tags["l"]; l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 || n > len(canonical) {
+			res.Err = errors.New("dkim: invalid l= tag")
+			return res
+		}
+		canonical = canonical[:n]
+	}
+
+	bh := newHash()
+	bh.Write(canonical)
+
+	want, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil || subtle.ConstantTimeCompare(bh.Sum(nil), want) != 1 {
+		res.Err = errors.New("dkim: body hash mismatch")
+		return res
+	}
+
+	h := newHash()
+	writeSignedHeader(h, hc, fields[:sig], fields[sig+1:], signed)
+	h.Write([]byte(strings.TrimSuffix(hc.Header(stripSignature(fields[sig].raw)), "\r\n")))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		res.Err = errors.New("dkim: malformed signature")
+		return res
+	}
+
+	key, err := lookup(res.Selector, res.Domain, keyType)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, cryptoHash, h.Sum(nil), signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, h.Sum(nil), signature) {
+			err = errors.New("invalid signature")
+		}
+	}
+	if err != nil {
+		res.Err = fmt.Errorf("dkim: %v", err)
+	}
+
+	return res
+}
+
+func dkimAlgorithm(a string) (func() hash.Hash, crypto.Hash, string, error) {
+	switch a {
+	case "rsa-sha256":
+		return sha256.New, crypto.SHA256, "rsa", nil
+	case "rsa-sha1":
+		return sha1.New, crypto.SHA1, "rsa", nil
+	case "ed25519-sha256":
+		return sha256.New, crypto.SHA256, "ed25519", nil
+	}
+	return nil, 0, "", fmt.Errorf("dkim: unsupported algorithm %q", a)
+}
+
+// writeSignedHeader writes the canonical form of the signed fields to w.
+// Fields are both the ones above and below the signature; repeated
+// names select occurrences from the bottom up (RFC 6376 section 5.4.2).
+func writeSignedHeader(w io.Writer, c Canonicalization, above, below []rawField, signed []string) {
+	all := make([]rawField, 0, len(above)+len(below))
+	all = append(all, above...)
+	all = append(all, below...)
+
+	used := make([]bool, len(all))
+
+	for _, name := range signed {
+		name = strings.TrimSpace(name)
+		for i := len(all) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(all[i].name, name) {
+				continue
+			}
+			used[i] = true
+			w.Write([]byte(c.Header(all[i].raw)))
+			break
+		}
+	}
+}
+
+// stripSignature empties the b= tag of a raw DKIM-Signature field.
+func stripSignature(raw string) string {
+	i := strings.IndexByte(raw, ':') + 1
+	specs := strings.Split(raw[i:], ";")
+
+	for j, spec := range specs {
+		k := strings.IndexByte(spec, '=')
+		if k >= 0 && strings.TrimSpace(spec[:k]) == "b" {
+			specs[j] = spec[:k+1]
+			if strings.HasSuffix(spec, "\r\n") && j == len(specs)-1 {
+				specs[j] += "\r\n"
+			}
+		}
+	}
+
+	return raw[:i] + strings.Join(specs, ";")
+}
+
+func lookupDKIMKey(selector, domain, keyType string) (crypto.PublicKey, error) {
+	txts, err := lookupTXT(selector + "._domainkey." + domain)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: key lookup: %v", err)
+	}
+	if len(txts) == 0 {
+		return nil, errors.New("dkim: no key record")
+	}
+
+	tags, err := parseTags(strings.Join(txts, ""))
+	if err != nil {
+		return nil, fmt.Errorf("dkim: key record: %v", err)
+	}
+
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("dkim: unsupported key version %q", v)
+	}
+
+	k := tags["k"]
+	if k == "" {
+		k = "rsa"
+	}
+	if k != keyType {
+		return nil, fmt.Errorf("dkim: %s key for a %s signature", k, keyType)
+	}
+
+	p := tags["p"]
+	if p == "" {
+		return nil, errors.New("dkim: key revoked")
+	}
+
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: malformed key: %v", err)
+	}
+
+	if k == "ed25519" {
+		if len(der) != ed25519.PublicKeySize {
+			return nil, errors.New("dkim: malformed ed25519 key")
+		}
+		return ed25519.PublicKey(der), nil
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		if pub, err = x509.ParsePKCS1PublicKey(der); err != nil {
+			return nil, fmt.Errorf("dkim: malformed key: %v", err)
+		}
+	}
+
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("dkim: not an RSA key")
+	}
+
+	return rsaPub, nil
+}
+
+// defaultSignedFields are the fields a DKIMSigner signs when present,
+// unless its Headers says otherwise.
+var defaultSignedFields = []string{
+	"From", "Sender", "Reply-To", "To", "Cc", "Subject", "Date",
+	"Message-ID", "In-Reply-To", "References", "MIME-Version",
+	"Content-Type", "Content-Transfer-Encoding", "List-Id",
+	"List-Unsubscribe", "List-Unsubscribe-Post", "BIMI-Selector",
+}
+
+// DKIMSigner signs messages on behalf of a domain (RFC 6376).
+type DKIMSigner struct {
+	// Domain is the signing domain, the d= tag.
+	Domain string
+
+	// Selector is the name of the key in the _domainkey zone of Domain,
+	// the s= tag.
+	Selector string
+
+	// Key is the private key, either an *rsa.PrivateKey or an
+	// ed25519.PrivateKey.
+	Key crypto.Signer
+
+	// Headers lists the fields to sign. Empty means a default set of
+	// fields covering the originator, the recipients and the content.
+	// From is always signed.
+	Headers []string
+
+	HeaderCanonicalization Canonicalization
+	BodyCanonicalization   Canonicalization
+
+	// NotBefore and NotAfter bound the period during which the key signs
+	// messages; zero times leave it unbounded. When several keys of the
+	// same type are valid for a domain, as while they are rotated, the
+	// one valid since the latest time signs alone.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// ParseDKIMKey parses a PEM encoded PKCS #1 or PKCS #8 private key.
+func ParseDKIMKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("postman: no PEM encoded key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("postman: invalid key: %v", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("postman: unsupported key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// keyType returns the k= tag of the key record of the signer.
+func (s *DKIMSigner) keyType() (string, error) {
+	switch s.Key.(type) {
+	case *rsa.PrivateKey:
+		return "rsa", nil
+	case ed25519.PrivateKey:
+		return "ed25519", nil
+	}
+	return "", fmt.Errorf("postman: unsupported DKIM key type %T", s.Key)
+}
+
+// Valid reports whether the key signs messages at t.
+func (s *DKIMSigner) Valid(t time.Time) bool {
+	return (s.NotBefore.IsZero() || !t.Before(s.NotBefore)) &&
+		(s.NotAfter.IsZero() || t.Before(s.NotAfter))
+}
+
+// Record returns the DNS TXT record publishing the public key, under
+// the Selector name of the _domainkey zone of Domain.
+func (s *DKIMSigner) Record() (string, error) {
+	k, err := s.keyType()
+	if err != nil {
+		return "", err
+	}
+
+	var der []byte
+	if pub, ok := s.Key.Public().(ed25519.PublicKey); ok {
+		der = pub
+	} else if der, err = x509.MarshalPKIXPublicKey(s.Key.Public()); err != nil {
+		return "", err
+	}
+
+	return "v=DKIM1; k=" + k + "; p=" + base64.StdEncoding.EncodeToString(der), nil
+}
+
+// Sign returns the DKIM-Signature field for a raw message, terminating
+// CRLF included, to be prepended to it.
+func (s *DKIMSigner) Sign(raw []byte) (string, error) {
+	fields, body := splitMessage(raw)
+	bh := sha256.Sum256(s.BodyCanonicalization.Body(body))
+	return s.signHeader(fields, bh[:])
+}
+
+// signHeader returns the DKIM-Signature field for a message with the
+// given header fields and body hash.
+func (s *DKIMSigner) signHeader(fields []rawField, bh []byte) (string, error) {
+	k, err := s.keyType()
+	if err != nil {
+		return "", err
+	}
+	algorithm := k + "-sha256"
+
+	var signed []string
+	for _, name := range s.signedFields() {
+		for i := range fields {
+			if strings.EqualFold(fields[i].name, name) {
+				signed = append(signed, name)
+				break
+			}
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + algorithm,
+		"c=" + s.HeaderCanonicalization.String() + "/" + s.BodyCanonicalization.String(),
+		"d=" + s.Domain,
+		"s=" + s.Selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(signed, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bh),
+		"b=",
+	}
+
+	var b strings.Builder
+	bw := bufio.NewWriter(&b)
+	hw := headerWriter{w: bw}
+	hw.list("DKIM-Signature", tags, ";")
+	bw.Flush()
+
+	field := b.String()
+
+	h := sha256.New()
+	writeSignedHeader(h, s.HeaderCanonicalization, fields, nil, signed)
+	h.Write([]byte(strings.TrimSuffix(s.HeaderCanonicalization.Header(field), "\r\n")))
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if algorithm == "ed25519-sha256" {
+		opts = crypto.Hash(0)
+	}
+
+	signature, err := s.Key.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return "", err
+	}
+
+	// The b= value is ignored, folding included, when the signature is
+	// verified: it can be folded freely.
+	b.Reset()
+	b.WriteString(strings.TrimSuffix(field, "\r\n"))
+
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	for len(encoded) > 0 {
+		n := 72
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		b.WriteString("\r\n\t")
+		b.WriteString(encoded[:n])
+		encoded = encoded[n:]
+	}
+	b.WriteString("\r\n")
+
+	return b.String(), nil
+}
+
+func (s *DKIMSigner) signedFields() []string {
+	headers := s.Headers
+	if len(headers) == 0 {
+		headers = defaultSignedFields
+	}
+
+	for _, name := range headers {
+		if strings.EqualFold(name, "From") {
+			return headers
+		}
+	}
+
+	return append([]string{"From"}, headers...)
+}
+
+// currentSigners returns the signers valid at t, keeping for each domain
+// and key type the one valid since the latest time.
+func currentSigners(signers []*DKIMSigner, t time.Time) []*DKIMSigner {
+	current := make(map[string]int)
+
+	var res []*DKIMSigner
+	for _, s := range signers {
+		if !s.Valid(t) {
+			continue
+		}
+
+		k, _ := s.keyType()
+		key := strings.ToLower(s.Domain) + " " + k
+
+		if i, ok := current[key]; ok {
+			if s.NotBefore.After(res[i].NotBefore) {
+				res[i] = s
+			}
+			continue
+		}
+
+		current[key] = len(res)
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// aligned reports whether the signer domain is aligned with the domain
+// of the author, following the relaxed mode of DMARC (RFC 7489 section
+// 3.1.1).
+func (s *DKIMSigner) aligned(from string) bool {
+	domain, ok := addressDomain(from)
+	return ok && inDomain(domain, s.Domain)
+}
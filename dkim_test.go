@@ -0,0 +1,385 @@
+package postman
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc8463Key is the Ed25519 key of the examples of RFC 8463, published as
+// brisbane._domainkey.football.example.com.
+func rfc8463Key(t *testing.T) *DKIMSigner {
+	t.Helper()
+
+	seed, err := base64.StdEncoding.DecodeString("nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &DKIMSigner{
+		Domain:   "football.example.com",
+		Selector: "brisbane",
+		Key:      ed25519.NewKeyFromSeed(seed),
+	}
+}
+
+// rfc8463Message is the message of RFC 8463 appendix A.3, with its
+// Ed25519 signature only.
+const rfc8463Message = "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+	" subject : date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+	" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+// withDKIMKeys makes the DKIM key lookups return the records of the
+// given signers until the returned function is called.
+func withDKIMKeys(t *testing.T, signers ...*DKIMSigner) func() {
+	t.Helper()
+
+	records := make(map[string]string)
+	for _, s := range signers {
+		record, err := s.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[s.Selector+"._domainkey."+s.Domain] = record
+	}
+
+	lookup := lookupTXT
+	lookupTXT = func(name string) ([]string, error) {
+		if record, ok := records[name]; ok {
+			return []string{record}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	return func() { lookupTXT = lookup }
+}
+
+func TestDKIMRFC8463(t *testing.T) {
+	s := rfc8463Key(t)
+
+	record, err := s.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="; record != want {
+		t.Errorf("Record() = %q, want %q", record, want)
+	}
+
+	defer withDKIMKeys(t, s)()
+
+	results, err := VerifyDKIM([]byte(rfc8463Message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("got %+v, want a valid signature", results)
+	}
+	if r := results[0]; r.Domain != "football.example.com" || r.Selector != "brisbane" {
+		t.Errorf("signature of %s by %s", r.Domain, r.Selector)
+	}
+
+	tampered := strings.Replace(rfc8463Message, "hungry", "thirsty", 1)
+	if results, _ := VerifyDKIM([]byte(tampered)); results[0].Err == nil {
+		t.Error("tampered message verified")
+	}
+}
+
+// dkimMessage is the message signed by the round trip tests.
+const dkimMessage = "From: Sender <sender@example.com>\r\n" +
+	"To: rcpt@example.net\r\n" +
+	"Subject: Signed  message\r\n" +
+	"Date: Sat, 3 Feb 2001 04:05:06 +0000\r\n" +
+	"Message-ID: <dkim@example.com>\r\n" +
+	"\r\n" +
+	"Hello,\r\n" +
+	"\r\n" +
+	"World.\r\n"
+
+// signDKIM returns raw signed by s.
+func signDKIM(t *testing.T, s *DKIMSigner, raw string) string {
+	t.Helper()
+
+	field, err := s.Sign([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return field + raw
+}
+
+// verifyDKIMError returns the verification error of the single signature
+// of raw.
+func verifyDKIMError(t *testing.T, raw string) error {
+	t.Helper()
+
+	results, err := VerifyDKIM([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d signatures, want 1", len(results))
+	}
+	return results[0].Err
+}
+
+func TestDKIMRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signers := []*DKIMSigner{
+		{Domain: "example.com", Selector: "rsa", Key: rsaKey},
+		rfc8463Key(t),
+	}
+	signers[1].Domain = "example.com"
+	defer withDKIMKeys(t, signers...)()
+
+	canonicalizations := []Canonicalization{CanonicalizationSimple, CanonicalizationRelaxed}
+
+	for _, s := range signers {
+		for _, hc := range canonicalizations {
+			for _, bc := range canonicalizations {
+				s.HeaderCanonicalization, s.BodyCanonicalization = hc, bc
+				k, _ := s.keyType()
+				name := k + "-sha256 " + hc.String() + "/" + bc.String()
+
+				t.Run(name, func(t *testing.T) {
+					signed := signDKIM(t, s, dkimMessage)
+					if !strings.Contains(signed, "a="+k+"-sha256;") {
+						t.Errorf("signature without a=%s-sha256:\n%s", k, signed)
+					}
+
+					if err := verifyDKIMError(t, signed); err != nil {
+						t.Fatalf("signed message: %v", err)
+					}
+
+					// Keys given to VerifyDKIMKeys are used rather than
+					// DNS.
+					results, err := VerifyDKIMKeys([]byte(signed), []*DKIMSigner{s})
+					if err != nil || results[0].Err != nil {
+						t.Errorf("VerifyDKIMKeys: %v, %+v", err, results)
+					}
+
+					body := strings.Replace(signed, "World.", "Moon.", 1)
+					if err := verifyDKIMError(t, body); err == nil || !strings.Contains(err.Error(), "body hash mismatch") {
+						t.Errorf("tampered body: got %v", err)
+					}
+
+					header := strings.Replace(signed, "Subject: Signed", "Subject: Forged", 1)
+					if err := verifyDKIMError(t, header); err == nil {
+						t.Error("tampered header: got no error")
+					}
+
+					// Relaxed canonicalizations tolerate changes of
+					// whitespace, simple ones do not.
+					spaced := strings.Replace(signed, "Signed  message", "Signed message", 1)
+					spaced = strings.Replace(spaced, "World.\r\n", "World. \r\n\r\n", 1)
+					if err := verifyDKIMError(t, spaced); (err == nil) != (hc == CanonicalizationRelaxed && bc == CanonicalizationRelaxed) {
+						t.Errorf("whitespace changed: got %v", err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDKIMOverSigning(t *testing.T) {
+	s := rfc8463Key(t)
+	s.Domain = "example.com"
+	defer withDKIMKeys(t, s)()
+
+	// A field added on top of the message is the first one read by most
+	// readers.
+	forge := func(signed string) string {
+		return "Subject: Forged\r\n" + signed
+	}
+
+	s.Headers = []string{"From", "Subject"}
+	signed := signDKIM(t, s, dkimMessage)
+	if !strings.Contains(signed, "h=From:Subject;") {
+		t.Errorf("signed fields not From:Subject:\n%s", signed)
+	}
+	if err := verifyDKIMError(t, forge(signed)); err != nil {
+		t.Errorf("added field not signed: %v", err)
+	}
+
+	// Listing a field once more than it appears signs its absence.
+	s.Headers = []string{"From", "Subject", "Subject"}
+	signed = signDKIM(t, s, dkimMessage)
+	if !strings.Contains(signed, "h=From:Subject:Subject;") {
+		t.Errorf("signed fields not From:Subject:Subject:\n%s", signed)
+	}
+	if err := verifyDKIMError(t, signed); err != nil {
+		t.Fatalf("over-signed message: %v", err)
+	}
+	if err := verifyDKIMError(t, forge(signed)); err == nil {
+		t.Error("field added to an over-signed message: got no error")
+	}
+
+	// Fields absent from the message are not signed.
+	s.Headers = []string{"From", "Reply-To"}
+	if signed := signDKIM(t, s, dkimMessage); !strings.Contains(signed, "h=From;") {
+		t.Errorf("absent field signed:\n%s", signed)
+	}
+}
+
+func TestDKIMVerifyErrors(t *testing.T) {
+	s := rfc8463Key(t)
+	s.Domain = "example.com"
+	defer withDKIMKeys(t, s)()
+
+	signed := signDKIM(t, s, dkimMessage)
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"unknown selector", strings.Replace(signed, "s=brisbane", "s=other", 1), "key lookup"},
+		{"From not signed", strings.Replace(signed, "h=From:", "h=", 1), "From is not signed"},
+		{"unknown algorithm", strings.Replace(signed, "a=ed25519-sha256", "a=ed448-sha256", 1), "unsupported algorithm"},
+		{"version", strings.Replace(signed, "v=1", "v=2", 1), "unsupported version"},
+		{"expired", strings.Replace(signed, "v=1;", "v=1; x=1;", 1), "signature expired"},
+	}
+
+	for _, tt := range tests {
+		if err := verifyDKIMError(t, tt.raw); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := VerifyDKIM([]byte(dkimMessage)); err == nil {
+		t.Error("unsigned message: got no error")
+	}
+}
+
+// rotatedKeys returns two Ed25519 keys of example.com: old is being
+// replaced by new, both being valid during the overlap from new.NotBefore
+// to old.NotAfter.
+func rotatedKeys(t *testing.T, now time.Time) (old, new *DKIMSigner) {
+	t.Helper()
+
+	mk := func(selector string) *DKIMSigner {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &DKIMSigner{Domain: "example.com", Selector: selector, Key: key}
+	}
+
+	old, new = mk("old"), mk("new")
+	old.NotBefore, old.NotAfter = now.Add(-30*24*time.Hour), now.Add(24*time.Hour)
+	new.NotBefore = now.Add(-time.Hour)
+
+	return old, new
+}
+
+func TestCurrentSigners(t *testing.T) {
+	now := time.Now()
+	old, new := rotatedKeys(t, now)
+
+	other := rfc8463Key(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigner := &DKIMSigner{Domain: "example.com", Selector: "rsa", Key: rsaKey}
+
+	signers := []*DKIMSigner{old, new, other, rsaSigner}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []*DKIMSigner
+	}{
+		{"before the rotation", now.Add(-2 * time.Hour), []*DKIMSigner{old, other, rsaSigner}},
+		{"during the overlap", now, []*DKIMSigner{new, other, rsaSigner}},
+		{"after the overlap", now.Add(48 * time.Hour), []*DKIMSigner{new, other, rsaSigner}},
+		{"before every key", now.Add(-60 * 24 * time.Hour), []*DKIMSigner{other, rsaSigner}},
+	}
+
+	for _, tt := range tests {
+		got := currentSigners(signers, tt.at)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d signers, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: signer %d: %s, want %s", tt.name, i, got[i].Selector, tt.want[i].Selector)
+			}
+		}
+	}
+}
+
+// TestClientKeyRotation checks that a client signs with the latest key
+// only, and that the messages signed with the previous one still verify
+// during the overlap.
+func TestClientKeyRotation(t *testing.T) {
+	now := time.Now()
+	old, new := rotatedKeys(t, now)
+
+	// Only the new key is published.
+	defer withDKIMKeys(t, new)()
+
+	// A message signed before the rotation.
+	signedBefore := signDKIM(t, old, dkimMessage)
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	c.DKIM = []*DKIMSigner{old, new}
+	defer c.Close()
+
+	m := benchMail(1)
+	if err := c.Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(msgs))
+	}
+
+	results, err := VerifyDKIM([]byte(msgs[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Selector != "new" || results[0].Err != nil {
+		t.Errorf("got %+v, want a valid signature of the new key", results)
+	}
+
+	// The previous key is no longer in DNS, but is still known to the
+	// verifier.
+	if err := verifyDKIMError(t, signedBefore); err == nil {
+		t.Error("message of the unpublished key verified from DNS")
+	}
+	results, err = VerifyDKIMKeys([]byte(signedBefore), []*DKIMSigner{old, new})
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Errorf("message of the previous key: %v, %+v", err, results)
+	}
+}
@@ -0,0 +1,304 @@
+package postman
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entity is a node of a MIME tree, for the bodies whose structure does not
+// fit the parts, attachments and digest of a Mail, such as an HTML part
+// related to its inline images, alternative to a text part, the whole
+// mixed with attachments:
+//
+//	m.Body = postman.NewMultipart("mixed",
+//		postman.NewMultipart("alternative",
+//			text.Entity(),
+//			postman.NewMultipart("related",
+//				html.Entity(),
+//				logo.Entity(),
+//			),
+//		),
+//		report.Entity(),
+//	)
+type Entity struct {
+	// ContentType is the media type of the entity, e.g. "image/png" or
+	// "multipart/related". As for parts, parameters following it are
+	// accepted, Params overriding them. The boundary of multipart
+	// entities is generated.
+	ContentType string            `json:"content_type"`
+	Params      map[string]string `json:"params,omitempty"`
+
+	// Charset, if set, is the charset the content is converted to from
+	// UTF-8, as for parts.
+	Charset string `json:"charset,omitempty"`
+
+	// Header holds the other fields of the entity, such as
+	// Content-Disposition or Content-ID.
+	Header textproto.MIMEHeader `json:"header,omitempty"`
+
+	// ContentTransferEncoding is empty to let the content be encoded as
+	// needed: text and message entities are written as they are, others
+	// in base64. As for attachments, "base64" encodes the content and any
+	// other value means it is already encoded.
+	ContentTransferEncoding string `json:"content_transfer_encoding,omitempty"`
+
+	// Content is the content of leaf entities.
+	Content []byte `json:"content,omitempty"`
+
+	// Children are the entities of multipart entities.
+	Children []*Entity `json:"children,omitempty"`
+}
+
+// NewEntity returns a leaf entity.
+func NewEntity(contentType string, content []byte) *Entity {
+	return &Entity{ContentType: contentType, Content: content}
+}
+
+// NewMultipart returns a multipart entity, subtype being "mixed",
+// "alternative", "related" or any other multipart subtype.
+func NewMultipart(subtype string, children ...*Entity) *Entity {
+	return &Entity{ContentType: "multipart/" + subtype, Children: children}
+}
+
+// Entity returns the part as an entity.
+func (p *Part) Entity() *Entity {
+	return &Entity{
+		ContentType: p.ContentType,
+		Params:      p.Params,
+		Charset:     p.Charset,
+		Content:     p.Content,
+	}
+}
+
+// Entity returns the attachment as an entity, with the same fields as
+// when it is written as an attachment of a message. Attachments given by
+// URL must be fetched first.
+func (a *Attachment) Entity() *Entity {
+	ctype := mime.TypeByExtension(filepath.Ext(a.Filename))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	disposition := a.ContentDisposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	e := Entity{
+		ContentType:             ctype,
+		Header:                  make(textproto.MIMEHeader),
+		ContentTransferEncoding: a.ContentTransfertEncoding,
+		Content:                 a.Content,
+	}
+
+	if e.ContentTransferEncoding == "" {
+		e.ContentTransferEncoding = "base64"
+	}
+
+	if a.Filename != "" {
+		e.Params = map[string]string{"name": a.Filename}
+		e.Header.Set("Content-Disposition", formatMediaType(disposition, "filename", a.Filename))
+	} else {
+		e.Header.Set("Content-Disposition", disposition)
+	}
+
+	if a.ContentID != "" {
+		e.Header.Set("Content-Id", "<"+a.ContentID+">")
+	}
+
+	return &e
+}
+
+// MediaType returns the media type of the entity and its parameters,
+// merging those given in ContentType and in Params.
+func (e *Entity) MediaType() (string, map[string]string, error) {
+	return mediaType(e.ContentType, e.Params)
+}
+
+func (e *Entity) isMultipart() bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(e.ContentType)), "multipart/")
+}
+
+// encode returns e, or a copy of the tree if some of its entities have
+// a Charset, with their content converted and the charset set in their
+// parameters.
+func (e *Entity) encode() (*Entity, error) {
+	if e.Charset != "" {
+		mediatype, params, err := e.MediaType()
+		if err != nil {
+			return nil, err
+		}
+
+		ce := *e
+		if ce.Content, err = encodeCharset(e.Charset, e.Content); err != nil {
+			return nil, err
+		}
+
+		params["charset"] = e.Charset
+		ce.ContentType, ce.Params, ce.Charset = mediatype, params, ""
+		e = &ce
+	}
+
+	var children []*Entity
+	for i, c := range e.Children {
+		cc, err := c.encode()
+		if err != nil {
+			return nil, err
+		}
+
+		if cc != c && children == nil {
+			children = append([]*Entity(nil), e.Children...)
+		}
+		if children != nil {
+			children[i] = cc
+		}
+	}
+
+	if children != nil {
+		ce := *e
+		ce.Children = children
+		e = &ce
+	}
+
+	return e, nil
+}
+
+// transferEncoding returns the Content-Transfer-Encoding of a leaf
+// entity, empty for 7bit content written as it is.
+func (e *Entity) transferEncoding() string {
+	if e.ContentTransferEncoding != "" {
+		return e.ContentTransferEncoding
+	}
+
+	mediatype := strings.ToLower(strings.TrimSpace(e.ContentType))
+	switch {
+	case strings.HasPrefix(mediatype, "text/"):
+		return textEncoding(e.Content)
+
+	case strings.HasPrefix(mediatype, "message/"):
+		// Messages may not be encoded (RFC 2046 section 5.2.1).
+		if !is7bit(e.Content) {
+			return "8bit"
+		}
+		return ""
+	}
+
+	return "base64"
+}
+
+// fields appends the header fields of the entity to fields and returns
+// the boundary of its children, if it is multipart.
+func (e *Entity) fields(fields []headerField) ([]headerField, string, error) {
+	mediatype, params, err := e.MediaType()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var boundary string
+	if e.isMultipart() {
+		if len(e.Children) == 0 {
+			return nil, "", fmt.Errorf("postman: %s entity without children", mediatype)
+		}
+
+		if boundary, err = genBoundary(); err != nil {
+			return nil, "", err
+		}
+		params["boundary"] = boundary
+	} else {
+		defaultCharset(mediatype, params, e.Content)
+	}
+
+	ctype := mime.FormatMediaType(mediatype, params)
+	if ctype == "" {
+		return nil, "", fmt.Errorf("postman: invalid content type %q", mediatype)
+	}
+
+	fields = append(fields, headerField{name: "Content-Type", value: ctype})
+
+	if boundary == "" {
+		if cte := e.transferEncoding(); cte != "" {
+			fields = append(fields, headerField{name: "Content-Transfer-Encoding", value: cte})
+		}
+	}
+
+	keys := make([]string, 0, len(e.Header))
+	for k := range e.Header {
+		switch textproto.CanonicalMIMEHeaderKey(k) {
+		case "Content-Type", "Content-Transfer-Encoding":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range e.Header[k] {
+			fields = append(fields, headerField{name: k, value: v})
+		}
+	}
+
+	return fields, boundary, nil
+}
+
+// writeContent writes the body of the entity, boundary being the one
+// returned by fields.
+func (e *Entity) writeContent(w *bufio.Writer, boundary string) error {
+	if boundary != "" {
+		hw := headerWriter{w: w}
+
+		for _, c := range e.Children {
+			fields, inner, err := c.fields(nil)
+			if err != nil {
+				return err
+			}
+
+			writeBoundary(w, boundary)
+			for i := range fields {
+				hw.write(&fields[i])
+			}
+			if hw.err != nil {
+				return hw.err
+			}
+			w.WriteString("\r\n")
+
+			if err := c.writeContent(w, inner); err != nil {
+				return err
+			}
+		}
+
+		writeCloseBoundary(w, boundary)
+
+		return nil
+	}
+
+	if e.ContentTransferEncoding == "" && strings.HasPrefix(strings.ToLower(strings.TrimSpace(e.ContentType)), "text/") {
+		return writeText(w, e.Content)
+	}
+
+	switch e.transferEncoding() {
+	case "", "8bit":
+		if e.ContentTransferEncoding == "" {
+			writeLines(w, e.Content)
+			return nil
+		}
+
+	case "base64":
+		bw := getBase64Writer(w)
+		defer putBase64Writer(bw)
+
+		if _, err := bw.Write(e.Content); err != nil {
+			return err
+		}
+		return bw.Close()
+	}
+
+	// Any other encoding means the content is already encoded by the
+	// caller.
+	_, err := w.Write(e.Content)
+	return err
+}
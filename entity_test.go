@@ -0,0 +1,98 @@
+package postman
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+func TestEntityRender(t *testing.T) {
+	forwarded := []byte("From: jane@example.com\r\nSubject: Café\r\n\r\nHéllo\r\n")
+
+	text := NewEntity("text/plain", []byte("Hello, café"))
+	text.Charset = "iso-8859-1"
+
+	m := &Mail{
+		Date:      time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC),
+		MessageID: "<m1@postman.test>",
+		From:      "sender@example.com",
+		Subject:   "Forwarded",
+		Body: NewMultipart("mixed",
+			NewMultipart("alternative", text, NewEntity("text/html", []byte("<p>Hello</p>"))),
+			NewEntity("message/rfc822", forwarded),
+			NewEntity("message/rfc822", []byte("From: john@example.com\r\n\r\nHello\r\n")),
+			NewEntity("image/png", []byte("png")),
+			NewEntity("text/plain", []byte("Voilà, en français.")),
+		),
+	}
+
+	b, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The leaves are written with their encoding; messages as they are,
+	// 8bit if need be.
+	for _, leaf := range []string{
+		"Content-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nHello, caf=E9\r\n",
+		"Content-Type: text/html\r\n\r\n<p>Hello</p>\r\n",
+		"Content-Type: message/rfc822\r\nContent-Transfer-Encoding: 8bit\r\n\r\n" + string(forwarded),
+		"Content-Type: message/rfc822\r\n\r\nFrom: john@example.com\r\n\r\nHello\r\n",
+		"Content-Type: image/png\r\nContent-Transfer-Encoding: base64\r\n\r\ncG5n\r\n",
+		// UTF-8 text is declared as such.
+		"Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\nVm9pbMOgLCBlbiBmcmFuw6dhaXMu\r\n",
+	} {
+		if !bytes.Contains(b, []byte(leaf)) {
+			t.Errorf("%s does not contain\n%s", b, leaf)
+		}
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := multipartReader(t, msg.Header.Get("Content-Type"), "multipart/mixed", msg.Body)
+
+	p, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alternative := multipartReader(t, p.Header.Get("Content-Type"), "multipart/alternative", p)
+	if n := countParts(t, alternative); n != 2 {
+		t.Errorf("%d alternatives, want 2", n)
+	}
+	if n := countParts(t, mixed); n != 4 {
+		t.Errorf("%d entities after the alternatives, want 4", n)
+	}
+}
+
+// countParts returns the number of parts left in r.
+func countParts(t *testing.T, r *multipart.Reader) int {
+	t.Helper()
+
+	for n := 0; ; n++ {
+		_, err := r.NextPart()
+		if err == io.EOF {
+			return n
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEntityErrors(t *testing.T) {
+	for _, e := range []*Entity{
+		NewMultipart("mixed"),
+		NewMultipart("mixed", NewEntity("text/plain", nil), NewMultipart("related")),
+		NewEntity("text/", nil),
+	} {
+		m := &Mail{From: "sender@example.com", Body: e}
+		if _, err := m.Bytes(); err == nil {
+			t.Errorf("%s entity rendered", e.ContentType)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package postman
+
+import (
+	"net/mail"
+	"time"
+)
+
+// NullReversePath is the empty reverse-path, for messages which must
+// never cause a bounce such as delivery notifications or auto replies
+// (RFC 5321 section 4.5.5).
+const NullReversePath = "<>"
+
+// Envelope is the SMTP envelope of a message, as opposed to its header
+// (RFC 5321 section 2.3.1).
+type Envelope struct {
+	// MailFrom is the reverse-path given in MAIL FROM, i.e. the bounce
+	// address. Empty means the Sender of the message, or its From when
+	// there is no Sender.
+	MailFrom string
+
+	// RcptTo is the list of forward-paths given in RCPT TO. Empty means
+	// every To, Cc and Bcc recipient of the message.
+	RcptTo []string
+
+	// DeliverBy, if positive, is the time within which the message must
+	// be delivered (RFC 2852). Messages which are not are returned to the
+	// sender as failed, unless DeliverByNotify is set, in which case the
+	// delivery goes on and the sender is notified of the delay.
+	DeliverBy       time.Duration
+	DeliverByNotify bool
+
+	// RequireTLS fails the delivery of the message, rather than sending
+	// it in clear, when the connection to the server is not encrypted,
+	// whatever the TLS mode of the client: clients which disable TLS
+	// open an encrypted connection for it.
+	RequireTLS bool
+}
+
+// ReversePath returns the bare address to send in MAIL FROM. It returns
+// an empty string for the null reverse-path.
+func (m *Mail) ReversePath() (string, error) {
+	from := m.Envelope.MailFrom
+	switch {
+	case from == NullReversePath:
+		return "", nil
+	case from == "" && m.Sender != "":
+		from = m.Sender
+	case from == "":
+		from = m.From
+	}
+
+	return bareAddress(from)
+}
+
+// Recipients returns the bare addresses to send in RCPT TO.
+func (m *Mail) Recipients() ([]string, error) {
+	rcpts := m.Envelope.RcptTo
+	if len(rcpts) == 0 {
+		rcpts = make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+		rcpts = append(rcpts, m.To...)
+		rcpts = append(rcpts, m.Cc...)
+		rcpts = append(rcpts, m.Bcc...)
+	}
+
+	addrs := make([]string, len(rcpts))
+	for i, rcpt := range rcpts {
+		addr, err := bareAddress(rcpt)
+		if err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+	}
+
+	return addrs, nil
+}
+
+func bareAddress(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
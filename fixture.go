@@ -0,0 +1,233 @@
+package postman
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/textproto"
+	"path/filepath"
+	texttemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mailFile is a message defined in a YAML file, as loaded by LoadMail.
+type mailFile struct {
+	From       string   `yaml:"from"`
+	Sender     string   `yaml:"sender"`
+	ReplyTo    string   `yaml:"reply_to"`
+	To         []string `yaml:"to"`
+	Cc         []string `yaml:"cc"`
+	Bcc        []string `yaml:"bcc"`
+	Subject    string   `yaml:"subject"`
+	MessageID  string   `yaml:"message_id"`
+	InReplyTo  string   `yaml:"in_reply_to"`
+	References []string `yaml:"references"`
+	Keywords   []string `yaml:"keywords"`
+
+	Importance  string `yaml:"importance"`
+	Priority    string `yaml:"priority"`
+	Sensitivity string `yaml:"sensitivity"`
+
+	Mailer   string `yaml:"mailer"`
+	Campaign string `yaml:"campaign"`
+
+	Headers map[string]yamlStrings `yaml:"headers"`
+
+	Template *templateFile `yaml:"template"`
+
+	Parts       []partFile       `yaml:"parts"`
+	Attachments []attachmentFile `yaml:"attachments"`
+}
+
+type templateFile struct {
+	// Subject is the subject template itself, since it is a single
+	// line.
+	Subject string `yaml:"subject"`
+
+	// Text and HTML are the paths of the templates of the body.
+	Text string `yaml:"text"`
+	HTML string `yaml:"html"`
+
+	Data interface{} `yaml:"data"`
+}
+
+type partFile struct {
+	ContentType string            `yaml:"content_type"`
+	Params      map[string]string `yaml:"params"`
+	Charset     string            `yaml:"charset"`
+	Content     string            `yaml:"content"`
+
+	// Path is read instead of Content when set.
+	Path string `yaml:"path"`
+}
+
+type attachmentFile struct {
+	Path                    string `yaml:"path"`
+	Filename                string `yaml:"filename"`
+	ContentDisposition      string `yaml:"content_disposition"`
+	ContentID               string `yaml:"content_id"`
+	ContentTransferEncoding string `yaml:"content_transfer_encoding"`
+	URL                     string `yaml:"url"`
+}
+
+// yamlStrings is a list of strings which may be written as a single
+// string.
+type yamlStrings []string
+
+func (ss *yamlStrings) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*ss = yamlStrings{value.Value}
+		return nil
+	}
+
+	return value.Decode((*[]string)(ss))
+}
+
+// LoadMail reads a message defined in a YAML file, such as a test fixture
+// or an alert:
+//
+//	from: Alerts <alerts@example.com>
+//	to: [ops@example.com]
+//	priority: urgent
+//	headers:
+//	  X-Alert: disk-full
+//	template:
+//	  subject: "Disk full on {{.host}}"
+//	  text: disk-full.txt
+//	  html: disk-full.html
+//	  data:
+//	    host: db1
+//	attachments:
+//	  - path: runbooks/disk-full.pdf
+//
+// Relative paths are relative to the directory of the file. Templates are
+// executed with their data after the parts given in the file are added;
+// attachments with a url instead of a path are left to FetchAttachments.
+func LoadMail(path string) (*Mail, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mf mailFile
+	if err := yaml.Unmarshal(data, &mf); err != nil {
+		return nil, fmt.Errorf("postman: %s: %v", path, err)
+	}
+
+	m, err := mf.mail(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("postman: %s: %v", path, err)
+	}
+
+	return m, nil
+}
+
+func (mf *mailFile) mail(dir string) (*Mail, error) {
+	m := Mail{
+		From:       mf.From,
+		Sender:     mf.Sender,
+		ReplyTo:    mf.ReplyTo,
+		To:         mf.To,
+		Cc:         mf.Cc,
+		Bcc:        mf.Bcc,
+		Subject:    mf.Subject,
+		MessageID:  mf.MessageID,
+		InReplyTo:  mf.InReplyTo,
+		References: mf.References,
+		Keywords:   mf.Keywords,
+		Mailer:     mf.Mailer,
+		Campaign:   mf.Campaign,
+	}
+
+	var err error
+
+	if mf.Importance != "" {
+		if m.Importance, err = ParseImportance(mf.Importance); err != nil {
+			return nil, err
+		}
+	}
+	if mf.Priority != "" {
+		if m.Priority, err = ParsePriority(mf.Priority); err != nil {
+			return nil, err
+		}
+	}
+	if mf.Sensitivity != "" {
+		if m.Sensitivity, err = ParseSensitivity(mf.Sensitivity); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(mf.Headers) > 0 {
+		m.Header = make(textproto.MIMEHeader, len(mf.Headers))
+		for k, v := range mf.Headers {
+			m.Header[textproto.CanonicalMIMEHeaderKey(k)] = v
+		}
+	}
+
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	for _, pf := range mf.Parts {
+		p := Part{ContentType: pf.ContentType, Params: pf.Params, Charset: pf.Charset, Content: []byte(pf.Content)}
+		if pf.Path != "" {
+			if p.Content, err = ioutil.ReadFile(resolve(pf.Path)); err != nil {
+				return nil, err
+			}
+		}
+		m.Parts = append(m.Parts, p)
+	}
+
+	if tf := mf.Template; tf != nil {
+		var t Template
+
+		if tf.Subject != "" {
+			if t.Subject, err = texttemplate.New("subject").Parse(tf.Subject); err != nil {
+				return nil, err
+			}
+		}
+		if tf.Text != "" {
+			if t.Text, err = texttemplate.ParseFiles(resolve(tf.Text)); err != nil {
+				return nil, err
+			}
+		}
+		if tf.HTML != "" {
+			if t.HTML, err = htmltemplate.ParseFiles(resolve(tf.HTML)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := t.Execute(&m, tf.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, af := range mf.Attachments {
+		a := Attachment{
+			Filename:                 af.Filename,
+			ContentDisposition:       af.ContentDisposition,
+			ContentID:                af.ContentID,
+			ContentTransfertEncoding: af.ContentTransferEncoding,
+			URL:                      af.URL,
+		}
+
+		if af.Path != "" {
+			if a.Content, err = ioutil.ReadFile(resolve(af.Path)); err != nil {
+				return nil, err
+			}
+			if a.Filename == "" {
+				a.Filename = filepath.Base(af.Path)
+			}
+		} else if af.URL == "" {
+			return nil, fmt.Errorf("attachment without path or url")
+		}
+
+		m.Attachments = append(m.Attachments, a)
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,51 @@
+package postman
+
+import (
+	"bytes"
+)
+
+// Checks of the fuzz targets of gofuzz.go. Besides not crashing, the
+// lenient mode must accept whatever the strict one does. They return 1
+// for the inputs the strict parser accepts, which go-fuzz favors.
+
+var fuzzParsers = []Parser{
+	{Mode: ParseStrict, MaxSize: 1 << 20, MaxDepth: 8, MaxParts: 100},
+	{Mode: ParseLenient, MaxSize: 1 << 20, MaxDepth: 8, MaxParts: 100},
+}
+
+func fuzzParseMail(data []byte) int {
+	m, err := fuzzParsers[0].ParseMail(bytes.NewReader(data))
+	if _, lerr := fuzzParsers[1].ParseMail(bytes.NewReader(data)); err == nil && lerr != nil {
+		panic("lenient parser rejects a message accepted by the strict one: " + lerr.Error())
+	}
+	if err != nil {
+		return 0
+	}
+
+	// Rendering may fail on what was parsed, but must not crash.
+	m.Bytes()
+
+	return 1
+}
+
+func fuzzParseDSN(data []byte) int {
+	_, err := fuzzParsers[0].ParseDSN(bytes.NewReader(data))
+	if _, lerr := fuzzParsers[1].ParseDSN(bytes.NewReader(data)); err == nil && lerr != nil {
+		panic("lenient parser rejects a DSN accepted by the strict one: " + lerr.Error())
+	}
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+func fuzzParseFeedbackReport(data []byte) int {
+	_, err := fuzzParsers[0].ParseFeedbackReport(bytes.NewReader(data))
+	if _, lerr := fuzzParsers[1].ParseFeedbackReport(bytes.NewReader(data)); err == nil && lerr != nil {
+		panic("lenient parser rejects a report accepted by the strict one: " + lerr.Error())
+	}
+	if err != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,202 @@
+package postman
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runCorpus runs the check of a fuzz target on its corpus, and returns
+// the results by file name.
+func runCorpus(t *testing.T, target string, check func([]byte) int) map[string]int {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "fuzz", target, "corpus", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no corpus for %s", target)
+	}
+
+	results := make(map[string]int)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: %v", file, r)
+				}
+			}()
+			results[filepath.Base(file)] = check(data)
+		}()
+	}
+
+	return results
+}
+
+// checkAccepted checks which inputs of the corpus the strict parser
+// accepts.
+func checkAccepted(t *testing.T, results map[string]int, want map[string]bool) {
+	t.Helper()
+
+	for name, accepted := range want {
+		got, ok := results[name]
+		if !ok {
+			t.Errorf("%s: not in the corpus", name)
+			continue
+		}
+		if (got == 1) != accepted {
+			t.Errorf("%s: accepted by the strict parser: %t, want %t", name, got == 1, accepted)
+		}
+	}
+}
+
+func TestFuzzParseMailCorpus(t *testing.T) {
+	results := runCorpus(t, "FuzzParseMail", fuzzParseMail)
+	checkAccepted(t, results, map[string]bool{
+		"plain.eml":       true,
+		"alternative.eml": true,
+		"mixed.eml":       true,
+		"truncated.eml":   false,
+		"nested.eml":      false,
+		"bad-header.eml":  false,
+	})
+}
+
+func TestFuzzParseDSNCorpus(t *testing.T) {
+	results := runCorpus(t, "FuzzParseDSN", fuzzParseDSN)
+	checkAccepted(t, results, map[string]bool{
+		"failed.eml":       true,
+		"not-a-report.eml": false,
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "fuzz", "FuzzParseDSN", "corpus", "failed.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseDSN(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ReportingMTA != "mx.example.net" || d.OriginalMessageID != "original@example.com" || len(d.Recipients) != 2 {
+		t.Fatalf("got %+v", d)
+	}
+	if rcpt := d.Recipients[0]; rcpt.Action != "failed" || rcpt.Status != "5.1.1" {
+		t.Errorf("first recipient: got %+v", rcpt)
+	}
+}
+
+func TestFuzzParseFeedbackReportCorpus(t *testing.T) {
+	results := runCorpus(t, "FuzzParseFeedbackReport", fuzzParseFeedbackReport)
+	checkAccepted(t, results, map[string]bool{
+		"abuse.eml":        true,
+		"headers-only.eml": true,
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "fuzz", "FuzzParseFeedbackReport", "corpus", "abuse.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFeedbackReport(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FeedbackType != "abuse" || f.OriginalMessageID != "original@example.com" || len(f.OriginalRcptTo) != 1 {
+		t.Errorf("got %+v", f)
+	}
+}
+
+// TestParseLimits checks that hostile messages are stopped by the limits
+// in both modes.
+func TestParseLimits(t *testing.T) {
+	nested, err := ioutil.ReadFile(filepath.Join("testdata", "fuzz", "FuzzParseMail", "corpus", "nested.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var many bytes.Buffer
+	many.WriteString("Content-Type: multipart/mixed; boundary=b\r\n\r\n")
+	for i := 0; i < 200; i++ {
+		many.WriteString("--b\r\n\r\npart\r\n")
+	}
+	many.WriteString("--b--\r\n")
+
+	large := append([]byte("Subject: large\r\n\r\n"), bytes.Repeat([]byte("0123456789\r\n"), 1<<17)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"nested", nested, ErrNestingTooDeep},
+		{"many parts", many.Bytes(), ErrTooManyParts},
+		{"large", large, ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		for _, p := range fuzzParsers {
+			if _, err := p.ParseMail(bytes.NewReader(tt.data)); err != tt.want {
+				t.Errorf("%s, mode %d: got %v, want %v", tt.name, p.Mode, err, tt.want)
+			}
+		}
+	}
+}
+
+// TestFuzzParseMailRoundTrip checks that the messages of the corpus
+// accepted by the strict parser parse back to the same message once
+// rendered.
+func TestFuzzParseMailRoundTrip(t *testing.T) {
+	for _, name := range []string{"plain.eml", "alternative.eml", "mixed.eml"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "fuzz", "FuzzParseMail", "corpus", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := ParseMail(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Those generated when rendering would differ.
+		if m.Date.IsZero() {
+			m.Date = time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+		}
+		if m.MessageID == "" {
+			m.MessageID = "<" + name + "@postman.test>"
+		}
+
+		b, err := m.Bytes()
+		if err != nil {
+			t.Fatalf("%s: rendering: %v", name, err)
+		}
+		got, err := ParseMail(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: parsing the rendering: %v", name, err)
+		}
+
+		if !got.Date.Equal(m.Date) {
+			t.Errorf("%s: Date: got %s, want %s", name, got.Date, m.Date)
+		}
+		got.Date = m.Date
+
+		// Parameters are parsed from the Content-Type written, if any.
+		for _, m := range []*Mail{m, got} {
+			for i := range m.Parts {
+				if len(m.Parts[i].Params) == 0 {
+					m.Parts[i].Params = nil
+				}
+			}
+		}
+
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%s: got\n%+v\nwant\n%+v", name, got, m)
+		}
+	}
+}
@@ -1,3 +1,10 @@
 module github.com/jobteaser/postman
 
-go 1.12
+go 1.13
+
+require (
+	github.com/golang/protobuf v1.3.2
+	golang.org/x/text v0.3.6
+	google.golang.org/grpc v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,24 @@
+//go:build gofuzz
+// +build gofuzz
+
+package postman
+
+// Fuzz targets for go-fuzz (github.com/dvyukov/go-fuzz), built with the
+// gofuzz tag, whose seed corpus is in testdata/fuzz:
+//
+//	go-fuzz-build -func FuzzParseMail github.com/jobteaser/postman
+//	go-fuzz -bin postman-fuzz.zip -func FuzzParseMail -workdir testdata/fuzz/FuzzParseMail
+//
+// The checks are in fuzz.go, for the tests to run them on the corpus.
+
+func FuzzParseMail(data []byte) int {
+	return fuzzParseMail(data)
+}
+
+func FuzzParseDSN(data []byte) int {
+	return fuzzParseDSN(data)
+}
+
+func FuzzParseFeedbackReport(data []byte) int {
+	return fuzzParseFeedbackReport(data)
+}
@@ -0,0 +1,223 @@
+package postman
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+)
+
+// Lines SHOULD NOT be longer than 78 characters, excluding the CRLF
+// (RFC 5322 section 2.1.1).
+const maxLineLength = 78
+
+// headerField is a header field waiting to be written. Fields holding a
+// list are folded between list elements only.
+type headerField struct {
+	name  string
+	value string
+	list  []string
+	sep   string
+}
+
+// sortFields reorders fields following order. Fields which are not in
+// order keep their relative position after the ordered ones.
+func sortFields(fields []headerField, order []string) {
+	rank := func(name string) int {
+		for i, o := range order {
+			if strings.EqualFold(o, name) {
+				return i
+			}
+		}
+		return len(order)
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return rank(fields[i].name) < rank(fields[j].name)
+	})
+}
+
+// headerWriter writes header fields directly into w, folding long values
+// on whitespace. Write errors are sticky on bufio.Writer and are reported
+// by Flush.
+type headerWriter struct {
+	w   *bufio.Writer
+	col int
+
+	// err is the first invalid field met, which is not written: line
+	// breaks in names or values would inject other fields.
+	err error
+}
+
+// validFieldName reports whether name is made of printable US-ASCII
+// characters other than colon (RFC 5322 section 2.2).
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < '!' || c > '~' || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// validFieldValue reports whether value has no control characters other
+// than horizontal tabs.
+func validFieldValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// check records the first invalid field and reports whether the field
+// may be written.
+func (hw *headerWriter) check(key string, values ...string) bool {
+	if !validFieldName(key) {
+		if hw.err == nil {
+			hw.err = fmt.Errorf("postman: invalid header field name %q", key)
+		}
+		return false
+	}
+
+	for _, v := range values {
+		if !validFieldValue(v) {
+			if hw.err == nil {
+				hw.err = fmt.Errorf("postman: control character in %s field %q", key, v)
+			}
+			return false
+		}
+	}
+
+	return true
+}
+
+func (hw *headerWriter) write(f *headerField) {
+	if f.list != nil {
+		hw.list(f.name, f.list, f.sep)
+	} else {
+		hw.field(f.name, f.value)
+	}
+}
+
+// field writes a single "key: value" field, folding value at spaces.
+func (hw *headerWriter) field(key, value string) {
+	if !hw.check(key, value) {
+		return
+	}
+
+	hw.key(key)
+
+	for i := 0; i == 0 || value != ""; i++ {
+		var word string
+		if j := strings.IndexByte(value, ' '); j >= 0 {
+			word, value = value[:j], value[j+1:]
+		} else {
+			word, value = value, ""
+		}
+
+		if i == 0 {
+			hw.w.WriteByte(' ')
+			hw.col++
+		} else {
+			hw.space(len(word))
+		}
+
+		hw.w.WriteString(word)
+		hw.col += len(word)
+	}
+
+	hw.end()
+}
+
+// list writes values separated by sep, folding between values only.
+func (hw *headerWriter) list(key string, values []string, sep string) {
+	if !hw.check(key, values...) {
+		return
+	}
+
+	hw.key(key)
+
+	for i, v := range values {
+		if i == 0 {
+			hw.w.WriteByte(' ')
+			hw.col++
+		} else {
+			hw.w.WriteString(sep)
+			hw.col += len(sep)
+			hw.space(len(v))
+		}
+
+		hw.w.WriteString(v)
+		hw.col += len(v)
+	}
+
+	hw.end()
+}
+
+func (hw *headerWriter) key(key string) {
+	hw.w.WriteString(key)
+	hw.w.WriteByte(':')
+	hw.col = len(key) + 1
+}
+
+// space writes the whitespace preceding a token of length n, folding the
+// line first when the token would not fit.
+func (hw *headerWriter) space(n int) {
+	if hw.col+1+n > maxLineLength {
+		hw.w.WriteString("\r\n")
+		hw.col = 0
+	}
+
+	hw.w.WriteByte(' ')
+	hw.col++
+}
+
+func (hw *headerWriter) end() {
+	hw.w.WriteString("\r\n")
+	hw.col = 0
+}
+
+// encodeText encodes unstructured text with non-ASCII characters, as the
+// Subject, in RFC 2047 encoded words. Text with control characters is
+// kept as is for headerWriter to reject it.
+func encodeText(s string) string {
+	if is7bit([]byte(s)) || !validFieldValue(s) {
+		return s
+	}
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// encodeAddresses encodes the non-ASCII display names of the addresses of
+// the list s in RFC 2047 encoded words. Invalid lists, which Lint
+// reports, are kept as is.
+func encodeAddresses(s string) string {
+	if is7bit([]byte(s)) || !validFieldValue(s) {
+		return s
+	}
+
+	addrs, err := mail.ParseAddressList(s)
+	if err != nil {
+		return s
+	}
+
+	encoded := make([]string, len(addrs))
+	for i, a := range addrs {
+		encoded[i] = a.String()
+	}
+	return strings.Join(encoded, ", ")
+}
+
+func encodeAddressList(list []string) []string {
+	encoded := make([]string, len(list))
+	for i, s := range list {
+		encoded[i] = encodeAddresses(s)
+	}
+	return encoded
+}
@@ -0,0 +1,173 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func writeFields(fields ...headerField) (string, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	hw := headerWriter{w: w}
+	for i := range fields {
+		hw.write(&fields[i])
+	}
+	w.Flush()
+
+	return buf.String(), hw.err
+}
+
+func TestHeaderWriter(t *testing.T) {
+	long := strings.Repeat("word ", 20)
+
+	tests := []struct {
+		name  string
+		field headerField
+		want  string
+	}{
+		{"field", headerField{name: "Subject", value: "Hello"}, "Subject: Hello\r\n"},
+		{"empty", headerField{name: "Subject"}, "Subject: \r\n"},
+		{
+			"folded", headerField{name: "Subject", value: strings.TrimSpace(long)},
+			"Subject: word word word word word word word word word word word word word word\r\n" +
+				" word word word word word word\r\n",
+		},
+		{
+			"list", headerField{name: "To", list: []string{"a@example.com", "b@example.com"}, sep: ","},
+			"To: a@example.com, b@example.com\r\n",
+		},
+		{
+			"folded list",
+			headerField{name: "To", list: []string{
+				"Alice Example <alice@example.com>",
+				"Bob Example <bob@example.com>",
+				"Carol Example <carol@example.com>",
+			}, sep: ","},
+			"To: Alice Example <alice@example.com>, Bob Example <bob@example.com>,\r\n" +
+				" Carol Example <carol@example.com>\r\n",
+		},
+		{"tab", headerField{name: "X-Tab", value: "a\tb"}, "X-Tab: a\tb\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writeFields(tt.field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderWriterInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		field headerField
+	}{
+		{"CRLF in value", headerField{name: "Subject", value: "Hi\r\nBcc: victim@example.com"}},
+		{"LF in value", headerField{name: "Subject", value: "Hi\nBcc: victim@example.com"}},
+		{"NUL in value", headerField{name: "Subject", value: "Hi\x00"}},
+		{"DEL in value", headerField{name: "Subject", value: "Hi\x7f"}},
+		{"CR in list", headerField{name: "To", list: []string{"a@example.com", "b@example.com\rBcc: c"}, sep: ","}},
+		{"colon in name", headerField{name: "X-A:B", value: "v"}},
+		{"space in name", headerField{name: "X A", value: "v"}},
+		{"LF in name", headerField{name: "X-A\nBcc", value: "v"}},
+		{"empty name", headerField{value: "v"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writeFields(headerField{name: "From", value: "a@example.com"}, tt.field)
+			if err == nil {
+				t.Error("got no error")
+			}
+			if got != "From: a@example.com\r\n" {
+				t.Errorf("invalid field written: %q", got)
+			}
+		})
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Hello", "Hello"},
+		{"Café", "=?utf-8?q?Caf=C3=A9?="},
+		{"Café\r\n", "Café\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeText(tt.in); got != tt.want {
+			t.Errorf("encodeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got, want := encodeAddresses("José <jose@example.com>, b@example.com"), "=?utf-8?q?Jos=C3=A9?= <jose@example.com>, <b@example.com>"; got != want {
+		t.Errorf("encodeAddresses = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkHeaderWriter(b *testing.B) {
+	rcpts := make([]string, 100)
+	for i := range rcpts {
+		rcpts[i] = "Recipient <rcpt@example.com>"
+	}
+	fields := []headerField{
+		{name: "From", value: "Sender <sender@example.com>"},
+		{name: "To", list: rcpts, sep: ","},
+		{name: "Subject", value: strings.Repeat("A rather long subject ", 10)},
+		{name: "Message-ID", value: "<bench@postman.test>"},
+	}
+
+	w := bufio.NewWriter(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hw := headerWriter{w: w}
+		for j := range fields {
+			hw.write(&fields[j])
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		mail  Mail
+	}{
+		{"Subject", "Subject", Mail{Subject: "Hi\r\nBcc: victim@example.com"}},
+		{"To", "To", Mail{To: []string{"a@example.com\nBcc: victim@example.com"}}},
+		{"header value", "X-Custom", Mail{Header: map[string][]string{"X-Custom": {"v\r\nBcc: victim@example.com"}}}},
+		{"header name", "", Mail{Header: map[string][]string{"X-Custom\r\nBcc": {"victim@example.com"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.mail
+			m.From = "sender@example.com"
+			if len(m.To) == 0 {
+				m.To = []string{"rcpt@example.com"}
+			}
+			m.Parts = []Part{{ContentType: "text/plain", Content: []byte("Hello")}}
+
+			if _, err := m.Bytes(); err == nil {
+				t.Error("Bytes: got no error")
+			}
+
+			var found bool
+			for _, i := range Lint(&m) {
+				if i.Severity == SeverityError && i.Field == tt.field {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Lint did not report %s: %v", tt.field, Lint(&m))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package postman
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+// Identity is a domain a Client sends messages for, as services sending
+// on behalf of many customer domains do. The identity of a message is
+// selected by the domain of its author, or of its Sender when it has no
+// author yet; the identities of parent domains apply to their
+// subdomains, unless these have identities of their own.
+type Identity struct {
+	Domain string
+
+	// From is the author of the messages which have none.
+	From string
+
+	// Sender is the Sender of the messages which have none, i.e. the
+	// agent sending them on behalf of their author.
+	Sender string
+
+	// MailFrom is the bounce address of the messages whose envelope does
+	// not set one, rather than their Sender or From.
+	MailFrom string
+
+	// DKIM signs the messages of the identity, in addition to the signers
+	// of the client aligned with their author domain.
+	DKIM []*DKIMSigner
+
+	// BIMISelector, when set, is the selector of the BIMI record of the
+	// messages which have no BIMI-Selector field.
+	BIMISelector string
+
+	// Quota, when set, limits the messages of the identity instead of the
+	// quota of the client.
+	Quota *Quota
+}
+
+// identity returns the identity of m, or nil if it has none.
+func (c *Client) identity(m *Mail) *Identity {
+	addr := m.From
+	if addr == "" {
+		addr = m.Sender
+	}
+
+	domain, ok := addressDomain(addr)
+	if !ok {
+		return nil
+	}
+
+	var best *Identity
+	for _, id := range c.Identities {
+		if inDomain(domain, id.Domain) && (best == nil || len(id.Domain) > len(best.Domain)) {
+			best = id
+		}
+	}
+
+	return best
+}
+
+// apply fills the fields of m which the identity provides and m does not
+// set.
+func (id *Identity) apply(m *Mail) {
+	if m.From == "" {
+		m.From = id.From
+	}
+
+	if m.Sender == "" && id.Sender != "" && !strings.EqualFold(id.Sender, m.From) {
+		m.Sender = id.Sender
+	}
+
+	if m.Envelope.MailFrom == "" {
+		m.Envelope.MailFrom = id.MailFrom
+	}
+
+	if id.BIMISelector != "" && m.Header.Get("BIMI-Selector") == "" {
+		// The header is shared with the original message.
+		h := make(textproto.MIMEHeader, len(m.Header)+1)
+		for k, v := range m.Header {
+			h[k] = v
+		}
+		h.Set("BIMI-Selector", BIMISelector(id.BIMISelector))
+		m.Header = h
+	}
+}
+
+// addressDomain returns the domain of an address, lower cased.
+func addressDomain(s string) (string, bool) {
+	addr, err := bareAddress(s)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.ToLower(addr[strings.LastIndexByte(addr, '@')+1:]), true
+}
+
+// inDomain reports whether domain, lower cased, is parent or one of its
+// subdomains.
+func inDomain(domain, parent string) bool {
+	parent = strings.ToLower(parent)
+	return domain == parent || strings.HasSuffix(domain, "."+parent)
+}
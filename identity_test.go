@@ -0,0 +1,145 @@
+package postman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// identityClient returns a client sending for customer.example, for
+// eu.customer.example with another bounce address, and for self.example.
+func identityClient(addr string) *Client {
+	c := NewClient(addr)
+	c.Identities = []*Identity{
+		{
+			Domain:   "customer.example",
+			From:     "Customer <hello@customer.example>",
+			Sender:   "Postman <postman@example.com>",
+			MailFrom: "bounces+customer@example.com",
+		},
+		{Domain: "eu.customer.example", MailFrom: "bounces+eu@example.com"},
+		{Domain: "self.example", Sender: "Self <self@self.example>"},
+	}
+	return c
+}
+
+func TestIdentity(t *testing.T) {
+	c := identityClient("localhost:25")
+
+	tests := []struct {
+		name     string
+		m        Mail
+		header   []string // the From and Sender fields
+		mailFrom string
+	}{
+		{
+			"author domain",
+			Mail{From: "news@customer.example"},
+			[]string{"From: news@customer.example", "Sender: Postman <postman@example.com>"},
+			"bounces+customer@example.com",
+		},
+		{
+			// The identity of the closest domain applies.
+			"subdomain",
+			Mail{From: "news@shop.eu.customer.example"},
+			[]string{"From: news@shop.eu.customer.example"},
+			"bounces+eu@example.com",
+		},
+		{
+			"parent domain",
+			Mail{From: "news@shop.customer.example"},
+			[]string{"From: news@shop.customer.example", "Sender: Postman <postman@example.com>"},
+			"bounces+customer@example.com",
+		},
+		{
+			// The author is the one of the identity of the Sender.
+			"no author",
+			Mail{Sender: "agent@customer.example"},
+			[]string{"From: Customer <hello@customer.example>", "Sender: agent@customer.example"},
+			"bounces+customer@example.com",
+		},
+		{
+			// A Sender is not added when it is the author.
+			"author is the sender",
+			Mail{From: "Self <self@self.example>"},
+			[]string{"From: Self <self@self.example>"},
+			"self@self.example",
+		},
+		{
+			"envelope",
+			Mail{From: "news@customer.example", Envelope: Envelope{MailFrom: "other@example.com"}},
+			[]string{"From: news@customer.example", "Sender: Postman <postman@example.com>"},
+			"other@example.com",
+		},
+		{
+			"other domain",
+			Mail{From: "news@other.example"},
+			[]string{"From: news@other.example"},
+			"news@other.example",
+		},
+	}
+
+	for _, tt := range tests {
+		m := tt.m
+		m.Date = time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+		m.MessageID = "<m1@postman.test>"
+		m.To = []string{"rcpt@example.com"}
+
+		prepared, err := c.Prepare(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := prepared.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var header []string
+		for _, line := range strings.Split(string(raw[:bytes.Index(raw, []byte("\r\n\r\n"))]), "\r\n") {
+			if strings.HasPrefix(line, "From: ") || strings.HasPrefix(line, "Sender: ") {
+				header = append(header, line)
+			}
+		}
+		if strings.Join(header, "\n") != strings.Join(tt.header, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, header, tt.header)
+		}
+
+		if from, err := prepared.ReversePath(); err != nil || from != tt.mailFrom {
+			t.Errorf("%s: MAIL FROM %q, %v, want %q", tt.name, from, err, tt.mailFrom)
+		}
+	}
+}
+
+// TestIdentitySend checks the reverse path sent for an identity.
+func TestIdentitySend(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := identityClient(s.Addr)
+	defer c.Close()
+
+	m := benchMail(1)
+	m.From = "news@customer.example"
+	if err := c.Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(msgs))
+	}
+	if msgs[0].From != "bounces+customer@example.com" {
+		t.Errorf("MAIL FROM %q", msgs[0].From)
+	}
+	if !strings.Contains(msgs[0].Data, "\nSender: Postman <postman@example.com>\n") {
+		t.Errorf("no Sender field in\n%s", msgs[0].Data)
+	}
+	// The message sent is not changed.
+	if m.Sender != "" || m.Envelope.MailFrom != "" {
+		t.Errorf("message changed: %+v", m)
+	}
+}
@@ -0,0 +1,472 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonMail is the JSON representation of a Mail. Addresses are objects
+// with a name and an address; the address fields of a single mailbox,
+// such as from, are objects and the others are arrays. Strings are
+// accepted too when decoding.
+type jsonMail struct {
+	Date       *time.Time     `json:"date,omitempty"`
+	From       *jsonAddresses `json:"from,omitempty"`
+	Sender     *jsonAddresses `json:"sender,omitempty"`
+	ReplyTo    *jsonAddresses `json:"reply_to,omitempty"`
+	To         *jsonAddresses `json:"to,omitempty"`
+	Cc         *jsonAddresses `json:"cc,omitempty"`
+	Bcc        *jsonAddresses `json:"bcc,omitempty"`
+	MessageID  string         `json:"message_id,omitempty"`
+	InReplyTo  string         `json:"in_reply_to,omitempty"`
+	References []string       `json:"references,omitempty"`
+	Subject    string         `json:"subject,omitempty"`
+	Comments   []string       `json:"comments,omitempty"`
+	Keywords   []string       `json:"keywords,omitempty"`
+
+	ResentDate      *time.Time     `json:"resent_date,omitempty"`
+	ResentFrom      *jsonAddresses `json:"resent_from,omitempty"`
+	ResentSender    *jsonAddresses `json:"resent_sender,omitempty"`
+	ResentTo        *jsonAddresses `json:"resent_to,omitempty"`
+	ResentCc        *jsonAddresses `json:"resent_cc,omitempty"`
+	ResentBcc       *jsonAddresses `json:"resent_bcc,omitempty"`
+	ResentReplyTo   *jsonAddresses `json:"resent_reply_to,omitempty"`
+	ResentMessageID string         `json:"resent_message_id,omitempty"`
+
+	Envelope *jsonEnvelope  `json:"envelope,omitempty"`
+	Received []jsonReceived `json:"received,omitempty"`
+
+	Encrypted                      string         `json:"encrypted,omitempty"`
+	DispositionNotificationTo      *jsonAddresses `json:"disposition_notification_to,omitempty"`
+	DispositionNotificationOptions []string       `json:"disposition_notification_options,omitempty"`
+	AcceptLanguage                 string         `json:"accept_language,omitempty"`
+
+	Importance  string `json:"importance,omitempty"`
+	Priority    string `json:"priority,omitempty"`
+	Sensitivity string `json:"sensitivity,omitempty"`
+
+	Mailer     string `json:"mailer,omitempty"`
+	OmitMailer bool   `json:"omit_mailer,omitempty"`
+	OmitFooter bool   `json:"omit_footer,omitempty"`
+	Campaign   string `json:"campaign,omitempty"`
+
+	Header      map[string][]string `json:"header,omitempty"`
+	HeaderOrder []string            `json:"header_order,omitempty"`
+
+	Parts       []jsonPart       `json:"parts,omitempty"`
+	Attachments []jsonAttachment `json:"attachments,omitempty"`
+	Digest      []*Mail          `json:"digest,omitempty"`
+	Body        *Entity          `json:"body,omitempty"`
+}
+
+type jsonAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+// jsonAddresses is a list of addresses, encoded as an object when single
+// is set and the list has exactly one address. Empty lists are nil.
+type jsonAddresses struct {
+	list   []jsonAddress
+	single bool
+}
+
+type jsonEnvelope struct {
+	MailFrom string   `json:"mail_from,omitempty"`
+	RcptTo   []string `json:"rcpt_to,omitempty"`
+
+	// DeliverBy is in seconds.
+	DeliverBy       int64 `json:"deliver_by,omitempty"`
+	DeliverByNotify bool  `json:"deliver_by_notify,omitempty"`
+	RequireTLS      bool  `json:"require_tls,omitempty"`
+}
+
+type jsonReceived struct {
+	From     string    `json:"from,omitempty"`
+	FromHost string    `json:"from_host,omitempty"`
+	FromIP   net.IP    `json:"from_ip,omitempty"`
+	By       string    `json:"by,omitempty"`
+	Via      string    `json:"via,omitempty"`
+	With     string    `json:"with,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	For      string    `json:"for,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// jsonPart holds text content as a string, and anything which is not
+// valid UTF-8 base64 encoded.
+type jsonPart struct {
+	ContentType   string            `json:"content_type,omitempty"`
+	Params        map[string]string `json:"params,omitempty"`
+	Charset       string            `json:"charset,omitempty"`
+	Content       string            `json:"content,omitempty"`
+	ContentBase64 []byte            `json:"content_base64,omitempty"`
+}
+
+type jsonAttachment struct {
+	Filename                string `json:"filename,omitempty"`
+	ContentDisposition      string `json:"content_disposition,omitempty"`
+	ContentID               string `json:"content_id,omitempty"`
+	ContentTransferEncoding string `json:"content_transfer_encoding,omitempty"`
+	Content                 []byte `json:"content,omitempty"`
+	URL                     string `json:"url,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *Mail) MarshalJSON() ([]byte, error) {
+	jm := jsonMail{
+		From:                           newJSONAddresses(true, m.From),
+		Sender:                         newJSONAddresses(true, m.Sender),
+		ReplyTo:                        newJSONAddresses(false, m.ReplyTo),
+		To:                             newJSONAddresses(false, m.To...),
+		Cc:                             newJSONAddresses(false, m.Cc...),
+		Bcc:                            newJSONAddresses(false, m.Bcc...),
+		MessageID:                      m.MessageID,
+		InReplyTo:                      m.InReplyTo,
+		References:                     m.References,
+		Subject:                        m.Subject,
+		Comments:                       m.Comments,
+		Keywords:                       m.Keywords,
+		ResentFrom:                     newJSONAddresses(false, m.ResentFrom...),
+		ResentSender:                   newJSONAddresses(true, m.ResentSender),
+		ResentTo:                       newJSONAddresses(false, m.ResentTo...),
+		ResentCc:                       newJSONAddresses(false, m.ResentCc...),
+		ResentBcc:                      newJSONAddresses(false, m.ResentBcc...),
+		ResentReplyTo:                  newJSONAddresses(false, m.ResentReplyTo),
+		ResentMessageID:                m.ResentMessageID,
+		Encrypted:                      m.Encrypted,
+		DispositionNotificationTo:      newJSONAddresses(false, m.DispositionNotificationTo),
+		DispositionNotificationOptions: m.DispositionNotificationOptions,
+		AcceptLanguage:                 m.AcceptLanguage,
+		Mailer:                         m.Mailer,
+		OmitMailer:                     m.OmitMailer,
+		OmitFooter:                     m.OmitFooter,
+		Campaign:                       m.Campaign,
+		Header:                         m.Header,
+		HeaderOrder:                    m.HeaderOrder,
+		Digest:                         m.Digest,
+		Body:                           m.Body,
+	}
+
+	if !m.Date.IsZero() {
+		jm.Date = &m.Date
+	}
+
+	if !m.ResentDate.IsZero() {
+		jm.ResentDate = &m.ResentDate
+	}
+
+	if e := m.Envelope; e.MailFrom != "" || len(e.RcptTo) > 0 || e.DeliverBy > 0 || e.RequireTLS {
+		jm.Envelope = &jsonEnvelope{
+			MailFrom:        e.MailFrom,
+			RcptTo:          e.RcptTo,
+			DeliverBy:       int64((e.DeliverBy + time.Second - 1) / time.Second),
+			DeliverByNotify: e.DeliverByNotify,
+			RequireTLS:      e.RequireTLS,
+		}
+	}
+
+	for _, r := range m.Received {
+		jm.Received = append(jm.Received, jsonReceived(r))
+	}
+
+	if m.Importance != 0 {
+		jm.Importance = m.Importance.String()
+	}
+	if m.Priority != 0 {
+		jm.Priority = m.Priority.String()
+	}
+	if m.Sensitivity != 0 {
+		jm.Sensitivity = m.Sensitivity.String()
+	}
+
+	for _, p := range m.Parts {
+		jp := jsonPart{ContentType: p.ContentType, Params: p.Params, Charset: p.Charset}
+		if utf8.Valid(p.Content) {
+			jp.Content = string(p.Content)
+		} else {
+			jp.ContentBase64 = p.Content
+		}
+		jm.Parts = append(jm.Parts, jp)
+	}
+
+	for _, a := range m.Attachments {
+		jm.Attachments = append(jm.Attachments, jsonAttachment{
+			Filename:                a.Filename,
+			ContentDisposition:      a.ContentDisposition,
+			ContentID:               a.ContentID,
+			ContentTransferEncoding: a.ContentTransfertEncoding,
+			Content:                 a.Content,
+			URL:                     a.URL,
+		})
+	}
+
+	return json.Marshal(&jm)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Attachments given by URL
+// are not fetched; see FetchAttachments.
+func (m *Mail) UnmarshalJSON(data []byte) error {
+	var jm jsonMail
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+
+	*m = Mail{
+		From:                           jm.From.join(),
+		Sender:                         jm.Sender.join(),
+		ReplyTo:                        jm.ReplyTo.join(),
+		To:                             jm.To.strings(),
+		Cc:                             jm.Cc.strings(),
+		Bcc:                            jm.Bcc.strings(),
+		MessageID:                      jm.MessageID,
+		InReplyTo:                      jm.InReplyTo,
+		References:                     jm.References,
+		Subject:                        jm.Subject,
+		Comments:                       jm.Comments,
+		Keywords:                       jm.Keywords,
+		ResentFrom:                     jm.ResentFrom.strings(),
+		ResentSender:                   jm.ResentSender.join(),
+		ResentTo:                       jm.ResentTo.strings(),
+		ResentCc:                       jm.ResentCc.strings(),
+		ResentBcc:                      jm.ResentBcc.strings(),
+		ResentReplyTo:                  jm.ResentReplyTo.join(),
+		ResentMessageID:                jm.ResentMessageID,
+		Encrypted:                      jm.Encrypted,
+		DispositionNotificationTo:      jm.DispositionNotificationTo.join(),
+		DispositionNotificationOptions: jm.DispositionNotificationOptions,
+		AcceptLanguage:                 jm.AcceptLanguage,
+		Mailer:                         jm.Mailer,
+		OmitMailer:                     jm.OmitMailer,
+		OmitFooter:                     jm.OmitFooter,
+		Campaign:                       jm.Campaign,
+		HeaderOrder:                    jm.HeaderOrder,
+		Digest:                         jm.Digest,
+		Body:                           jm.Body,
+	}
+
+	if jm.Date != nil {
+		m.Date = *jm.Date
+	}
+
+	if jm.ResentDate != nil {
+		m.ResentDate = *jm.ResentDate
+	}
+
+	if e := jm.Envelope; e != nil {
+		m.Envelope = Envelope{
+			MailFrom:        e.MailFrom,
+			RcptTo:          e.RcptTo,
+			DeliverBy:       time.Duration(e.DeliverBy) * time.Second,
+			DeliverByNotify: e.DeliverByNotify,
+			RequireTLS:      e.RequireTLS,
+		}
+	}
+
+	for _, r := range jm.Received {
+		m.Received = append(m.Received, Received(r))
+	}
+
+	var err error
+
+	if jm.Importance != "" {
+		if m.Importance, err = ParseImportance(jm.Importance); err != nil {
+			return err
+		}
+	}
+	if jm.Priority != "" {
+		if m.Priority, err = ParsePriority(jm.Priority); err != nil {
+			return err
+		}
+	}
+	if jm.Sensitivity != "" {
+		if m.Sensitivity, err = ParseSensitivity(jm.Sensitivity); err != nil {
+			return err
+		}
+	}
+
+	if len(jm.Header) > 0 {
+		m.Header = make(textproto.MIMEHeader, len(jm.Header))
+		for k, v := range jm.Header {
+			m.Header[textproto.CanonicalMIMEHeaderKey(k)] = v
+		}
+	}
+
+	for _, jp := range jm.Parts {
+		p := Part{ContentType: jp.ContentType, Params: jp.Params, Charset: jp.Charset, Content: []byte(jp.Content)}
+		if jp.ContentBase64 != nil {
+			p.Content = jp.ContentBase64
+		}
+		m.Parts = append(m.Parts, p)
+	}
+
+	for _, ja := range jm.Attachments {
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:                 ja.Filename,
+			ContentDisposition:       ja.ContentDisposition,
+			ContentID:                ja.ContentID,
+			ContentTransfertEncoding: ja.ContentTransferEncoding,
+			Content:                  ja.Content,
+			URL:                      ja.URL,
+		})
+	}
+
+	return nil
+}
+
+// FetchAttachments downloads the content of the attachments which only
+// have a URL, naming them after the last element of its path when they
+// have no file name. A nil client means http.DefaultClient.
+func (m *Mail) FetchAttachments(client *http.Client) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for i := range m.Attachments {
+		a := &m.Attachments[i]
+		if a.Content != nil || a.URL == "" {
+			continue
+		}
+
+		resp, err := client.Get(a.URL)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("postman: attachment %s: %s", a.URL, resp.Status)
+		}
+
+		if a.Filename == "" {
+			if u, err := url.Parse(a.URL); err == nil {
+				if name := path.Base(u.Path); name != "/" && name != "." {
+					a.Filename = name
+				}
+			}
+		}
+
+		a.Content = content
+	}
+
+	return nil
+}
+
+// newJSONAddresses parses the address lists values. Values which do not
+// parse are kept as they are, as the address of an object without name.
+func newJSONAddresses(single bool, values ...string) *jsonAddresses {
+	as := jsonAddresses{single: single}
+
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+
+		list, err := mail.ParseAddressList(v)
+		if err != nil {
+			as.list = append(as.list, jsonAddress{Address: v})
+			continue
+		}
+
+		for _, a := range list {
+			as.list = append(as.list, jsonAddress{Name: a.Name, Address: a.Address})
+		}
+	}
+
+	if len(as.list) == 0 {
+		return nil
+	}
+
+	return &as
+}
+
+func (as jsonAddresses) MarshalJSON() ([]byte, error) {
+	if as.single && len(as.list) == 1 {
+		return json.Marshal(as.list[0])
+	}
+	return json.Marshal(as.list)
+}
+
+func (as *jsonAddresses) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return err
+		}
+
+		for _, raw := range raws {
+			var a jsonAddresses
+			if err := a.UnmarshalJSON(raw); err != nil {
+				return err
+			}
+			as.list = append(as.list, a.list...)
+		}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if a := newJSONAddresses(false, s); a != nil {
+			as.list = append(as.list, a.list...)
+		}
+		return nil
+	}
+
+	var a jsonAddress
+	if err := json.Unmarshal(data, &a); err != nil {
+		return fmt.Errorf("postman: invalid address %s", data)
+	}
+	if a.Address == "" {
+		return fmt.Errorf("postman: address without address %s", data)
+	}
+	as.list = append(as.list, a)
+
+	return nil
+}
+
+// strings returns the addresses formatted for the fields of Mail.
+func (as *jsonAddresses) strings() []string {
+	if as == nil {
+		return nil
+	}
+
+	var ss []string
+	for _, a := range as.list {
+		if a.Name == "" {
+			ss = append(ss, a.Address)
+		} else {
+			ss = append(ss, (&mail.Address{Name: a.Name, Address: a.Address}).String())
+		}
+	}
+	return ss
+}
+
+// join returns the addresses as a single list field value.
+func (as *jsonAddresses) join() string {
+	return strings.Join(as.strings(), ", ")
+}
@@ -0,0 +1,105 @@
+package postman
+
+import (
+	"encoding/json"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMailJSON(t *testing.T) {
+	date := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	m := &Mail{
+		Date:       date,
+		From:       `"Sender" <sender@example.com>`,
+		ReplyTo:    "reply@example.com",
+		To:         []string{`"To 1" <to1@example.com>`, "to2@example.com"},
+		Bcc:        []string{"bcc@example.com"},
+		MessageID:  "<m1@postman.test>",
+		References: []string{"<m0@postman.test>"},
+		Subject:    "Hello",
+		Envelope: Envelope{
+			MailFrom:   "bounces@example.com",
+			RcptTo:     []string{"rcpt@example.com"},
+			DeliverBy:  90 * time.Second,
+			RequireTLS: true,
+		},
+		Received: []Received{{
+			From:   "client.example.com",
+			FromIP: net.ParseIP("192.0.2.1"),
+			By:     "mx.example.com",
+			With:   "ESMTPS",
+			Date:   date,
+		}},
+		Importance:  ImportanceHigh,
+		Priority:    PriorityUrgent,
+		Sensitivity: SensitivityPersonal,
+		Campaign:    "spring",
+		Header:      textproto.MIMEHeader{"X-Entity-Ref-Id": {"ref"}},
+		Parts: []Part{
+			{ContentType: "text/plain", Charset: "iso-8859-1", Content: []byte("Hello")},
+			{ContentType: "application/octet-stream", Content: []byte{0xff, 0xfe}},
+		},
+		Attachments: []Attachment{{Filename: "a.txt", Content: []byte("attachment")}},
+		Digest:      []*Mail{{From: "other@example.com", Subject: "Other"}},
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Single mailboxes are objects, lists arrays, and content which is not
+	// UTF-8 is base64 encoded.
+	for _, s := range []string{
+		`"from":{"name":"Sender","address":"sender@example.com"}`,
+		`"reply_to":[{"address":"reply@example.com"}]`,
+		`"deliver_by":90`,
+		`"priority":"urgent"`,
+		`"content_base64":"//4="`,
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("%s does not contain %s", data, s)
+		}
+	}
+
+	var got Mail
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, m) {
+		t.Errorf("got\n%+v\nwant\n%+v", &got, m)
+	}
+}
+
+func TestMailJSONAddressStrings(t *testing.T) {
+	var m Mail
+	err := json.Unmarshal([]byte(`{
+		"from": "Sender <sender@example.com>",
+		"to": ["to1@example.com, To 2 <to2@example.com>", {"address": "to3@example.com"}],
+		"header": {"x-entity-ref-id": ["ref"]}
+	}`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.From != `"Sender" <sender@example.com>` {
+		t.Errorf("From: %s", m.From)
+	}
+	if want := []string{"to1@example.com", `"To 2" <to2@example.com>`, "to3@example.com"}; !reflect.DeepEqual(m.To, want) {
+		t.Errorf("To: %q, want %q", m.To, want)
+	}
+	if m.Header.Get("X-Entity-Ref-Id") != "ref" {
+		t.Errorf("Header: %v", m.Header)
+	}
+
+	for _, data := range []string{`{"from": {"name": "Sender"}}`, `{"priority": "asap"}`} {
+		if err := json.Unmarshal([]byte(data), &m); err == nil {
+			t.Errorf("%s accepted", data)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package postman
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity tells whether an Issue prevents a message from being sent.
+type Severity string
+
+const (
+	// SeverityError marks messages which are invalid, and likely to be
+	// rejected or mangled.
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks messages which are valid but likely to be
+	// displayed or delivered badly.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found by Lint or LintRaw.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Field    string   `json:"field,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// HasErrors reports whether issues contains an error.
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// LintError is returned for messages which Lint finds errors in.
+type LintError struct {
+	Issues []Issue
+}
+
+func (e *LintError) Error() string {
+	var msgs []string
+	for _, i := range e.Issues {
+		if i.Severity == SeverityError {
+			msgs = append(msgs, i.String())
+		}
+	}
+	return "postman: invalid message: " + strings.Join(msgs, "; ")
+}
+
+type linter []Issue
+
+func (l *linter) errorf(field, format string, args ...interface{}) {
+	*l = append(*l, Issue{SeverityError, field, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(field, format string, args ...interface{}) {
+	*l = append(*l, Issue{SeverityWarning, field, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) address(field, v string) {
+	if v == "" {
+		return
+	}
+	if _, err := mail.ParseAddress(v); err != nil {
+		l.errorf(field, "invalid address %q: %v", v, err)
+	}
+}
+
+func (l *linter) addresses(field string, list []string) {
+	for _, v := range list {
+		if _, err := mail.ParseAddressList(v); err != nil {
+			l.errorf(field, "invalid address %q: %v", v, err)
+		}
+	}
+}
+
+func (l *linter) msgID(field, v string) {
+	id := angleBracket(v)
+	if !strings.HasSuffix(id, ">") || strings.Count(id, "@") != 1 {
+		l.warnf(field, "%q is not a valid message identifier", v)
+	}
+}
+
+// text reports the values holding control characters, which would let
+// them inject other fields.
+func (l *linter) text(field string, values ...string) {
+	for _, v := range values {
+		if !validFieldValue(v) {
+			l.errorf(field, "control character in %q", v)
+		}
+	}
+}
+
+// fields checks the names and values of the fields of header.
+func (l *linter) fields(header map[string][]string) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !validFieldName(k) {
+			l.errorf("", "invalid field name %q", k)
+			continue
+		}
+		l.text(k, header[k]...)
+	}
+}
+
+// Lint validates the addresses, identifiers and MIME structure of m.
+func Lint(m *Mail) []Issue {
+	var l linter
+
+	l.text("From", m.From)
+	l.text("Sender", m.Sender)
+	l.text("Reply-To", m.ReplyTo)
+	l.text("To", m.To...)
+	l.text("Cc", m.Cc...)
+	l.text("Bcc", m.Bcc...)
+	l.text("Envelope", m.Envelope.MailFrom)
+	l.text("Envelope", m.Envelope.RcptTo...)
+	l.text("Subject", m.Subject)
+	l.text("Message-ID", m.MessageID)
+	l.text("In-Reply-To", m.InReplyTo)
+	l.text("References", m.References...)
+	l.text("Comments", m.Comments...)
+	l.text("Keywords", m.Keywords...)
+	l.text("Accept-Language", m.AcceptLanguage)
+	l.text("Disposition-Notification-To", m.DispositionNotificationTo)
+	l.text("Disposition-Notification-Options", m.DispositionNotificationOptions...)
+	l.text("X-Mailer", m.Mailer)
+	l.text("X-Campaign", m.Campaign)
+	l.fields(m.Header)
+
+	for _, k := range []string{"Return-Path", "Bcc"} {
+		if _, ok := m.Header[k]; ok {
+			l.warnf(k, "ignored in the header; set the envelope or the Bcc recipients instead")
+		}
+	}
+
+	switch from, err := mail.ParseAddressList(m.From); {
+	case m.From == "":
+		l.errorf("From", "missing")
+	case err != nil:
+		l.errorf("From", "invalid address %q: %v", m.From, err)
+	case len(from) > 1 && m.Sender == "":
+		l.errorf("Sender", "required when From has several mailboxes")
+	}
+
+	l.address("Sender", m.Sender)
+	l.addresses("Reply-To", splitList(m.ReplyTo))
+	l.addresses("To", m.To)
+	l.addresses("Cc", m.Cc)
+	l.addresses("Bcc", m.Bcc)
+	l.addresses("Envelope", m.Envelope.RcptTo)
+
+	if from := m.Envelope.MailFrom; from != "" && from != NullReversePath {
+		l.address("Envelope", from)
+	}
+
+	if len(m.To)+len(m.Cc)+len(m.Bcc)+len(m.Envelope.RcptTo) == 0 {
+		l.errorf("To", "no recipient")
+	}
+
+	if m.Subject == "" {
+		l.warnf("Subject", "missing")
+	}
+
+	if m.MessageID != "" {
+		l.msgID("Message-ID", m.MessageID)
+	}
+	if m.InReplyTo != "" {
+		l.msgID("In-Reply-To", m.InReplyTo)
+	}
+	for _, ref := range m.References {
+		l.msgID("References", ref)
+	}
+
+	if v := m.Header.Get("BIMI-Selector"); v != "" {
+		if tags, err := parseTags(v); err != nil || !strings.EqualFold(tags["v"], "BIMI1") || tags["s"] == "" {
+			l.errorf("BIMI-Selector", "%q is not a valid selector, as returned by BIMISelector", v)
+		}
+	}
+
+	if m.Body != nil {
+		if len(m.Parts) > 0 || len(m.Attachments) > 0 || len(m.Digest) > 0 {
+			l.warnf("", "parts, attachments and digest ignored for the MIME tree of the body")
+		}
+		l.entity(m.Body)
+		return l
+	}
+
+	if len(m.Parts) == 0 && len(m.Attachments) == 0 && len(m.Digest) == 0 {
+		l.warnf("", "empty body")
+	}
+
+	types := make(map[string]bool)
+	for i := range m.Parts {
+		l.part(&m.Parts[i], types)
+	}
+
+	ids := make(map[string]bool)
+	for i := range m.Attachments {
+		l.attachment(&m.Attachments[i], ids)
+	}
+
+	return l
+}
+
+func (l *linter) part(p *Part, types map[string]bool) {
+	if p.ContentType == "" {
+		l.errorf("Content-Type", "missing on part")
+		return
+	}
+
+	mediatype, params, err := p.MediaType()
+	if err != nil {
+		l.errorf("Content-Type", "%s", strings.TrimPrefix(err.Error(), "postman: "))
+		return
+	}
+
+	if mime.FormatMediaType(mediatype, params) == "" {
+		l.errorf("Content-Type", "invalid %q", mediatype)
+		return
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		l.errorf("Content-Type", "%s part without its own structure", mediatype)
+	}
+
+	if types[mediatype] {
+		l.warnf("Content-Type", "several %s alternatives", mediatype)
+	}
+	types[mediatype] = true
+
+	if p.Charset != "" {
+		cp := *p
+		if err := cp.encode(); err != nil {
+			l.errorf("Content-Type", "%s", strings.TrimPrefix(err.Error(), "postman: "))
+		}
+	} else if strings.HasPrefix(mediatype, "text/") && params["charset"] == "" && !is7bit(p.Content) && !utf8.Valid(p.Content) {
+		// Valid UTF-8 is declared as such when written.
+		l.warnf("Content-Type", "%s part with 8bit content which is not UTF-8 but no charset", mediatype)
+	}
+}
+
+func (l *linter) entity(e *Entity) {
+	l.fields(e.Header)
+
+	mediatype, params, err := e.MediaType()
+	if err != nil {
+		l.errorf("Content-Type", "%s", strings.TrimPrefix(err.Error(), "postman: "))
+		return
+	}
+
+	if mime.FormatMediaType(mediatype, params) == "" {
+		l.errorf("Content-Type", "invalid %q", mediatype)
+		return
+	}
+
+	if !e.isMultipart() {
+		if len(e.Children) > 0 {
+			l.errorf("Content-Type", "%s entity with children", mediatype)
+		}
+		if e.Charset != "" {
+			if _, err := encodeCharset(e.Charset, e.Content); err != nil {
+				l.errorf("Content-Type", "%s", strings.TrimPrefix(err.Error(), "postman: "))
+			}
+		}
+		return
+	}
+
+	if len(e.Children) == 0 {
+		l.errorf("Content-Type", "%s entity without children", mediatype)
+	}
+
+	for _, c := range e.Children {
+		l.entity(c)
+	}
+}
+
+func (l *linter) attachment(a *Attachment, ids map[string]bool) {
+	if a.Filename == "" && a.ContentID == "" {
+		l.warnf("Content-Disposition", "attachment without file name")
+	}
+
+	l.text("Content-Disposition", a.Filename)
+	l.text("Content-ID", a.ContentID)
+
+	if a.Content == nil && a.File != "" {
+		// Spooled.
+	} else if a.Content == nil && a.URL != "" {
+		l.errorf("Content-Disposition", "attachment %s not fetched", a.URL)
+	} else if len(a.Content) == 0 {
+		l.warnf("Content-Disposition", "empty attachment %q", a.Filename)
+	}
+
+	switch strings.ToLower(a.ContentDisposition) {
+	case "", "attachment", "inline":
+	default:
+		l.errorf("Content-Disposition", "unknown disposition %q", a.ContentDisposition)
+	}
+
+	switch strings.ToLower(a.ContentTransfertEncoding) {
+	case "", "base64", "quoted-printable", "7bit", "8bit", "binary":
+	default:
+		l.errorf("Content-Transfer-Encoding", "unknown encoding %q", a.ContentTransfertEncoding)
+	}
+
+	if a.ContentID != "" {
+		if ids[a.ContentID] {
+			l.errorf("Content-ID", "duplicate %q", a.ContentID)
+		}
+		ids[a.ContentID] = true
+	}
+}
+
+// LintRaw checks the lines of a raw message: their length, endings, and
+// the encoding of the header.
+func LintRaw(raw []byte) []Issue {
+	var l linter
+
+	var (
+		header        = true
+		crlf, lf      int
+		long, tooLong int
+		eightBit      bool
+	)
+
+	for len(raw) > 0 {
+		line := raw
+		if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+			line, raw = raw[:i], raw[i+1:]
+			if bytes.HasSuffix(line, []byte{'\r'}) {
+				line = line[:len(line)-1]
+				crlf++
+			} else {
+				lf++
+			}
+		} else {
+			raw = nil
+		}
+
+		switch {
+		case len(line) > 998:
+			tooLong++
+		case len(line) > maxLineLength && header:
+			long++
+		}
+
+		if header {
+			if len(line) == 0 {
+				header = false
+			} else if !is7bit(line) {
+				eightBit = true
+			}
+		}
+	}
+
+	if tooLong > 0 {
+		l.errorf("", "%d lines longer than 998 characters", tooLong)
+	}
+
+	if long > 0 {
+		l.warnf("", "%d header lines longer than %d characters", long, maxLineLength)
+	}
+
+	if crlf > 0 && lf > 0 {
+		l.errorf("", "%d bare LF line endings mixed with CRLF", lf)
+	}
+
+	if eightBit {
+		l.warnf("", "8bit header requires SMTPUTF8 support from every server")
+	}
+
+	return l
+}
@@ -1,15 +1,18 @@
-package main
+package postman
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"math/big"
-	"net/smtp"
+	"net/textproto"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -87,6 +90,9 @@ type Mail struct {
 	// Status: standard
 	//
 	// Specification document(s): RFC 2822 (section 3.6.3)
+	//
+	// The addresses are only given to the server as recipients, never
+	// written in the header.
 	Bcc []string
 
 	// Contains a single unique message identifier that refers to a
@@ -237,26 +243,24 @@ type Mail struct {
 	// Specification document(s): RFC 2822 (section 3.6.6)
 	ResentMessageID string
 
-	// Return path for message response diagnostics.  See also RFC 2821
-	// Defined as standard by RFC 822.
-	//
-	// Applicable protocol: Mail
-	//
-	// Status: standard
+	// The SMTP envelope of the message.  Return-Path is never rendered:
+	// the final MTA adds it from the envelope reverse-path, which is
+	// where bounces are sent to.
 	//
-	// Specification document(s): RFC 2822 (section 3.6.7)
-	ReturnPath string
+	// Specification document(s): RFC 5321 (sections 2.3.1 and 4.4)
+	Envelope Envelope
 
 	// Contains information about receipt of the current message by a
 	// mail transfer agent on the transfer path. See also RFC 2821.
-	// Defined as standard by RFC 822.
+	// Defined as standard by RFC 822.  Most recent first; use
+	// AddReceived to stamp the message when relaying it.
 	//
 	// Applicable protocol: Mail
 	//
 	// Status: standard
 	//
 	// Specification document(s): RFC 2822 (section 3.6.7)
-	Received string
+	Received []Received
 
 	// Defined by RFC 822, but was found to be inadequately specified,
 	// was not widely implemented, and was removed in RFC 2822.  Current
@@ -304,30 +308,87 @@ type Mail struct {
 	// A hint from the originator to the recipients about how important a
 	// message is.
 	//
-	// Values: High, normal, or low.
-	Importance string
+	// Values: ImportanceHigh, ImportanceNormal, or ImportanceLow.  The
+	// zero value omits the field.
+	Importance Importance
 
 	// Can be 'normal', 'urgent', or 'non-urgent' and can influence
 	// transmission speed and delivery.  RFC 2156 (MIXER); not for
-	// general use.
-	Priority string
+	// general use.  The zero value omits the field.
+	Priority Priority
 
 	// How sensitive it is to disclose this message to people other than
 	// the specified recipients.  Values: Personal, private, and company
 	// confidential.  The absence of this header field in messages
 	// gatewayed from X.400 indicates that the message is not sensitive.
 	// Proposed for use with RFC 2156 (MIXER) [10] and RFC 3801 (VPIM)
-	// [14].
-	Sensitivity string
+	// [14].  The zero value omits the field.
+	Sensitivity Sensitivity
+
+	// Identifies the software that generated the message.  When empty,
+	// Client.Send stamps its own Mailer unless OmitMailer is set.
+	//
+	// Status: non-standard, widely used
+	Mailer string
+
+	// Disables X-Mailer for this message, even when the client sending
+	// it defines one.
+	OmitMailer bool
+
+	// OmitFooter keeps the footers of Client.Defaults out of the text
+	// parts of the message.
+	OmitFooter bool
+
+	// Tags the message as part of a campaign, for archive searches and
+	// reports.  Rendered in the X-Campaign field, so that it survives the
+	// message being stored and parsed back.
+	//
+	// Status: non-standard
+	Campaign string
+
+	// Additional header fields, such as List-Unsubscribe or X- fields,
+	// rendered after the fields above in key order.  It must not repeat
+	// any of the fields above.
+	Header textproto.MIMEHeader
+
+	// Lists header field names in the order they are emitted. Fields
+	// which are not listed follow, in the default order. Names are case
+	// insensitive.
+	//
+	// A stable order matters when the message is signed or compared
+	// byte for byte.
+	HeaderOrder []string
 
 	Parts []Part
 
 	Attachments []Attachment
+
+	// Digest holds enclosed messages, written as a multipart/digest
+	// entity after the parts and before the attachments; see NewDigest.
+	Digest []*Mail
+
+	// Body, if set, is the MIME tree of the body, written instead of the
+	// parts, attachments and digest. ParseMail does not set it.
+	Body *Entity
 }
 
 type Part struct {
+	// ContentType is the media type of the content, e.g. "text/plain".
+	// Parameters following it, as in "text/plain; charset=utf-8", are
+	// still accepted, Params overriding them.
 	ContentType string
 
+	// Params are the parameters of the content type, such as charset,
+	// format=flowed, or method for text/calendar parts. They are quoted
+	// as needed when the message is written. Text which is not ASCII
+	// but valid UTF-8 gets charset=utf-8 if it declares no charset.
+	Params map[string]string
+
+	// Charset, if set, is the charset the content is converted to from
+	// UTF-8 when the message is written, e.g. "ISO-8859-1" or "GB2312";
+	// it replaces the charset parameter of the content type.
+	Charset string
+
 	Content []byte
 }
 
@@ -341,6 +402,15 @@ type Attachment struct {
 	ContentTransfertEncoding string
 
 	Content []byte
+
+	// File, when Content is nil, is the path of the file the content is
+	// read from whenever the message is written, as for the attachments
+	// spooled by a Spool.
+	File string
+
+	// URL locates the content of attachments received without it, as in
+	// JSON messages; FetchAttachments downloads it before sending.
+	URL string
 }
 
 // Output: RFC <XXX> compliant message id
@@ -362,59 +432,244 @@ func genMsgID() (string, error) {
 	return fmt.Sprintf("<%d.%d.%d@%s>", t, pid, rint, host), nil
 }
 
-func (m *Mail) String() (string, error) {
-	var header string
+// WriteTo writes the message to w. It implements io.WriterTo.
+func (m *Mail) WriteTo(w io.Writer) (int64, error) {
+	m, err := m.encodeParts()
+	if err != nil {
+		return 0, err
+	}
+
+	if m.Body != nil {
+		body, err := m.Body.encode()
+		if err != nil {
+			return 0, err
+		}
+		if body != m.Body {
+			cm := *m
+			cm.Body = body
+			m = &cm
+		}
+	}
 
-	header += fmt.Sprintf("Sender: %s\r\n", m.Sender)
-	header += fmt.Sprintf("From: %s\r\n", m.From)
+	cw := &countWriter{w: w}
+	bw := getWriter(cw)
+	defer putWriter(bw)
 
-	if len(m.To) > 0 {
-		header += fmt.Sprintf("To: %s\r\n", strings.Join(m.To, ";"))
+	boundary, err := m.writeHeader(bw)
+	if err != nil {
+		return cw.n, err
 	}
 
-	if len(m.Cc) > 0 {
-		header += fmt.Sprintf("Cc: %s\r\n", strings.Join(m.Cc, ";"))
+	bw.WriteString("\r\n")
+
+	if err := m.writeBody(bw, boundary); err != nil {
+		return cw.n, err
 	}
 
-	if len(m.Bcc) > 0 {
-		header += fmt.Sprintf("Bcc: %s\r\n", strings.Join(m.Bcc, ";"))
+	err = bw.Flush()
+	return cw.n, err
+}
+
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewWriterSize(nil, 4096)
+	},
+}
+
+func getWriter(w io.Writer) *bufio.Writer {
+	bw := writerPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw
+}
+
+// The writer is reset so the pool does not retain the destination.
+func putWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	writerPool.Put(bw)
+}
+
+func (m *Mail) String() (string, error) {
+	var b strings.Builder
+
+	if _, err := m.WriteTo(&b); err != nil {
+		return "", err
 	}
 
-	if m.ReplyTo != "" {
-		header += fmt.Sprintf("Reply-To: %s\r\n", m.ReplyTo)
+	return b.String(), nil
+}
+
+// Bytes returns the rendered message.
+func (m *Mail) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
 	}
 
-	msgid, err := genMsgID()
+	return buf.Bytes(), nil
+}
+
+func (m *Mail) writeHeader(w *bufio.Writer) (string, error) {
+	fields, boundary, err := m.header()
 	if err != nil {
 		return "", err
 	}
 
-	header += fmt.Sprintf("Message-ID: %s\r\n", msgid)
-	header += fmt.Sprintf("Subject: %s\r\n", m.Subject)
+	if len(m.HeaderOrder) > 0 {
+		sortFields(fields, m.HeaderOrder)
+	}
+
+	hw := headerWriter{w: w}
+
+	// Trace fields always come first, whatever the order of the other
+	// fields (RFC 5322 section 3.6.7).
+	for i := range m.Received {
+		hw.field("Received", m.Received[i].String())
+	}
+
+	for i := range fields {
+		hw.write(&fields[i])
+	}
 
-	return header, nil
+	return boundary, hw.err
 }
 
-func main() {
-	conn, err := smtp.Dial("localhost:1025")
-	if err != nil {
-		log.Fatal(err)
+// dateLayout is the date-time format of RFC 5322 section 3.3.
+const dateLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
+
+// header returns the header fields of the message in their default
+// order, along with the boundary of the body if it is multipart.
+func (m *Mail) header() ([]headerField, string, error) {
+	fields := make([]headerField, 0, 16)
+
+	// Many MTAs reject or rewrite messages without a Date.
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
 	}
-	defer conn.Close()
 
-	conn.Mail("foo@bar.fr")
-	conn.Rcpt("recp@foo.fr")
+	fields = append(fields, headerField{name: "Date", value: date.Format(dateLayout)})
+	// Display names and the subject are the only parts of the fields
+	// likely to hold non-ASCII text.
+	fields = append(fields, headerField{name: "From", value: encodeAddresses(m.From)})
 
-	wc, err := conn.Data()
-	if err != nil {
-		log.Fatal(err)
+	if m.Sender != "" {
+		fields = append(fields, headerField{name: "Sender", value: encodeAddresses(m.Sender)})
+	}
+
+	if m.ReplyTo != "" {
+		fields = append(fields, headerField{name: "Reply-To", value: encodeAddresses(m.ReplyTo)})
+	}
+
+	if len(m.To) > 0 {
+		fields = append(fields, headerField{name: "To", list: encodeAddressList(m.To), sep: ","})
+	}
+
+	if len(m.Cc) > 0 {
+		fields = append(fields, headerField{name: "Cc", list: encodeAddressList(m.Cc), sep: ","})
+	}
+
+	msgid := m.MessageID
+	if msgid == "" {
+		var err error
+		if msgid, err = genMsgID(); err != nil {
+			return nil, "", err
+		}
 	}
-	defer wc.Close()
 
-	buf := bytes.NewBufferString("some email body")
+	fields = append(fields, headerField{name: "Message-ID", value: angleBracket(msgid)})
+
+	if m.InReplyTo != "" {
+		fields = append(fields, headerField{name: "In-Reply-To", value: angleBracket(m.InReplyTo)})
+	}
 
-	_, err = buf.WriteTo(wc)
+	// References are separated by whitespace only, and folded between
+	// identifiers so that long reply chains stay within line limits.
+	if len(m.References) > 0 {
+		refs := make([]string, len(m.References))
+		for i, ref := range m.References {
+			refs[i] = angleBracket(ref)
+		}
+		fields = append(fields, headerField{name: "References", list: refs})
+	}
+	fields = append(fields, headerField{name: "Subject", value: encodeText(m.Subject)})
+
+	// Each comment is a field of its own (RFC 5322 section 3.6.5).
+	for _, c := range m.Comments {
+		fields = append(fields, headerField{name: "Comments", value: encodeText(c)})
+	}
+
+	if len(m.Keywords) > 0 {
+		keywords := make([]string, len(m.Keywords))
+		for i, k := range m.Keywords {
+			keywords[i] = encodeText(k)
+		}
+		fields = append(fields, headerField{name: "Keywords", list: keywords, sep: ","})
+	}
+
+	if m.AcceptLanguage != "" {
+		fields = append(fields, headerField{name: "Accept-Language", value: m.AcceptLanguage})
+	}
+
+	if m.DispositionNotificationTo != "" {
+		fields = append(fields, headerField{
+			name:  "Disposition-Notification-To",
+			value: encodeAddresses(m.DispositionNotificationTo),
+		})
+	}
+
+	if len(m.DispositionNotificationOptions) > 0 {
+		fields = append(fields, headerField{
+			name: "Disposition-Notification-Options",
+			list: m.DispositionNotificationOptions,
+			sep:  ";",
+		})
+	}
+
+	fields, err := m.priorityHeader(fields)
 	if err != nil {
-		log.Fatal(err)
+		return nil, "", err
 	}
+
+	if m.Mailer != "" && !m.OmitMailer {
+		fields = append(fields, headerField{name: "X-Mailer", value: m.Mailer})
+	}
+
+	if m.Campaign != "" {
+		fields = append(fields, headerField{name: "X-Campaign", value: m.Campaign})
+	}
+
+	if len(m.Header) > 0 {
+		keys := make([]string, 0, len(m.Header))
+		for k := range m.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			// Return-Path is added by the final server from the
+			// envelope, and Bcc would disclose the blind recipients.
+			switch textproto.CanonicalMIMEHeaderKey(k) {
+			case "Return-Path", "Bcc":
+				continue
+			}
+
+			for _, v := range m.Header[k] {
+				fields = append(fields, headerField{name: k, value: v})
+			}
+		}
+	}
+
+	return m.mimeHeader(fields)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,174 @@
+package postman
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchMail returns a message with n recipients in To and in Cc, and a
+// text and an HTML part.
+func benchMail(n int) *Mail {
+	m := &Mail{
+		Date:      time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC),
+		MessageID: "<bench@postman.test>",
+		From:      "Sender <sender@example.com>",
+		Subject:   "Benchmark",
+		Parts: []Part{
+			{ContentType: "text/plain", Content: bytes.Repeat([]byte("Hello, World!\r\n"), 100)},
+			{ContentType: "text/html", Content: bytes.Repeat([]byte("<p>Hello, World!</p>\r\n"), 100)},
+		},
+	}
+	for i := 0; i < n; i++ {
+		m.To = append(m.To, fmt.Sprintf("To %d <to%d@example.com>", i, i))
+		m.Cc = append(m.Cc, fmt.Sprintf("cc%d@example.com", i))
+	}
+
+	return m
+}
+
+func TestBytes(t *testing.T) {
+	m := benchMail(10)
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+
+	b, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the boundaries, of a fixed length, differ between renderings.
+	if len(b) != buf.Len() {
+		t.Errorf("Bytes rendered %d bytes, WriteTo %d", len(b), buf.Len())
+	}
+}
+
+func benchmarkWriteTo(b *testing.B, n int) {
+	m := benchMail(n)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.WriteTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteTo1(b *testing.B)    { benchmarkWriteTo(b, 1) }
+func BenchmarkWriteTo100(b *testing.B)  { benchmarkWriteTo(b, 100) }
+func BenchmarkWriteTo1000(b *testing.B) { benchmarkWriteTo(b, 1000) }
+
+func BenchmarkString1000(b *testing.B) {
+	m := benchMail(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.String(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// renderHeader returns the header fields of m as rendered, folded.
+func renderHeader(t *testing.T, m *Mail) string {
+	t.Helper()
+
+	if m.From == "" {
+		m.From = "sender@example.com"
+	}
+	m.Date = time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	m.MessageID = "<test@postman.test>"
+	m.Parts = []Part{{ContentType: "text/plain", Content: []byte("Hello")}}
+
+	b, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b[:bytes.Index(b, []byte("\r\n\r\n"))+2])
+}
+
+func TestHeaderFields(t *testing.T) {
+	tests := []struct {
+		name string
+		mail Mail
+		want []string
+	}{
+		{
+			"comments", Mail{Comments: []string{"First comment", "Commentaire déplacé"}},
+			[]string{"Comments: First comment\r\n", "Comments: =?utf-8?q?Commentaire_d=C3=A9plac=C3=A9?=\r\n"},
+		},
+		{
+			"folded comment", Mail{Comments: []string{strings.TrimSpace(strings.Repeat("comment ", 12))}},
+			[]string{"Comments: comment comment comment comment comment comment comment comment\r\n comment comment comment comment\r\n"},
+		},
+		{
+			"keywords", Mail{Keywords: []string{"invoice", "march", "urgent"}},
+			[]string{"Keywords: invoice, march, urgent\r\n"},
+		},
+		{
+			"encoded keywords", Mail{Keywords: []string{"café", "b"}},
+			[]string{"Keywords: =?utf-8?q?caf=C3=A9?=, b\r\n"},
+		},
+		{
+			"accept language", Mail{AcceptLanguage: "fr, en;q=0.8"},
+			[]string{"Accept-Language: fr, en;q=0.8\r\n"},
+		},
+		{
+			"disposition notification",
+			Mail{
+				DispositionNotificationTo:      "Sender <sender@example.com>",
+				DispositionNotificationOptions: []string{"signed-receipt-protocol=optional,pkcs7-signature", "signed-receipt-micalg=optional,sha1"},
+			},
+			[]string{
+				"Disposition-Notification-To: Sender <sender@example.com>\r\n",
+				"Disposition-Notification-Options: signed-receipt-protocol=optional,pkcs7-signature;\r\n signed-receipt-micalg=optional,sha1\r\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := renderHeader(t, &tt.mail)
+			for _, want := range tt.want {
+				if !strings.Contains(header, want) {
+					t.Errorf("header lacks %q:\n%s", want, header)
+				}
+			}
+		})
+	}
+}
+
+func TestHeaderFieldsOmitted(t *testing.T) {
+	header := renderHeader(t, &Mail{})
+	for _, name := range []string{"Comments:", "Keywords:", "Accept-Language:", "Disposition-Notification-To:", "Disposition-Notification-Options:"} {
+		if strings.Contains(header, name) {
+			t.Errorf("header has an empty %s field:\n%s", name, header)
+		}
+	}
+}
+
+func TestKeywordsRoundTrip(t *testing.T) {
+	keywords := []string{"café", "plain"}
+
+	raw, err := (&Mail{From: "sender@example.com", Keywords: keywords}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ParseMail(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Keywords, keywords) {
+		t.Errorf("got %q, want %q", m.Keywords, keywords)
+	}
+}
@@ -0,0 +1,160 @@
+package postman
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Option configures a Client created by NewClient:
+//
+//	c := postman.NewClient("smtp.example.com:587",
+//		postman.WithAuth(smtp.PlainAuth("", "postman", password, "smtp.example.com")),
+//		postman.WithTLS(postman.TLSRequired, nil),
+//		postman.WithPool(10),
+//		postman.WithRetry(postman.RetryPolicy{Attempts: 3, Backoff: time.Second}),
+//	)
+//
+// Options only set fields of the Client, which may still be set directly.
+type Option func(*Client)
+
+// WithAuth sets the authentication of the client.
+func WithAuth(auth smtp.Auth) Option {
+	return func(c *Client) {
+		c.Auth = auth
+	}
+}
+
+// WithXClient sends the attributes of the original client of the
+// messages with the XCLIENT command.
+func WithXClient(x *XClient) Option {
+	return func(c *Client) {
+		c.XClient = x
+	}
+}
+
+// WithTLS sets how connections are encrypted, and with which
+// configuration if cfg is not nil.
+func WithTLS(mode TLSMode, cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.TLSMode = mode
+		c.TLSConfig = cfg
+	}
+}
+
+// WithPins restricts the servers accepted to those matching one of the
+// pins, as Client.Pins describes.
+func WithPins(pins ...string) Option {
+	return func(c *Client) {
+		c.Pins = pins
+	}
+}
+
+// WithTLSPolicies sets the TLS policies of destinations, as
+// Client.TLSPolicies describes.
+func WithTLSPolicies(policies TLSPolicyMap) Option {
+	return func(c *Client) {
+		c.TLSPolicies = policies
+	}
+}
+
+// WithDefaults sets the values merged into every message sent.
+func WithDefaults(d *Defaults) Option {
+	return func(c *Client) {
+		c.Defaults = d
+	}
+}
+
+// WithDialer sets the dialer opening the connections and the delay
+// between the concurrent attempts to the addresses of the server.
+func WithDialer(d *net.Dialer, fallbackDelay time.Duration) Option {
+	return func(c *Client) {
+		c.Dialer = d
+		c.FallbackDelay = fallbackDelay
+	}
+}
+
+// WithProxyProtocol sends the header of the given version of the PROXY
+// protocol on every connection.
+func WithProxyProtocol(version int) Option {
+	return func(c *Client) {
+		c.ProxyProtocol = version
+	}
+}
+
+// WithPool sets the number of idle connections kept open.
+func WithPool(size int) Option {
+	return func(c *Client) {
+		c.PoolSize = size
+	}
+}
+
+// WithRetry sets the policy applied to temporary failures.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.Retry = policy
+	}
+}
+
+// WithMailer sets the X-Mailer field stamped in messages; empty disables
+// it.
+func WithMailer(mailer string) Option {
+	return func(c *Client) {
+		c.Mailer = mailer
+	}
+}
+
+// WithDKIM adds signers to the client.
+func WithDKIM(signers ...*DKIMSigner) Option {
+	return func(c *Client) {
+		c.DKIM = append(c.DKIM, signers...)
+	}
+}
+
+// WithIdentities adds sending identities to the client.
+func WithIdentities(ids ...*Identity) Option {
+	return func(c *Client) {
+		c.Identities = append(c.Identities, ids...)
+	}
+}
+
+// WithQuota limits the messages sent by every sender to q, counted in
+// store, or in memory if store is nil.
+func WithQuota(q Quota, store QuotaStore) Option {
+	return func(c *Client) {
+		c.Quota = &q
+		c.Quotas = store
+	}
+}
+
+// WithSpool spools the attachments of the messages to temporary files in
+// dir once their total size exceeds threshold bytes.
+func WithSpool(threshold int64, dir string) Option {
+	return func(c *Client) {
+		c.Spool = &Spool{Threshold: threshold, Dir: dir}
+	}
+}
+
+// WithSuppressions leaves the addresses suppressed in store out of the
+// recipients of the messages.
+func WithSuppressions(store SuppressionStore) Option {
+	return func(c *Client) {
+		c.Suppressions = store
+	}
+}
+
+// WithArchive records the messages sent, as they are sent, in a.
+func WithArchive(a *Archive) Option {
+	return func(c *Client) {
+		c.Archive = a
+	}
+}
+
+// WithTracker sets the tracker recording the delivery status of the
+// messages; nil disables tracking.
+func WithTracker(t *Tracker) Option {
+	return func(c *Client) {
+		c.Tracker = t
+	}
+}
@@ -0,0 +1,331 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Outbox delivers messages written to a SQL table in the transaction of
+// the application, so that they are only sent once it commits. The
+// table must be created beforehand, for example:
+//
+//	CREATE TABLE postman_outbox (
+//	    id           VARCHAR(32) PRIMARY KEY,
+//	    state        VARCHAR(16) NOT NULL,
+//	    created      TIMESTAMP NOT NULL,
+//	    updated      TIMESTAMP NOT NULL,
+//	    locked_until TIMESTAMP,
+//	    attempts     INTEGER NOT NULL,
+//	    error        TEXT,
+//	    mail_from    TEXT NOT NULL,
+//	    rcpt_to      TEXT NOT NULL,
+//	    message      BLOB NOT NULL   -- BYTEA with PostgreSQL
+//	);
+//	CREATE INDEX postman_outbox_state ON postman_outbox (state, created);
+//
+// Several relays may run against the same table: each row is claimed
+// before being sent. A message is sent at least once; it may be sent
+// again if its relay dies between the delivery and the update of its
+// row.
+type Outbox struct {
+	// DB is the database holding the table.
+	DB *sql.DB
+
+	// Transport delivers the messages. Those implementing RawSender, as
+	// Client, send the stored messages as they are, with their stored
+	// envelope; others are given the messages parsed back, which may lose
+	// part of their structure.
+	Transport Transport
+
+	// Table is the name of the table; the default is "postman_outbox".
+	Table string
+
+	// Placeholder returns the placeholder of the i-th parameter of a
+	// query, from 1. The default is "?", as used by MySQL and SQLite;
+	// PostgreSQL requires DollarPlaceholder.
+	Placeholder func(i int) string
+
+	// Interval is the delay between two polls of the table. The default
+	// is 5 seconds.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of messages sent per poll. The
+	// default is 100.
+	BatchSize int
+
+	// MaxAttempts is the number of deliveries attempted for a message
+	// before it is marked as failed. The default is 5, each attempt
+	// being delayed twice as much as the previous one.
+	MaxAttempts int
+
+	// Lock is how long a relay owns a claimed message. The default is 5
+	// minutes.
+	Lock time.Duration
+}
+
+// States of the messages of an Outbox.
+const (
+	outboxPending = "pending"
+	outboxSent    = "sent"
+	outboxFailed  = "failed"
+)
+
+// DollarPlaceholder numbers parameters as PostgreSQL does, e.g. "$1".
+func DollarPlaceholder(i int) string {
+	return "$" + strconv.Itoa(i)
+}
+
+// Enqueue renders m and writes it to the outbox with tx, usually the
+// transaction of the change the message is about. It returns the
+// identifier of the row. Messages are first completed by the transport if
+// it implements Preparer, as Client does, for the message stored to be
+// the one it would send.
+func (o *Outbox) Enqueue(ctx context.Context, tx Execer, m *Mail) (string, error) {
+	if p, ok := o.Transport.(Preparer); ok {
+		var err error
+		if m, err = p.Prepare(m); err != nil {
+			return "", err
+		}
+	}
+
+	from, err := m.ReversePath()
+	if err != nil {
+		return "", err
+	}
+
+	rcpts, err := m.Recipients()
+	if err != nil {
+		return "", err
+	}
+
+	if len(rcpts) == 0 {
+		return "", fmt.Errorf("postman: no recipient")
+	}
+
+	raw, err := m.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := genID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	_, err = tx.ExecContext(ctx,
+		o.query("INSERT INTO %s (id, state, created, updated, attempts, mail_from, rcpt_to, message) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		id, outboxPending, now, now, 0, from, strings.Join(rcpts, "\n"), raw)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Run relays the pending messages until ctx is done.
+func (o *Outbox) Run(ctx context.Context) error {
+	interval := o.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := o.Relay(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Relay sends one batch of pending messages and returns the number of
+// messages sent. Delivery errors are recorded in the table rather than
+// returned.
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	batch := o.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+
+	now := time.Now().UTC()
+
+	rows, err := o.DB.QueryContext(ctx,
+		o.query("SELECT id FROM %s WHERE state = ? AND (locked_until IS NULL OR locked_until < ?) ORDER BY created LIMIT "+strconv.Itoa(batch)),
+		outboxPending, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		ok, err := o.relay(ctx, id)
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// relay claims and sends a message, and reports whether it was sent.
+func (o *Outbox) relay(ctx context.Context, id string) (bool, error) {
+	lock := o.Lock
+	if lock <= 0 {
+		lock = 5 * time.Minute
+	}
+
+	now := time.Now().UTC()
+
+	res, err := o.DB.ExecContext(ctx,
+		o.query("UPDATE %s SET locked_until = ? WHERE id = ? AND state = ? AND (locked_until IS NULL OR locked_until < ?)"),
+		now.Add(lock), id, outboxPending, now)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		// Claimed by another relay.
+		return false, err
+	}
+
+	var (
+		attempts int
+		from     string
+		rcpts    string
+		raw      []byte
+	)
+
+	err = o.DB.QueryRowContext(ctx,
+		o.query("SELECT attempts, mail_from, rcpt_to, message FROM %s WHERE id = ?"), id).
+		Scan(&attempts, &from, &rcpts, &raw)
+	if err != nil {
+		return false, err
+	}
+
+	err = o.send(from, rcpts, raw)
+	attempts++
+
+	switch {
+	case err == nil:
+		_, err = o.DB.ExecContext(ctx,
+			o.query("UPDATE %s SET state = ?, updated = ?, attempts = ?, error = NULL, locked_until = NULL WHERE id = ?"),
+			outboxSent, time.Now().UTC(), attempts, id)
+		return err == nil, err
+
+	case attempts >= o.maxAttempts() || !isTemporary(err):
+		_, err = o.DB.ExecContext(ctx,
+			o.query("UPDATE %s SET state = ?, updated = ?, attempts = ?, error = ?, locked_until = NULL WHERE id = ?"),
+			outboxFailed, time.Now().UTC(), attempts, err.Error(), id)
+		return false, err
+
+	default:
+		retry := time.Now().UTC().Add(o.backoff(attempts))
+		if qerr, ok := err.(*QuotaError); ok && qerr.Reset.After(retry) {
+			// Deferred by a quota until its next window.
+			retry = qerr.Reset.UTC()
+		}
+		_, err = o.DB.ExecContext(ctx,
+			o.query("UPDATE %s SET updated = ?, attempts = ?, error = ?, locked_until = ? WHERE id = ?"),
+			time.Now().UTC(), attempts, err.Error(), retry, id)
+		return false, err
+	}
+}
+
+func (o *Outbox) send(from, rcpts string, raw []byte) error {
+	if rs, ok := o.Transport.(RawSender); ok {
+		return rs.SendRaw(from, strings.Split(rcpts, "\n"), raw)
+	}
+
+	m, err := ParseMail(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	if from == "" {
+		from = NullReversePath
+	}
+	m.Envelope = Envelope{MailFrom: from, RcptTo: strings.Split(rcpts, "\n")}
+
+	return o.Transport.Send(m)
+}
+
+func (o *Outbox) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return 5
+	}
+	return o.MaxAttempts
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.Interval
+	if d <= 0 {
+		d = 5 * time.Second
+	}
+	return d << uint(attempts-1)
+}
+
+// query formats q with the table name and the placeholders of the
+// database.
+func (o *Outbox) query(q string) string {
+	table := o.Table
+	if table == "" {
+		table = "postman_outbox"
+	}
+	q = fmt.Sprintf(q, table)
+
+	if o.Placeholder == nil {
+		return q
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString(o.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
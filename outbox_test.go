@@ -0,0 +1,430 @@
+package postman
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outboxRow is a row of the table of a stubOutbox.
+type outboxRow struct {
+	id               string
+	state            string
+	created, updated time.Time
+	lockedUntil      *time.Time
+	attempts         int64
+	err              *string
+	from, rcpts      string
+	message          []byte
+}
+
+// stubOutbox is the table of an Outbox, kept in memory.
+type stubOutbox struct {
+	stubDB
+	rows map[string]*outboxRow
+}
+
+func newStubOutbox() *stubOutbox {
+	db := &stubOutbox{rows: make(map[string]*outboxRow)}
+
+	db.handle("INSERT INTO postman_outbox ", func(args []driver.Value) (*stubResult, error) {
+		r := &outboxRow{
+			id:       args[0].(string),
+			state:    args[1].(string),
+			created:  args[2].(time.Time),
+			updated:  args[3].(time.Time),
+			attempts: args[4].(int64),
+			from:     args[5].(string),
+			rcpts:    args[6].(string),
+			message:  args[7].([]byte),
+		}
+		db.rows[r.id] = r
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("SELECT id FROM postman_outbox WHERE state = ? AND (locked_until IS NULL OR locked_until < ?) ORDER BY created LIMIT ", func(args []driver.Value) (*stubResult, error) {
+		var rows []*outboxRow
+		for _, r := range db.rows {
+			if r.state == args[0].(string) && !r.locked(args[1].(time.Time)) {
+				rows = append(rows, r)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].created.Before(rows[j].created) })
+
+		res := &stubResult{columns: []string{"id"}}
+		for _, r := range rows {
+			res.rows = append(res.rows, []driver.Value{r.id})
+		}
+		return res, nil
+	})
+
+	db.handle("UPDATE postman_outbox SET locked_until = ? WHERE id = ? AND state = ? AND (locked_until IS NULL OR locked_until < ?)", func(args []driver.Value) (*stubResult, error) {
+		r := db.rows[args[1].(string)]
+		if r == nil || r.state != args[2].(string) || r.locked(args[3].(time.Time)) {
+			return &stubResult{}, nil
+		}
+		until := args[0].(time.Time)
+		r.lockedUntil = &until
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("SELECT attempts, mail_from, rcpt_to, message FROM postman_outbox WHERE id = ?", func(args []driver.Value) (*stubResult, error) {
+		res := &stubResult{columns: []string{"attempts", "mail_from", "rcpt_to", "message"}}
+		if r := db.rows[args[0].(string)]; r != nil {
+			res.rows = append(res.rows, []driver.Value{r.attempts, r.from, r.rcpts, r.message})
+		}
+		return res, nil
+	})
+
+	db.handle("UPDATE postman_outbox SET state = ?, updated = ?, attempts = ?, error = NULL, locked_until = NULL WHERE id = ?", func(args []driver.Value) (*stubResult, error) {
+		r := db.rows[args[3].(string)]
+		r.state, r.updated, r.attempts, r.err, r.lockedUntil = args[0].(string), args[1].(time.Time), args[2].(int64), nil, nil
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("UPDATE postman_outbox SET state = ?, updated = ?, attempts = ?, error = ?, locked_until = NULL WHERE id = ?", func(args []driver.Value) (*stubResult, error) {
+		r := db.rows[args[4].(string)]
+		msg := args[3].(string)
+		r.state, r.updated, r.attempts, r.err, r.lockedUntil = args[0].(string), args[1].(time.Time), args[2].(int64), &msg, nil
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("UPDATE postman_outbox SET updated = ?, attempts = ?, error = ?, locked_until = ? WHERE id = ?", func(args []driver.Value) (*stubResult, error) {
+		r := db.rows[args[4].(string)]
+		msg, until := args[2].(string), args[3].(time.Time)
+		r.updated, r.attempts, r.err, r.lockedUntil = args[0].(time.Time), args[1].(int64), &msg, &until
+		return &stubResult{affected: 1}, nil
+	})
+
+	return db
+}
+
+func (r *outboxRow) locked(now time.Time) bool {
+	return r.lockedUntil != nil && !r.lockedUntil.Before(now)
+}
+
+// row returns a copy of the row with the given identifier.
+func (db *stubOutbox) row(t *testing.T, id string) outboxRow {
+	t.Helper()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	r := db.rows[id]
+	if r == nil {
+		t.Fatalf("no row %s", id)
+	}
+	return *r
+}
+
+func TestOutboxQuota(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	for _, deferred := range []bool{false, true} {
+		c := NewClient(s.Addr, WithQuota(Quota{Messages: 1, Window: time.Hour, Defer: deferred}, nil))
+
+		db := newStubOutbox()
+		o := &Outbox{DB: db.open(), Transport: c}
+		defer o.DB.Close()
+
+		ctx := context.Background()
+		var ids []string
+		for _, msgID := range []string{"<first@postman.test>", "<second@postman.test>"} {
+			m := benchMail(1)
+			m.MessageID = msgID
+			id, err := o.Enqueue(ctx, o.DB, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+
+			// The rows are relayed in the order they were created in.
+			time.Sleep(time.Millisecond)
+		}
+
+		n, err := o.Relay(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("Defer %t: %d messages sent, want 1", deferred, n)
+		}
+
+		if r := db.row(t, ids[0]); r.state != outboxSent {
+			t.Errorf("Defer %t: first message %s", deferred, r.state)
+		}
+
+		r := db.row(t, ids[1])
+		if r.err == nil || r.attempts != 1 {
+			t.Fatalf("Defer %t: second message: %d attempts, error %v", deferred, r.attempts, r.err)
+		}
+		if !deferred {
+			if r.state != outboxFailed {
+				t.Errorf("Defer false: second message %s, want %s", r.state, outboxFailed)
+			}
+			c.Close()
+			continue
+		}
+
+		reset := time.Now().Truncate(time.Hour).Add(time.Hour)
+		if r.state != outboxPending || r.lockedUntil == nil || r.lockedUntil.Before(reset) {
+			t.Errorf("Defer true: second message %s until %v, want %s until %s", r.state, r.lockedUntil, outboxPending, reset)
+		}
+
+		c.Close()
+	}
+
+	s.Close()
+	if got := len(s.Messages()); got != 2 {
+		t.Errorf("server received %d messages, want 2", got)
+	}
+}
+
+// TestOutboxPrepare checks that the messages stored by an outbox are
+// completed by its client as those it sends.
+func TestOutboxPrepare(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr, WithDefaults(&Defaults{
+		From:    "Defaults <defaults@example.com>",
+		Header:  textproto.MIMEHeader{"X-Entity-Ref-Id": {"ref"}},
+		Footers: []Footer{{Text: "-- \r\nFooter"}},
+	}))
+	c.Mailer = "postman-test"
+	defer c.Close()
+
+	db := newStubOutbox()
+	o := &Outbox{DB: db.open(), Transport: c}
+	defer o.DB.Close()
+
+	ctx := context.Background()
+	m := &Mail{
+		To:      []string{"rcpt@example.com"},
+		Subject: "Outbox",
+		Parts:   []Part{{ContentType: "text/plain", Content: []byte("Hello\r\n")}},
+	}
+	if _, err := o.Enqueue(ctx, o.DB, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "" || m.MessageID != "" {
+		t.Errorf("message enqueued changed: %+v", m)
+	}
+
+	if n, err := o.Relay(ctx); err != nil || n != 1 {
+		t.Fatalf("Relay: %d messages sent, %v", n, err)
+	}
+
+	c.Close()
+	s.Close()
+
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(msgs))
+	}
+	if msgs[0].From != "defaults@example.com" {
+		t.Errorf("MAIL FROM:<%s>, want <defaults@example.com>", msgs[0].From)
+	}
+
+	for _, want := range []string{
+		"\nFrom: Defaults <defaults@example.com>\n",
+		"\nX-Entity-Ref-Id: ref\n",
+		"\nX-Mailer: postman-test\n",
+		"\nMessage-ID: <",
+		"\nDate: ",
+		"Hello\n\n-- \nFooter",
+	} {
+		if !strings.Contains("\n"+msgs[0].Data, want) {
+			t.Errorf("message sent without %q:\n%s", want, msgs[0].Data)
+		}
+	}
+}
+
+// outboxTransport is a transport failing with the errors it is given, in
+// turn, then delivering the messages.
+type outboxTransport struct {
+	mu   sync.Mutex
+	errs []error
+	sent []*Mail
+}
+
+func (t *outboxTransport) Send(m *Mail) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		return err
+	}
+	t.sent = append(t.sent, m)
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	temporary := &textproto.Error{Code: 451, Msg: "4.3.0 Try again later"}
+	permanent := &textproto.Error{Code: 550, Msg: "5.1.1 Unknown user"}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		relays   int
+		state    string
+		attempts int64
+		err      error
+	}{
+		{"sent", nil, 1, outboxSent, 1, nil},
+		{"retried", []error{temporary}, 1, outboxPending, 1, temporary},
+		{"sent when retried", []error{temporary, temporary}, 3, outboxSent, 3, nil},
+		{"failed", []error{permanent}, 1, outboxFailed, 1, permanent},
+		{"attempts exhausted", []error{temporary, temporary, temporary}, 3, outboxFailed, 3, temporary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &outboxTransport{errs: tt.errs}
+			db := newStubOutbox()
+			o := &Outbox{DB: db.open(), Transport: transport, MaxAttempts: 3, Interval: time.Minute}
+			defer o.DB.Close()
+
+			ctx := context.Background()
+			m := benchMail(1)
+			m.Bcc = []string{"bcc@example.com"}
+			id, err := o.Enqueue(ctx, o.DB, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := db.row(t, id)
+			if r.state != outboxPending || r.attempts != 0 || r.from != "sender@example.com" || r.rcpts != "to0@example.com\ncc0@example.com\nbcc@example.com" {
+				t.Fatalf("enqueued %+v", r)
+			}
+
+			for i := 0; i < tt.relays; i++ {
+				if i > 0 {
+					// Release the message as if its retry delay had
+					// elapsed.
+					db.mu.Lock()
+					db.rows[id].lockedUntil = nil
+					db.mu.Unlock()
+				}
+
+				before := time.Now().UTC()
+				if _, err := o.Relay(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				r := db.row(t, id)
+				if r.state == outboxPending {
+					// Each attempt is delayed twice as much as the
+					// previous one.
+					retry := before.Add(time.Minute << uint(r.attempts-1))
+					if r.lockedUntil == nil || r.lockedUntil.Before(retry) || r.lockedUntil.After(retry.Add(time.Second)) {
+						t.Errorf("attempt %d: retried at %v, want %v", r.attempts, r.lockedUntil, retry)
+					}
+				}
+			}
+
+			r = db.row(t, id)
+			if r.state != tt.state || r.attempts != tt.attempts {
+				t.Errorf("%s after %d attempts, want %s after %d", r.state, r.attempts, tt.state, tt.attempts)
+			}
+			switch {
+			case tt.err == nil && r.err != nil:
+				t.Errorf("error %q recorded", *r.err)
+			case tt.err != nil && (r.err == nil || *r.err != tt.err.Error()):
+				t.Errorf("error %v recorded, want %q", r.err, tt.err.Error())
+			}
+			if r.state != outboxPending && r.lockedUntil != nil {
+				t.Errorf("%s message still locked", r.state)
+			}
+
+			if tt.state != outboxSent {
+				if len(transport.sent) != 0 {
+					t.Errorf("%d messages sent, want none", len(transport.sent))
+				}
+				return
+			}
+			if len(transport.sent) != 1 {
+				t.Fatalf("%d messages sent, want 1", len(transport.sent))
+			}
+
+			// The message is parsed back for transports unable to send it
+			// as it was stored, with its envelope.
+			sent := transport.sent[0]
+			if sent.Subject != "Benchmark" || sent.MessageID != "<bench@postman.test>" {
+				t.Errorf("sent %q, %s", sent.Subject, sent.MessageID)
+			}
+			if rcpts, _ := sent.Recipients(); strings.Join(rcpts, " ") != "to0@example.com cc0@example.com bcc@example.com" {
+				t.Errorf("sent to %q", rcpts)
+			}
+
+			if n, err := o.Relay(ctx); err != nil || n != 0 {
+				t.Errorf("message sent relayed again: %d, %v", n, err)
+			}
+		})
+	}
+}
+
+// TestOutboxClaim checks that a message is only sent by the relay which
+// claimed it, even when several relays run at once.
+func TestOutboxClaim(t *testing.T) {
+	transport := &outboxTransport{}
+	db := newStubOutbox()
+	o := &Outbox{DB: db.open(), Transport: transport}
+	defer o.DB.Close()
+
+	ctx := context.Background()
+	var ids []string
+	for i := 0; i < 10; i++ {
+		id, err := o.Enqueue(ctx, o.DB, benchMail(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// A message claimed by a relay which has not released it yet.
+	locked := time.Now().UTC().Add(time.Minute)
+	db.mu.Lock()
+	db.rows[ids[0]].lockedUntil = &locked
+	db.mu.Unlock()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		sent int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := o.Relay(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			sent += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if sent != 9 || len(transport.sent) != 9 {
+		t.Errorf("%d messages reported sent and %d sent, want 9", sent, len(transport.sent))
+	}
+	if r := db.row(t, ids[0]); r.state != outboxPending || r.attempts != 0 {
+		t.Errorf("claimed message %s after %d attempts", r.state, r.attempts)
+	}
+	for _, id := range ids[1:] {
+		if r := db.row(t, id); r.state != outboxSent || r.attempts != 1 {
+			t.Errorf("message %s after %d attempts, want sent after 1", r.state, r.attempts)
+		}
+	}
+}
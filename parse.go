@@ -0,0 +1,412 @@
+package postman
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// knownFields are the header fields ParseMail maps to a field of Mail.
+// Any other field is kept in Mail.Header.
+var knownFields = map[string]bool{
+	"Date":                             true,
+	"From":                             true,
+	"Sender":                           true,
+	"Reply-To":                         true,
+	"To":                               true,
+	"Cc":                               true,
+	"Bcc":                              true,
+	"Message-Id":                       true,
+	"In-Reply-To":                      true,
+	"References":                       true,
+	"Subject":                          true,
+	"Comments":                         true,
+	"Keywords":                         true,
+	"Resent-Date":                      true,
+	"Resent-From":                      true,
+	"Resent-Sender":                    true,
+	"Resent-To":                        true,
+	"Resent-Cc":                        true,
+	"Resent-Bcc":                       true,
+	"Resent-Reply-To":                  true,
+	"Resent-Message-Id":                true,
+	"Return-Path":                      true,
+	"Received":                         true,
+	"Encrypted":                        true,
+	"Disposition-Notification-To":      true,
+	"Disposition-Notification-Options": true,
+	"Accept-Language":                  true,
+	"Importance":                       true,
+	"Priority":                         true,
+	"Sensitivity":                      true,
+	"X-Mailer":                         true,
+	"X-Campaign":                       true,
+	"Mime-Version":                     true,
+	"Content-Type":                     true,
+	"Content-Transfer-Encoding":        true,
+}
+
+var wordDecoder = mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	},
+}
+
+// ParseMail reads a message, as found in an .eml file or received over
+// SMTP. The Return-Path field, if any, becomes the envelope reverse-path.
+// Body parts are decoded: alternative representations become Parts and
+// anything with a file name or an attachment disposition becomes an
+// Attachment. It uses a strict Parser with the default limits.
+func ParseMail(r io.Reader) (*Mail, error) {
+	return new(Parser).ParseMail(r)
+}
+
+// ParseMail reads a message as the ParseMail function does, within the
+// limits of p.
+func (p *Parser) ParseMail(r io.Reader) (*Mail, error) {
+	s := p.session(r)
+
+	m, err := s.mail(s.r, 0)
+	if err != nil {
+		return nil, s.err(err)
+	}
+
+	return m, nil
+}
+
+// mail parses a message, enclosed in depth entities.
+func (s *parseSession) mail(r io.Reader, depth int) (*Mail, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := msg.Header
+	m := &Mail{
+		From:                      h.Get("From"),
+		Sender:                    h.Get("Sender"),
+		ReplyTo:                   h.Get("Reply-To"),
+		To:                        splitList(h.Get("To")),
+		Cc:                        splitList(h.Get("Cc")),
+		Bcc:                       splitList(h.Get("Bcc")),
+		MessageID:                 h.Get("Message-Id"),
+		InReplyTo:                 h.Get("In-Reply-To"),
+		References:                strings.Fields(h.Get("References")),
+		Subject:                   decodeHeader(h.Get("Subject")),
+		ResentFrom:                splitList(h.Get("Resent-From")),
+		ResentSender:              h.Get("Resent-Sender"),
+		ResentTo:                  splitList(h.Get("Resent-To")),
+		ResentCc:                  splitList(h.Get("Resent-Cc")),
+		ResentBcc:                 splitList(h.Get("Resent-Bcc")),
+		ResentReplyTo:             h.Get("Resent-Reply-To"),
+		ResentMessageID:           h.Get("Resent-Message-Id"),
+		Encrypted:                 h.Get("Encrypted"),
+		DispositionNotificationTo: h.Get("Disposition-Notification-To"),
+		AcceptLanguage:            h.Get("Accept-Language"),
+		Mailer:                    h.Get("X-Mailer"),
+		Campaign:                  h.Get("X-Campaign"),
+	}
+
+	if v := h.Get("Date"); v != "" {
+		if m.Date, err = mail.ParseDate(v); err != nil && !s.lenient {
+			return nil, fmt.Errorf("postman: invalid Date: %v", err)
+		}
+	}
+
+	if v := h.Get("Resent-Date"); v != "" {
+		if m.ResentDate, err = mail.ParseDate(v); err != nil && !s.lenient {
+			return nil, fmt.Errorf("postman: invalid Resent-Date: %v", err)
+		}
+	}
+
+	if v := h.Get("Return-Path"); v != "" {
+		m.Envelope.MailFrom = strings.TrimSpace(v)
+	}
+
+	for _, v := range h["Comments"] {
+		m.Comments = append(m.Comments, decodeHeader(v))
+	}
+
+	for _, v := range h["Received"] {
+		m.Received = append(m.Received, parseReceived(v))
+	}
+
+	if v := h.Get("Keywords"); v != "" {
+		for _, k := range strings.Split(decodeHeader(v), ",") {
+			m.Keywords = append(m.Keywords, strings.TrimSpace(k))
+		}
+	}
+
+	if v := h.Get("Disposition-Notification-Options"); v != "" {
+		for _, o := range strings.Split(v, ";") {
+			m.DispositionNotificationOptions = append(m.DispositionNotificationOptions, strings.TrimSpace(o))
+		}
+	}
+
+	if v := h.Get("Importance"); v != "" {
+		if m.Importance, err = ParseImportance(v); err != nil && !s.lenient {
+			return nil, err
+		}
+	}
+
+	if v := h.Get("Priority"); v != "" {
+		if m.Priority, err = ParsePriority(v); err != nil && !s.lenient {
+			return nil, err
+		}
+	}
+
+	if v := h.Get("Sensitivity"); v != "" {
+		if m.Sensitivity, err = ParseSensitivity(v); err != nil && !s.lenient {
+			return nil, err
+		}
+	}
+
+	for k, v := range h {
+		if knownFields[k] {
+			continue
+		}
+		if m.Header == nil {
+			m.Header = make(textproto.MIMEHeader)
+		}
+		m.Header[k] = v
+	}
+
+	if h.Get("Content-Type") == "" && h.Get("Mime-Version") == "" {
+		body, err := ioutil.ReadAll(msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > 0 {
+			m.Parts = []Part{{ContentType: "text/plain", Content: body}}
+		}
+		return m, nil
+	}
+
+	if err := s.entity(m, textproto.MIMEHeader(h), msg.Body, depth); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// entity adds the MIME entity with header h and body r, at the given
+// depth, to the parts or the attachments of m, descending into multipart
+// entities. The messages of a multipart/digest entity are added to its
+// digest.
+func (s *parseSession) entity(m *Mail, h textproto.MIMEHeader, r io.Reader, depth int) error {
+	if err := s.enter(depth); err != nil {
+		return err
+	}
+
+	ctype := h.Get("Content-Type")
+	if ctype == "" {
+		ctype = "text/plain; charset=us-ascii"
+	}
+
+	mediatype, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		if !s.lenient {
+			return fmt.Errorf("postman: invalid Content-Type %q: %v", ctype, err)
+		}
+		mediatype, params = "application/octet-stream", nil
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			if !s.lenient {
+				return fmt.Errorf("postman: %s without boundary", mediatype)
+			}
+			mediatype, params = "application/octet-stream", nil
+		}
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				if s.recoverable(err) {
+					return nil
+				}
+				return err
+			}
+
+			if mediatype == "multipart/digest" {
+				if ctype, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); ctype == "" || ctype == "message/rfc822" {
+					msg, err := s.mail(decodeBody(p.Header, p), depth+1)
+					if err == nil {
+						m.Digest = append(m.Digest, msg)
+					} else if !s.recoverable(err) {
+						return err
+					}
+					continue
+				}
+			}
+
+			if err := s.entity(m, p.Header, p, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := ioutil.ReadAll(decodeBody(h, r))
+	if err != nil && !s.recoverable(err) {
+		return err
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+
+	filename := dparams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	filename = decodeHeader(filename)
+
+	if disposition == "attachment" || filename != "" {
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:           filename,
+			ContentDisposition: disposition,
+			ContentID:          strings.Trim(h.Get("Content-Id"), "<>"),
+			Content:            content,
+		})
+		return nil
+	}
+
+	m.Parts = append(m.Parts, Part{ContentType: mediatype, Params: params, Content: content})
+
+	return nil
+}
+
+// decodeBody undoes the transfer encoding of an entity. Quoted-printable
+// parts of a multipart body are already decoded by multipart.Reader,
+// which also removes their Content-Transfer-Encoding field.
+func decodeBody(h textproto.MIMEHeader, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+func decodeHeader(s string) string {
+	d, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return d
+}
+
+// splitList splits an address list on the commas which are neither
+// quoted, commented nor inside angle brackets.
+func splitList(s string) []string {
+	var (
+		list  []string
+		depth int
+		quote bool
+		start int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quote:
+			i++
+		case c == '"':
+			quote = !quote
+		case quote:
+		case c == '(' || c == '<':
+			depth++
+		case c == ')' || c == '>':
+			depth--
+		case c == ',' && depth == 0:
+			if v := strings.TrimSpace(s[start:i]); v != "" {
+				list = append(list, v)
+			}
+			start = i + 1
+		}
+	}
+
+	if v := strings.TrimSpace(s[start:]); v != "" {
+		list = append(list, v)
+	}
+
+	return list
+}
+
+// parseReceived parses the clauses of a Received field value. Clauses it
+// does not know about are ignored.
+func parseReceived(v string) Received {
+	var r Received
+
+	if i := strings.LastIndexByte(v, ';'); i >= 0 {
+		r.Date, _ = mail.ParseDate(strings.TrimSpace(v[i+1:]))
+		v = v[:i]
+	}
+
+	tokens := strings.Fields(v)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if strings.HasPrefix(tok, "(") {
+			var comment []string
+			for ; i < len(tokens); i++ {
+				comment = append(comment, tokens[i])
+				if strings.HasSuffix(tokens[i], ")") {
+					break
+				}
+			}
+			if r.FromHost == "" && r.FromIP == nil && r.By == "" {
+				r.parseTCPInfo(strings.Trim(strings.Join(comment, " "), "()"))
+			}
+			continue
+		}
+
+		if i+1 >= len(tokens) {
+			break
+		}
+
+		switch strings.ToLower(tok) {
+		case "from":
+			r.From = tokens[i+1]
+		case "by":
+			r.By = tokens[i+1]
+		case "via":
+			r.Via = tokens[i+1]
+		case "with":
+			r.With = tokens[i+1]
+		case "id":
+			r.ID = tokens[i+1]
+		case "for":
+			r.For = strings.Trim(tokens[i+1], "<>")
+		default:
+			continue
+		}
+		i++
+	}
+
+	return r
+}
+
+func (r *Received) parseTCPInfo(info string) {
+	for _, tok := range strings.Fields(info) {
+		if strings.HasPrefix(tok, "[") {
+			lit := strings.TrimPrefix(strings.Trim(tok, "[]"), "IPv6:")
+			r.FromIP = net.ParseIP(lit)
+		} else if r.FromHost == "" {
+			r.FromHost = tok
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package postman
+
+import (
+	"errors"
+	"io"
+)
+
+// Limits of the parsers whose limits are zero.
+const (
+	DefaultMaxMessageSize = 32 << 20
+	DefaultMaxDepth       = 16
+	DefaultMaxParts       = 1000
+)
+
+// Errors returned by parsers when a message exceeds their limits, in
+// either mode.
+var (
+	ErrMessageTooLarge = errors.New("postman: message too large")
+	ErrNestingTooDeep  = errors.New("postman: MIME entities nested too deeply")
+	ErrTooManyParts    = errors.New("postman: too many MIME entities")
+)
+
+// ParseMode tells how a Parser handles malformed messages.
+type ParseMode int
+
+const (
+	// ParseStrict rejects malformed messages.
+	ParseStrict ParseMode = iota
+
+	// ParseLenient recovers what it can of malformed messages: invalid
+	// dates and priorities are ignored, entities with an invalid content
+	// type are kept as application/octet-stream parts, truncated
+	// multipart entities keep the parts read and entities whose transfer
+	// encoding is broken keep the content decoded.
+	ParseLenient
+)
+
+// Parser reads messages while bounding the resources spent on each, so
+// that inbound processing cannot be exhausted by crafted messages. The
+// zero value is a strict parser with the default limits, as used by
+// ParseMail, ParseDSN and ParseFeedbackReport.
+type Parser struct {
+	Mode ParseMode
+
+	// MaxSize is the maximum size of a message, in bytes.
+	MaxSize int64
+
+	// MaxDepth is the maximum nesting of multipart entities and enclosed
+	// messages.
+	MaxDepth int
+
+	// MaxParts is the maximum number of MIME entities of a message,
+	// including those of enclosed messages.
+	MaxParts int
+}
+
+// parseSession holds the state of the parsing of a message.
+type parseSession struct {
+	lenient  bool
+	maxDepth int
+	maxParts int
+	parts    int
+	r        *limitReader
+}
+
+func (p *Parser) session(r io.Reader) *parseSession {
+	s := parseSession{
+		lenient:  p.Mode == ParseLenient,
+		maxDepth: p.MaxDepth,
+		maxParts: p.MaxParts,
+		r:        &limitReader{r: r, n: p.MaxSize},
+	}
+
+	if s.maxDepth <= 0 {
+		s.maxDepth = DefaultMaxDepth
+	}
+	if s.maxParts <= 0 {
+		s.maxParts = DefaultMaxParts
+	}
+	if s.r.n <= 0 {
+		s.r.n = DefaultMaxMessageSize
+	}
+
+	return &s
+}
+
+// enter accounts for a new entity at the given depth.
+func (s *parseSession) enter(depth int) error {
+	if depth > s.maxDepth {
+		return ErrNestingTooDeep
+	}
+
+	s.parts++
+	if s.parts > s.maxParts {
+		return ErrTooManyParts
+	}
+
+	return nil
+}
+
+// recoverable reports whether the parsing goes on after err in lenient
+// mode, which is not the case when a limit is reached.
+func (s *parseSession) recoverable(err error) bool {
+	return s.lenient && !s.r.exceeded && err != ErrNestingTooDeep && err != ErrTooManyParts
+}
+
+// err returns the error ending the parsing, ErrMessageTooLarge if the
+// message exceeded the size limit, whatever the reader which failed
+// reported.
+func (s *parseSession) err(err error) error {
+	if s.r.exceeded {
+		return ErrMessageTooLarge
+	}
+	return err
+}
+
+// limitReader fails once more than n bytes are read from r.
+type limitReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, ErrMessageTooLarge
+	}
+
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		l.exceeded = true
+		return 0, ErrMessageTooLarge
+	}
+	l.n -= int64(n)
+
+	return n, err
+}
@@ -0,0 +1,97 @@
+package postman
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// pin is the SHA-256 hash of a certificate or of its public key.
+type pin struct {
+	cert bool
+	hash []byte
+}
+
+// parsePin parses "sha256/" followed by the base64 encoded SHA-256 hash of
+// a SubjectPublicKeyInfo, as in HPKP, or "cert-sha256/" followed by that
+// of a whole certificate.
+func parsePin(s string) (pin, error) {
+	var p pin
+
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return p, fmt.Errorf("postman: invalid pin %q", s)
+	}
+
+	switch s[:i] {
+	case "sha256":
+	case "cert-sha256":
+		p.cert = true
+	default:
+		return p, fmt.Errorf("postman: unknown pin algorithm %q", s[:i])
+	}
+
+	hash, err := base64.StdEncoding.DecodeString(s[i+1:])
+	if err != nil || len(hash) != sha256.Size {
+		return p, fmt.Errorf("postman: invalid pin %q", s)
+	}
+	p.hash = hash
+
+	return p, nil
+}
+
+func (p pin) match(cert *x509.Certificate) bool {
+	data := cert.RawSubjectPublicKeyInfo
+	if p.cert {
+		data = cert.Raw
+	}
+	hash := sha256.Sum256(data)
+	return bytes.Equal(hash[:], p.hash)
+}
+
+// pinVerifier returns a VerifyPeerCertificate function accepting the
+// server when one of its certificates matches one of the pins, after
+// calling next, if any. The whole verified chains are checked; when the
+// certificate is not verified against certificate authorities, only the
+// certificate of the server itself is.
+func pinVerifier(pins []string, next func([][]byte, [][]*x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+
+		var certs []*x509.Certificate
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+
+		if len(verifiedChains) == 0 && len(rawCerts) > 0 {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+
+		for _, s := range pins {
+			p, err := parsePin(s)
+			if err != nil {
+				return err
+			}
+
+			for _, cert := range certs {
+				if p.match(cert) {
+					return nil
+				}
+			}
+		}
+
+		return errors.New("postman: server certificate does not match any pin")
+	}
+}
@@ -0,0 +1,110 @@
+package postman
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// pinOf returns the pin of the public key of cert, or of the whole
+// certificate.
+func pinOf(cert *x509.Certificate, whole bool) string {
+	if whole {
+		hash := sha256.Sum256(cert.Raw)
+		return "cert-sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+	}
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func TestParsePin(t *testing.T) {
+	if p, err := parsePin(testPin); err != nil || p.cert || len(p.hash) != sha256.Size {
+		t.Errorf("parsePin(%q) = %+v, %v", testPin, p, err)
+	}
+	if p, err := parsePin("cert-" + testPin); err != nil || !p.cert {
+		t.Errorf("parsePin(%q) = %+v, %v", "cert-"+testPin, p, err)
+	}
+
+	for _, s := range []string{
+		"",
+		"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		"sha1/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		"sha256/not base64",
+		"sha256/" + base64.StdEncoding.EncodeToString(make([]byte, 20)),
+	} {
+		if _, err := parsePin(s); err == nil {
+			t.Errorf("parsePin(%q): got no error", s)
+		}
+	}
+}
+
+func TestPinVerifier(t *testing.T) {
+	ca := testCert(t, "Test CA", true, nil)
+	server := testCert(t, "mx.example.com", false, &ca)
+	other := testCert(t, "mx.example.com", false, nil)
+
+	raw := server.Certificate
+	chains := [][]*x509.Certificate{{server.Leaf, ca.Leaf}}
+
+	tests := []struct {
+		name   string
+		pins   []string
+		chains [][]*x509.Certificate
+		ok     bool
+	}{
+		{"public key", []string{pinOf(server.Leaf, false)}, chains, true},
+		{"certificate", []string{pinOf(server.Leaf, true)}, chains, true},
+		{"one of several", []string{pinOf(other.Leaf, false), pinOf(server.Leaf, true)}, chains, true},
+		{"certificate authority", []string{pinOf(ca.Leaf, false)}, chains, true},
+		{"mismatch", []string{pinOf(other.Leaf, false), pinOf(other.Leaf, true)}, chains, false},
+		{"unverified server", []string{pinOf(server.Leaf, false)}, nil, true},
+		// Without verified chains, the chain sent by the server proves
+		// nothing.
+		{"unverified certificate authority", []string{pinOf(ca.Leaf, false)}, nil, false},
+		{"invalid pin", []string{"sha256/"}, chains, false},
+	}
+
+	for _, tt := range tests {
+		err := pinVerifier(tt.pins, nil)(raw, tt.chains)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want success: %t", tt.name, err, tt.ok)
+		}
+	}
+
+	err := pinVerifier([]string{pinOf(other.Leaf, false)}, nil)(raw, chains)
+	if err == nil || !strings.Contains(err.Error(), "does not match any pin") {
+		t.Errorf("mismatch: got %v", err)
+	}
+}
+
+// TestClientPins checks that a handshake with a server whose certificate
+// matches no pin fails.
+func TestClientPins(t *testing.T) {
+	ca := testCert(t, "Test CA", true, nil)
+	server := testCert(t, "mx.example.com", false, &ca)
+	other := testCert(t, "mx.example.com", false, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	for _, tt := range []struct {
+		name string
+		pin  string
+		ok   bool
+	}{
+		{"match", pinOf(server.Leaf, false), true},
+		{"mismatch", pinOf(other.Leaf, false), false},
+	} {
+		c := &Client{TLSConfig: &tls.Config{ServerName: "mx.example.com", RootCAs: roots}}
+		cfg, err := c.tlsConfig("mx.example.com", "25", connPolicy{mode: TLSRequired, pins: []string{tt.pin}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(cfg, server); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want success: %t", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,743 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: postman.proto
+
+package postmanpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Message struct {
+	From       string   `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Sender     string   `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	ReplyTo    string   `protobuf:"bytes,3,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	To         []string `protobuf:"bytes,4,rep,name=to,proto3" json:"to,omitempty"`
+	Cc         []string `protobuf:"bytes,5,rep,name=cc,proto3" json:"cc,omitempty"`
+	Bcc        []string `protobuf:"bytes,6,rep,name=bcc,proto3" json:"bcc,omitempty"`
+	Subject    string   `protobuf:"bytes,7,opt,name=subject,proto3" json:"subject,omitempty"`
+	MessageId  string   `protobuf:"bytes,8,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	InReplyTo  string   `protobuf:"bytes,9,opt,name=in_reply_to,json=inReplyTo,proto3" json:"in_reply_to,omitempty"`
+	References []string `protobuf:"bytes,10,rep,name=references,proto3" json:"references,omitempty"`
+	// One of "urgent", "normal" or "non-urgent".
+	Priority    string        `protobuf:"bytes,11,opt,name=priority,proto3" json:"priority,omitempty"`
+	Headers     []*Header     `protobuf:"bytes,12,rep,name=headers,proto3" json:"headers,omitempty"`
+	Parts       []*Part       `protobuf:"bytes,13,rep,name=parts,proto3" json:"parts,omitempty"`
+	Attachments []*Attachment `protobuf:"bytes,14,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	// Tags the message for archive searches and reports.
+	Campaign             string   `protobuf:"bytes,15,opt,name=campaign,proto3" json:"campaign,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+func (*Message) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{0}
+}
+
+func (m *Message) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message.Unmarshal(m, b)
+}
+func (m *Message) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message.Marshal(b, m, deterministic)
+}
+func (m *Message) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message.Merge(m, src)
+}
+func (m *Message) XXX_Size() int {
+	return xxx_messageInfo_Message.Size(m)
+}
+func (m *Message) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message proto.InternalMessageInfo
+
+func (m *Message) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *Message) GetSender() string {
+	if m != nil {
+		return m.Sender
+	}
+	return ""
+}
+
+func (m *Message) GetReplyTo() string {
+	if m != nil {
+		return m.ReplyTo
+	}
+	return ""
+}
+
+func (m *Message) GetTo() []string {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *Message) GetCc() []string {
+	if m != nil {
+		return m.Cc
+	}
+	return nil
+}
+
+func (m *Message) GetBcc() []string {
+	if m != nil {
+		return m.Bcc
+	}
+	return nil
+}
+
+func (m *Message) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *Message) GetMessageId() string {
+	if m != nil {
+		return m.MessageId
+	}
+	return ""
+}
+
+func (m *Message) GetInReplyTo() string {
+	if m != nil {
+		return m.InReplyTo
+	}
+	return ""
+}
+
+func (m *Message) GetReferences() []string {
+	if m != nil {
+		return m.References
+	}
+	return nil
+}
+
+func (m *Message) GetPriority() string {
+	if m != nil {
+		return m.Priority
+	}
+	return ""
+}
+
+func (m *Message) GetHeaders() []*Header {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *Message) GetParts() []*Part {
+	if m != nil {
+		return m.Parts
+	}
+	return nil
+}
+
+func (m *Message) GetAttachments() []*Attachment {
+	if m != nil {
+		return m.Attachments
+	}
+	return nil
+}
+
+func (m *Message) GetCampaign() string {
+	if m != nil {
+		return m.Campaign
+	}
+	return ""
+}
+
+type Header struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Header) Reset()         { *m = Header{} }
+func (m *Header) String() string { return proto.CompactTextString(m) }
+func (*Header) ProtoMessage()    {}
+func (*Header) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{1}
+}
+
+func (m *Header) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Header.Unmarshal(m, b)
+}
+func (m *Header) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Header.Marshal(b, m, deterministic)
+}
+func (m *Header) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Header.Merge(m, src)
+}
+func (m *Header) XXX_Size() int {
+	return xxx_messageInfo_Header.Size(m)
+}
+func (m *Header) XXX_DiscardUnknown() {
+	xxx_messageInfo_Header.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Header proto.InternalMessageInfo
+
+func (m *Header) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Header) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Part struct {
+	// Defaults to "text/plain; charset=utf-8".
+	ContentType          string   `protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Content              []byte   `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Part) Reset()         { *m = Part{} }
+func (m *Part) String() string { return proto.CompactTextString(m) }
+func (*Part) ProtoMessage()    {}
+func (*Part) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{2}
+}
+
+func (m *Part) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Part.Unmarshal(m, b)
+}
+func (m *Part) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Part.Marshal(b, m, deterministic)
+}
+func (m *Part) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Part.Merge(m, src)
+}
+func (m *Part) XXX_Size() int {
+	return xxx_messageInfo_Part.Size(m)
+}
+func (m *Part) XXX_DiscardUnknown() {
+	xxx_messageInfo_Part.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Part proto.InternalMessageInfo
+
+func (m *Part) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *Part) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+type Attachment struct {
+	Filename             string   `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	ContentDisposition   string   `protobuf:"bytes,2,opt,name=content_disposition,json=contentDisposition,proto3" json:"content_disposition,omitempty"`
+	ContentId            string   `protobuf:"bytes,3,opt,name=content_id,json=contentId,proto3" json:"content_id,omitempty"`
+	Content              []byte   `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Attachment) Reset()         { *m = Attachment{} }
+func (m *Attachment) String() string { return proto.CompactTextString(m) }
+func (*Attachment) ProtoMessage()    {}
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{3}
+}
+
+func (m *Attachment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Attachment.Unmarshal(m, b)
+}
+func (m *Attachment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Attachment.Marshal(b, m, deterministic)
+}
+func (m *Attachment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Attachment.Merge(m, src)
+}
+func (m *Attachment) XXX_Size() int {
+	return xxx_messageInfo_Attachment.Size(m)
+}
+func (m *Attachment) XXX_DiscardUnknown() {
+	xxx_messageInfo_Attachment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Attachment proto.InternalMessageInfo
+
+func (m *Attachment) GetFilename() string {
+	if m != nil {
+		return m.Filename
+	}
+	return ""
+}
+
+func (m *Attachment) GetContentDisposition() string {
+	if m != nil {
+		return m.ContentDisposition
+	}
+	return ""
+}
+
+func (m *Attachment) GetContentId() string {
+	if m != nil {
+		return m.ContentId
+	}
+	return ""
+}
+
+func (m *Attachment) GetContent() []byte {
+	if m != nil {
+		return m.Content
+	}
+	return nil
+}
+
+type StatusRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
+func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
+func (*StatusRequest) ProtoMessage()    {}
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{4}
+}
+
+func (m *StatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusRequest.Unmarshal(m, b)
+}
+func (m *StatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusRequest.Marshal(b, m, deterministic)
+}
+func (m *StatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusRequest.Merge(m, src)
+}
+func (m *StatusRequest) XXX_Size() int {
+	return xxx_messageInfo_StatusRequest.Size(m)
+}
+func (m *StatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
+
+func (m *StatusRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	// Restricts the events to these messages; empty means all.
+	Ids                  []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{5}
+}
+
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(m, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetIds() []string {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+type MessageStatus struct {
+	// The Message-ID of the message, without angle brackets.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// One of "queued", "sending", "delivered", "deferred", "bounced",
+	// "complained" or "failed".
+	State                string               `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Attempts             int32                `protobuf:"varint,3,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Error                string               `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Queued               *timestamp.Timestamp `protobuf:"bytes,5,opt,name=queued,proto3" json:"queued,omitempty"`
+	Updated              *timestamp.Timestamp `protobuf:"bytes,6,opt,name=updated,proto3" json:"updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *MessageStatus) Reset()         { *m = MessageStatus{} }
+func (m *MessageStatus) String() string { return proto.CompactTextString(m) }
+func (*MessageStatus) ProtoMessage()    {}
+func (*MessageStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cb717a335233a8c0, []int{6}
+}
+
+func (m *MessageStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MessageStatus.Unmarshal(m, b)
+}
+func (m *MessageStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MessageStatus.Marshal(b, m, deterministic)
+}
+func (m *MessageStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MessageStatus.Merge(m, src)
+}
+func (m *MessageStatus) XXX_Size() int {
+	return xxx_messageInfo_MessageStatus.Size(m)
+}
+func (m *MessageStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_MessageStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MessageStatus proto.InternalMessageInfo
+
+func (m *MessageStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetAttempts() int32 {
+	if m != nil {
+		return m.Attempts
+	}
+	return 0
+}
+
+func (m *MessageStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetQueued() *timestamp.Timestamp {
+	if m != nil {
+		return m.Queued
+	}
+	return nil
+}
+
+func (m *MessageStatus) GetUpdated() *timestamp.Timestamp {
+	if m != nil {
+		return m.Updated
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Message)(nil), "postman.Message")
+	proto.RegisterType((*Header)(nil), "postman.Header")
+	proto.RegisterType((*Part)(nil), "postman.Part")
+	proto.RegisterType((*Attachment)(nil), "postman.Attachment")
+	proto.RegisterType((*StatusRequest)(nil), "postman.StatusRequest")
+	proto.RegisterType((*WatchRequest)(nil), "postman.WatchRequest")
+	proto.RegisterType((*MessageStatus)(nil), "postman.MessageStatus")
+}
+
+func init() { proto.RegisterFile("postman.proto", fileDescriptor_cb717a335233a8c0) }
+
+var fileDescriptor_cb717a335233a8c0 = []byte{
+	// 645 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0xdb, 0x6a, 0xdb, 0x4c,
+	0x10, 0x46, 0x3e, 0x29, 0x1e, 0xd9, 0x49, 0xd8, 0xfc, 0x7f, 0xd8, 0x1a, 0x9a, 0xb8, 0x2e, 0x14,
+	0xf7, 0xc6, 0x2e, 0x6e, 0x4b, 0x2f, 0x0a, 0x85, 0x9e, 0x68, 0x73, 0x51, 0x08, 0x4a, 0xa0, 0xd0,
+	0x1b, 0xb3, 0x96, 0xc6, 0xf6, 0x06, 0x4b, 0xab, 0xec, 0x8e, 0x02, 0x7e, 0x8d, 0x3e, 0x4e, 0x6f,
+	0xfa, 0x0c, 0x7d, 0xa3, 0xa2, 0xd5, 0x4a, 0x71, 0x02, 0xa1, 0x57, 0xda, 0xef, 0x30, 0xab, 0x8f,
+	0x99, 0x59, 0xe8, 0x67, 0xca, 0x50, 0x22, 0xd2, 0x49, 0xa6, 0x15, 0x29, 0xe6, 0x3b, 0x38, 0x38,
+	0x5d, 0x29, 0xb5, 0xda, 0xe0, 0xd4, 0xd2, 0x8b, 0x7c, 0x39, 0x25, 0x99, 0xa0, 0x21, 0x91, 0x64,
+	0xa5, 0x73, 0xf4, 0xab, 0x09, 0xfe, 0x37, 0x34, 0x46, 0xac, 0x90, 0x31, 0x68, 0x2d, 0xb5, 0x4a,
+	0xb8, 0x37, 0xf4, 0xc6, 0xdd, 0xd0, 0x9e, 0xd9, 0x31, 0x74, 0x0c, 0xa6, 0x31, 0x6a, 0xde, 0xb0,
+	0xac, 0x43, 0xec, 0x11, 0xec, 0x69, 0xcc, 0x36, 0xdb, 0x39, 0x29, 0xde, 0xb4, 0x8a, 0x6f, 0xf1,
+	0xa5, 0x62, 0xfb, 0xd0, 0x20, 0xc5, 0x5b, 0xc3, 0xe6, 0xb8, 0x1b, 0x36, 0xc8, 0xe2, 0x28, 0xe2,
+	0xed, 0x12, 0x47, 0x11, 0x3b, 0x84, 0xe6, 0x22, 0x8a, 0x78, 0xc7, 0x12, 0xc5, 0x91, 0x71, 0xf0,
+	0x4d, 0xbe, 0xb8, 0xc2, 0x88, 0xb8, 0x5f, 0xde, 0xe5, 0x20, 0x7b, 0x0c, 0x90, 0x94, 0xe9, 0xe6,
+	0x32, 0xe6, 0x7b, 0x56, 0xec, 0x3a, 0xe6, 0x2c, 0x66, 0x27, 0x10, 0xc8, 0x74, 0x5e, 0x07, 0xe9,
+	0x96, 0xba, 0x4c, 0x43, 0x17, 0xe5, 0x04, 0x40, 0xe3, 0x12, 0x35, 0xa6, 0x11, 0x1a, 0x0e, 0xf6,
+	0x8f, 0x3b, 0x0c, 0x1b, 0xc0, 0x5e, 0xa6, 0xa5, 0xd2, 0x92, 0xb6, 0x3c, 0xb0, 0xc5, 0x35, 0x66,
+	0xcf, 0xc1, 0x5f, 0xa3, 0x88, 0x51, 0x1b, 0xde, 0x1b, 0x36, 0xc7, 0xc1, 0xec, 0x60, 0x52, 0x35,
+	0xf9, 0xab, 0xe5, 0xc3, 0x4a, 0x67, 0x4f, 0xa1, 0x9d, 0x09, 0x4d, 0x86, 0xf7, 0xad, 0xb1, 0x5f,
+	0x1b, 0xcf, 0x85, 0xa6, 0xb0, 0xd4, 0xd8, 0x6b, 0x08, 0x04, 0x91, 0x88, 0xd6, 0x09, 0xa6, 0x64,
+	0xf8, 0xbe, 0xb5, 0x1e, 0xd5, 0xd6, 0xf7, 0xb5, 0x16, 0xee, 0xfa, 0x8a, 0x88, 0x91, 0x48, 0x32,
+	0x21, 0x57, 0x29, 0x3f, 0x28, 0x23, 0x56, 0x78, 0x34, 0x83, 0x4e, 0x19, 0xa5, 0x18, 0x5d, 0x2a,
+	0x12, 0xac, 0x46, 0x57, 0x9c, 0xd9, 0x7f, 0xd0, 0xbe, 0x11, 0x9b, 0x1c, 0xdd, 0xe4, 0x4a, 0x30,
+	0xfa, 0x08, 0xad, 0x22, 0x15, 0x7b, 0x02, 0xbd, 0x48, 0xa5, 0x84, 0x29, 0xcd, 0x69, 0x9b, 0x55,
+	0x95, 0x81, 0xe3, 0x2e, 0xb7, 0x19, 0x16, 0x63, 0x71, 0xd0, 0x5e, 0xd1, 0x0b, 0x2b, 0x38, 0xfa,
+	0xe9, 0x01, 0xdc, 0x06, 0x2e, 0x32, 0x2e, 0xe5, 0x06, 0x77, 0x12, 0xd4, 0x98, 0x4d, 0xe1, 0xa8,
+	0xfa, 0x4f, 0x2c, 0x4d, 0xa6, 0x8c, 0x24, 0xa9, 0x52, 0x97, 0x89, 0x39, 0xe9, 0xd3, 0xad, 0x52,
+	0x8c, 0xbc, 0x2a, 0x90, 0xb1, 0xdb, 0xad, 0xae, 0x63, 0xce, 0xe2, 0xdd, 0x50, 0xad, 0xbb, 0xa1,
+	0x4e, 0xa1, 0x7f, 0x41, 0x82, 0x72, 0x13, 0xe2, 0x75, 0x8e, 0x86, 0x8a, 0xc5, 0x93, 0xb1, 0x0b,
+	0xd4, 0x90, 0xf1, 0x68, 0x08, 0xbd, 0xef, 0x82, 0xa2, 0x75, 0xa5, 0x1f, 0x42, 0x53, 0xc6, 0x86,
+	0x7b, 0xe5, 0x22, 0xca, 0xd8, 0x8c, 0xfe, 0x78, 0xd0, 0x77, 0xaf, 0xa1, 0xbc, 0xea, 0xfe, 0x1d,
+	0x45, 0x53, 0x0d, 0x09, 0xaa, 0x9b, 0x6a, 0x41, 0xd1, 0x00, 0x41, 0x84, 0x49, 0x46, 0xc6, 0x26,
+	0x6e, 0x87, 0x35, 0x2e, 0x2a, 0x50, 0x6b, 0xa5, 0x6d, 0xdc, 0x6e, 0x58, 0x02, 0x36, 0x83, 0xce,
+	0x75, 0x8e, 0x39, 0xc6, 0xbc, 0x3d, 0xf4, 0xc6, 0xc1, 0x6c, 0x30, 0x29, 0x5f, 0xea, 0xa4, 0x7a,
+	0xa9, 0x93, 0xcb, 0xea, 0xa5, 0x86, 0xce, 0xc9, 0x5e, 0x81, 0x9f, 0x67, 0xb1, 0x20, 0x8c, 0x79,
+	0xe7, 0x9f, 0x45, 0x95, 0x75, 0xf6, 0xdb, 0x03, 0xff, 0xbc, 0x5c, 0x32, 0xf6, 0x06, 0x82, 0x0b,
+	0x4c, 0xe3, 0xea, 0xc1, 0x1f, 0xd6, 0xdb, 0xe7, 0x98, 0xc1, 0xf1, 0x7d, 0xc6, 0xb5, 0xe1, 0x2d,
+	0x74, 0xbf, 0x20, 0x39, 0x70, 0x6b, 0xba, 0xd3, 0xef, 0x07, 0x8b, 0xdf, 0x41, 0x60, 0xfb, 0xfe,
+	0xf9, 0xc6, 0x6e, 0xf4, 0xff, 0xb5, 0x6d, 0x77, 0x1a, 0x0f, 0x55, 0xbf, 0xf0, 0x3e, 0x8c, 0x7f,
+	0x3c, 0x5b, 0x49, 0x5a, 0xe7, 0x8b, 0x49, 0xa4, 0x92, 0xe9, 0x95, 0x5a, 0x10, 0x0a, 0x83, 0x7a,
+	0xea, 0xfc, 0xd5, 0x37, 0x5b, 0x2c, 0x3a, 0xb6, 0x11, 0x2f, 0xff, 0x0e, 0x00, 0x68, 0x78, 0xb3,
+	0x28, 0x0f, 0x05, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PostmanClient is the client API for Postman service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PostmanClient interface {
+	// SendMessage queues a message. Messages failing linting are rejected
+	// with INVALID_ARGUMENT.
+	SendMessage(ctx context.Context, in *Message, opts ...grpc.CallOption) (*MessageStatus, error)
+	// GetStatus returns the status of a queued message, or NOT_FOUND.
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*MessageStatus, error)
+	// WatchEvents streams status changes until the call is canceled.
+	WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Postman_WatchEventsClient, error)
+}
+
+type postmanClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPostmanClient(cc *grpc.ClientConn) PostmanClient {
+	return &postmanClient{cc}
+}
+
+func (c *postmanClient) SendMessage(ctx context.Context, in *Message, opts ...grpc.CallOption) (*MessageStatus, error) {
+	out := new(MessageStatus)
+	err := c.cc.Invoke(ctx, "/postman.Postman/SendMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postmanClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*MessageStatus, error) {
+	out := new(MessageStatus)
+	err := c.cc.Invoke(ctx, "/postman.Postman/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postmanClient) WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Postman_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Postman_serviceDesc.Streams[0], "/postman.Postman/WatchEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &postmanWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Postman_WatchEventsClient interface {
+	Recv() (*MessageStatus, error)
+	grpc.ClientStream
+}
+
+type postmanWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *postmanWatchEventsClient) Recv() (*MessageStatus, error) {
+	m := new(MessageStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PostmanServer is the server API for Postman service.
+type PostmanServer interface {
+	// SendMessage queues a message. Messages failing linting are rejected
+	// with INVALID_ARGUMENT.
+	SendMessage(context.Context, *Message) (*MessageStatus, error)
+	// GetStatus returns the status of a queued message, or NOT_FOUND.
+	GetStatus(context.Context, *StatusRequest) (*MessageStatus, error)
+	// WatchEvents streams status changes until the call is canceled.
+	WatchEvents(*WatchRequest, Postman_WatchEventsServer) error
+}
+
+// UnimplementedPostmanServer can be embedded to have forward compatible implementations.
+type UnimplementedPostmanServer struct {
+}
+
+func (*UnimplementedPostmanServer) SendMessage(ctx context.Context, req *Message) (*MessageStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
+}
+func (*UnimplementedPostmanServer) GetStatus(ctx context.Context, req *StatusRequest) (*MessageStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (*UnimplementedPostmanServer) WatchEvents(req *WatchRequest, srv Postman_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+
+func RegisterPostmanServer(s *grpc.Server, srv PostmanServer) {
+	s.RegisterService(&_Postman_serviceDesc, srv)
+}
+
+func _Postman_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Message)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostmanServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/postman.Postman/SendMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostmanServer).SendMessage(ctx, req.(*Message))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Postman_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostmanServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/postman.Postman/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostmanServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Postman_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PostmanServer).WatchEvents(m, &postmanWatchEventsServer{stream})
+}
+
+type Postman_WatchEventsServer interface {
+	Send(*MessageStatus) error
+	grpc.ServerStream
+}
+
+type postmanWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *postmanWatchEventsServer) Send(m *MessageStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Postman_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "postman.Postman",
+	HandlerType: (*PostmanServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendMessage",
+			Handler:    _Postman_SendMessage_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Postman_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Postman_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "postman.proto",
+}
@@ -0,0 +1,173 @@
+// Package postmanpb implements the Postman gRPC service defined in
+// postman.proto, letting programs written in any language submit
+// messages to a postman.Queue.
+package postmanpb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative,Mgoogle/protobuf/timestamp.proto=github.com/golang/protobuf/ptypes/timestamp:. postman.proto
+
+import (
+	"context"
+	"net/textproto"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/jobteaser/postman"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchBuffer is the number of events buffered for each WatchEvents
+// stream; events are dropped when a client does not keep up.
+const watchBuffer = 64
+
+// Server serves the Postman service on top of a queue.
+type Server struct {
+	queue *postman.Queue
+}
+
+// NewServer returns a server queuing messages in q.
+func NewServer(q *postman.Queue) *Server {
+	return &Server{queue: q}
+}
+
+// SendMessage implements PostmanServer.
+func (s *Server) SendMessage(ctx context.Context, in *Message) (*MessageStatus, error) {
+	m, err := in.mail()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if issues := postman.Lint(m); postman.HasErrors(issues) {
+		var msgs []string
+		for _, i := range issues {
+			if i.Severity == postman.SeverityError {
+				msgs = append(msgs, i.String())
+			}
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message: %s", strings.Join(msgs, "; "))
+	}
+
+	id, err := s.queue.Enqueue(m)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return s.GetStatus(ctx, &StatusRequest{Id: id})
+}
+
+// GetStatus implements PostmanServer.
+func (s *Server) GetStatus(ctx context.Context, in *StatusRequest) (*MessageStatus, error) {
+	st, err := s.queue.Status(in.Id)
+	if err == postman.ErrUnknownMessage {
+		return nil, status.Error(codes.NotFound, "unknown message")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return newMessageStatus(&st)
+}
+
+// WatchEvents implements PostmanServer.
+func (s *Server) WatchEvents(in *WatchRequest, stream Postman_WatchEventsServer) error {
+	ids := make(map[string]bool, len(in.Ids))
+	for _, id := range in.Ids {
+		ids[id] = true
+	}
+
+	c := make(chan postman.Status, watchBuffer)
+	s.queue.Watch(c)
+	defer s.queue.Unwatch(c)
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case st := <-c:
+			if len(ids) > 0 && !ids[st.ID] {
+				continue
+			}
+
+			ms, err := newMessageStatus(&st)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if err := stream.Send(ms); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newMessageStatus(st *postman.Status) (*MessageStatus, error) {
+	queued, err := ptypes.TimestampProto(st.Queued)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ptypes.TimestampProto(st.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageStatus{
+		Id:       st.ID,
+		State:    string(st.State),
+		Attempts: int32(st.Attempts),
+		Error:    st.Error,
+		Queued:   queued,
+		Updated:  updated,
+	}, nil
+}
+
+func (in *Message) mail() (*postman.Mail, error) {
+	m := &postman.Mail{
+		From:       in.From,
+		Sender:     in.Sender,
+		ReplyTo:    in.ReplyTo,
+		To:         in.To,
+		Cc:         in.Cc,
+		Bcc:        in.Bcc,
+		Subject:    in.Subject,
+		MessageID:  in.MessageId,
+		InReplyTo:  in.InReplyTo,
+		References: in.References,
+		Campaign:   in.Campaign,
+	}
+
+	if in.Priority != "" {
+		var err error
+		if m.Priority, err = postman.ParsePriority(in.Priority); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(in.Headers) > 0 {
+		m.Header = make(textproto.MIMEHeader)
+		for _, h := range in.Headers {
+			m.Header.Add(h.Name, h.Value)
+		}
+	}
+
+	for _, p := range in.Parts {
+		part := postman.Part{ContentType: p.ContentType, Content: p.Content}
+		if part.ContentType == "" {
+			part.ContentType = "text/plain"
+			part.Params = map[string]string{"charset": "utf-8"}
+		}
+		m.Parts = append(m.Parts, part)
+	}
+
+	for _, a := range in.Attachments {
+		m.Attachments = append(m.Attachments, postman.Attachment{
+			Filename:           a.Filename,
+			ContentDisposition: a.ContentDisposition,
+			ContentID:          a.ContentId,
+			Content:            a.Content,
+		})
+	}
+
+	return m, nil
+}
@@ -0,0 +1,152 @@
+package postmanpb
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jobteaser/postman"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// transport delivers the messages to a channel.
+type transport chan *postman.Mail
+
+func (t transport) Send(m *postman.Mail) error {
+	t <- m
+	return nil
+}
+
+// dial returns a client of a server queuing messages in q.
+func dial(t *testing.T, q *postman.Queue) (PostmanClient, func()) {
+	t.Helper()
+
+	l := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterPostmanServer(gs, NewServer(q))
+	go gs.Serve(l)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return l.Dial() }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewPostmanClient(conn), func() {
+		conn.Close()
+		gs.Stop()
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	sent := make(transport, 1)
+	q := postman.NewQueue(sent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	client, stop := dial(t, q)
+	defer stop()
+
+	st, err := client.SendMessage(ctx, &Message{
+		From:      "Sender <sender@example.com>",
+		To:        []string{"rcpt@example.com"},
+		Bcc:       []string{"bcc@example.com"},
+		Subject:   "Hello",
+		MessageId: "<m1@postman.test>",
+		Priority:  "urgent",
+		Campaign:  "spring",
+		Headers:   []*Header{{Name: "x-entity-ref-id", Value: "ref"}},
+		Parts: []*Part{
+			{Content: []byte("Hello")},
+			{ContentType: "text/html", Content: []byte("<p>Hello</p>")},
+		},
+		Attachments: []*Attachment{{Filename: "a.txt", Content: []byte("attachment")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Id != "m1@postman.test" || st.State != "queued" || st.Queued == nil || st.Updated == nil {
+		t.Errorf("got status %+v", st)
+	}
+
+	var m *postman.Mail
+	select {
+	case m = <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not sent")
+	}
+
+	want := &postman.Mail{
+		From:      "Sender <sender@example.com>",
+		To:        []string{"rcpt@example.com"},
+		Bcc:       []string{"bcc@example.com"},
+		Subject:   "Hello",
+		MessageID: "<m1@postman.test>",
+		Priority:  postman.PriorityUrgent,
+		Campaign:  "spring",
+		Header:    textproto.MIMEHeader{"X-Entity-Ref-Id": {"ref"}},
+		Parts: []postman.Part{
+			// Parts are UTF-8 plain text by default.
+			{ContentType: "text/plain", Params: map[string]string{"charset": "utf-8"}, Content: []byte("Hello")},
+			{ContentType: "text/html", Content: []byte("<p>Hello</p>")},
+		},
+		Attachments: []postman.Attachment{{Filename: "a.txt", Content: []byte("attachment")}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("sent %+v, want %+v", m, want)
+	}
+
+	if st, err := client.GetStatus(ctx, &StatusRequest{Id: "m1@postman.test"}); err != nil || st.Id != "m1@postman.test" {
+		t.Errorf("GetStatus = %+v, %v", st, err)
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	q := postman.NewQueue(make(transport))
+
+	client, stop := dial(t, q)
+	defer stop()
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		msg  *Message
+		code codes.Code
+		err  string
+	}{
+		{"bad address", &Message{From: "sender@example.com", To: []string{"rcpt@"}, Parts: []*Part{{Content: []byte("Hello")}}},
+			codes.InvalidArgument, "invalid message: error: To: invalid address"},
+		{"bad priority", &Message{From: "sender@example.com", Priority: "asap"},
+			codes.InvalidArgument, ""},
+		// Beyond the 4 MB messages accepted by default by gRPC servers.
+		{"oversize message", &Message{From: "sender@example.com", Parts: []*Part{{Content: []byte(strings.Repeat("a", 5<<20))}}},
+			codes.ResourceExhausted, ""},
+	}
+
+	for _, tt := range tests {
+		_, err := client.SendMessage(ctx, tt.msg)
+		s, _ := status.FromError(err)
+		if s.Code() != tt.code || !strings.HasPrefix(s.Message(), tt.err) {
+			t.Errorf("%s: got %v, want %s %q", tt.name, err, tt.code, tt.err)
+		}
+	}
+
+	if n := q.Len(); n != 0 {
+		t.Errorf("%d messages queued", n)
+	}
+
+	_, err := client.GetStatus(ctx, &StatusRequest{Id: "unknown@postman.test"})
+	if s, _ := status.FromError(err); s.Code() != codes.NotFound {
+		t.Errorf("unknown message: got %v, want %s", err, codes.NotFound)
+	}
+}
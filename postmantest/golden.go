@@ -0,0 +1,206 @@
+// Package postmantest provides helpers for testing the messages composed
+// with postman, such as comparing them against golden files:
+//
+//	func TestWelcome(t *testing.T) {
+//		m := welcomeMessage(user)
+//		postmantest.AssertGolden(t, m, "testdata/welcome.eml")
+//	}
+//
+// Running the tests with POSTMAN_UPDATE_GOLDEN=1 writes the golden files
+// instead.
+package postmantest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jobteaser/postman"
+)
+
+// EnvUpdate is the environment variable making AssertGolden write the
+// golden files rather than compare them.
+const EnvUpdate = "POSTMAN_UPDATE_GOLDEN"
+
+// Date is the date of the messages rendered without one.
+var Date = time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+// boundaryPattern matches the boundaries generated by postman.
+var boundaryPattern = regexp.MustCompile(`\bboundary="?([0-9a-f]{30})\b`)
+
+// Render renders m as sent, but with the values generated at rendering
+// time fixed: messages without a date, including those of digests, have
+// Date, those without a Message-ID get "<1@postman.test>", "<2@...>" and so
+// on, in order of appearance, and the boundaries are numbered the same
+// way. m is not modified.
+func Render(m *postman.Mail) ([]byte, error) {
+	n := 0
+	raw, err := fix(m, &n).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	boundaries := make(map[string]string)
+	for _, match := range boundaryPattern.FindAllSubmatch(raw, -1) {
+		b := string(match[1])
+		if _, ok := boundaries[b]; !ok {
+			boundaries[b] = fmt.Sprintf("postmantest-boundary-%d", len(boundaries)+1)
+		}
+	}
+
+	for b, fixed := range boundaries {
+		raw = bytes.Replace(raw, []byte(b), []byte(fixed), -1)
+	}
+
+	return raw, nil
+}
+
+// fix returns a copy of m, and of its digest, with a date and Message-ID,
+// n being the number of identifiers already given.
+func fix(m *postman.Mail, n *int) *postman.Mail {
+	mm := *m
+
+	if mm.Date.IsZero() {
+		mm.Date = Date
+	}
+
+	if mm.MessageID == "" {
+		*n++
+		mm.MessageID = fmt.Sprintf("<%d@postman.test>", *n)
+	}
+
+	if len(m.Digest) > 0 {
+		mm.Digest = make([]*postman.Mail, len(m.Digest))
+		for i, msg := range m.Digest {
+			mm.Digest[i] = fix(msg, n)
+		}
+	}
+
+	return &mm
+}
+
+// AssertGolden renders m with Render and compares it against the golden
+// file at path, failing the test with a diff of their lines if they
+// differ. Line endings are ignored, in case the golden file was converted
+// by version control.
+func AssertGolden(t testing.TB, m *postman.Mail, path string) {
+	t.Helper()
+
+	raw, err := Render(m)
+	if err != nil {
+		t.Fatalf("rendering %s: %v", path, err)
+	}
+
+	if os.Getenv(EnvUpdate) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, EnvUpdate)
+	}
+
+	got, want := lines(raw), lines(golden)
+	if d := diff(want, got); d != "" {
+		t.Errorf("message differs from %s (-want +got):\n%s", path, d)
+	}
+}
+
+func lines(b []byte) []string {
+	s := strings.Replace(string(b), "\r\n", "\n", -1)
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diff returns the lines of a and b, prefixed with "-" when only in a, "+"
+// when only in b, and with a space, around the changes, when in both, or
+// an empty string if they are equal.
+func diff(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+
+	var ops []line
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, line{'-', a[i]})
+			changed = true
+			i++
+		default:
+			ops = append(ops, line{'+', b[j]})
+			changed = true
+			j++
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+
+	// Only the changes and the three lines around them are shown.
+	const context = 3
+
+	var buf strings.Builder
+	last := -1
+	for k, op := range ops {
+		near := false
+		for d := -context; d <= context; d++ {
+			if k+d >= 0 && k+d < len(ops) && ops[k+d].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+
+		if last >= 0 && k > last+1 {
+			buf.WriteString("...\n")
+		}
+		last = k
+
+		buf.WriteByte(op.op)
+		buf.WriteString(op.text)
+		buf.WriteByte('\n')
+	}
+
+	return buf.String()
+}
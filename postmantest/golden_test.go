@@ -0,0 +1,147 @@
+package postmantest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jobteaser/postman"
+)
+
+// digest returns a digest of two messages, none of them with a date or
+// a Message-ID.
+func digest() *postman.Mail {
+	return postman.NewDigest(
+		&postman.Mail{From: "list@example.com", To: []string{"rcpt@example.com"}, Subject: "Digest"},
+		&postman.Mail{From: "Jane Doe <jane@example.com>", Subject: "Release schedule",
+			Parts: []postman.Part{{ContentType: "text/plain", Content: []byte("Hello")}}},
+		&postman.Mail{From: "john@example.com", Subject: "Re: Release schedule",
+			Parts: []postman.Part{{ContentType: "text/plain", Content: []byte("Hello again")}}},
+	)
+}
+
+func TestRender(t *testing.T) {
+	m := digest()
+
+	raw, err := Render(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The values generated are numbered in order of appearance.
+	for _, s := range []string{
+		"Date: Sat, 03 Feb 2001 04:05:06 +0000\r\nFrom: list@example.com\r\n",
+		"Message-ID: <1@postman.test>\r\n",
+		"Content-Type: multipart/mixed; boundary=postmantest-boundary-1\r\n",
+		"--postmantest-boundary-1\r\nContent-Type: multipart/digest; boundary=postmantest-boundary-2\r\n",
+		"Date: Sat, 03 Feb 2001 04:05:06 +0000\r\nFrom: Jane Doe <jane@example.com>\r\nMessage-ID: <2@postman.test>\r\n",
+		"From: john@example.com\r\nMessage-ID: <3@postman.test>\r\n",
+		"--postmantest-boundary-2--\r\n\r\n--postmantest-boundary-1--\r\n",
+	} {
+		if !bytes.Contains(raw, []byte(s)) {
+			t.Errorf("%s does not contain\n%s", raw, s)
+		}
+	}
+
+	again, err := Render(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, again) {
+		t.Errorf("rendered\n%s\nthen\n%s", raw, again)
+	}
+
+	if !m.Date.IsZero() || m.MessageID != "" || m.Digest[0].MessageID != "" {
+		t.Errorf("message changed: %+v", m)
+	}
+}
+
+// recorder records the failures of a test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// Fatalf stops the function run by run, as the test would be.
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic(r)
+}
+
+// run calls f with r, and returns the failures recorded.
+func (r *recorder) run(f func(testing.TB)) (failures []string) {
+	defer func() {
+		if v := recover(); v != nil && v != r {
+			panic(v)
+		}
+		failures = r.failures
+	}()
+	f(r)
+	return
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join("testdata", "digest.eml")
+	AssertGolden(t, digest(), path)
+
+	// The golden file may have LF line endings.
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "postmantest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lf := filepath.Join(dir, "digest.eml")
+	if err := ioutil.WriteFile(lf, bytes.Replace(golden, []byte("\r\n"), []byte("\n"), -1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failures := new(recorder).run(func(tb testing.TB) { AssertGolden(tb, digest(), lf) })
+	if len(failures) != 0 {
+		t.Errorf("LF golden file: %q", failures)
+	}
+
+	m := digest()
+	m.Digest[1].Subject = "Re: Release date"
+	failures = new(recorder).run(func(tb testing.TB) { AssertGolden(tb, m, path) })
+	if len(failures) != 1 {
+		t.Fatalf("got failures %q, want 1", failures)
+	}
+	want := "message differs from " + path + " (-want +got):\n"
+	if !strings.HasPrefix(failures[0], want) ||
+		!strings.Contains(failures[0], "\n-Subject: Re: Release schedule\n+Subject: Re: Release date\n") {
+		t.Errorf("got failure\n%s", failures[0])
+	}
+
+	failures = new(recorder).run(func(tb testing.TB) { AssertGolden(tb, m, filepath.Join(dir, "missing.eml")) })
+	if len(failures) != 1 || !strings.Contains(failures[0], "set "+EnvUpdate+"=1 to create it") {
+		t.Errorf("missing golden file: got %q", failures)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := strings.Fields("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15")
+	b := strings.Fields("1 2 3 4 5 six 7 8 9 10 11 12 13 14 fifteen")
+
+	// Only the changes and the three lines around them are shown.
+	const want = " 3\n 4\n 5\n-6\n+six\n 7\n 8\n 9\n...\n 12\n 13\n 14\n-15\n+fifteen\n"
+	if d := diff(a, b); d != want {
+		t.Errorf("got\n%s\nwant\n%s", d, want)
+	}
+
+	if d := diff(a, a); d != "" {
+		t.Errorf("equal lines: got\n%s", d)
+	}
+}
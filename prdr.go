@@ -0,0 +1,65 @@
+package postman
+
+import (
+	"net/textproto"
+)
+
+// Result is the outcome of the delivery of a message.
+type Result struct {
+	// Reply is the reply of the server to the message data, which usually
+	// includes the identifier it was queued with.
+	Reply string
+
+	// Recipients holds the reply of the server for each recipient, in
+	// the order of the envelope, when it supports per-recipient data
+	// responses (PRDR). It is nil otherwise, the reply applying to every
+	// recipient.
+	Recipients []RecipientReply
+}
+
+// RecipientReply is the reply of the server to the message data for one
+// of its recipients.
+type RecipientReply struct {
+	Recipient string
+	Code      int
+	Message   string
+}
+
+// Accepted reports whether the message was accepted for the recipient.
+func (r *RecipientReply) Accepted() bool {
+	return r.Code >= 200 && r.Code < 300
+}
+
+// Err returns the reply as a *textproto.Error if the message was not
+// accepted for the recipient, nil otherwise.
+func (r *RecipientReply) Err() error {
+	if r.Accepted() {
+		return nil
+	}
+	return &textproto.Error{Code: r.Code, Msg: r.Message}
+}
+
+// readPRDR reads the replies following the message data when PRDR was
+// requested: the server starts with a 353 reply, then replies for each
+// recipient and finally for the whole message, with a 2yz code if it was
+// accepted for at least one of them.
+func readPRDR(text *textproto.Conn, rcpts []string) (*Result, error) {
+	if _, _, err := text.ReadResponse(353); err != nil {
+		// The server may also reject the message at once.
+		return nil, err
+	}
+
+	res := Result{Recipients: make([]RecipientReply, len(rcpts))}
+	for i, rcpt := range rcpts {
+		code, msg, err := text.ReadResponse(0)
+		if err != nil {
+			return nil, err
+		}
+		res.Recipients[i] = RecipientReply{Recipient: rcpt, Code: code, Message: msg}
+	}
+
+	_, reply, err := text.ReadResponse(250)
+	res.Reply = reply
+
+	return &res, err
+}
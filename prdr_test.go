@@ -0,0 +1,101 @@
+package postman
+
+import (
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestClientPRDR(t *testing.T) {
+	s := newTestServer(t, withExtensions("PRDR"), withDataReplies(map[string]string{
+		"cc0@example.com": "550 5.7.1 Mailbox full",
+		"cc1@example.com": "450 4.2.1 Try later",
+	}))
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	res, err := c.Deliver(benchMail(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmds := mailCommands(s); len(cmds) != 1 || !strings.HasSuffix(cmds[0], " PRDR") {
+		t.Errorf("MAIL commands %q, want PRDR requested", cmds)
+	}
+
+	if res.Reply != "OK queued" {
+		t.Errorf("reply %q, want %q", res.Reply, "OK queued")
+	}
+
+	want := []RecipientReply{
+		{"to0@example.com", 250, "2.1.5 OK"},
+		{"to1@example.com", 250, "2.1.5 OK"},
+		{"cc0@example.com", 550, "5.7.1 Mailbox full"},
+		{"cc1@example.com", 450, "4.2.1 Try later"},
+	}
+	if len(res.Recipients) != len(want) {
+		t.Fatalf("got %+v, want %+v", res.Recipients, want)
+	}
+	for i, r := range res.Recipients {
+		if r != want[i] {
+			t.Errorf("recipient %d: got %+v, want %+v", i, r, want[i])
+		}
+		if r.Accepted() != (r.Code == 250) {
+			t.Errorf("%s: accepted %t", r.Recipient, r.Accepted())
+		}
+		if err, ok := r.Err().(*textproto.Error); r.Accepted() == ok || (ok && err.Code != r.Code) {
+			t.Errorf("%s: error %v", r.Recipient, r.Err())
+		}
+	}
+}
+
+func TestClientPRDRRejected(t *testing.T) {
+	s := newTestServer(t, withExtensions("PRDR"), withDataReplies(map[string]string{
+		"to0@example.com": "550 5.7.1 Rejected",
+		"cc0@example.com": "550 5.7.1 Rejected",
+	}))
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	res, err := c.Deliver(benchMail(1))
+	if terr, ok := err.(*textproto.Error); !ok || terr.Code != 550 {
+		t.Fatalf("got %v, want a 550 error", err)
+	}
+	if res == nil || len(res.Recipients) != 2 {
+		t.Fatalf("got %+v, want the replies for both recipients", res)
+	}
+	for _, r := range res.Recipients {
+		if r.Accepted() {
+			t.Errorf("%s accepted", r.Recipient)
+		}
+	}
+
+	c.Close()
+	s.Close()
+	if n := len(s.Messages()); n != 0 {
+		t.Errorf("server kept %d messages", n)
+	}
+}
+
+func TestClientWithoutPRDR(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	defer c.Close()
+
+	res, err := c.Deliver(benchMail(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Recipients != nil {
+		t.Errorf("per-recipient replies without PRDR: %+v", res.Recipients)
+	}
+	if cmds := mailCommands(s); len(cmds) != 1 || strings.Contains(cmds[0], "PRDR") {
+		t.Errorf("MAIL commands %q, want PRDR not requested", cmds)
+	}
+}
@@ -0,0 +1,188 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/base64"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var previewPage = htmltemplate.Must(htmltemplate.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Mail.Subject}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; vertical-align: top; }
+iframe { width: 100%; height: 80vh; border: 1px solid #ccc; }
+</style>
+</head>
+<body>
+<table>
+{{range .Fields}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}{{range .Attachments}}<tr><th>{{if .Inline}}Inline{{else}}Attachment{{end}}</th><td>{{.Name}} ({{.Type}}, {{.Size}} bytes)</td></tr>
+{{end}}</table>
+{{if .HTML}}<iframe sandbox srcdoc="{{.HTML}}"></iframe>{{else}}<pre>{{.Text}}</pre>{{end}}
+</body>
+</html>
+`))
+
+// cidURL matches the cid: URLs of an HTML document, which refer to the
+// entities of the message by Content-ID (RFC 2392).
+var cidURL = regexp.MustCompile(`(?i)cid:[^"'\s()<>]+`)
+
+type previewField struct {
+	Name, Value string
+}
+
+type previewAttachment struct {
+	Name, Type string
+	Size       int
+	Inline     bool
+}
+
+// preview holds what the preview page shows of a message.
+type preview struct {
+	Mail        *Mail
+	Fields      []previewField
+	Attachments []previewAttachment
+	Text        string
+	HTML        string
+
+	// images are the data: URLs of the entities with a Content-ID.
+	images map[string]string
+}
+
+// WritePreviewHTML writes m as a standalone HTML page, for rendered
+// messages to be attached to design reviews: a table summarizing its
+// header and attachments, above its HTML part, with the inline images it
+// refers to embedded as data: URLs, or its text part. The HTML part is
+// shown in a sandboxed frame, which runs no script.
+func (m *Mail) WritePreviewHTML(w io.Writer) error {
+	p := preview{Mail: m, images: make(map[string]string)}
+
+	add := func(name, value string) {
+		if value != "" {
+			p.Fields = append(p.Fields, previewField{name, value})
+		}
+	}
+
+	add("From", m.From)
+	add("Sender", m.Sender)
+	add("Reply-To", m.ReplyTo)
+	add("To", strings.Join(m.To, ", "))
+	add("Cc", strings.Join(m.Cc, ", "))
+	add("Bcc", strings.Join(m.Bcc, ", "))
+	if !m.Date.IsZero() {
+		add("Date", m.Date.Format(dateLayout))
+	}
+	add("Subject", m.Subject)
+	add("Message-ID", m.MessageID)
+
+	if m.Body != nil {
+		p.entity(m.Body)
+	} else {
+		for i := range m.Parts {
+			p.entity(m.Parts[i].Entity())
+		}
+		for i := range m.Attachments {
+			if m.Attachments[i].Content != nil {
+				p.entity(m.Attachments[i].Entity())
+			}
+		}
+	}
+
+	p.HTML = cidURL.ReplaceAllStringFunc(p.HTML, func(s string) string {
+		id, err := url.PathUnescape(s[len("cid:"):])
+		if err != nil {
+			return s
+		}
+		if data, ok := p.images[id]; ok {
+			return data
+		}
+		return s
+	})
+
+	return previewPage.Execute(w, &p)
+}
+
+// ExportPreviewHTML writes the preview page of m, as WritePreviewHTML
+// does, to the file at path.
+func (m *Mail) ExportPreviewHTML(path string) error {
+	var buf bytes.Buffer
+	if err := m.WritePreviewHTML(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// entity records what the preview shows of e and its children: the
+// first text and HTML parts, the attachments and inline images.
+func (p *preview) entity(e *Entity) {
+	if e.isMultipart() {
+		for _, c := range e.Children {
+			p.entity(c)
+		}
+		return
+	}
+
+	mediatype, params, err := e.MediaType()
+	if err != nil {
+		mediatype = "application/octet-stream"
+	}
+
+	content := previewContent(e)
+
+	disposition := strings.ToLower(e.Header.Get("Content-Disposition"))
+	attached := strings.HasPrefix(disposition, "attachment")
+
+	switch {
+	case mediatype == "text/html" && !attached && p.HTML == "":
+		p.HTML = string(content)
+		return
+	case mediatype == "text/plain" && !attached && p.Text == "":
+		p.Text = string(content)
+		return
+	}
+
+	if id := strings.Trim(e.Header.Get("Content-Id"), "<> "); id != "" {
+		p.images[id] = "data:" + mediatype + ";base64," + base64.StdEncoding.EncodeToString(content)
+	}
+
+	name := params["name"]
+	if disposition != "" {
+		if _, dparams, err := mediaType(disposition, nil); err == nil && dparams["filename"] != "" {
+			name = dparams["filename"]
+		}
+	}
+
+	p.Attachments = append(p.Attachments, previewAttachment{
+		Name:   name,
+		Type:   mediatype,
+		Size:   len(content),
+		Inline: !attached,
+	})
+}
+
+// previewContent returns the content of a leaf entity, decoded when it is
+// given already encoded.
+func previewContent(e *Entity) []byte {
+	switch strings.ToLower(e.ContentTransferEncoding) {
+	case "", "base64", "7bit", "8bit", "binary":
+		return e.Content
+	}
+
+	h := textproto.MIMEHeader{"Content-Transfer-Encoding": {e.ContentTransferEncoding}}
+	content, err := ioutil.ReadAll(decodeBody(h, bytes.NewReader(e.Content)))
+	if err != nil {
+		return e.Content
+	}
+	return content
+}
@@ -0,0 +1,110 @@
+package postman
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// previewBody returns the page body written by WritePreviewHTML for m.
+func previewBody(t *testing.T, m *Mail) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := m.WritePreviewHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	i := strings.Index(page, "<body>\n")
+	if i < 0 || !strings.HasPrefix(page, "<!DOCTYPE html>\n") {
+		t.Fatalf("not an HTML page:\n%s", page)
+	}
+	return page[i:]
+}
+
+func TestWritePreviewHTML(t *testing.T) {
+	m := &Mail{
+		From:    "Jane <jane@example.com>",
+		To:      []string{"john@example.com", "jim@example.com"},
+		Date:    time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC),
+		Subject: "Launch <today>",
+		Parts: []Part{
+			{ContentType: "text/plain", Content: []byte("Hello & welcome\n")},
+			{ContentType: "text/html", Content: []byte(`<p>Hello & welcome</p><img src="cid:logo@example.com"><img src="cid:other@example.com">`)},
+		},
+		Attachments: []Attachment{
+			{Filename: "logo.png", ContentDisposition: "inline", ContentID: "logo@example.com", Content: []byte("PNG")},
+			{Filename: "terms.pdf", Content: []byte("%PDF-1.4")},
+		},
+	}
+
+	// The image referred to is embedded, the unknown one left as is.
+	const want = "<body>\n" +
+		"<table>\n" +
+		"<tr><th>From</th><td>Jane &lt;jane@example.com&gt;</td></tr>\n" +
+		"<tr><th>To</th><td>john@example.com, jim@example.com</td></tr>\n" +
+		"<tr><th>Date</th><td>Sat, 03 Feb 2001 04:05:06 &#43;0000</td></tr>\n" +
+		"<tr><th>Subject</th><td>Launch &lt;today&gt;</td></tr>\n" +
+		"<tr><th>Inline</th><td>logo.png (image/png, 3 bytes)</td></tr>\n" +
+		"<tr><th>Attachment</th><td>terms.pdf (application/pdf, 8 bytes)</td></tr>\n" +
+		"</table>\n" +
+		`<iframe sandbox srcdoc="&lt;p&gt;Hello &amp; welcome&lt;/p&gt;&lt;img src=&#34;data:image/png;base64,UE5H&#34;&gt;&lt;img src=&#34;cid:other@example.com&#34;&gt;"></iframe>` + "\n" +
+		"</body>\n" +
+		"</html>\n"
+	if got := previewBody(t, m); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWritePreviewHTMLText(t *testing.T) {
+	// The parts of parsed messages are decoded.
+	raw := "From: jane@example.com\r\n" +
+		"Subject: Notes\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Caf=C3=A9 at <noon>\r\n"
+	m, err := ParseMail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "<body>\n" +
+		"<table>\n" +
+		"<tr><th>From</th><td>jane@example.com</td></tr>\n" +
+		"<tr><th>Subject</th><td>Notes</td></tr>\n" +
+		"</table>\n" +
+		"<pre>Café at &lt;noon&gt;\r\n</pre>\n" +
+		"</body>\n" +
+		"</html>\n"
+	if got := previewBody(t, m); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestExportPreviewHTML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := &Mail{From: "jane@example.com", Parts: []Part{{ContentType: "text/plain", Content: []byte("Hello")}}}
+	path := filepath.Join(dir, "preview.html")
+	if err := m.ExportPreviewHTML(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var want bytes.Buffer
+	if err := m.WritePreviewHTML(&want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("got %q, %v, want %q", got, err, want.Bytes())
+	}
+}
@@ -0,0 +1,110 @@
+package postman
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Importance is the value of the Importance field (RFC 2156).
+type Importance int
+
+const (
+	ImportanceLow Importance = iota + 1
+	ImportanceNormal
+	ImportanceHigh
+)
+
+var importances = []string{"", "low", "normal", "high"}
+
+// ParseImportance parses an Importance field value, case insensitively.
+func ParseImportance(s string) (Importance, error) {
+	i, err := parseEnum("importance", importances, s)
+	return Importance(i), err
+}
+
+func (i Importance) String() string {
+	return enumString("Importance", importances, int(i))
+}
+
+// Priority is the value of the Priority field (RFC 2156).
+type Priority int
+
+const (
+	PriorityNonUrgent Priority = iota + 1
+	PriorityNormal
+	PriorityUrgent
+)
+
+var priorities = []string{"", "non-urgent", "normal", "urgent"}
+
+// ParsePriority parses a Priority field value, case insensitively.
+func ParsePriority(s string) (Priority, error) {
+	p, err := parseEnum("priority", priorities, s)
+	return Priority(p), err
+}
+
+func (p Priority) String() string {
+	return enumString("Priority", priorities, int(p))
+}
+
+// Sensitivity is the value of the Sensitivity field (RFC 2156).
+type Sensitivity int
+
+const (
+	SensitivityPersonal Sensitivity = iota + 1
+	SensitivityPrivate
+	SensitivityConfidential
+)
+
+var sensitivities = []string{"", "Personal", "Private", "Company-Confidential"}
+
+// ParseSensitivity parses a Sensitivity field value, case insensitively.
+func ParseSensitivity(s string) (Sensitivity, error) {
+	v, err := parseEnum("sensitivity", sensitivities, s)
+	return Sensitivity(v), err
+}
+
+func (s Sensitivity) String() string {
+	return enumString("Sensitivity", sensitivities, int(s))
+}
+
+func (m *Mail) priorityHeader(fields []headerField) ([]headerField, error) {
+	enums := []struct {
+		name   string
+		values []string
+		v      int
+	}{
+		{"Importance", importances, int(m.Importance)},
+		{"Priority", priorities, int(m.Priority)},
+		{"Sensitivity", sensitivities, int(m.Sensitivity)},
+	}
+
+	for _, e := range enums {
+		if e.v == 0 {
+			continue
+		}
+		if e.v < 0 || e.v >= len(e.values) {
+			return nil, fmt.Errorf("postman: invalid %s %d", strings.ToLower(e.name), e.v)
+		}
+		fields = append(fields, headerField{name: e.name, value: e.values[e.v]})
+	}
+
+	return fields, nil
+}
+
+func parseEnum(kind string, values []string, s string) (int, error) {
+	s = strings.TrimSpace(s)
+	for i, v := range values[1:] {
+		if strings.EqualFold(v, s) {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("postman: invalid %s %q", kind, s)
+}
+
+func enumString(kind string, values []string, v int) string {
+	if v <= 0 || v >= len(values) {
+		return fmt.Sprintf("%s(%d)", kind, v)
+	}
+	return values[v]
+}
@@ -0,0 +1,75 @@
+package postman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// proxySignature starts the headers of version 2 of the PROXY protocol.
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// writeProxyHeader sends the PROXY protocol header (version 1, the text
+// one, or 2, the binary one) describing conn, for the load balancers in
+// front of the server to learn the address of the client.
+func writeProxyHeader(conn net.Conn, version int) error {
+	src, _ := conn.LocalAddr().(*net.TCPAddr)
+	dst, _ := conn.RemoteAddr().(*net.TCPAddr)
+
+	var header []byte
+	switch version {
+	case 1:
+		header = proxyHeaderV1(src, dst)
+	case 2:
+		header = proxyHeaderV2(src, dst)
+	default:
+		return fmt.Errorf("postman: unknown PROXY protocol version %d", version)
+	}
+
+	_, err := conn.Write(header)
+	return err
+}
+
+func proxyHeaderV1(src, dst *net.TCPAddr) []byte {
+	if src == nil || dst == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	proto := "TCP4"
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		proto = "TCP6"
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	return []byte("PROXY " + proto + " " + srcIP.String() + " " + dstIP.String() + " " +
+		strconv.Itoa(src.Port) + " " + strconv.Itoa(dst.Port) + "\r\n")
+}
+
+func proxyHeaderV2(src, dst *net.TCPAddr) []byte {
+	header := append([]byte(nil), proxySignature...)
+
+	if src == nil || dst == nil {
+		// LOCAL command, without addresses.
+		return append(header, 0x20, 0x00, 0, 0)
+	}
+
+	family := byte(0x11) // TCP over IPv4
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		family = 0x21 // TCP over IPv6
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	n := 2*len(srcIP) + 4
+	header = append(header, 0x21, family, byte(n>>8), byte(n))
+	header = append(header, srcIP...)
+	header = append(header, dstIP...)
+
+	var ports [4]byte
+	binary.BigEndian.PutUint16(ports[:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(ports[2:], uint16(dst.Port))
+
+	return append(header, ports[:]...)
+}
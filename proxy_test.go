@@ -0,0 +1,114 @@
+package postman
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func tcpAddr(ip string, port int) *net.TCPAddr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: port}
+}
+
+func TestProxyHeaderV1(t *testing.T) {
+	tests := []struct {
+		name     string
+		src, dst *net.TCPAddr
+		want     string
+	}{
+		{"TCP4", tcpAddr("192.0.2.1", 56324), tcpAddr("198.51.100.1", 25), "PROXY TCP4 192.0.2.1 198.51.100.1 56324 25\r\n"},
+		{"TCP6", tcpAddr("2001:db8::1", 56324), tcpAddr("2001:db8::2", 587), "PROXY TCP6 2001:db8::1 2001:db8::2 56324 587\r\n"},
+		{"unknown", nil, tcpAddr("198.51.100.1", 25), "PROXY UNKNOWN\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := string(proxyHeaderV1(tt.src, tt.dst)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProxyHeaderV2(t *testing.T) {
+	sig := "\r\n\r\n\x00\r\nQUIT\n"
+
+	tests := []struct {
+		name     string
+		src, dst *net.TCPAddr
+		want     string
+	}{
+		{
+			"TCP4", tcpAddr("192.0.2.1", 56324), tcpAddr("198.51.100.1", 25),
+			sig + "\x21\x11\x00\x0c" +
+				"\xc0\x00\x02\x01" + "\xc6\x33\x64\x01" +
+				"\xdc\x04" + "\x00\x19",
+		},
+		{
+			"TCP6", tcpAddr("2001:db8::1", 56324), tcpAddr("2001:db8::2", 587),
+			sig + "\x21\x21\x00\x24" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02" +
+				"\xdc\x04" + "\x02\x4b",
+		},
+		{"local", nil, nil, sig + "\x20\x00\x00\x00"},
+	}
+
+	for _, tt := range tests {
+		got := proxyHeaderV2(tt.src, tt.dst)
+		if !bytes.Equal(got, []byte(tt.want)) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+
+		// The length covers what follows the first 16 bytes.
+		if n := int(got[14])<<8 | int(got[15]); n != len(got)-16 {
+			t.Errorf("%s: length %d for %d bytes of addresses", tt.name, n, len(got)-16)
+		}
+	}
+}
+
+// TestClientProxyProtocol checks that the server receives the header
+// describing the connection before the SMTP session.
+func TestClientProxyProtocol(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		s := newTestServer(t, withProxyProtocol(version))
+
+		c := NewClient(s.Addr, WithProxyProtocol(version))
+		if err := c.Send(benchMail(1)); err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		c.Close()
+		s.Close()
+
+		if n := len(s.Messages()); n != 1 {
+			t.Errorf("version %d: server received %d messages, want 1", version, n)
+		}
+
+		headers := s.Proxies()
+		if len(headers) != 1 {
+			t.Fatalf("version %d: %d headers, want 1", version, len(headers))
+		}
+
+		_, port, _ := net.SplitHostPort(s.Addr)
+		header := headers[0]
+		switch version {
+		case 1:
+			want := regexp.MustCompile(`^PROXY TCP4 127\.0\.0\.1 127\.0\.0\.1 [0-9]+ ` + port + "\r\n$")
+			if !want.Match(header) {
+				t.Errorf("version 1: got %q", header)
+			}
+		case 2:
+			prefix := "\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c\x7f\x00\x00\x01\x7f\x00\x00\x01"
+			if !bytes.HasPrefix(header, []byte(prefix)) || len(header) != 28 {
+				t.Errorf("version 2: got %q", header)
+			}
+			if p := int(header[26])<<8 | int(header[27]); net.JoinHostPort("127.0.0.1", strconv.Itoa(p)) != s.Addr {
+				t.Errorf("version 2: destination port %d", p)
+			}
+		}
+	}
+
+	if err := writeProxyHeader(&net.TCPConn{}, 3); err == nil {
+		t.Error("version 3: got no error")
+	}
+}
@@ -0,0 +1,304 @@
+package postman
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Queue delivers messages asynchronously through a Transport. Urgent
+// messages are sent first and non-urgent ones last, following their
+// Priority; messages of campaigns with a send window wait for it to
+// open, and those deferred by a quota for its next window. It is safe for
+// concurrent use.
+type Queue struct {
+	// Transport delivers the messages.
+	Transport Transport
+
+	// Workers is the number of messages delivered concurrently. Zero
+	// means one.
+	Workers int
+
+	// Tracker follows the messages of the queue. NewQueue shares the
+	// tracker of the Client it is given.
+	Tracker *Tracker
+
+	// Windows are the send windows of the messages, by campaign. Messages
+	// of other campaigns are delivered at any time.
+	Windows map[string]*SendWindow
+
+	mu       sync.Mutex
+	lanes    [3][]*queued
+	notify   chan struct{}
+	inFlight int
+}
+
+type queued struct {
+	id string
+	m  *Mail
+
+	// rcpts are the recipients of m, for its send window.
+	rcpts []string
+
+	// retry is the time a message deferred by a quota is sent again at.
+	retry time.Time
+}
+
+// NewQueue returns a queue delivering messages through t.
+func NewQueue(t Transport) *Queue {
+	tracker := new(Tracker)
+	if c, ok := t.(*Client); ok && c.Tracker != nil {
+		tracker = c.Tracker
+	}
+
+	return &Queue{Transport: t, Tracker: tracker}
+}
+
+// Enqueue adds a copy of m to the queue and returns its tracking
+// identifier, derived from its Message-ID, which is generated if m does
+// not define one.
+func (q *Queue) Enqueue(m *Mail) (string, error) {
+	mm := *m
+
+	if mm.MessageID == "" {
+		msgID, err := genMsgID()
+		if err != nil {
+			return "", err
+		}
+		mm.MessageID = msgID
+	}
+
+	id := TrackingID(mm.MessageID)
+	q.tracker().queue(id)
+
+	q.mu.Lock()
+	q.init()
+	lane := laneOf(mm.Priority)
+	q.lanes[lane] = append(q.lanes[lane], &queued{id: id, m: &mm})
+	q.mu.Unlock()
+
+	q.wake()
+
+	return id, nil
+}
+
+// Status returns the status of the message with the given identifier.
+func (q *Queue) Status(id string) (Status, error) {
+	return q.tracker().Status(id)
+}
+
+// Len returns the number of messages waiting to be sent.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, lane := range q.lanes {
+		n += len(lane)
+	}
+	return n
+}
+
+// Watch relays every status change of the tracker of the queue to c, as
+// Tracker.Watch does.
+func (q *Queue) Watch(c chan<- Status) {
+	q.tracker().Watch(c)
+}
+
+// Unwatch stops relaying status changes to c.
+func (q *Queue) Unwatch(c chan<- Status) {
+	q.tracker().Unwatch(c)
+}
+
+// Run delivers queued messages until ctx is done. Messages still queued
+// at that time stay in the queue.
+func (q *Queue) Run(ctx context.Context) error {
+	q.mu.Lock()
+	q.init()
+	q.mu.Unlock()
+
+	workers := q.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		e, next := q.pop()
+		if e == nil {
+			// Deferred messages are due when their window opens.
+			var (
+				timer *time.Timer
+				due   <-chan time.Time
+			)
+			if !next.IsZero() {
+				timer = time.NewTimer(time.Until(next))
+				due = timer.C
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-q.notify:
+			case <-due:
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
+		}
+
+		q.deliver(e)
+	}
+}
+
+// deliver delivers a message popped from the queue.
+func (q *Queue) deliver(e *queued) {
+	defer func() {
+		q.mu.Lock()
+		q.inFlight--
+		q.mu.Unlock()
+	}()
+
+	tracker := q.tracker()
+
+	var err error
+	// A client sharing the tracker records its attempts itself.
+	if c, ok := q.Transport.(*Client); ok && c.Tracker == tracker {
+		err = c.Send(e.m)
+	} else {
+		tracker.attempt(e.id)
+		err = q.Transport.Send(e.m)
+		tracker.done(e.id, err)
+	}
+
+	// Messages deferred by a quota wait for its next window in the
+	// queue.
+	if qerr, ok := err.(*QuotaError); ok && qerr.Temporary() {
+		e.retry = qerr.Reset
+
+		q.mu.Lock()
+		lane := laneOf(e.m.Priority)
+		q.lanes[lane] = append(q.lanes[lane], e)
+		q.mu.Unlock()
+
+		q.wake()
+	}
+}
+
+func (q *Queue) tracker() *Tracker {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.Tracker == nil {
+		q.Tracker = new(Tracker)
+	}
+	return q.Tracker
+}
+
+// pop removes the next message due from the queue. It returns nil if
+// there is none, along with the time the first deferred message is due
+// at, if any.
+func (q *Queue) pop() (*queued, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+
+	var next time.Time
+	for i, lane := range q.lanes {
+		for j, e := range lane {
+			if due := q.due(e, now); due.After(now) {
+				if next.IsZero() || due.Before(next) {
+					next = due
+				}
+				continue
+			}
+
+			copy(lane[j:], lane[j+1:])
+			lane[len(lane)-1] = nil
+			q.lanes[i] = lane[:len(lane)-1]
+			q.inFlight++
+
+			// Let another worker pick the next message.
+			if len(lane) > 1 || i < len(q.lanes)-1 {
+				q.wake()
+			}
+
+			return e, time.Time{}
+		}
+	}
+
+	return nil, next
+}
+
+// due returns the time e is due at, from now on.
+func (q *Queue) due(e *queued, now time.Time) time.Time {
+	if e.retry.After(now) {
+		now = e.retry
+	}
+
+	w := q.Windows[e.m.Campaign]
+	if w == nil {
+		return now
+	}
+
+	if e.rcpts == nil {
+		// Invalid recipients fail at delivery.
+		e.rcpts, _ = e.m.Recipients()
+		if e.rcpts == nil {
+			e.rcpts = []string{}
+		}
+	}
+
+	return w.Next(now, e.rcpts)
+}
+
+func (q *Queue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// init initializes the queue on first use. The lock must be held.
+func (q *Queue) init() {
+	if q.notify == nil {
+		q.notify = make(chan struct{}, 1)
+	}
+}
+
+func laneOf(p Priority) int {
+	switch p {
+	case PriorityUrgent:
+		return 0
+	case PriorityNonUrgent:
+		return 2
+	}
+	return 1
+}
+
+func genID() (string, error) {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b[:]), nil
+}
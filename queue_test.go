@@ -0,0 +1,88 @@
+package postman
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestQueueConcurrent enqueues messages from many goroutines while workers
+// deliver them through a shared client; run it with -race.
+func TestQueueConcurrent(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr, WithPool(4))
+	defer c.Close()
+
+	q := NewQueue(c)
+	q.Workers = 4
+
+	const n = 40
+	statuses := make(chan Status, 4*n)
+	q.Watch(statuses)
+	defer q.Unwatch(statuses)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx) }()
+
+	var wg sync.WaitGroup
+	ids := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			m := benchMail(1)
+			m.MessageID = ""
+			m.Subject = fmt.Sprintf("Message %d", i)
+			m.Priority = PriorityNonUrgent + Priority(i%3)
+
+			id, err := q.Enqueue(m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- id
+			q.Len()
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	delivered := make(map[string]bool)
+	timeout := time.After(10 * time.Second)
+	for len(delivered) < n {
+		select {
+		case st := <-statuses:
+			switch st.State {
+			case StateDelivered:
+				delivered[st.ID] = true
+			case StateFailed, StateBounced, StateDeferred:
+				t.Fatalf("message %s: %s: %s", st.ID, st.State, st.Error)
+			}
+		case <-timeout:
+			t.Fatalf("%d messages delivered out of %d", len(delivered), n)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run returned %v, want %v", err, context.Canceled)
+	}
+
+	for id := range ids {
+		if !delivered[id] {
+			t.Errorf("message %s not delivered", id)
+		}
+		if st, err := q.Status(id); err != nil || st.State != StateDelivered {
+			t.Errorf("Status(%s) = %+v, %v", id, st, err)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", q.Len())
+	}
+}
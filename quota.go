@@ -0,0 +1,215 @@
+package postman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quota limits what a sender may send per window of time, for a tenant
+// of a shared relay not to exhaust its capacity. Messages are counted
+// when their delivery starts, whatever its outcome. Windows are aligned
+// on multiples of Window since the zero time, as time.Truncate does; a
+// quota without window does not limit anything.
+type Quota struct {
+	// Messages and Bytes are the maximum number of messages and of bytes
+	// per window; zero means no limit. The size of a message is the one
+	// of the content of its parts and attachments, before encoding, and
+	// the one of the message itself when it is sent already rendered, as
+	// by an Outbox.
+	Messages int
+	Bytes    int64
+
+	Window time.Duration
+
+	// Defer makes the *QuotaError of the deliveries exceeding the quota
+	// temporary: the messages are deferred rather than failed, and a
+	// Queue sends them again once the window is over. The Send calls of
+	// a client never wait for the next window.
+	Defer bool
+}
+
+// QuotaError is returned for messages exceeding the quota of their
+// sender.
+type QuotaError struct {
+	Sender string
+	Quota  Quota
+
+	// Reset is the end of the current window.
+	Reset time.Time
+
+	// Size is the size of the message, as counted by the quota.
+	Size int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("postman: quota of %s exceeded until %s", e.Sender, e.Reset.Format(time.RFC3339))
+}
+
+// Temporary reports whether the message may be sent again after Reset,
+// which is the case when the quota defers the messages exceeding it,
+// unless the message exceeds it on its own.
+func (e *QuotaError) Temporary() bool {
+	return e.Quota.Defer && (e.Quota.Bytes <= 0 || e.Size <= e.Quota.Bytes)
+}
+
+// QuotaStore counts the messages and bytes sent by every sender, per
+// window, for clients which may share it across processes.
+type QuotaStore interface {
+	// Take counts a message of size bytes sent by sender in the window
+	// starting at start, unless it would exceed q, and reports whether it
+	// was counted.
+	Take(ctx context.Context, sender string, start time.Time, size int64, q Quota) (bool, error)
+}
+
+// QuotaCounter is a QuotaStore kept in memory, which only keeps the
+// current window of every sender. The zero value is ready to use.
+type QuotaCounter struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+type quotaWindow struct {
+	start    time.Time
+	messages int
+	bytes    int64
+}
+
+func (c *QuotaCounter) Take(ctx context.Context, sender string, start time.Time, size int64, q Quota) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.windows == nil {
+		c.windows = make(map[string]*quotaWindow)
+	}
+
+	w := c.windows[sender]
+	if w == nil || !w.start.Equal(start) {
+		w = &quotaWindow{start: start}
+		c.windows[sender] = w
+	}
+
+	if (q.Messages > 0 && w.messages+1 > q.Messages) || (q.Bytes > 0 && w.bytes+size > q.Bytes) {
+		return false, nil
+	}
+
+	w.messages++
+	w.bytes += size
+
+	return true, nil
+}
+
+// Take counts a message in the archive, which implements QuotaStore.
+// Past windows of the sender are removed.
+func (a *Archive) Take(ctx context.Context, sender string, start time.Time, size int64, q Quota) (bool, error) {
+	start = start.UTC()
+
+	if _, err := a.DB.ExecContext(ctx,
+		"DELETE FROM postman_quotas WHERE sender = ? AND window_start < ?", sender, start); err != nil {
+		return false, err
+	}
+
+	if _, err := a.DB.ExecContext(ctx,
+		"INSERT OR IGNORE INTO postman_quotas (sender, window_start, messages, bytes) VALUES (?, ?, 0, 0)",
+		sender, start); err != nil {
+		return false, err
+	}
+
+	res, err := a.DB.ExecContext(ctx,
+		`UPDATE postman_quotas SET messages = messages + 1, bytes = bytes + ?
+		WHERE sender = ? AND window_start = ? AND (? = 0 OR messages + 1 <= ?) AND (? = 0 OR bytes + ? <= ?)`,
+		size, sender, start, q.Messages, q.Messages, q.Bytes, size, q.Bytes)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// quota returns the sender identity m is counted for, and its quota:
+// the quota of its identity, or of the client. The identity is the
+// domain of the identity of m, or its author address.
+func (c *Client) quota(m *Mail) (string, *Quota) {
+	if id := c.identity(m); id != nil {
+		q := id.Quota
+		if q == nil {
+			q = c.Quota
+		}
+		return strings.ToLower(id.Domain), q
+	}
+
+	addr, err := bareAddress(m.From)
+	if err != nil {
+		addr = m.From
+	}
+
+	return strings.ToLower(addr), c.Quota
+}
+
+// takeQuota counts m, of size bytes, in the quota of its sender. Messages
+// exceeding it fail with a *QuotaError telling when the next window
+// starts, which is temporary if the quota defers them.
+func (c *Client) takeQuota(m *Mail, size int64) error {
+	sender, q := c.quota(m)
+	if q == nil || q.Window <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.Quotas == nil {
+		c.Quotas = new(QuotaCounter)
+	}
+	store := c.Quotas
+	c.mu.Unlock()
+
+	start := time.Now().Truncate(q.Window)
+
+	ok, err := store.Take(context.Background(), sender, start, size, *q)
+	if err != nil || ok {
+		return err
+	}
+
+	return &QuotaError{Sender: sender, Quota: *q, Reset: start.Add(q.Window), Size: size}
+}
+
+// size returns the size of the content of m, before encoding.
+func (m *Mail) size() int64 {
+	var n int64
+
+	for i := range m.Parts {
+		n += int64(len(m.Parts[i].Content))
+	}
+
+	for i := range m.Attachments {
+		a := &m.Attachments[i]
+		if a.Content == nil && a.File != "" {
+			if fi, err := os.Stat(a.File); err == nil {
+				n += fi.Size()
+			}
+			continue
+		}
+		n += int64(len(a.Content))
+	}
+
+	for _, d := range m.Digest {
+		n += d.size()
+	}
+
+	if m.Body != nil {
+		n += m.Body.size()
+	}
+
+	return n
+}
+
+func (e *Entity) size() int64 {
+	n := int64(len(e.Content))
+	for _, c := range e.Children {
+		n += c.size()
+	}
+	return n
+}
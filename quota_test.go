@@ -0,0 +1,285 @@
+package postman
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotaCounter(t *testing.T) {
+	ctx := context.Background()
+	q := Quota{Messages: 2, Bytes: 100, Window: time.Hour}
+	start := time.Date(2001, time.February, 3, 4, 0, 0, 0, time.UTC)
+
+	var c QuotaCounter
+	tests := []struct {
+		sender string
+		start  time.Time
+		size   int64
+		want   bool
+	}{
+		{"a", start, 60, true},
+		{"a", start, 60, false}, // bytes
+		{"b", start, 60, true},  // other sender
+		{"a", start, 40, true},
+		{"a", start, 0, false}, // messages
+		{"a", start.Add(time.Hour), 60, true},
+	}
+
+	for i, tt := range tests {
+		ok, err := c.Take(ctx, tt.sender, tt.start, tt.size, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%d: Take(%s, %s, %d) = %t, want %t", i, tt.sender, tt.start, tt.size, ok, tt.want)
+		}
+	}
+}
+
+func TestClientQuota(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	for _, deferred := range []bool{false, true} {
+		c := NewClient(s.Addr, WithQuota(Quota{Messages: 1, Window: time.Hour, Defer: deferred}, nil))
+
+		m := benchMail(1)
+		if err := c.Send(m); err != nil {
+			t.Fatal(err)
+		}
+
+		begin := time.Now()
+		m.MessageID = "<second@postman.test>"
+		err := c.Send(m)
+		if time.Since(begin) > time.Second {
+			t.Errorf("Defer %t: Send waited for the next window", deferred)
+		}
+
+		qerr, ok := err.(*QuotaError)
+		if !ok {
+			t.Fatalf("Defer %t: got %v, want a *QuotaError", deferred, err)
+		}
+		if qerr.Temporary() != deferred || isTemporary(err) != deferred {
+			t.Errorf("Defer %t: temporary error: %t", deferred, qerr.Temporary())
+		}
+		if want := time.Now().Truncate(time.Hour).Add(time.Hour); !qerr.Reset.Equal(want) {
+			t.Errorf("Defer %t: Reset %s, want %s", deferred, qerr.Reset, want)
+		}
+
+		state := StateFailed
+		if deferred {
+			state = StateDeferred
+		}
+		if st, _ := c.Status(TrackingID(m.MessageID)); st.State != state {
+			t.Errorf("Defer %t: state %s, want %s", deferred, st.State, state)
+		}
+
+		c.Close()
+	}
+
+	// A message exceeding the quota on its own is never deferred.
+	c := NewClient(s.Addr, WithQuota(Quota{Bytes: 10, Window: time.Hour, Defer: true}, nil))
+	defer c.Close()
+	if err, ok := c.Send(benchMail(1)).(*QuotaError); !ok || err.Temporary() {
+		t.Errorf("got %v, want a permanent *QuotaError", err)
+	}
+}
+
+// TestQueueQuota checks that the messages deferred by a quota are sent
+// again once its window is over, without blocking the other messages.
+func TestQueueQuota(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	const window = 200 * time.Millisecond
+	c := NewClient(s.Addr, WithQuota(Quota{Messages: 1, Window: window, Defer: true}, nil))
+	defer c.Close()
+
+	q := NewQueue(c)
+	statuses := make(chan Status, 16)
+	q.Watch(statuses)
+	defer q.Unwatch(statuses)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	// Wait for the beginning of a window, for both messages to be
+	// counted in the same one.
+	time.Sleep(time.Until(time.Now().Truncate(window).Add(window)))
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(benchMail(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var delivered, deferrals int
+	timeout := time.After(5 * time.Second)
+	for delivered < 2 {
+		select {
+		case st := <-statuses:
+			switch st.State {
+			case StateDelivered:
+				delivered++
+			case StateDeferred:
+				deferrals++
+			case StateFailed, StateBounced:
+				t.Fatalf("message %s: %s: %s", st.ID, st.State, st.Error)
+			}
+		case <-timeout:
+			t.Fatalf("%d messages delivered out of 2", delivered)
+		}
+	}
+
+	if deferrals == 0 {
+		t.Error("no message deferred")
+	}
+}
+
+// quotaRow is a row of the postman_quotas table of a stubQuotas.
+type quotaRow struct {
+	sender          string
+	start           time.Time
+	messages, bytes int64
+}
+
+// stubQuotas is the postman_quotas table of an Archive, kept in memory.
+type stubQuotas struct {
+	stubDB
+	rows []*quotaRow
+}
+
+func newStubQuotas() *stubQuotas {
+	db := &stubQuotas{}
+
+	db.handle("DELETE FROM postman_quotas WHERE sender = ? AND window_start < ?", func(args []driver.Value) (*stubResult, error) {
+		res := &stubResult{}
+		rows := db.rows[:0]
+		for _, r := range db.rows {
+			if r.sender == args[0].(string) && r.start.Before(args[1].(time.Time)) {
+				res.affected++
+				continue
+			}
+			rows = append(rows, r)
+		}
+		db.rows = rows
+		return res, nil
+	})
+
+	db.handle("INSERT OR IGNORE INTO postman_quotas (sender, window_start, messages, bytes) VALUES (?, ?, 0, 0)", func(args []driver.Value) (*stubResult, error) {
+		if db.find(args[0].(string), args[1].(time.Time)) != nil {
+			return &stubResult{}, nil
+		}
+		db.rows = append(db.rows, &quotaRow{sender: args[0].(string), start: args[1].(time.Time)})
+		return &stubResult{affected: 1}, nil
+	})
+
+	db.handle("UPDATE postman_quotas SET messages = messages + 1, bytes = bytes + ? WHERE sender = ? AND window_start = ? AND (? = 0 OR messages + 1 <= ?) AND (? = 0 OR bytes + ? <= ?)", func(args []driver.Value) (*stubResult, error) {
+		size := args[0].(int64)
+		r := db.find(args[1].(string), args[2].(time.Time))
+		if r == nil ||
+			(args[3].(int64) != 0 && r.messages+1 > args[4].(int64)) ||
+			(args[5].(int64) != 0 && r.bytes+args[6].(int64) > args[7].(int64)) {
+			return &stubResult{}, nil
+		}
+		r.messages++
+		r.bytes += size
+		return &stubResult{affected: 1}, nil
+	})
+
+	return db
+}
+
+func (db *stubQuotas) find(sender string, start time.Time) *quotaRow {
+	for _, r := range db.rows {
+		if r.sender == sender && r.start.Equal(start) {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestArchiveQuota(t *testing.T) {
+	db := newStubQuotas()
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	ctx := context.Background()
+	q := Quota{Messages: 2, Bytes: 100, Window: time.Hour}
+	start := time.Date(2001, time.February, 3, 4, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		sender string
+		start  time.Time
+		size   int64
+		want   bool
+	}{
+		{"a", start, 60, true},
+		{"a", start, 60, false}, // bytes
+		{"b", start, 60, true},  // other sender
+		{"a", start, 40, true},
+		{"a", start, 0, false}, // messages
+		{"a", start.Add(time.Hour), 60, true},
+	}
+
+	for i, tt := range tests {
+		ok, err := a.Take(ctx, tt.sender, tt.start, tt.size, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%d: Take(%s, %s, %d) = %t, want %t", i, tt.sender, tt.start, tt.size, ok, tt.want)
+		}
+	}
+
+	// The past window of a was removed, not the one of b.
+	if len(db.rows) != 2 || db.find("a", start.Add(time.Hour)) == nil || db.find("b", start) == nil {
+		t.Errorf("windows left: %+v", db.rows)
+	}
+}
+
+// TestArchiveQuotaConcurrency checks that the messages counted at once
+// by several clients sharing an archive never exceed its quota.
+func TestArchiveQuotaConcurrency(t *testing.T) {
+	db := newStubQuotas()
+	a := &Archive{DB: db.open()}
+	defer a.DB.Close()
+
+	ctx := context.Background()
+	q := Quota{Messages: 10, Window: time.Hour}
+	start := time.Date(2001, time.February, 3, 4, 0, 0, 0, time.UTC)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := a.Take(ctx, "sender@example.com", start, 1, q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if taken != q.Messages {
+		t.Errorf("%d messages counted, want %d", taken, q.Messages)
+	}
+	if r := db.find("sender@example.com", start); r == nil || r.messages != int64(q.Messages) {
+		t.Errorf("window counted %+v, want %d messages", r, q.Messages)
+	}
+}
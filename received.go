@@ -0,0 +1,121 @@
+package postman
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Received is a Received trace field, stamped by each MTA relaying the
+// message (RFC 5321 section 4.4). Empty clauses are omitted.
+type Received struct {
+	// From is the name the client gave in its HELO or EHLO command.
+	From string
+
+	// FromHost is the reverse DNS name of the client, if known.
+	FromHost string
+
+	// FromIP is the address the client connected from.
+	FromIP net.IP
+
+	// By is the name of the host receiving the message.
+	By string
+
+	// Via is the link type, e.g. "TCP".
+	Via string
+
+	// With is the protocol the message was received with, e.g. "ESMTP"
+	// or "ESMTPS" (RFC 3848).
+	With string
+
+	// ID is the identifier the receiving host assigned to the message.
+	ID string
+
+	// For is the recipient the message was received for, when there is
+	// a single one.
+	For string
+
+	// Date is the time the message was received. The zero value means
+	// the time String is called.
+	Date time.Time
+}
+
+// AddReceived prepends r to the trace fields of the message, as a relay
+// does before forwarding it.
+func (m *Mail) AddReceived(r Received) {
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+
+	m.Received = append([]Received{r}, m.Received...)
+}
+
+// String returns the value of the Received field.
+func (r *Received) String() string {
+	var b strings.Builder
+
+	clause := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(name)
+		b.WriteByte(' ')
+		b.WriteString(value)
+	}
+
+	clause("from", r.From)
+	if info := r.tcpInfo(); info != "" {
+		if b.Len() == 0 {
+			b.WriteString("from unknown")
+		}
+		b.WriteString(" (")
+		b.WriteString(info)
+		b.WriteByte(')')
+	}
+
+	clause("by", r.By)
+	clause("via", r.Via)
+	clause("with", r.With)
+	clause("id", r.ID)
+	clause("for", angleBracket(r.For))
+
+	date := r.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	b.WriteString("; ")
+	b.WriteString(date.Format(dateLayout))
+
+	return b.String()
+}
+
+func (r *Received) tcpInfo() string {
+	var lit string
+	switch {
+	case r.FromIP.To4() != nil:
+		lit = "[" + r.FromIP.String() + "]"
+	case r.FromIP != nil:
+		lit = "[IPv6:" + r.FromIP.String() + "]"
+	}
+
+	if r.FromHost == "" {
+		return lit
+	}
+	if lit == "" {
+		return r.FromHost
+	}
+	return r.FromHost + " " + lit
+}
+
+// angleBracket encloses an address or a message identifier in angle
+// brackets, unless it already is.
+func angleBracket(s string) string {
+	if s == "" || strings.HasPrefix(s, "<") {
+		return s
+	}
+	return "<" + s + ">"
+}
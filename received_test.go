@@ -0,0 +1,102 @@
+package postman
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceivedString(t *testing.T) {
+	date := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		r    Received
+		want string
+	}{
+		{
+			"every clause",
+			Received{
+				From:     "client.example.com",
+				FromHost: "mail.example.com",
+				FromIP:   net.ParseIP("192.0.2.1"),
+				By:       "mx.example.net",
+				Via:      "TCP",
+				With:     "ESMTPS",
+				ID:       "4F2A1C0A3",
+				For:      "rcpt@example.net",
+				Date:     date,
+			},
+			"from client.example.com (mail.example.com [192.0.2.1]) by mx.example.net via TCP with ESMTPS id 4F2A1C0A3 for <rcpt@example.net>; Sat, 03 Feb 2001 04:05:06 +0000",
+		},
+		{
+			"IPv6",
+			Received{From: "client.example.com", FromIP: net.ParseIP("2001:db8::1"), By: "mx.example.net", Date: date},
+			"from client.example.com ([IPv6:2001:db8::1]) by mx.example.net; Sat, 03 Feb 2001 04:05:06 +0000",
+		},
+		{
+			// The address of a client without HELO name is still given.
+			"no HELO",
+			Received{FromIP: net.ParseIP("192.0.2.1"), By: "mx.example.net", Date: date},
+			"from unknown ([192.0.2.1]) by mx.example.net; Sat, 03 Feb 2001 04:05:06 +0000",
+		},
+		{
+			"bracketed recipient",
+			Received{By: "mx.example.net", For: "<rcpt@example.net>", Date: date},
+			"by mx.example.net for <rcpt@example.net>; Sat, 03 Feb 2001 04:05:06 +0000",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddReceived(t *testing.T) {
+	m := benchMail(1)
+	m.Received = []Received{{From: "client.example.com", By: "relay.example.com", Date: m.Date}}
+
+	before := time.Now()
+	m.AddReceived(Received{From: "relay.example.com", By: "mx.example.net", With: "ESMTP"})
+
+	if m.Received[0].Date.Before(before) || m.Received[0].Date.After(time.Now()) {
+		t.Errorf("stamped at %s", m.Received[0].Date)
+	}
+	m.Received[0].Date = m.Date
+
+	b, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The trace fields are on top, the latest first.
+	const want = "Received: from relay.example.com by mx.example.net with ESMTP; Sat, 03 Feb\r\n" +
+		" 2001 04:05:06 +0000\r\n" +
+		"Received: from client.example.com by relay.example.com; Sat, 03 Feb 2001\r\n" +
+		" 04:05:06 +0000\r\n" +
+		"Date: "
+	if !bytes.HasPrefix(b, []byte(want)) {
+		t.Errorf("got\n%s\nwant\n%s", b[:bytes.Index(b, []byte("\r\n\r\n"))], want)
+	}
+
+	// The fields parse back.
+	parsed, err := ParseMail(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range parsed.Received {
+		parsed.Received[i].Date = parsed.Received[i].Date.UTC()
+	}
+	if !reflect.DeepEqual(parsed.Received, m.Received) {
+		t.Errorf("parsed %+v, want %+v", parsed.Received, m.Received)
+	}
+
+	if strings.Count(string(b), "Received: ") != 2 {
+		t.Errorf("not 2 Received fields in\n%s", b)
+	}
+}
@@ -0,0 +1,315 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// DSN is a delivery status notification (RFC 3464).
+type DSN struct {
+	ReportingMTA       string
+	OriginalEnvelopeID string
+
+	// OriginalMessageID is the Message-ID of the message the report is
+	// about, taken from the copy of its header returned with the report.
+	OriginalMessageID string
+
+	Recipients []DSNRecipient
+}
+
+// DSNRecipient is the status of the delivery to a recipient.
+type DSNRecipient struct {
+	FinalRecipient    string
+	OriginalRecipient string
+
+	// Action is one of "failed", "delayed", "delivered", "relayed" or
+	// "expanded".
+	Action string
+
+	// Status is the enhanced status code, such as "5.1.1" (RFC 3463).
+	Status string
+
+	RemoteMTA      string
+	DiagnosticCode string
+}
+
+// FeedbackReport is an abuse report (RFC 5965), sent by mailbox
+// providers when a recipient marks a message as spam.
+type FeedbackReport struct {
+	// FeedbackType is one of "abuse", "fraud", "virus", "not-spam",
+	// "auth-failure" or "other".
+	FeedbackType string
+
+	UserAgent        string
+	OriginalMailFrom string
+	OriginalRcptTo   []string
+	ReportedDomain   string
+	SourceIP         string
+
+	// OriginalMessageID is the Message-ID of the message reported.
+	OriginalMessageID string
+}
+
+// report collects the parts of a multipart/report message.
+type report struct {
+	session    *parseSession
+	reportType string
+	fields     []textproto.MIMEHeader
+	original   string
+}
+
+// ParseDSN reads a delivery status notification with a strict Parser
+// and the default limits.
+func ParseDSN(r io.Reader) (*DSN, error) {
+	return new(Parser).ParseDSN(r)
+}
+
+// ParseDSN reads a delivery status notification within the limits of p.
+func (p *Parser) ParseDSN(r io.Reader) (*DSN, error) {
+	rep, err := p.parseReport(r, "delivery-status")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rep.fields) == 0 {
+		return nil, fmt.Errorf("postman: DSN without delivery status")
+	}
+
+	d := DSN{OriginalMessageID: rep.original}
+
+	h := rep.fields[0]
+	d.ReportingMTA = typedValue(h.Get("Reporting-Mta"))
+	d.OriginalEnvelopeID = h.Get("Original-Envelope-Id")
+
+	for _, h := range rep.fields[1:] {
+		d.Recipients = append(d.Recipients, DSNRecipient{
+			FinalRecipient:    typedValue(h.Get("Final-Recipient")),
+			OriginalRecipient: typedValue(h.Get("Original-Recipient")),
+			Action:            strings.ToLower(h.Get("Action")),
+			Status:            h.Get("Status"),
+			RemoteMTA:         typedValue(h.Get("Remote-Mta")),
+			DiagnosticCode:    typedValue(h.Get("Diagnostic-Code")),
+		})
+	}
+
+	if len(d.Recipients) == 0 {
+		return nil, fmt.Errorf("postman: DSN without recipient")
+	}
+
+	return &d, nil
+}
+
+// ParseFeedbackReport reads an abuse report in the Abuse Reporting
+// Format, with a strict Parser and the default limits.
+func ParseFeedbackReport(r io.Reader) (*FeedbackReport, error) {
+	return new(Parser).ParseFeedbackReport(r)
+}
+
+// ParseFeedbackReport reads an abuse report within the limits of p.
+func (p *Parser) ParseFeedbackReport(r io.Reader) (*FeedbackReport, error) {
+	rep, err := p.parseReport(r, "feedback-report")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rep.fields) == 0 {
+		return nil, fmt.Errorf("postman: feedback report without fields")
+	}
+
+	h := rep.fields[0]
+	f := FeedbackReport{
+		FeedbackType:      strings.ToLower(h.Get("Feedback-Type")),
+		UserAgent:         h.Get("User-Agent"),
+		OriginalMailFrom:  h.Get("Original-Mail-From"),
+		OriginalRcptTo:    h["Original-Rcpt-To"],
+		ReportedDomain:    h.Get("Reported-Domain"),
+		SourceIP:          h.Get("Source-Ip"),
+		OriginalMessageID: rep.original,
+	}
+
+	return &f, nil
+}
+
+func (p *Parser) parseReport(r io.Reader, reportType string) (*report, error) {
+	s := p.session(r)
+
+	msg, err := mail.ReadMessage(s.r)
+	if err != nil {
+		return nil, s.err(err)
+	}
+
+	rep := report{session: s}
+	if err := rep.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, s.err(err)
+	}
+
+	if rep.reportType != reportType {
+		return nil, fmt.Errorf("postman: not a %s report", reportType)
+	}
+
+	return &rep, nil
+}
+
+// walk looks for the report and the original message in an entity at the
+// given depth, descending into multipart entities, since reports are
+// sometimes forwarded.
+func (rep *report) walk(h textproto.MIMEHeader, r io.Reader, depth int) error {
+	if err := rep.session.enter(depth); err != nil {
+		return err
+	}
+
+	mediatype, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		if mediatype == "multipart/report" && rep.reportType == "" {
+			rep.reportType = strings.ToLower(params["report-type"])
+		}
+
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				if rep.session.recoverable(err) {
+					return nil
+				}
+				return err
+			}
+
+			if err := rep.walk(p.Header, p, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := ioutil.ReadAll(decodeBody(h, r))
+	if err != nil && !rep.session.recoverable(err) {
+		return err
+	}
+
+	switch mediatype {
+	case "message/delivery-status", "message/feedback-report",
+		"message/global-delivery-status":
+		if rep.fields == nil {
+			rep.fields, err = readFieldGroups(content)
+			if err != nil && rep.session.recoverable(err) {
+				err = nil
+			}
+		}
+		return err
+
+	case "message/rfc822", "text/rfc822-headers", "message/global",
+		"message/global-headers":
+		if rep.original == "" {
+			tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(content)))
+			orig, _ := tp.ReadMIMEHeader()
+			rep.original = TrackingID(orig.Get("Message-Id"))
+		}
+	}
+
+	return nil
+}
+
+// readFieldGroups reads the groups of header fields, separated by blank
+// lines, of a report.
+func readFieldGroups(content []byte) ([]textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(content)))
+
+	var groups []textproto.MIMEHeader
+	for {
+		h, err := tp.ReadMIMEHeader()
+		if len(h) > 0 {
+			groups = append(groups, h)
+		}
+		if err == io.EOF {
+			return groups, nil
+		}
+		if err != nil {
+			return groups, err
+		}
+	}
+}
+
+// typedValue strips the type of a report field, as in "rfc822;
+// user@example.com" or "smtp; 550 5.1.1 unknown user".
+func typedValue(v string) string {
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// ApplyDSN updates the status of the message d is about: bounced if the
+// delivery to any recipient failed, deferred if delayed, and delivered
+// otherwise.
+func (t *Tracker) ApplyDSN(d *DSN) error {
+	if d.OriginalMessageID == "" {
+		return fmt.Errorf("postman: DSN without original Message-ID")
+	}
+
+	state, errMsg := d.state()
+	t.Update(d.OriginalMessageID, state, errMsg)
+
+	return nil
+}
+
+// state returns the state of the message d is about.
+func (d *DSN) state() (State, string) {
+	state, errMsg := StateDelivered, ""
+	for _, rcpt := range d.Recipients {
+		switch s := rcpt.state(); {
+		case s == StateBounced:
+			state, errMsg = s, rcpt.describe()
+		case s == StateDeferred && state != StateBounced:
+			state, errMsg = s, rcpt.describe()
+		}
+	}
+	return state, errMsg
+}
+
+// state returns the state of the delivery to rcpt.
+func (rcpt *DSNRecipient) state() State {
+	switch rcpt.Action {
+	case "failed":
+		return StateBounced
+	case "delayed":
+		return StateDeferred
+	}
+	return StateDelivered
+}
+
+func (rcpt *DSNRecipient) describe() string {
+	s := rcpt.FinalRecipient + ": " + rcpt.Status
+	if rcpt.DiagnosticCode != "" {
+		s += " " + rcpt.DiagnosticCode
+	}
+	return s
+}
+
+// ApplyFeedbackReport marks the message f is about as complained. Other
+// feedback than abuse and fraud is ignored.
+func (t *Tracker) ApplyFeedbackReport(f *FeedbackReport) error {
+	if f.OriginalMessageID == "" {
+		return fmt.Errorf("postman: feedback report without original Message-ID")
+	}
+
+	switch f.FeedbackType {
+	case "abuse", "fraud":
+		t.Update(f.OriginalMessageID, StateComplained, f.FeedbackType+" report from "+f.UserAgent)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package postman
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// ScramSHA1Auth returns an smtp.Auth implementing the SCRAM-SHA-1
+// mechanism (RFC 5802), which proves the knowledge of the password
+// without sending it, and authenticates the server in return. It keeps the
+// state of the authentication, hence must not be shared by concurrent
+// sessions, except through a Client, which gives each of them a copy.
+func ScramSHA1Auth(username, password string) smtp.Auth {
+	return &scramAuth{name: "SCRAM-SHA-1", hash: sha1.New, username: username, password: password}
+}
+
+// ScramSHA256Auth returns an smtp.Auth implementing the SCRAM-SHA-256
+// mechanism (RFC 7677), as ScramSHA1Auth does SCRAM-SHA-1.
+func ScramSHA256Auth(username, password string) smtp.Auth {
+	return &scramAuth{name: "SCRAM-SHA-256", hash: sha256.New, username: username, password: password}
+}
+
+// scramAuth holds the state of an authentication: a Client gives each of
+// its sessions a copy.
+type scramAuth struct {
+	name     string
+	hash     func() hash.Hash
+	username string
+	password string
+
+	clientFirst     string
+	serverSignature []byte
+	verified        bool
+}
+
+func (a *scramAuth) session() smtp.Auth {
+	aa := *a
+	return &aa
+}
+
+// scramNonce returns the nonce of the client, replaced by the tests.
+var scramNonce = func() (string, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+func (a *scramAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	nonce, err := scramNonce()
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(a.username)
+	a.clientFirst = "n=" + name + ",r=" + nonce
+	a.serverSignature, a.verified = nil, false
+
+	// No channel binding, nor authorization identity.
+	return a.name, []byte("n,," + a.clientFirst), nil
+}
+
+func (a *scramAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	switch {
+	case !more:
+		if !a.verified {
+			return nil, errors.New("server not authenticated")
+		}
+		return nil, nil
+
+	case a.serverSignature == nil:
+		return a.clientFinal(string(fromServer))
+
+	default:
+		attrs := scramAttrs(string(fromServer))
+		if e, ok := attrs["e"]; ok {
+			return nil, errors.New("authentication failed: " + e)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+		if err != nil || !hmac.Equal(signature, a.serverSignature) {
+			return nil, errors.New("invalid server signature")
+		}
+		a.verified = true
+
+		return []byte{}, nil
+	}
+}
+
+// clientFinal returns the proof answering the server first message.
+func (a *scramAuth) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttrs(serverFirst)
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, a.clientFirst[strings.LastIndex(a.clientFirst, "r=")+2:]) {
+		return nil, errors.New("invalid server nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, errors.New("invalid salt")
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("invalid iteration count")
+	}
+
+	// "biws" is the base64 encoded GS2 header, "n,,".
+	final := "c=biws,r=" + nonce
+	authMessage := []byte(a.clientFirst + "," + serverFirst + "," + final)
+
+	salted := pbkdf2(a.hash, []byte(a.password), salt, iterations)
+
+	clientKey := a.hmac(salted, []byte("Client Key"))
+	h := a.hash()
+	h.Write(clientKey)
+	clientSignature := a.hmac(h.Sum(nil), authMessage)
+
+	proof := make([]byte, len(clientKey))
+	for i := range proof {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	a.serverSignature = a.hmac(a.hmac(salted, []byte("Server Key")), authMessage)
+
+	return []byte(final + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (a *scramAuth) hmac(key, data []byte) []byte {
+	mac := hmac.New(a.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// scramAttrs parses the comma separated attributes of a SCRAM message.
+func scramAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	return attrs
+}
+
+// pbkdf2 derives a key as RFC 8018 section 5.2 describes, of the length
+// of the hash, which is all SCRAM needs.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(h, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+
+	key := append([]byte(nil), u...)
+	for n := 1; n < iterations; n++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+
+	return key
+}
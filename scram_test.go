@@ -0,0 +1,169 @@
+package postman
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+// scramExchange is the example exchange of a SCRAM specification, for
+// the user "user" with the password "pencil".
+type scramExchange struct {
+	name        string
+	auth        func(username, password string) smtp.Auth
+	nonce       string
+	clientFirst string
+	serverFirst string
+	clientFinal string
+	serverFinal string
+}
+
+var scramExchanges = []scramExchange{
+	{
+		// RFC 5802 section 5.
+		name:        "SCRAM-SHA-1",
+		auth:        ScramSHA1Auth,
+		nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+		clientFirst: "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL",
+		serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+		clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+		serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+	},
+	{
+		// RFC 7677 section 3.
+		name:        "SCRAM-SHA-256",
+		auth:        ScramSHA256Auth,
+		nonce:       "rOprNGfwEbeRWgbNEkqO",
+		clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+		serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+	},
+}
+
+// startScram starts the authentication of ex up to the client final
+// message.
+func startScram(t *testing.T, ex scramExchange) smtp.Auth {
+	t.Helper()
+
+	defer func(f func() (string, error)) { scramNonce = f }(scramNonce)
+	scramNonce = func() (string, error) { return ex.nonce, nil }
+
+	a := ex.auth("user", "pencil")
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: "mx.example.com", TLS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mech != ex.name {
+		t.Errorf("mechanism %s, want %s", mech, ex.name)
+	}
+	if string(resp) != ex.clientFirst {
+		t.Errorf("client first message %q, want %q", resp, ex.clientFirst)
+	}
+
+	resp, err = a.Next([]byte(ex.serverFirst), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != ex.clientFinal {
+		t.Errorf("client final message %q, want %q", resp, ex.clientFinal)
+	}
+
+	return a
+}
+
+func TestScram(t *testing.T) {
+	for _, ex := range scramExchanges {
+		t.Run(ex.name, func(t *testing.T) {
+			a := startScram(t, ex)
+
+			resp, err := a.Next([]byte(ex.serverFinal), true)
+			if err != nil {
+				t.Fatalf("server final message: %v", err)
+			}
+			if len(resp) != 0 {
+				t.Errorf("answer to the server final message %q, want none", resp)
+			}
+
+			if _, err := a.Next(nil, false); err != nil {
+				t.Errorf("success: %v", err)
+			}
+		})
+	}
+}
+
+func TestScramServerErrors(t *testing.T) {
+	ex := scramExchanges[1]
+
+	tests := []struct {
+		name        string
+		serverFinal string
+		want        string
+	}{
+		{"signature mismatch", "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=", "invalid server signature"},
+		{"invalid signature", "v=!", "invalid server signature"},
+		{"no signature", "x=1", "invalid server signature"},
+		{"server error", "e=invalid-proof", "authentication failed: invalid-proof"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := startScram(t, ex)
+
+			_, err := a.Next([]byte(tt.serverFinal), true)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got %v, want %q", err, tt.want)
+			}
+
+			// The success of a server which did not prove its identity
+			// is rejected.
+			if _, err := a.Next(nil, false); err == nil {
+				t.Error("success of an unauthenticated server: got no error")
+			}
+		})
+	}
+}
+
+func TestScramServerFirstErrors(t *testing.T) {
+	ex := scramExchanges[1]
+
+	tests := []struct {
+		name        string
+		serverFirst string
+		want        string
+	}{
+		{"other nonce", strings.Replace(ex.serverFirst, "r=rOpr", "r=xOpr", 1), "invalid server nonce"},
+		{"invalid salt", strings.Replace(ex.serverFirst, "s=W22Z", "s=!22Z", 1), "invalid salt"},
+		{"no iteration", strings.Replace(ex.serverFirst, "i=4096", "i=0", 1), "invalid iteration count"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(f func() (string, error)) { scramNonce = f }(scramNonce)
+			scramNonce = func() (string, error) { return ex.nonce, nil }
+
+			a := ex.auth("user", "pencil")
+			if _, _, err := a.Start(&smtp.ServerInfo{Name: "mx.example.com", TLS: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := a.Next([]byte(tt.serverFirst), true)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScramUsername(t *testing.T) {
+	defer func(f func() (string, error)) { scramNonce = f }(scramNonce)
+	scramNonce = func() (string, error) { return "nonce", nil }
+
+	_, resp, err := ScramSHA256Auth("a=b,c", "password").Start(&smtp.ServerInfo{Name: "mx.example.com", TLS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "n,,n=a=3Db=2Cc,r=nonce"; string(resp) != want {
+		t.Errorf("got %q, want %q", resp, want)
+	}
+}
@@ -0,0 +1,76 @@
+package postman
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+)
+
+// Query parameters added by URLSigner.Sign.
+const (
+	URLMessageParam   = "m"
+	URLRecipientParam = "r"
+	URLTokenParam     = "t"
+)
+
+// URLSigner signs the URLs of the tracking and unsubscribe links of a
+// message with a token, the HMAC-SHA256 of its Message-ID and recipient,
+// so that the endpoints they point to can authenticate the callbacks
+// without a database lookup:
+//
+//	link, err := signer.Sign("https://example.com/unsubscribe", m.MessageID, rcpt)
+//
+// and, in the handler of the endpoint:
+//
+//	msgID, rcpt, err := signer.Verify(r.URL)
+type URLSigner struct {
+	Secret []byte
+}
+
+// Sign returns rawurl with the tracking identifier of the message, the
+// recipient and their token added to its query.
+func (s *URLSigner) Sign(rawurl, msgID, rcpt string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	id := TrackingID(msgID)
+
+	q := u.Query()
+	q.Set(URLMessageParam, id)
+	q.Set(URLRecipientParam, rcpt)
+	q.Set(URLTokenParam, s.Token(id, rcpt))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Token returns the token of the message with the given Message-ID, or
+// tracking identifier, and of the recipient.
+func (s *URLSigner) Token(msgID, rcpt string) string {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(TrackingID(msgID)))
+	h.Write([]byte{0})
+	h.Write([]byte(rcpt))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks the token of a URL signed by Sign and returns the
+// tracking identifier of the message and the recipient it was signed for.
+func (s *URLSigner) Verify(u *url.URL) (string, string, error) {
+	q := u.Query()
+
+	id, rcpt, token := q.Get(URLMessageParam), q.Get(URLRecipientParam), q.Get(URLTokenParam)
+	if id == "" || rcpt == "" || token == "" {
+		return "", "", errors.New("postman: unsigned URL")
+	}
+
+	if !hmac.Equal([]byte(token), []byte(s.Token(id, rcpt))) {
+		return "", "", errors.New("postman: invalid URL signature")
+	}
+
+	return id, rcpt, nil
+}
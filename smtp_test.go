@@ -0,0 +1,289 @@
+package postman
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testMessage is a message received by a testServer.
+type testMessage struct {
+	From  string
+	Rcpts []string
+
+	// Data is the content of the message, unstuffed, with its lines
+	// ending with LF.
+	Data string
+}
+
+// testServer is a minimal SMTP server accepting every message, for the
+// tests of the client. It offers PIPELINING and 8BITMIME, and no
+// encryption nor authentication.
+type testServer struct {
+	Addr string
+
+	l  net.Listener
+	wg sync.WaitGroup
+
+	// Set by the options.
+	extensions  []string
+	proxy       int
+	dataReplies map[string]string
+
+	mu       sync.Mutex
+	messages []testMessage
+	commands []string
+	proxies  [][]byte
+	aborted  int // transactions whose connection dropped during DATA
+}
+
+// testServerOption configures a testServer before it starts.
+type testServerOption func(*testServer)
+
+// withExtensions has the server offer the given EHLO keywords, with their
+// parameters, in addition to PIPELINING and 8BITMIME. Offering XCLIENT
+// has it accept the command.
+func withExtensions(ext ...string) testServerOption {
+	return func(s *testServer) {
+		s.extensions = append(s.extensions, ext...)
+	}
+}
+
+// withProxyProtocol has the server read the PROXY protocol header of the
+// given version at the beginning of every connection.
+func withProxyProtocol(version int) testServerOption {
+	return func(s *testServer) {
+		s.proxy = version
+	}
+}
+
+// withDataReplies sets the replies to the data of the transactions
+// requesting PRDR for some of the recipients, such as "550 5.7.1
+// Rejected"; the others are accepted.
+func withDataReplies(replies map[string]string) testServerOption {
+	return func(s *testServer) {
+		s.dataReplies = replies
+	}
+}
+
+// newTestServer starts a testServer on the loopback interface. It must be
+// closed by the caller.
+func newTestServer(tb testing.TB, opts ...testServerOption) *testServer {
+	tb.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	s := &testServer{Addr: l.Addr().String(), l: l}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops the server and waits for its sessions to end.
+func (s *testServer) Close() {
+	s.l.Close()
+	s.wg.Wait()
+}
+
+// Messages returns the messages received so far.
+func (s *testServer) Messages() []testMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]testMessage(nil), s.messages...)
+}
+
+// Commands returns the commands received so far, in order.
+func (s *testServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.commands...)
+}
+
+// Proxies returns the PROXY protocol headers received so far.
+func (s *testServer) Proxies() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][]byte(nil), s.proxies...)
+}
+
+// Aborted returns the number of transactions aborted during DATA.
+func (s *testServer) Aborted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.aborted
+}
+
+func (s *testServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *testServer) session(text *textproto.Conn) {
+	if s.proxy != 0 {
+		header, err := readProxyHeader(text, s.proxy)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.proxies = append(s.proxies, header)
+		s.mu.Unlock()
+	}
+
+	var (
+		m    testMessage
+		prdr bool
+	)
+
+	text.PrintfLine("220 postman.test ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb := strings.ToUpper(line)
+		if i := strings.IndexByte(verb, ' '); i >= 0 {
+			verb = verb[:i]
+		}
+
+		switch verb {
+		case "EHLO":
+			ext := append([]string{"postman.test", "PIPELINING", "8BITMIME"}, s.extensions...)
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				text.PrintfLine("250%s%s", sep, e)
+			}
+		case "HELO", "NOOP":
+			text.PrintfLine("250 OK")
+		case "XCLIENT":
+			// The server greets the client again, as a new session.
+			m = testMessage{}
+			text.PrintfLine("220 postman.test ESMTP")
+		case "RSET":
+			m = testMessage{}
+			text.PrintfLine("250 OK")
+		case "MAIL":
+			m = testMessage{From: between(line, "<", ">")}
+			prdr = strings.Contains(strings.ToUpper(line), " PRDR")
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			m.Rcpts = append(m.Rcpts, between(line, "<", ">"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := ioutil.ReadAll(text.DotReader())
+			if err != nil {
+				s.mu.Lock()
+				s.aborted++
+				s.mu.Unlock()
+				return
+			}
+			m.Data = string(data)
+
+			accepted := !prdr || s.dataReply(text, m.Rcpts)
+			if accepted {
+				s.mu.Lock()
+				s.messages = append(s.messages, m)
+				s.mu.Unlock()
+				text.PrintfLine("250 OK queued")
+			} else {
+				text.PrintfLine("550 5.7.1 Rejected for every recipient")
+			}
+			m = testMessage{}
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// dataReply writes the replies of the server to the data of a PRDR
+// transaction, but the final one, and reports whether the message was
+// accepted for at least one recipient.
+func (s *testServer) dataReply(text *textproto.Conn, rcpts []string) bool {
+	text.PrintfLine("353 Content analysis started")
+
+	accepted := false
+	for _, rcpt := range rcpts {
+		reply, ok := s.dataReplies[rcpt]
+		if !ok {
+			reply = "250 2.1.5 OK"
+		}
+		accepted = accepted || strings.HasPrefix(reply, "2")
+		text.PrintfLine("%s", reply)
+	}
+
+	return accepted
+}
+
+// readProxyHeader reads a PROXY protocol header of the given version.
+func readProxyHeader(text *textproto.Conn, version int) ([]byte, error) {
+	if version == 1 {
+		line, err := text.R.ReadString('\n')
+		return []byte(line), err
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(text.R, header); err != nil {
+		return nil, err
+	}
+
+	addrs := make([]byte, int(header[14])<<8|int(header[15]))
+	if _, err := io.ReadFull(text.R, addrs); err != nil {
+		return nil, err
+	}
+
+	return append(header, addrs...), nil
+}
+
+// between returns the part of s between the first occurrence of open and
+// the following close.
+func between(s, open, close string) string {
+	i := strings.Index(s, open)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(open):]
+	if j := strings.Index(s, close); j >= 0 {
+		s = s[:j]
+	}
+
+	return s
+}
@@ -0,0 +1,301 @@
+package postman
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	lookupIP = net.LookupIP
+	lookupMX = net.LookupMX
+)
+
+// SPFResult is the result of an SPF evaluation (RFC 7208 section 2.6).
+type SPFResult string
+
+const (
+	SPFNone      SPFResult = "none"
+	SPFNeutral   SPFResult = "neutral"
+	SPFPass      SPFResult = "pass"
+	SPFFail      SPFResult = "fail"
+	SPFSoftFail  SPFResult = "softfail"
+	SPFTempError SPFResult = "temperror"
+	SPFPermError SPFResult = "permerror"
+)
+
+// The number of mechanisms and modifiers causing DNS lookups is limited
+// to 10 (RFC 7208 section 4.6.4).
+const spfMaxLookups = 10
+
+var errSPFMacro = errors.New("spf: macros are not supported")
+
+// CheckSPF evaluates the SPF policy of domain for a message sent from
+// ip. The error explains temperror and permerror results. Macros are not
+// supported and evaluate to permerror.
+func CheckSPF(ip net.IP, domain string) (SPFResult, error) {
+	c := spfCheck{ip: ip}
+	return c.check(domain)
+}
+
+type spfCheck struct {
+	ip      net.IP
+	lookups int
+}
+
+func (c *spfCheck) lookup() error {
+	c.lookups++
+	if c.lookups > spfMaxLookups {
+		return errors.New("spf: too many DNS lookups")
+	}
+	return nil
+}
+
+func (c *spfCheck) check(domain string) (SPFResult, error) {
+	record, err := spfRecord(domain)
+	if err != nil {
+		if _, ok := err.(*net.DNSError); ok {
+			return SPFTempError, err
+		}
+		return SPFPermError, err
+	}
+	if record == "" {
+		return SPFNone, nil
+	}
+
+	var redirect string
+
+	for _, term := range strings.Fields(record)[1:] {
+		if i := strings.IndexByte(term, '='); i >= 0 && !strings.ContainsAny(term[:i], ":/") {
+			if strings.EqualFold(term[:i], "redirect") {
+				redirect = term[i+1:]
+			}
+			continue
+		}
+
+		result := SPFPass
+		switch term[0] {
+		case '+':
+			term = term[1:]
+		case '-':
+			result, term = SPFFail, term[1:]
+		case '~':
+			result, term = SPFSoftFail, term[1:]
+		case '?':
+			result, term = SPFNeutral, term[1:]
+		}
+
+		match, err := c.match(term, domain)
+		if err != nil {
+			if _, ok := err.(*net.DNSError); ok {
+				return SPFTempError, err
+			}
+			return SPFPermError, err
+		}
+		if match {
+			return result, nil
+		}
+	}
+
+	if redirect != "" {
+		if strings.IndexByte(redirect, '%') >= 0 {
+			return SPFPermError, errSPFMacro
+		}
+		if err := c.lookup(); err != nil {
+			return SPFPermError, err
+		}
+
+		result, err := c.check(redirect)
+		if result == SPFNone {
+			return SPFPermError, fmt.Errorf("spf: redirect to %s without policy", redirect)
+		}
+		return result, err
+	}
+
+	return SPFNeutral, nil
+}
+
+func (c *spfCheck) match(term, domain string) (bool, error) {
+	name, arg := term, ""
+	if i := strings.IndexAny(term, ":/"); i >= 0 {
+		name, arg = term[:i], term[i:]
+	}
+	arg = strings.TrimPrefix(arg, ":")
+
+	if strings.IndexByte(arg, '%') >= 0 {
+		return false, errSPFMacro
+	}
+
+	switch strings.ToLower(name) {
+	case "all":
+		return true, nil
+
+	case "ip4", "ip6":
+		if !strings.Contains(arg, "/") {
+			if strings.ToLower(name) == "ip4" {
+				arg += "/32"
+			} else {
+				arg += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(arg)
+		if err != nil {
+			return false, fmt.Errorf("spf: invalid %s", term)
+		}
+		return network.Contains(c.ip), nil
+
+	case "a":
+		if err := c.lookup(); err != nil {
+			return false, err
+		}
+		target, v4, v6, err := parseDualCIDR(arg, domain)
+		if err != nil {
+			return false, err
+		}
+		return c.matchHost(target, v4, v6)
+
+	case "mx":
+		if err := c.lookup(); err != nil {
+			return false, err
+		}
+		target, v4, v6, err := parseDualCIDR(arg, domain)
+		if err != nil {
+			return false, err
+		}
+		mxs, err := lookupMX(target)
+		if err != nil {
+			return false, dnsError(err)
+		}
+		for i, mx := range mxs {
+			if i == spfMaxLookups {
+				return false, errors.New("spf: too many MX records")
+			}
+			ok, err := c.matchHost(mx.Host, v4, v6)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case "include":
+		if err := c.lookup(); err != nil {
+			return false, err
+		}
+		result, err := c.check(arg)
+		switch result {
+		case SPFPass:
+			return true, nil
+		case SPFFail, SPFSoftFail, SPFNeutral:
+			return false, nil
+		case SPFNone:
+			return false, fmt.Errorf("spf: include of %s without policy", arg)
+		}
+		return false, err
+
+	case "exists":
+		if err := c.lookup(); err != nil {
+			return false, err
+		}
+		ips, err := lookupIP(arg)
+		if err != nil {
+			return false, dnsError(err)
+		}
+		return len(ips) > 0, nil
+
+	case "ptr":
+		// Deprecated, and never matching is the behavior RFC 7208
+		// section 5.5 recommends for it.
+		if err := c.lookup(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("spf: unknown mechanism %q", name)
+}
+
+func (c *spfCheck) matchHost(host string, v4, v6 int) (bool, error) {
+	ips, err := lookupIP(host)
+	if err != nil {
+		return false, dnsError(err)
+	}
+
+	for _, ip := range ips {
+		bits, ones := 128, v6
+		if ip.To4() != nil {
+			ip, bits, ones = ip.To4(), 32, v4
+		}
+
+		network := net.IPNet{IP: ip.Mask(net.CIDRMask(ones, bits)), Mask: net.CIDRMask(ones, bits)}
+		if network.Contains(c.ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseDualCIDR parses the optional domain and prefix lengths of the a
+// and mx mechanisms, as in "example.com/24//64".
+func parseDualCIDR(arg, domain string) (string, int, int, error) {
+	v4, v6 := 32, 128
+
+	if i := strings.Index(arg, "//"); i >= 0 {
+		n, err := strconv.Atoi(arg[i+2:])
+		if err != nil || n < 0 || n > 128 {
+			return "", 0, 0, fmt.Errorf("spf: invalid prefix %q", arg)
+		}
+		v6, arg = n, arg[:i]
+	}
+
+	if i := strings.IndexByte(arg, '/'); i >= 0 {
+		n, err := strconv.Atoi(arg[i+1:])
+		if err != nil || n < 0 || n > 32 {
+			return "", 0, 0, fmt.Errorf("spf: invalid prefix %q", arg)
+		}
+		v4, arg = n, arg[:i]
+	}
+
+	if arg == "" {
+		arg = domain
+	}
+
+	return arg, v4, v6, nil
+}
+
+// spfRecord returns the SPF record of domain, or an empty string when it
+// publishes none.
+func spfRecord(domain string) (string, error) {
+	txts, err := lookupTXT(domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var record string
+	for _, txt := range txts {
+		lower := strings.ToLower(txt)
+		if lower != "v=spf1" && !strings.HasPrefix(lower, "v=spf1 ") {
+			continue
+		}
+		if record != "" {
+			return "", fmt.Errorf("spf: several records for %s", domain)
+		}
+		record = txt
+	}
+
+	return record, nil
+}
+
+// dnsError turns "no such host" into an empty answer, which SPF does not
+// consider an error.
+func dnsError(err error) error {
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package postman
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// withHosts makes the A, AAAA and MX lookups answer from hosts and mxs
+// for the rest of a test.
+func withHosts(hosts map[string][]string, mxs map[string][]string) func() {
+	ipLookup, mxLookup := lookupIP, lookupMX
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		var ips []net.IP
+		for _, a := range addrs {
+			ips = append(ips, net.ParseIP(a))
+		}
+		return ips, nil
+	}
+
+	lookupMX = func(name string) ([]*net.MX, error) {
+		hosts, ok := mxs[name]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		var records []*net.MX
+		for _, h := range hosts {
+			records = append(records, &net.MX{Host: h, Pref: 10})
+		}
+		return records, nil
+	}
+
+	return func() { lookupIP, lookupMX = ipLookup, mxLookup }
+}
+
+func TestCheckSPF(t *testing.T) {
+	defer withTXT(map[string][]string{
+		"example.com":          {"google-site-verification=abc", "v=spf1 ip4:192.0.2.0/24 a:mail.example.com mx include:_spf.example.net ~all"},
+		"_spf.example.net":     {"v=spf1 ip6:2001:db8::/32 -all"},
+		"strict.example.com":   {"v=spf1 a/24 -all"},
+		"redirect.example":     {"v=spf1 redirect=example.com"},
+		"nowhere.example":      {"v=spf1 redirect=missing.example"},
+		"macro.example":        {"v=spf1 exists:%{i}.spf.example.com -all"},
+		"two.example":          {"v=spf1 -all", "v=spf1 +all"},
+		"unknown.example":      {"v=spf1 foo:bar -all"},
+		"loop.example":         {"v=spf1 include:loop.example -all"},
+		"neutral.example":      {"v=spf1 ?all"},
+		"no-policy.example":    {"v=spf1"},
+		"include-none.example": {"v=spf1 include:missing.example -all"},
+	})()
+	defer withHosts(map[string][]string{
+		"mail.example.com":   {"198.51.100.1"},
+		"mx.example.com":     {"198.51.100.2", "2001:db8:1::2"},
+		"strict.example.com": {"203.0.113.7"},
+	}, map[string][]string{
+		"example.com": {"mx.example.com"},
+	})()
+
+	tests := []struct {
+		ip     string
+		domain string
+		want   SPFResult
+		err    string
+	}{
+		{"192.0.2.10", "example.com", SPFPass, ""},
+		{"198.51.100.1", "example.com", SPFPass, ""},
+		{"198.51.100.2", "example.com", SPFPass, ""},
+		{"2001:db8:1::2", "example.com", SPFPass, ""},
+		{"2001:db8::25", "example.com", SPFPass, ""},
+		{"203.0.113.1", "example.com", SPFSoftFail, ""},
+		{"203.0.113.200", "strict.example.com", SPFPass, ""},
+		{"198.51.100.9", "strict.example.com", SPFFail, ""},
+		{"192.0.2.10", "redirect.example", SPFPass, ""},
+		{"192.0.2.10", "neutral.example", SPFNeutral, ""},
+		{"192.0.2.10", "no-policy.example", SPFNeutral, ""},
+		{"192.0.2.10", "none.example", SPFNone, ""},
+		{"192.0.2.10", "nowhere.example", SPFPermError, "spf: redirect to missing.example without policy"},
+		{"192.0.2.10", "macro.example", SPFPermError, "spf: macros are not supported"},
+		{"192.0.2.10", "two.example", SPFPermError, "spf: several records for two.example"},
+		{"192.0.2.10", "unknown.example", SPFPermError, `spf: unknown mechanism "foo"`},
+		{"192.0.2.10", "loop.example", SPFPermError, "spf: too many DNS lookups"},
+		{"192.0.2.10", "include-none.example", SPFPermError, "spf: include of missing.example without policy"},
+	}
+
+	for _, tt := range tests {
+		got, err := CheckSPF(net.ParseIP(tt.ip), tt.domain)
+		if got != tt.want || (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s from %s: got %s, %v, want %s, %q", tt.domain, tt.ip, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Spool keeps the attachments of large messages in temporary files
+// rather than in memory while they are sent, for services with little
+// memory to send big files: once the total size of the attachments of a
+// message exceeds Threshold, their content is written to files and
+// streamed from disk whenever the message is written. Messages signed
+// with DKIM are then rendered to a file as well.
+type Spool struct {
+	// Threshold is the total size of the attachments of a message, in
+	// bytes, above which they are spooled.
+	Threshold int64
+
+	// Dir is the directory of the temporary files; empty means the one
+	// returned by os.TempDir.
+	Dir string
+}
+
+// Spool moves the content of the attachments of m to temporary files if
+// their total size exceeds the threshold: m is given a copy of its
+// attachments, with their File set and their Content cleared, so that
+// the attachments of the caller are left untouched. It returns a function
+// removing the files, to be called once m is no longer written.
+func (s *Spool) Spool(m *Mail) (release func(), err error) {
+	var size int64
+	for i := range m.Attachments {
+		size += int64(len(m.Attachments[i].Content))
+	}
+
+	var files []string
+	release = func() {
+		for _, name := range files {
+			os.Remove(name)
+		}
+	}
+
+	if size <= s.Threshold {
+		return release, nil
+	}
+
+	// The attachments are only replaced once all the files are written,
+	// for m to stay whole on failure.
+	atts := append([]Attachment(nil), m.Attachments...)
+	for i := range atts {
+		if atts[i].Content == nil {
+			continue
+		}
+
+		name, err := s.write(atts[i].Content)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		files = append(files, name)
+		atts[i].File, atts[i].Content = name, nil
+	}
+	m.Attachments = atts
+
+	return release, nil
+}
+
+// write writes content to a new temporary file and returns its name.
+func (s *Spool) write(content []byte) (string, error) {
+	f, err := ioutil.TempFile(s.Dir, "postman-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// sign renders m to a temporary file and signs it from there, hashing
+// the body as it is read.
+func (s *Spool) sign(m *Mail, signers []*DKIMSigner) (*payload, error) {
+	f, err := ioutil.TempFile(s.Dir, "postman-")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &payload{file: f}
+
+	if _, err := m.WriteTo(f); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	var header []byte
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		header = append(header, line...)
+		if err != nil || len(bytes.TrimRight(line, "\r\n")) == 0 {
+			break
+		}
+	}
+
+	fields, _ := splitMessage(header)
+
+	for _, signer := range signers {
+		if _, err := f.Seek(int64(len(header)), io.SeekStart); err != nil {
+			p.close()
+			return nil, err
+		}
+
+		h := sha256.New()
+		bw := signer.BodyCanonicalization.BodyWriter(h)
+		if _, err := io.Copy(bw, f); err != nil {
+			p.close()
+			return nil, err
+		}
+		bw.Close()
+
+		field, err := signer.signHeader(fields, h.Sum(nil))
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.signatures = append(p.signatures, field...)
+	}
+
+	return p, nil
+}
+
+// spooled reports whether attachments of m are spooled.
+func (m *Mail) spooled() bool {
+	for i := range m.Attachments {
+		if m.Attachments[i].File != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	small := []byte("small")
+	large := bytes.Repeat([]byte("large"), 100)
+
+	s := &Spool{Threshold: 100, Dir: dir}
+
+	m := &Mail{Attachments: []Attachment{{Filename: "small.txt", Content: small}}}
+	release, err := s.Spool(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if m.Attachments[0].File != "" || !bytes.Equal(m.Attachments[0].Content, small) {
+		t.Errorf("attachments below the threshold spooled: %+v", m.Attachments[0])
+	}
+
+	m = &Mail{Attachments: []Attachment{
+		{Filename: "small.txt", Content: small},
+		{Filename: "large.txt", Content: large},
+		{Filename: "file.txt", File: "testdata/file.txt"},
+	}}
+	// The caller keeps its own reference to the attachments.
+	caller := m.Attachments
+
+	if release, err = s.Spool(m); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range [][]byte{small, large} {
+		if a := caller[i]; a.File != "" || !bytes.Equal(a.Content, want) {
+			t.Errorf("%s: attachment of the caller changed: %+v", a.Filename, a)
+		}
+
+		a := m.Attachments[i]
+		if a.Content != nil {
+			t.Errorf("%s: content not released", a.Filename)
+		}
+		if filepath.Dir(a.File) != dir {
+			t.Errorf("%s: spooled to %q, not in %q", a.Filename, a.File, dir)
+		}
+		if got, err := ioutil.ReadFile(a.File); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: spooled %q, %v", a.Filename, got, err)
+		}
+	}
+	if m.Attachments[2].File != "testdata/file.txt" {
+		t.Errorf("attachment given by file changed: %+v", m.Attachments[2])
+	}
+
+	release()
+	for _, a := range m.Attachments[:2] {
+		if _, err := os.Stat(a.File); !os.IsNotExist(err) {
+			t.Errorf("%s: file not removed: %v", a.Filename, err)
+		}
+	}
+}
+
+func TestSpoolError(t *testing.T) {
+	content := bytes.Repeat([]byte("large"), 100)
+	s := &Spool{Threshold: 100, Dir: filepath.Join("testdata", "missing")}
+
+	m := &Mail{Attachments: []Attachment{{Filename: "large.txt", Content: content}}}
+	if _, err := s.Spool(m); err == nil {
+		t.Fatal("got no error")
+	}
+	if a := m.Attachments[0]; a.File != "" || !bytes.Equal(a.Content, content) {
+		t.Errorf("attachment changed on failure: %+v", a)
+	}
+}
+
+func TestClientSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	c := NewClient(srv.Addr, WithSpool(100, dir))
+	defer c.Close()
+
+	content := bytes.Repeat([]byte("attachment content "), 100)
+	m := benchMail(1)
+	m.Attachments = []Attachment{{Filename: "data.bin", Content: content}}
+
+	// The same message is sent twice.
+	for i := 0; i < 2; i++ {
+		if err := c.Send(m); err != nil {
+			t.Fatalf("send %d: %v", i+1, err)
+		}
+	}
+
+	if a := m.Attachments[0]; a.File != "" || !bytes.Equal(a.Content, content) {
+		t.Errorf("attachment of the message sent changed: %q", a.File)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d spooled files left", len(files))
+	}
+
+	c.Close()
+	srv.Close()
+
+	msgs := srv.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("server received %d messages, want 2", len(msgs))
+	}
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for i, msg := range msgs {
+		if got := strings.Replace(msg.Data, "\n", "", -1); !strings.Contains(got, encoded) {
+			t.Errorf("message %d: attachment content not sent", i+1)
+		}
+	}
+}
+
+// TestQueueSpool checks that a message with spooled attachments deferred
+// by a quota is sent whole once its window is over.
+func TestQueueSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postman-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	const window = 200 * time.Millisecond
+	c := NewClient(srv.Addr,
+		WithSpool(100, dir),
+		WithQuota(Quota{Messages: 1, Window: window, Defer: true}, nil))
+	defer c.Close()
+
+	q := NewQueue(c)
+	statuses := make(chan Status, 16)
+	q.Watch(statuses)
+	defer q.Unwatch(statuses)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	// Wait for the beginning of a window, for the second message to be
+	// deferred.
+	time.Sleep(time.Until(time.Now().Truncate(window).Add(window)))
+
+	content := bytes.Repeat([]byte("attachment content "), 100)
+	for i := 0; i < 2; i++ {
+		m := benchMail(1)
+		m.Attachments = []Attachment{{Filename: "data.bin", Content: content}}
+		if _, err := q.Enqueue(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var delivered, deferrals int
+	timeout := time.After(5 * time.Second)
+	for delivered < 2 {
+		select {
+		case st := <-statuses:
+			switch st.State {
+			case StateDelivered:
+				delivered++
+			case StateDeferred:
+				deferrals++
+			case StateFailed, StateBounced:
+				t.Fatalf("message %s: %s: %s", st.ID, st.State, st.Error)
+			}
+		case <-timeout:
+			t.Fatalf("%d messages delivered out of 2", delivered)
+		}
+	}
+	if deferrals == 0 {
+		t.Error("no message deferred")
+	}
+
+	c.Close()
+	srv.Close()
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for i, msg := range srv.Messages() {
+		if got := strings.Replace(msg.Data, "\n", "", -1); !strings.Contains(got, encoded) {
+			t.Errorf("message %d: attachment content not sent", i+1)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package postman
+
+import (
+	"time"
+)
+
+// StatsPeriod is the period over which the errors reported by Stats are
+// counted.
+const StatsPeriod = 15 * time.Minute
+
+// ErrorStats counts the deliveries which failed over the last
+// StatsPeriod: with a temporary error, a network error or a 4yz reply,
+// with a permanent error, a 5yz reply, or with any other error.
+type ErrorStats struct {
+	Temporary int `json:"temporary"`
+	Permanent int `json:"permanent"`
+	Other     int `json:"other"`
+}
+
+// ClientStats is a snapshot of the activity of a Client.
+type ClientStats struct {
+	// Open is the number of connections open to the server, Idle and
+	// Authenticated those which are idle in the pool and which are
+	// authenticated.
+	Open          int `json:"open"`
+	Idle          int `json:"idle"`
+	Authenticated int `json:"authenticated"`
+
+	// InFlight is the number of messages being delivered.
+	InFlight int `json:"in_flight"`
+
+	Errors ErrorStats `json:"errors"`
+}
+
+// QueueStats is a snapshot of the activity of a Queue.
+type QueueStats struct {
+	// Urgent, Normal and NonUrgent are the numbers of messages waiting
+	// to be sent, by priority. Deferred is the number of those waiting
+	// for their send window.
+	Urgent    int `json:"urgent"`
+	Normal    int `json:"normal"`
+	NonUrgent int `json:"non_urgent"`
+	Deferred  int `json:"deferred"`
+
+	// InFlight is the number of messages being delivered.
+	InFlight int `json:"in_flight"`
+
+	// Client is the snapshot of the transport of the queue, when it is a
+	// Client.
+	Client *ClientStats `json:"client,omitempty"`
+}
+
+// errorCounter counts errors per minute over the last StatsPeriod.
+type errorCounter struct {
+	buckets [int(StatsPeriod / time.Minute)]errorBucket
+}
+
+type errorBucket struct {
+	minute int64
+	counts ErrorStats
+}
+
+func (c *errorCounter) add(err error, now time.Time) {
+	minute := now.Unix() / 60
+	b := &c.buckets[minute%int64(len(c.buckets))]
+	if b.minute != minute {
+		*b = errorBucket{minute: minute}
+	}
+
+	switch {
+	case isTemporary(err):
+		b.counts.Temporary++
+	case isPermanent(err):
+		b.counts.Permanent++
+	default:
+		b.counts.Other++
+	}
+}
+
+func (c *errorCounter) stats(now time.Time) ErrorStats {
+	minute := now.Unix() / 60
+
+	var s ErrorStats
+	for _, b := range c.buckets {
+		if minute-b.minute < int64(len(c.buckets)) {
+			s.Temporary += b.counts.Temporary
+			s.Permanent += b.counts.Permanent
+			s.Other += b.counts.Other
+		}
+	}
+	return s
+}
+
+// Stats returns a snapshot of the connections and deliveries of the
+// client.
+func (c *Client) Stats() ClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := ClientStats{
+		Open:     len(c.open),
+		Idle:     len(c.idle),
+		InFlight: c.inFlight,
+		Errors:   c.errors.stats(time.Now()),
+	}
+
+	for _, authed := range c.open {
+		if authed {
+			s.Authenticated++
+		}
+	}
+
+	return s
+}
+
+// Stats returns a snapshot of the messages of the queue, and of its
+// transport when it is a Client.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+
+	now := time.Now()
+	s := QueueStats{
+		Urgent:    len(q.lanes[0]),
+		Normal:    len(q.lanes[1]),
+		NonUrgent: len(q.lanes[2]),
+		InFlight:  q.inFlight,
+	}
+
+	for _, lane := range q.lanes {
+		for _, e := range lane {
+			if q.due(e, now).After(now) {
+				s.Deferred++
+			}
+		}
+	}
+
+	q.mu.Unlock()
+
+	if c, ok := q.Transport.(*Client); ok {
+		cs := c.Stats()
+		s.Client = &cs
+	}
+
+	return s
+}
@@ -0,0 +1,111 @@
+package postman
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestErrorCounter(t *testing.T) {
+	var c errorCounter
+
+	now := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	c.add(&textproto.Error{Code: 451, Msg: "4.3.0 Try again later"}, now.Add(-StatsPeriod))
+	c.add(&textproto.Error{Code: 421, Msg: "4.7.0 Too many connections"}, now.Add(-10*time.Minute))
+	c.add(io.EOF, now.Add(-time.Minute))
+	c.add(&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}, now)
+	c.add(errors.New("postman: no recipient"), now)
+
+	// The first error is out of the period.
+	if got, want := c.stats(now), (ErrorStats{Temporary: 2, Permanent: 1, Other: 1}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got, want := c.stats(now.Add(10*time.Minute)), (ErrorStats{Temporary: 1, Permanent: 1, Other: 1}); got != want {
+		t.Errorf("10 minutes later: got %+v, want %+v", got, want)
+	}
+	if got := c.stats(now.Add(StatsPeriod)); got != (ErrorStats{}) {
+		t.Errorf("after the period: got %+v", got)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	s := newTestServer(t, withExtensions("PRDR"), withDataReplies(map[string]string{
+		"rejected@example.com": "550 5.1.1 No such user",
+	}))
+	defer s.Close()
+
+	c := NewClient(s.Addr, WithPool(1))
+	defer c.Close()
+
+	if got := c.Stats(); got != (ClientStats{}) {
+		t.Errorf("new client: got %+v", got)
+	}
+
+	if err := c.Send(benchMail(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Stats(), (ClientStats{Open: 1, Idle: 1}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	rejected := benchMail(1)
+	rejected.To, rejected.Cc = []string{"rejected@example.com"}, nil
+	if err := c.Send(rejected); err == nil {
+		t.Error("rejected message sent")
+	}
+
+	invalid := benchMail(1)
+	invalid.To, invalid.Cc = []string{"not an address"}, nil
+	if err := c.Send(invalid); err == nil {
+		t.Error("message to an invalid address sent")
+	}
+
+	want := ClientStats{Open: 1, Idle: 1, Errors: ErrorStats{Permanent: 1, Other: 1}}
+	if got := c.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	c.Close()
+	if got := c.Stats(); got.Open != 0 || got.Idle != 0 {
+		t.Errorf("closed client: got %+v", got)
+	}
+}
+
+func TestQueueStats(t *testing.T) {
+	c := NewClient("127.0.0.1:0")
+	q := NewQueue(c)
+
+	// The window of the campaign is closed for the next hour.
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := (now.Sub(midnight) + time.Hour) % (24 * time.Hour)
+	q.Windows = map[string]*SendWindow{"newsletter": {Start: start, End: (start + time.Hour) % (24 * time.Hour)}}
+
+	for _, p := range []Priority{PriorityUrgent, PriorityNormal, PriorityNormal, PriorityNonUrgent} {
+		m := benchMail(1)
+		m.MessageID = ""
+		m.Priority = p
+		if p == PriorityNormal {
+			m.Campaign = "newsletter"
+		}
+		if _, err := q.Enqueue(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := QueueStats{Urgent: 1, Normal: 2, NonUrgent: 1, Deferred: 2, Client: &ClientStats{}}
+	got := q.Stats()
+	if got.Client == nil || *got.Client != *want.Client {
+		t.Errorf("client: got %+v", got.Client)
+	}
+	got.Client = want.Client
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got := NewQueue(&outboxTransport{}).Stats(); got != (QueueStats{}) {
+		t.Errorf("queue of another transport: got %+v", got)
+	}
+}
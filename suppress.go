@@ -0,0 +1,129 @@
+package postman
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suppression is an address messages must no longer be sent to.
+type Suppression struct {
+	Address string
+
+	// Reason tells why the address is suppressed, as "unsubscribe" for
+	// the requests handled by an Unsubscriber.
+	Reason string
+
+	// MessageID is the Message-ID of the message the suppression comes
+	// from, if known.
+	MessageID string
+
+	Time time.Time
+}
+
+// SuppressionStore records suppressed addresses. Addresses are compared
+// ignoring case. The first suppression of an address is kept.
+type SuppressionStore interface {
+	Suppress(ctx context.Context, s Suppression) error
+
+	// Suppressed returns the suppression of addr, or nil if it is not
+	// suppressed.
+	Suppressed(ctx context.Context, addr string) (*Suppression, error)
+}
+
+// ErrSuppressed is returned for the messages all the recipients of which
+// are suppressed.
+var ErrSuppressed = errors.New("postman: all recipients are suppressed")
+
+// unsuppressed returns the recipients which are not suppressed by the
+// suppression store of the client.
+func (c *Client) unsuppressed(rcpts []string) ([]string, error) {
+	if c.Suppressions == nil {
+		return rcpts, nil
+	}
+
+	kept := make([]string, 0, len(rcpts))
+	for _, rcpt := range rcpts {
+		s, err := c.Suppressions.Suppressed(context.Background(), rcpt)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			kept = append(kept, rcpt)
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, ErrSuppressed
+	}
+	return kept, nil
+}
+
+// SuppressionList is a SuppressionStore kept in memory. The zero value
+// is an empty list.
+type SuppressionList struct {
+	mu    sync.Mutex
+	addrs map[string]Suppression
+}
+
+func (l *SuppressionList) Suppress(ctx context.Context, s Suppression) error {
+	key := strings.ToLower(s.Address)
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.addrs == nil {
+		l.addrs = make(map[string]Suppression)
+	}
+	if _, ok := l.addrs[key]; !ok {
+		l.addrs[key] = s
+	}
+
+	return nil
+}
+
+func (l *SuppressionList) Suppressed(ctx context.Context, addr string) (*Suppression, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.addrs[strings.ToLower(addr)]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// Suppress records a suppression in the archive, which implements
+// SuppressionStore.
+func (a *Archive) Suppress(ctx context.Context, s Suppression) error {
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+
+	_, err := a.DB.ExecContext(ctx,
+		"INSERT OR IGNORE INTO postman_suppressions (address, reason, message_id, created) VALUES (?, ?, ?, ?)",
+		s.Address, s.Reason, s.MessageID, s.Time.UTC())
+	return err
+}
+
+// Suppressed returns the suppression of addr recorded in the archive.
+func (a *Archive) Suppressed(ctx context.Context, addr string) (*Suppression, error) {
+	s := Suppression{Address: addr}
+
+	err := a.DB.QueryRowContext(ctx,
+		"SELECT reason, message_id, created FROM postman_suppressions WHERE address = ?", addr).
+		Scan(&s.Reason, &s.MessageID, &s.Time)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
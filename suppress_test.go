@@ -0,0 +1,56 @@
+package postman
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClientSuppressions(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	store := new(SuppressionList)
+	store.Suppress(context.Background(), Suppression{Address: "Suppressed@example.com", Reason: "unsubscribe"})
+
+	c := NewClient(s.Addr, WithSuppressions(store))
+	defer c.Close()
+
+	m := &Mail{
+		From:  "sender@example.com",
+		To:    []string{"rcpt@example.com"},
+		Cc:    []string{"suppressed@example.com"},
+		Parts: []Part{{ContentType: "text/plain", Content: []byte("Hello")}},
+	}
+	if err := c.Send(m); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SendRaw("sender@example.com", []string{"suppressed@example.com", "rcpt@example.com"}, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	m.To = nil
+	if err := c.Send(m); err != ErrSuppressed {
+		t.Errorf("Send to suppressed recipients only: got %v, want %v", err, ErrSuppressed)
+	}
+	if err := c.SendRaw("sender@example.com", []string{"SUPPRESSED@example.com"}, raw); err != ErrSuppressed {
+		t.Errorf("SendRaw to suppressed recipients only: got %v, want %v", err, ErrSuppressed)
+	}
+
+	c.Close()
+	s.Close()
+
+	msgs := s.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("server received %d messages, want 2", len(msgs))
+	}
+	for _, msg := range msgs {
+		if len(msg.Rcpts) != 1 || msg.Rcpts[0] != "rcpt@example.com" {
+			t.Errorf("RCPT TO %q, want only rcpt@example.com", msg.Rcpts)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package postman
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// Template composes messages from templates. Any of its templates may be
+// nil.
+type Template struct {
+	Subject *texttemplate.Template
+	Text    *texttemplate.Template
+	HTML    *htmltemplate.Template
+}
+
+// Execute applies the templates to data, setting the subject of m and
+// appending its text and HTML parts, in that order.
+func (t *Template) Execute(m *Mail, data interface{}) error {
+	var buf bytes.Buffer
+
+	if t.Subject != nil {
+		if err := t.Subject.Execute(&buf, data); err != nil {
+			return err
+		}
+		m.Subject = buf.String()
+		buf.Reset()
+	}
+
+	if t.Text != nil {
+		if err := t.Text.Execute(&buf, data); err != nil {
+			return err
+		}
+		m.Parts = append(m.Parts, Part{
+			ContentType: "text/plain",
+			Params:      map[string]string{"charset": "utf-8"},
+			Content:     append([]byte(nil), buf.Bytes()...),
+		})
+		buf.Reset()
+	}
+
+	if t.HTML != nil {
+		if err := t.HTML.Execute(&buf, data); err != nil {
+			return err
+		}
+		m.Parts = append(m.Parts, Part{
+			ContentType: "text/html",
+			Params:      map[string]string{"charset": "utf-8"},
+			Content:     append([]byte(nil), buf.Bytes()...),
+		})
+	}
+
+	return nil
+}
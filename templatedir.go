@@ -0,0 +1,207 @@
+package postman
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// Suffixes of the files of a TemplateDir.
+const (
+	subjectTemplateSuffix = ".subject.tmpl"
+	textTemplateSuffix    = ".text.tmpl"
+	htmlTemplateSuffix    = ".html.tmpl"
+)
+
+// TemplateDir holds the templates of a directory, where the templates
+// named "welcome" are the files welcome.subject.tmpl, welcome.text.tmpl
+// and welcome.html.tmpl, any of which may be missing. The subject is
+// trimmed of surrounding white space, such as the final newline added by
+// editors.
+type TemplateDir struct {
+	Dir string
+
+	mu        sync.RWMutex
+	templates map[string]*Template
+	files     map[string]os.FileInfo
+}
+
+// LoadTemplateDir loads the templates of dir.
+func LoadTemplateDir(dir string) (*TemplateDir, error) {
+	d := &TemplateDir{Dir: dir}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Lookup returns the template with the given name, or nil if there is
+// none.
+func (d *TemplateDir) Lookup(name string) *Template {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.templates[name]
+}
+
+// Execute applies the template with the given name to data, as
+// Template.Execute does.
+func (d *TemplateDir) Execute(m *Mail, name string, data interface{}) error {
+	t := d.Lookup(name)
+	if t == nil {
+		return fmt.Errorf("postman: no template %q in %s", name, d.Dir)
+	}
+	return t.Execute(m, data)
+}
+
+// Reload loads the templates of the directory again. The templates
+// previously loaded are kept if any of them fails to parse.
+func (d *TemplateDir) Reload() error {
+	files, err := d.scan()
+	if err != nil {
+		return err
+	}
+
+	templates, err := d.parse(files)
+
+	// The files are recorded even when they fail to parse, for Watch to
+	// report the error once.
+	d.mu.Lock()
+	d.files = files
+	if err == nil {
+		d.templates = templates
+	}
+	d.mu.Unlock()
+
+	return err
+}
+
+func (d *TemplateDir) parse(files map[string]os.FileInfo) (map[string]*Template, error) {
+	templates := make(map[string]*Template)
+	get := func(name string) *Template {
+		t := templates[name]
+		if t == nil {
+			t = new(Template)
+			templates[name] = t
+		}
+		return t
+	}
+
+	var err error
+	for filename := range files {
+		path := filepath.Join(d.Dir, filename)
+
+		switch {
+		case strings.HasSuffix(filename, subjectTemplateSuffix):
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			t := get(strings.TrimSuffix(filename, subjectTemplateSuffix))
+			if t.Subject, err = texttemplate.New(filename).Parse(strings.TrimSpace(string(content))); err != nil {
+				return nil, err
+			}
+
+		case strings.HasSuffix(filename, textTemplateSuffix):
+			t := get(strings.TrimSuffix(filename, textTemplateSuffix))
+			if t.Text, err = texttemplate.ParseFiles(path); err != nil {
+				return nil, err
+			}
+
+		case strings.HasSuffix(filename, htmlTemplateSuffix):
+			t := get(strings.TrimSuffix(filename, htmlTemplateSuffix))
+			if t.HTML, err = htmltemplate.ParseFiles(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// scan returns the template files of the directory.
+func (d *TemplateDir) scan() (map[string]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]os.FileInfo)
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.Mode().IsRegular() && (strings.HasSuffix(name, subjectTemplateSuffix) ||
+			strings.HasSuffix(name, textTemplateSuffix) || strings.HasSuffix(name, htmlTemplateSuffix)) {
+			files[name] = fi
+		}
+	}
+
+	return files, nil
+}
+
+// changed reports whether template files were added, removed or modified
+// since the last reload.
+func (d *TemplateDir) changed() (bool, error) {
+	files, err := d.scan()
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(files) != len(d.files) {
+		return true, nil
+	}
+
+	for name, fi := range files {
+		old, ok := d.files[name]
+		if !ok || !fi.ModTime().Equal(old.ModTime()) || fi.Size() != old.Size() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Watch checks the directory for changes every interval, one second if
+// zero, and reloads the templates when their files change, until the
+// returned function is called. It is meant for development, to edit the
+// templates of a running application. Errors, such as templates failing
+// to parse, are passed to errorf, when set; the previous templates are
+// then kept.
+func (d *TemplateDir) Watch(interval time.Duration, errorf func(error)) (cancel func()) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				changed, err := d.changed()
+				if err == nil && changed {
+					err = d.Reload()
+				}
+				if err != nil && errorf != nil {
+					errorf(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
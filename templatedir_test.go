@@ -0,0 +1,156 @@
+package postman
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// templateDir returns a directory holding files, by name.
+func templateDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "postman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeFile(t, dir, name, content)
+	}
+	return dir
+}
+
+// executeTemplate returns the subject and the parts produced by the
+// template of d with the given name.
+func executeTemplate(t *testing.T, d *TemplateDir, name string, data interface{}) (string, []Part) {
+	t.Helper()
+
+	var m Mail
+	if err := d.Execute(&m, name, data); err != nil {
+		t.Fatal(err)
+	}
+	return m.Subject, m.Parts
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir := templateDir(t, map[string]string{
+		"welcome.subject.tmpl": "Welcome {{.}}\n",
+		"welcome.text.tmpl":    "Hello {{.}}!\n",
+		"welcome.html.tmpl":    "<p>Hello {{.}}!</p>\n",
+		"reset.text.tmpl":      "Reset your password, {{.}}.\n",
+		"notes.txt":            "{{",
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := LoadTemplateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utf8 := map[string]string{"charset": "utf-8"}
+
+	subject, parts := executeTemplate(t, d, "welcome", "Jane <3")
+	want := []Part{
+		{ContentType: "text/plain", Params: utf8, Content: []byte("Hello Jane <3!\n")},
+		{ContentType: "text/html", Params: utf8, Content: []byte("<p>Hello Jane &lt;3!</p>\n")},
+	}
+	if subject != "Welcome Jane <3" || !reflect.DeepEqual(parts, want) {
+		t.Errorf("welcome: got %q, %+v", subject, parts)
+	}
+
+	// Missing files are missing parts.
+	subject, parts = executeTemplate(t, d, "reset", "Jane")
+	want = []Part{{ContentType: "text/plain", Params: utf8, Content: []byte("Reset your password, Jane.\n")}}
+	if subject != "" || !reflect.DeepEqual(parts, want) {
+		t.Errorf("reset: got %q, %+v", subject, parts)
+	}
+
+	if d.Lookup("notes") != nil {
+		t.Error("template loaded from notes.txt")
+	}
+	err = d.Execute(new(Mail), "missing", nil)
+	if want := `postman: no template "missing" in ` + dir; err == nil || err.Error() != want {
+		t.Errorf("missing template: got %v, want %s", err, want)
+	}
+}
+
+func TestTemplateDirReload(t *testing.T) {
+	dir := templateDir(t, map[string]string{"welcome.subject.tmpl": "Welcome {{.}}"})
+	defer os.RemoveAll(dir)
+
+	d, err := LoadTemplateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "welcome.subject.tmpl", "Welcome aboard {{.}}")
+	writeFile(t, dir, "bye.subject.tmpl", "Bye {{.}}")
+	if err := d.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if subject, _ := executeTemplate(t, d, "welcome", "Jane"); subject != "Welcome aboard Jane" {
+		t.Errorf("welcome: got %q", subject)
+	}
+	if d.Lookup("bye") == nil {
+		t.Error("added template not loaded")
+	}
+
+	// The templates are kept when one fails to parse.
+	writeFile(t, dir, "broken.text.tmpl", "{{.")
+	if err := d.Reload(); err == nil || !strings.Contains(err.Error(), "broken.text.tmpl") {
+		t.Errorf("broken template: got %v", err)
+	}
+	if subject, _ := executeTemplate(t, d, "welcome", "Jane"); subject != "Welcome aboard Jane" {
+		t.Errorf("welcome after a failed reload: got %q", subject)
+	}
+	if d.Lookup("broken") != nil {
+		t.Error("broken template loaded")
+	}
+}
+
+func TestTemplateDirWatch(t *testing.T) {
+	dir := templateDir(t, map[string]string{"welcome.subject.tmpl": "Welcome {{.}}"})
+	defer os.RemoveAll(dir)
+
+	d, err := LoadTemplateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	cancel := d.Watch(10*time.Millisecond, func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	defer cancel()
+
+	// The size changes, whatever the resolution of modification times.
+	writeFile(t, dir, "welcome.subject.tmpl", "Welcome aboard {{.}}")
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if subject, _ := executeTemplate(t, d, "welcome", "Jane"); subject == "Welcome aboard Jane" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("changed template not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Parse errors are reported once.
+	writeFile(t, dir, "broken.text.tmpl", "{{.")
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken.text.tmpl") {
+		t.Errorf("got errors %v, want one about broken.text.tmpl", errs)
+	}
+}
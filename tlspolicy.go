@@ -0,0 +1,182 @@
+package postman
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TLSPolicy tells how the connections to the server are encrypted for a
+// destination, as the TLS policy tables of MTAs do.
+type TLSPolicy struct {
+	Mode TLSMode
+
+	// Pins restricts the certificates accepted for the destination, as
+	// Client.Pins does. Connections are then required to be encrypted.
+	// Only the policy of the server can pin its certificates.
+	Pins []string
+
+	// DANE requires the certificate of the server to match its TLSA
+	// records (RFC 7672), which the resolver of the client must have
+	// validated with DNSSEC. Connections are then required to be
+	// encrypted. Only the policy of the server can require DANE.
+	DANE bool
+}
+
+// ParseTLSPolicy parses a policy: "none", "opportunistic", "required",
+// "implicit", "pinned" followed by pins, or "dane".
+func ParseTLSPolicy(s string) (*TLSPolicy, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("postman: empty TLS policy")
+	}
+
+	p := &TLSPolicy{Mode: TLSRequired}
+
+	switch name := strings.ToLower(fields[0]); name {
+	case "none":
+		p.Mode = TLSDisabled
+	case "pinned":
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("postman: pinned TLS policy without pins")
+		}
+		for _, pin := range fields[1:] {
+			if _, err := parsePin(pin); err != nil {
+				return nil, err
+			}
+		}
+		p.Pins = fields[1:]
+		return p, nil
+	case "dane":
+		p.DANE = true
+	default:
+		mode, err := ParseTLSMode(name)
+		if err != nil {
+			return nil, fmt.Errorf("postman: unknown TLS policy %q", fields[0])
+		}
+		p.Mode = mode
+	}
+
+	if len(fields) > 1 {
+		return nil, fmt.Errorf("postman: invalid TLS policy %q", s)
+	}
+
+	return p, nil
+}
+
+// TLSPolicyMap maps destinations to their TLS policy. Destinations are
+// the domain of the server, whose policy replaces the TLS settings of
+// the client, and the domains of the recipients, whose policies can only
+// make the connections their messages are sent over stricter. Keys are
+// domains, or domains starting with a dot matching all their
+// subdomains, as ".example.com".
+//
+// The client connects to its server whatever the recipients, which is
+// usually a relay rather than their own servers: the pins and the TLSA
+// records of a recipient domain would be checked against the certificate
+// of the relay. The messages to domains whose policy pins certificates
+// or requires DANE therefore fail, unless the policy is the one of the
+// server.
+type TLSPolicyMap map[string]*TLSPolicy
+
+// lookup returns the policy of domain, or nil if it has none.
+func (m TLSPolicyMap) lookup(domain string) *TLSPolicy {
+	if len(m) == 0 {
+		return nil
+	}
+
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if p := m[domain]; p != nil {
+		return p
+	}
+
+	for d := domain; ; {
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			return nil
+		}
+		if p := m[d[i:]]; p != nil {
+			return p
+		}
+		d = d[i+1:]
+	}
+}
+
+// connPolicy is the policy a connection is established with.
+type connPolicy struct {
+	mode TLSMode
+	pins []string
+	dane bool
+}
+
+// require makes the connection encrypted, with STARTTLS unless it already
+// is with implicit TLS.
+func (p *connPolicy) require() {
+	if p.mode == TLSDisabled || p.mode == TLSOpportunistic {
+		p.mode = TLSRequired
+	}
+}
+
+// basePolicy returns the policy of the connections to the server.
+func (c *Client) basePolicy() connPolicy {
+	p := connPolicy{mode: c.TLSMode, pins: c.Pins}
+
+	if tp := c.serverPolicy(); tp != nil {
+		p = connPolicy{mode: tp.Mode, pins: tp.Pins, dane: tp.DANE}
+		// Neither the pins nor the TLSA records can be checked over
+		// a connection that is not encrypted.
+		if len(tp.Pins) > 0 || tp.DANE {
+			p.require()
+		}
+	}
+
+	return p
+}
+
+// serverPolicy returns the policy of the server, or nil if it has none.
+func (c *Client) serverPolicy() *TLSPolicy {
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return nil
+	}
+	return c.TLSPolicies.lookup(host)
+}
+
+// policy returns the policy of the connection m is sent over: the base
+// policy made stricter by the ones of the domains of the recipients and
+// by the TLS requirement of m. It fails if the policy of a recipient
+// domain pins certificates or requires DANE, which cannot be checked
+// against the server.
+func (c *Client) policy(m *Mail, rcpts []string) (connPolicy, error) {
+	p := c.basePolicy()
+
+	if m.Envelope.RequireTLS {
+		p.require()
+	}
+
+	seen := map[*TLSPolicy]bool{c.serverPolicy(): true}
+	for _, rcpt := range rcpts {
+		domain := rcpt[strings.LastIndexByte(rcpt, '@')+1:]
+
+		tp := c.TLSPolicies.lookup(domain)
+		if tp == nil || seen[tp] {
+			continue
+		}
+		seen[tp] = true
+
+		if len(tp.Pins) > 0 || tp.DANE {
+			return p, fmt.Errorf("postman: the TLS policy of %s checks the certificates of its own servers, not of %s", domain, c.Addr)
+		}
+
+		switch tp.Mode {
+		case TLSOpportunistic:
+			if p.mode == TLSDisabled {
+				p.mode = TLSOpportunistic
+			}
+		case TLSRequired, TLSImplicit:
+			p.require()
+		}
+	}
+
+	return p, nil
+}
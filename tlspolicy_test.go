@@ -0,0 +1,187 @@
+package postman
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPin = "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+func TestParseTLSPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *TLSPolicy
+	}{
+		{"none", &TLSPolicy{Mode: TLSDisabled}},
+		{"dane", &TLSPolicy{Mode: TLSRequired, DANE: true}},
+		{"pinned " + testPin, &TLSPolicy{Mode: TLSRequired, Pins: []string{testPin}}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTLSPolicy(tt.in)
+		if err != nil {
+			t.Errorf("ParseTLSPolicy(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTLSPolicy(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := ParseTLSPolicy("pinned"); err == nil {
+		t.Error(`ParseTLSPolicy("pinned"): got no error`)
+	}
+}
+
+func TestBasePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   TLSMode
+		policy *TLSPolicy
+		want   connPolicy
+	}{
+		{"client mode", TLSOpportunistic, nil, connPolicy{mode: TLSOpportunistic}},
+		{"policy mode", TLSOpportunistic, &TLSPolicy{Mode: TLSDisabled}, connPolicy{mode: TLSDisabled}},
+		{
+			"pins", TLSOpportunistic,
+			&TLSPolicy{Mode: TLSOpportunistic, Pins: []string{"a"}},
+			connPolicy{mode: TLSRequired, pins: []string{"a"}},
+		},
+		{
+			"pins with implicit TLS", TLSOpportunistic,
+			&TLSPolicy{Mode: TLSImplicit, Pins: []string{"a"}},
+			connPolicy{mode: TLSImplicit, pins: []string{"a"}},
+		},
+		{
+			"dane", TLSOpportunistic,
+			&TLSPolicy{Mode: TLSOpportunistic, DANE: true},
+			connPolicy{mode: TLSRequired, dane: true},
+		},
+		{
+			"dane without TLS", TLSDisabled,
+			&TLSPolicy{Mode: TLSDisabled, DANE: true},
+			connPolicy{mode: TLSRequired, dane: true},
+		},
+		{
+			"dane with implicit TLS", TLSOpportunistic,
+			&TLSPolicy{Mode: TLSImplicit, DANE: true},
+			connPolicy{mode: TLSImplicit, dane: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Addr: "mx.example.com:25", TLSMode: tt.mode}
+			if tt.policy != nil {
+				c.TLSPolicies = TLSPolicyMap{"mx.example.com": tt.policy}
+			}
+
+			if got := c.basePolicy(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	c := &Client{
+		Addr:    "mx.example.com:25",
+		TLSMode: TLSOpportunistic,
+		TLSPolicies: TLSPolicyMap{
+			"pinned.example":   {Mode: TLSRequired, Pins: []string{"a"}},
+			"dane.example":     {Mode: TLSRequired, DANE: true},
+			".plain.example":   {Mode: TLSDisabled},
+			"secure.example":   {Mode: TLSRequired},
+			"implicit.example": {Mode: TLSImplicit},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rcpts []string
+		want  connPolicy
+	}{
+		{"no policy", []string{"a@other.example"}, connPolicy{mode: TLSOpportunistic}},
+		{"subdomain only", []string{"a@plain.example"}, connPolicy{mode: TLSOpportunistic}},
+		{"disabled", []string{"a@sub.plain.example"}, connPolicy{mode: TLSOpportunistic}},
+		{"required", []string{"a@other.example", "b@secure.example"}, connPolicy{mode: TLSRequired}},
+		{"implicit", []string{"a@implicit.example"}, connPolicy{mode: TLSRequired}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.policy(&Mail{}, tt.rcpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got, _ := c.policy(&Mail{Envelope: Envelope{RequireTLS: true}}, []string{"a@other.example"}); got.mode != TLSRequired {
+		t.Errorf("REQUIRETLS message: got %+v", got)
+	}
+
+	// The pins and the TLSA records of recipient domains would be checked
+	// against the certificate of the server.
+	for _, rcpt := range []string{"a@pinned.example", "a@dane.example"} {
+		_, err := c.policy(&Mail{}, []string{"b@other.example", rcpt})
+		if err == nil || !strings.Contains(err.Error(), "mx.example.com:25") {
+			t.Errorf("%s: got %v, want an error", rcpt, err)
+		}
+	}
+}
+
+// TestPolicyServer checks that the policy of the server, which is the
+// host the client connects to, pins its certificates, including for the
+// recipients it matches.
+func TestPolicyServer(t *testing.T) {
+	c := &Client{
+		Addr:    "mx.example.com:25",
+		TLSMode: TLSOpportunistic,
+		Pins:    []string{"client"},
+		TLSPolicies: TLSPolicyMap{
+			".example.com": {Mode: TLSOpportunistic, Pins: []string{"server"}},
+		},
+	}
+
+	want := connPolicy{mode: TLSRequired, pins: []string{"server"}}
+	got, err := c.policy(&Mail{}, []string{"a@sub.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Without a policy, the pins of the client apply.
+	c.TLSPolicies = nil
+	if got, _ := c.policy(&Mail{}, []string{"a@sub.example.com"}); !reflect.DeepEqual(got, connPolicy{mode: TLSOpportunistic, pins: []string{"client"}}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestClientRecipientPins checks that a message to a domain whose policy
+// pins the certificates of its servers is not sent through a relay.
+func TestClientRecipientPins(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	c := NewClient(s.Addr)
+	c.TLSPolicies = TLSPolicyMap{"example.com": {Mode: TLSRequired, Pins: []string{testPin}}}
+	defer c.Close()
+
+	err := c.Send(benchMail(1))
+	if err == nil || !strings.Contains(err.Error(), "TLS policy of example.com") {
+		t.Errorf("got %v, want an error", err)
+	}
+
+	c.Close()
+	s.Close()
+	if cmds := s.Commands(); len(cmds) != 0 {
+		t.Errorf("commands sent: %q", cmds)
+	}
+}
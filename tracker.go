@@ -0,0 +1,234 @@
+package postman
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is a step in the lifecycle of a message.
+type State string
+
+const (
+	// StateQueued messages wait in a Queue.
+	StateQueued State = "queued"
+
+	// StateSending messages are being transmitted to the server.
+	StateSending State = "sending"
+
+	// StateDelivered messages were accepted by the server, or reported
+	// delivered by a DSN.
+	StateDelivered State = "delivered"
+
+	// StateDeferred messages failed temporarily, with a 4yz reply, a
+	// network error or a delayed DSN; they may still be delivered.
+	StateDeferred State = "deferred"
+
+	// StateBounced messages were rejected with a 5yz reply, or reported
+	// failed by a DSN.
+	StateBounced State = "bounced"
+
+	// StateComplained messages were reported as spam by a recipient.
+	StateComplained State = "complained"
+
+	// StateFailed messages could not be sent for another reason, such as
+	// an invalid address.
+	StateFailed State = "failed"
+)
+
+// Status is the delivery status of a message.
+type Status struct {
+	ID       string    `json:"id"`
+	State    State     `json:"state"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	Queued   time.Time `json:"queued"`
+	Updated  time.Time `json:"updated"`
+}
+
+// ErrUnknownMessage is returned for identifiers which do not match any
+// message.
+var ErrUnknownMessage = errors.New("postman: unknown message")
+
+// DefaultRetention is how long a Tracker remembers a message after its
+// last update, unless told otherwise.
+const DefaultRetention = 24 * time.Hour
+
+// Tracker follows messages through their lifecycle. Messages are
+// identified by their Message-ID without angle brackets, which is how
+// delivery status notifications and feedback reports refer to them.
+//
+// The Queue and the Client update it as they send messages, ApplyDSN and
+// ApplyFeedbackReport as reports come back; parsers of the webhooks of
+// other providers may call Update. It is safe for concurrent use.
+type Tracker struct {
+	// Retention is how long messages are kept after their last update.
+	// Zero means DefaultRetention.
+	Retention time.Duration
+
+	mu       sync.Mutex
+	statuses map[string]*list.Element
+	watchers map[chan<- Status]bool
+
+	// order holds the statuses from the least recently updated, for
+	// purges to only look at the expired ones.
+	order list.List
+}
+
+// TrackingID returns the identifier of the message with the given
+// Message-ID in a Tracker.
+func TrackingID(msgID string) string {
+	return strings.Trim(angleBracket(msgID), "<>")
+}
+
+// Status returns the status of the message with the given identifier.
+func (t *Tracker) Status(id string) (Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.statuses[id]
+	if !ok {
+		return Status{}, ErrUnknownMessage
+	}
+
+	return *e.Value.(*Status), nil
+}
+
+// Update sets the state of a message, errMsg explaining failures. Unknown
+// messages are added, since reports may come back after a restart.
+func (t *Tracker) Update(id string, state State, errMsg string) {
+	t.update(id, func(s *Status) {
+		s.State = state
+		s.Error = errMsg
+	})
+}
+
+// Watch relays every status change to c. Like signal.Notify, Tracker
+// does not block sending to c: the caller must ensure c has sufficient
+// buffer space to keep up.
+func (t *Tracker) Watch(c chan<- Status) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.watchers == nil {
+		t.watchers = make(map[chan<- Status]bool)
+	}
+	t.watchers[c] = true
+}
+
+// Unwatch stops relaying status changes to c.
+func (t *Tracker) Unwatch(c chan<- Status) {
+	t.mu.Lock()
+	delete(t.watchers, c)
+	t.mu.Unlock()
+}
+
+// queue adds a message in the queued state.
+func (t *Tracker) queue(id string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.purge(now)
+
+	if e, ok := t.statuses[id]; ok {
+		t.order.Remove(e)
+	}
+
+	s := &Status{ID: id, State: StateQueued, Queued: now, Updated: now}
+	t.add(s)
+	t.broadcast(s)
+}
+
+// attempt marks a message as being sent.
+func (t *Tracker) attempt(id string) {
+	t.update(id, func(s *Status) {
+		s.State = StateSending
+		s.Error = ""
+		s.Attempts++
+	})
+}
+
+// done records the outcome of an attempt.
+func (t *Tracker) done(id string, err error) {
+	t.update(id, func(s *Status) {
+		s.State, s.Error = StateDelivered, ""
+		if err != nil {
+			s.State, s.Error = failureState(err), err.Error()
+		}
+	})
+}
+
+func (t *Tracker) update(id string, f func(*Status)) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var s *Status
+	if e, ok := t.statuses[id]; ok {
+		s = e.Value.(*Status)
+		t.order.MoveToBack(e)
+	} else {
+		t.purge(now)
+
+		s = &Status{ID: id, Queued: now}
+		t.add(s)
+	}
+
+	f(s)
+	s.Updated = now
+	t.broadcast(s)
+}
+
+// add tracks a new status, as the most recently updated. The lock must
+// be held.
+func (t *Tracker) add(s *Status) {
+	if t.statuses == nil {
+		t.statuses = make(map[string]*list.Element)
+	}
+	t.statuses[s.ID] = t.order.PushBack(s)
+}
+
+// broadcast relays s to the watchers. The lock must be held.
+func (t *Tracker) broadcast(s *Status) {
+	for c := range t.watchers {
+		select {
+		case c <- *s:
+		default:
+		}
+	}
+}
+
+// purge forgets the messages past their retention. The lock must be
+// held.
+func (t *Tracker) purge(now time.Time) {
+	retention := t.Retention
+	if retention == 0 {
+		retention = DefaultRetention
+	}
+
+	for e := t.order.Front(); e != nil; e = t.order.Front() {
+		s := e.Value.(*Status)
+		if now.Sub(s.Updated) <= retention {
+			return
+		}
+		t.order.Remove(e)
+		delete(t.statuses, s.ID)
+	}
+}
+
+// failureState returns the state of a message whose delivery failed with
+// err.
+func failureState(err error) State {
+	if isTemporary(err) {
+		return StateDeferred
+	}
+	if isPermanent(err) {
+		return StateBounced
+	}
+	return StateFailed
+}
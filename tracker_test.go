@@ -0,0 +1,226 @@
+package postman
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+// watched returns the statuses relayed to c so far.
+func watched(c chan Status) []Status {
+	var statuses []Status
+	for {
+		select {
+		case s := <-c:
+			statuses = append(statuses, s)
+		default:
+			return statuses
+		}
+	}
+}
+
+func TestTrackerLifecycle(t *testing.T) {
+	var tr Tracker
+	c := make(chan Status, 16)
+	tr.Watch(c)
+
+	temporary := &textproto.Error{Code: 451, Msg: "4.3.0 Try again later"}
+
+	tr.queue("m1@postman.test")
+	tr.attempt("m1@postman.test")
+	tr.done("m1@postman.test", temporary)
+	tr.attempt("m1@postman.test")
+	tr.done("m1@postman.test", nil)
+
+	want := []struct {
+		state    State
+		attempts int
+		err      string
+	}{
+		{StateQueued, 0, ""},
+		{StateSending, 1, ""},
+		{StateDeferred, 1, temporary.Error()},
+		{StateSending, 2, ""},
+		{StateDelivered, 2, ""},
+	}
+
+	statuses := watched(c)
+	if len(statuses) != len(want) {
+		t.Fatalf("%d statuses relayed, want %d: %+v", len(statuses), len(want), statuses)
+	}
+	for i, s := range statuses {
+		if s.ID != "m1@postman.test" || s.State != want[i].state || s.Attempts != want[i].attempts || s.Error != want[i].err {
+			t.Errorf("status %d: %+v, want %+v", i, s, want[i])
+		}
+		if !s.Queued.Equal(statuses[0].Queued) || s.Updated.Before(s.Queued) || (i > 0 && s.Updated.Before(statuses[i-1].Updated)) {
+			t.Errorf("status %d queued at %s and updated at %s", i, s.Queued, s.Updated)
+		}
+	}
+
+	if s, err := tr.Status("m1@postman.test"); err != nil || s != statuses[len(statuses)-1] {
+		t.Errorf("Status = %+v, %v, want %+v", s, err, statuses[len(statuses)-1])
+	}
+
+	// Queuing a message again starts its lifecycle over.
+	tr.queue("m1@postman.test")
+	if s, _ := tr.Status("m1@postman.test"); s.State != StateQueued || s.Attempts != 0 {
+		t.Errorf("queued again: %+v", s)
+	}
+
+	watched(c)
+	tr.Unwatch(c)
+	tr.attempt("m1@postman.test")
+	if statuses := watched(c); len(statuses) != 0 {
+		t.Errorf("%d statuses relayed after Unwatch", len(statuses))
+	}
+
+	if _, err := tr.Status("unknown@postman.test"); err != ErrUnknownMessage {
+		t.Errorf("unknown message: got %v, want %v", err, ErrUnknownMessage)
+	}
+}
+
+func TestTrackerFailureStates(t *testing.T) {
+	tests := []struct {
+		err   error
+		state State
+	}{
+		{&textproto.Error{Code: 421, Msg: "4.7.0 Too many connections"}, StateDeferred},
+		{&textproto.Error{Code: 550, Msg: "5.1.1 Unknown user"}, StateBounced},
+		{errors.New("mail: missing '@' or angle-addr"), StateFailed},
+	}
+
+	var tr Tracker
+	for _, tt := range tests {
+		tr.attempt("m1@postman.test")
+		tr.done("m1@postman.test", tt.err)
+
+		s, err := tr.Status("m1@postman.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State != tt.state || s.Error != tt.err.Error() {
+			t.Errorf("%v: %s with %q, want %s", tt.err, s.State, s.Error, tt.state)
+		}
+	}
+}
+
+// TestTrackerReports checks the states reported after the delivery: a
+// deferral later delivered, and a complaint.
+func TestTrackerReports(t *testing.T) {
+	var tr Tracker
+	c := make(chan Status, 16)
+	tr.Watch(c)
+
+	tr.queue("m1@postman.test")
+	tr.attempt("m1@postman.test")
+	tr.done("m1@postman.test", nil)
+
+	delayed := DSNRecipient{FinalRecipient: "rcpt@example.com", Action: "delayed", Status: "4.4.1", DiagnosticCode: "smtp; 421 connection timed out"}
+	if err := tr.ApplyDSN(&DSN{OriginalMessageID: "m1@postman.test", Recipients: []DSNRecipient{delayed}}); err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := DSNRecipient{FinalRecipient: "rcpt@example.com", Action: "delivered", Status: "2.0.0"}
+	if err := tr.ApplyDSN(&DSN{OriginalMessageID: "m1@postman.test", Recipients: []DSNRecipient{delivered}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.ApplyFeedbackReport(&FeedbackReport{FeedbackType: "abuse", UserAgent: "Mailbox/1.0", OriginalMessageID: "m1@postman.test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		state State
+		err   string
+	}{
+		{StateQueued, ""},
+		{StateSending, ""},
+		{StateDelivered, ""},
+		{StateDeferred, "rcpt@example.com: 4.4.1 smtp; 421 connection timed out"},
+		{StateDelivered, ""},
+		{StateComplained, "abuse report from Mailbox/1.0"},
+	}
+
+	statuses := watched(c)
+	if len(statuses) != len(want) {
+		t.Fatalf("%d statuses relayed, want %d: %+v", len(statuses), len(want), statuses)
+	}
+	for i, s := range statuses {
+		if s.State != want[i].state || (want[i].err != "" && s.Error != want[i].err) || (want[i].err == "" && s.Error != "") {
+			t.Errorf("status %d: %s with %q, want %s with %q", i, s.State, s.Error, want[i].state, want[i].err)
+		}
+	}
+
+	// Reports about unknown messages, as after a restart, add them.
+	tr.Update("m2@postman.test", StateBounced, "5.1.1")
+	if s, err := tr.Status("m2@postman.test"); err != nil || s.State != StateBounced {
+		t.Errorf("Status = %+v, %v", s, err)
+	}
+}
+
+func TestTrackerRetention(t *testing.T) {
+	tr := Tracker{Retention: 100 * time.Millisecond}
+
+	tr.queue("old@postman.test")
+	tr.queue("updated@postman.test")
+	time.Sleep(60 * time.Millisecond)
+	tr.attempt("updated@postman.test")
+	time.Sleep(60 * time.Millisecond)
+
+	// Statuses are purged as others are added.
+	tr.queue("new@postman.test")
+
+	if _, err := tr.Status("old@postman.test"); err != ErrUnknownMessage {
+		t.Errorf("expired message: got %v, want %v", err, ErrUnknownMessage)
+	}
+	for _, id := range []string{"updated@postman.test", "new@postman.test"} {
+		if _, err := tr.Status(id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+}
+
+// TestQueueTracker checks the states a queue goes through for a message
+// deferred, then delivered once its deferral is reported over.
+func TestQueueTracker(t *testing.T) {
+	transport := &outboxTransport{errs: []error{&textproto.Error{Code: 451, Msg: "4.3.0 Try again later"}}}
+	q := NewQueue(transport)
+
+	c := make(chan Status, 16)
+	q.Watch(c)
+	defer q.Unwatch(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	id, err := q.Enqueue(benchMail(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "bench@postman.test" {
+		t.Errorf("tracked as %s", id)
+	}
+
+	want := []State{StateQueued, StateSending, StateDeferred}
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < len(want); i++ {
+		select {
+		case s := <-c:
+			if s.ID != id || s.State != want[i] {
+				t.Errorf("status %d: %s of %s, want %s", i, s.State, s.ID, want[i])
+			}
+		case <-timeout:
+			t.Fatalf("%d statuses relayed, want %d", i, len(want))
+		}
+	}
+
+	if err := q.Tracker.ApplyDSN(&DSN{OriginalMessageID: id, Recipients: []DSNRecipient{{Action: "delivered"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := q.Status(id); err != nil || s.State != StateDelivered || s.Attempts != 1 {
+		t.Errorf("Status = %+v, %v, want delivered after 1 attempt", s, err)
+	}
+}
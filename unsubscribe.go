@@ -0,0 +1,139 @@
+package postman
+
+import (
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// Unsubscriber handles the unsubscribe requests of the recipients of a
+// list, recording them in a suppression store: the one-click POST
+// requests (RFC 8058) to its endpoint, served by ServeHTTP, and the
+// messages sent to its mailto address, passed by the caller to
+// HandleMail. Headers adds the List-Unsubscribe fields pointing to them.
+type Unsubscriber struct {
+	// URL is the endpoint served by the handler, as seen by recipients.
+	URL string
+
+	// MailTo is the address unsubscribe messages are sent to, if any.
+	MailTo string
+
+	// Signer signs the links, so that the handler trusts the recipient
+	// they give.
+	Signer *URLSigner
+
+	Store SuppressionStore
+}
+
+// Headers sets the List-Unsubscribe and List-Unsubscribe-Post fields of
+// m, sent to rcpt alone, since the links carry a token specific to the
+// recipient and to the Message-ID, which m must have.
+func (u *Unsubscriber) Headers(m *Mail, rcpt string) error {
+	if m.MessageID == "" {
+		return errors.New("postman: unsubscribe links without Message-ID")
+	}
+
+	link, err := u.Signer.Sign(u.URL, m.MessageID, rcpt)
+	if err != nil {
+		return err
+	}
+
+	links := "<" + link + ">"
+
+	if u.MailTo != "" {
+		// The token is carried by the subject of the messages.
+		signed, err := u.Signer.Sign("", m.MessageID, rcpt)
+		if err != nil {
+			return err
+		}
+		subject := "unsubscribe " + strings.TrimPrefix(signed, "?")
+		links = "<mailto:" + u.MailTo + "?subject=" + strings.Replace(url.QueryEscape(subject), "+", "%20", -1) + ">, " + links
+	}
+
+	if m.Header == nil {
+		m.Header = make(textproto.MIMEHeader)
+	}
+	m.Header.Set("List-Unsubscribe", links)
+	m.Header.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+
+	return nil
+}
+
+var unsubscribePage = htmltemplate.Must(htmltemplate.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<form method="post" action="{{.}}">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+// ServeHTTP suppresses the recipient of a signed link on POST requests.
+// GET requests, which link scanners send as well, only get a page asking
+// for confirmation.
+func (u *Unsubscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, rcpt, err := u.Signer.Verify(r.URL)
+	if err != nil {
+		http.Error(w, "Invalid unsubscribe link", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		err := u.Store.Suppress(r.Context(), Suppression{
+			Address:   rcpt,
+			Reason:    "unsubscribe",
+			MessageID: angleBracket(id),
+		})
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("You have been unsubscribed.\n"))
+
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		unsubscribePage.Execute(w, r.URL.RequestURI())
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleMail suppresses the recipient the token of the subject of a
+// message received at the mailto address was signed for. Messages
+// without a valid token, such as those written by hand, are not trusted,
+// their author being easily forged: an error is returned for them.
+// Automatic replies, as told by IsAutoReply, are ignored.
+func (u *Unsubscriber) HandleMail(ctx context.Context, m *Mail) error {
+	if m.IsAutoReply() {
+		return nil
+	}
+
+	// The token is the last word of the subject.
+	var q url.Values
+	if fields := strings.Fields(m.Subject); len(fields) > 0 {
+		q, _ = url.ParseQuery(fields[len(fields)-1])
+	}
+
+	id, rcpt, err := u.Signer.Verify(&url.URL{RawQuery: q.Encode()})
+	if err != nil {
+		return err
+	}
+
+	return u.Store.Suppress(ctx, Suppression{
+		Address:   rcpt,
+		Reason:    "unsubscribe",
+		MessageID: angleBracket(id),
+	})
+}
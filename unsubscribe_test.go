@@ -0,0 +1,112 @@
+package postman
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestUnsubscriber() *Unsubscriber {
+	return &Unsubscriber{
+		URL:    "https://example.com/unsubscribe",
+		MailTo: "unsubscribe@example.com",
+		Signer: &URLSigner{Secret: []byte("secret")},
+		Store:  new(SuppressionList),
+	}
+}
+
+// mailtoSubject returns the subject of the mailto link of the
+// List-Unsubscribe field of m.
+func mailtoSubject(t *testing.T, m *Mail) string {
+	t.Helper()
+
+	links := m.Header.Get("List-Unsubscribe")
+	start := strings.Index(links, "<mailto:")
+	end := strings.IndexByte(links[start:], '>')
+	u, err := url.Parse(links[start+1 : start+end])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query().Get("subject")
+}
+
+func TestUnsubscriberHandleMail(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUnsubscriber()
+
+	m := &Mail{MessageID: "<list@example.com>"}
+	if err := u.Headers(m, "rcpt@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	subject := mailtoSubject(t, m)
+
+	tests := []struct {
+		name    string
+		mail    Mail
+		wantErr bool
+	}{
+		{"unsigned", Mail{From: "victim@example.com", Subject: "unsubscribe"}, true},
+		{"forged", Mail{From: "victim@example.com", Subject: strings.Replace(subject, "rcpt%40", "victim%40", 1)}, true},
+		{"auto reply", Mail{From: "victim@example.com", Subject: "unsubscribe", Header: map[string][]string{"Auto-Submitted": {"auto-replied"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.HandleMail(ctx, &tt.mail)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HandleMail: got %v, want error: %t", err, tt.wantErr)
+			}
+			if s, _ := u.Store.Suppressed(ctx, "victim@example.com"); s != nil {
+				t.Errorf("victim@example.com suppressed: %+v", s)
+			}
+		})
+	}
+
+	// Signed tokens are honored whoever sends them.
+	if err := u.HandleMail(ctx, &Mail{From: "other@example.com", Subject: subject}); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := u.Store.Suppressed(ctx, "rcpt@example.com"); s == nil || s.Reason != "unsubscribe" {
+		t.Errorf("rcpt@example.com: got suppression %+v", s)
+	}
+	if s, _ := u.Store.Suppressed(ctx, "other@example.com"); s != nil {
+		t.Errorf("other@example.com suppressed: %+v", s)
+	}
+}
+
+func TestUnsubscriberServeHTTP(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUnsubscriber()
+
+	link, err := u.Signer.Sign(u.URL, "<list@example.com>", "rcpt@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, url string
+		code        int
+		suppressed  bool
+	}{
+		{http.MethodGet, link, http.StatusOK, false},
+		{http.MethodPost, u.URL + "?rcpt=rcpt%40example.com", http.StatusForbidden, false},
+		{http.MethodPost, link, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		u.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, strings.NewReader("List-Unsubscribe=One-Click")))
+		if w.Code != tt.code {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.url, w.Code, tt.code)
+		}
+
+		s, _ := u.Store.Suppressed(ctx, "rcpt@example.com")
+		if (s != nil) != tt.suppressed {
+			t.Errorf("%s %s: got suppression %+v, want suppressed: %t", tt.method, tt.url, s, tt.suppressed)
+		}
+	}
+}
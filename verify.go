@@ -0,0 +1,84 @@
+package postman
+
+import (
+	"errors"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	// ErrCannotVerify is returned by Verify and Expand when the server
+	// does not tell whether the address is valid, but would attempt to
+	// deliver messages to it (252 reply).
+	ErrCannotVerify = errors.New("postman: server cannot verify the address")
+
+	// ErrNotImplemented is returned by Verify and Expand when the server
+	// does not implement the command or has disabled it (502 reply), as
+	// most public servers do.
+	ErrNotImplemented = errors.New("postman: command not implemented by the server")
+)
+
+// Verify asks the server whether addr is a valid mailbox with the VRFY
+// command, and returns the mailbox given in its reply, usually with the
+// name of its owner, as in "Jane Doe <jane@example.com>".
+func (c *Client) Verify(addr string) (string, error) {
+	lines, err := c.query("VRFY", addr)
+	if err != nil {
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// Expand asks the server for the members of the mailing list with the
+// EXPN command.
+func (c *Client) Expand(list string) ([]string, error) {
+	return c.query("EXPN", list)
+}
+
+// query sends a VRFY or EXPN command and returns the lines of its reply.
+func (c *Client) query(verb, arg string) ([]string, error) {
+	if strings.ContainsAny(arg, "\r\n") {
+		return nil, errors.New("smtp: A line must not contain CR or LF")
+	}
+
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	code, msg, err := queryConn(conn, verb, arg)
+	if _, ok := err.(*textproto.Error); err != nil && !ok {
+		c.close(conn)
+		return nil, err
+	}
+
+	c.put(conn)
+
+	switch {
+	case code == 252:
+		return nil, ErrCannotVerify
+	case code == 502:
+		return nil, ErrNotImplemented
+	case err != nil:
+		return nil, err
+	}
+
+	return strings.Split(msg, "\n"), nil
+}
+
+func queryConn(conn *smtp.Client, verb, arg string) (int, string, error) {
+	// Extension sends EHLO if it has not been yet.
+	conn.Extension(verb)
+
+	text := conn.Text
+
+	id, err := text.Cmd("%s %s", verb, arg)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	return text.ReadResponse(25)
+}
@@ -0,0 +1,246 @@
+package postman
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// subscriptionBuffer is the number of status changes buffered for a
+// callback registered with Subscribe.
+const subscriptionBuffer = 1024
+
+// Subscribe calls f, from a goroutine of its own, for every status change
+// tracked by t until the returned function is called. Changes are dropped
+// if f falls more than 1024 changes behind.
+func (t *Tracker) Subscribe(f func(Status)) (cancel func()) {
+	c := make(chan Status, subscriptionBuffer)
+	done := make(chan struct{})
+
+	t.Watch(c)
+
+	go func() {
+		for {
+			select {
+			case s := <-c:
+				f(s)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		t.Unwatch(c)
+		close(done)
+	}
+}
+
+// Event is the payload posted by a Webhook for a status change.
+type Event struct {
+	// ID identifies the event, for receivers to discard duplicates.
+	ID string `json:"id"`
+
+	// Type is "message." followed by the new state of the message, as in
+	// "message.bounced".
+	Type string `json:"type"`
+
+	Time    time.Time `json:"time"`
+	Message Status    `json:"message"`
+}
+
+// WebhookSignatureField is the header field holding the signature of the
+// events posted by a Webhook, as in "t=1700000000,v1=5257a869...": t is
+// the Unix time of the signature and v1 the hex encoded HMAC-SHA256 of t,
+// a dot, and the body.
+const WebhookSignatureField = "Postman-Signature"
+
+// DefaultWebhookTimeout bounds each attempt of a Webhook to post an
+// event, unless told otherwise.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Webhook posts status changes as JSON events to an HTTP endpoint.
+type Webhook struct {
+	URL string
+
+	// Secret signs the events; no signature is sent if empty.
+	Secret []byte
+
+	// Client posts the events. Nil means http.DefaultClient.
+	Client *http.Client
+
+	// Timeout bounds each attempt, whatever the timeout of Client, for a
+	// slow endpoint not to hold the events following. Zero means
+	// DefaultWebhookTimeout.
+	Timeout time.Duration
+
+	// Attempts is the number of deliveries attempted for an event when
+	// the endpoint fails or replies with a 5xx status. Zero means 3.
+	Attempts int
+
+	// Backoff is the delay before the first retry, doubled after each
+	// attempt. Zero means one second.
+	Backoff time.Duration
+}
+
+// Follow posts the status changes of t until the returned function is
+// called, which aborts the post in progress. Errors are passed to errorf,
+// when set.
+func (w *Webhook) Follow(t *Tracker, errorf func(error)) (cancel func()) {
+	ctx, stop := context.WithCancel(context.Background())
+
+	unsubscribe := t.Subscribe(func(s Status) {
+		if err := w.PostContext(ctx, s); err != nil && ctx.Err() == nil && errorf != nil {
+			errorf(err)
+		}
+	})
+
+	return func() {
+		stop()
+		unsubscribe()
+	}
+}
+
+// Post posts the event of a status change.
+func (w *Webhook) Post(s Status) error {
+	return w.PostContext(context.Background(), s)
+}
+
+// PostContext posts the event of a status change, giving up when ctx is
+// done.
+func (w *Webhook) PostContext(ctx context.Context, s Status) error {
+	id, err := genID()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(Event{
+		ID:      id,
+		Type:    "message." + string(s.State),
+		Time:    s.Updated,
+		Message: s,
+	})
+	if err != nil {
+		return err
+	}
+
+	attempts := w.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil || !retry || attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post posts body once, and reports whether a failure may be retried.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(w.Secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookSignatureField, "t="+ts+",v1="+webhookMAC(w.Secret, ts, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() != context.Canceled, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode >= 500, fmt.Errorf("postman: webhook %s: %s", w.URL, resp.Status)
+	}
+
+	return false, nil
+}
+
+// VerifyWebhookSignature checks the value of the Postman-Signature field
+// of an event against its body, rejecting signatures older than
+// tolerance, if positive, to prevent replays.
+func VerifyWebhookSignature(secret []byte, signature string, body []byte, tolerance time.Duration) error {
+	var ts, mac string
+	for _, kv := range strings.Split(signature, ",") {
+		switch {
+		case strings.HasPrefix(kv, "t="):
+			ts = kv[2:]
+		case strings.HasPrefix(kv, "v1="):
+			mac = kv[3:]
+		}
+	}
+
+	if ts == "" || mac == "" {
+		return errors.New("postman: malformed webhook signature")
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(webhookMAC(secret, ts, body))) {
+		return errors.New("postman: invalid webhook signature")
+	}
+
+	if tolerance > 0 {
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return errors.New("postman: malformed webhook signature")
+		}
+		if d := time.Since(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+			return errors.New("postman: expired webhook signature")
+		}
+	}
+
+	return nil
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(ts))
+	h.Write([]byte{'.'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,229 @@
+package postman
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRequest is a request received by a webhookServer.
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookServer is an endpoint replying with the given statuses in turn,
+// then with 204.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []webhookRequest
+}
+
+func newWebhookServer(statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, webhookRequest{header: r.Header, body: body})
+		status := http.StatusNoContent
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *webhookServer) received() []webhookRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webhookRequest(nil), s.requests...)
+}
+
+func TestWebhookPost(t *testing.T) {
+	s := newWebhookServer(http.StatusServiceUnavailable, http.StatusBadGateway)
+	defer s.Close()
+
+	secret := []byte("secret")
+	w := &Webhook{URL: s.URL, Secret: secret, Backoff: time.Millisecond}
+
+	updated := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	status := Status{
+		ID:       "m1@postman.test",
+		State:    StateBounced,
+		Attempts: 1,
+		Error:    "550 5.1.1 Unknown user",
+		Queued:   updated.Add(-time.Minute),
+		Updated:  updated,
+	}
+
+	before := time.Now().Unix()
+	if err := w.Post(status); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event is posted again, as it is, on 5xx replies.
+	reqs := s.received()
+	if len(reqs) != 3 {
+		t.Fatalf("%d requests received, want 3", len(reqs))
+	}
+	for _, req := range reqs[1:] {
+		if string(req.body) != string(reqs[0].body) {
+			t.Errorf("retried with\n%s\nfirst posted\n%s", req.body, reqs[0].body)
+		}
+	}
+
+	req := reqs[0]
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type: %s", ct)
+	}
+
+	var event struct {
+		ID      string          `json:"id"`
+		Type    string          `json:"type"`
+		Time    string          `json:"time"`
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID == "" || event.Type != "message.bounced" || event.Time != "2001-02-03T04:05:06Z" {
+		t.Errorf("got event %+v", event)
+	}
+	const message = `{"id":"m1@postman.test","state":"bounced","attempts":1,"error":"550 5.1.1 Unknown user","queued":"2001-02-03T04:04:06Z","updated":"2001-02-03T04:05:06Z"}`
+	if string(event.Message) != message {
+		t.Errorf("got message\n%s\nwant\n%s", event.Message, message)
+	}
+
+	sig := req.header.Get(WebhookSignatureField)
+	parts := strings.Split(sig, ",")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") || !strings.HasPrefix(parts[1], "v1=") {
+		t.Fatalf("%s: %s", WebhookSignatureField, sig)
+	}
+	ts, err := strconv.ParseInt(parts[0][2:], 10, 64)
+	if err != nil || ts < before || ts > time.Now().Unix() {
+		t.Errorf("signed at %s", parts[0][2:])
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0][2:] + "."))
+	mac.Write(req.body)
+	if want := hex.EncodeToString(mac.Sum(nil)); parts[1][3:] != want {
+		t.Errorf("signature %s, want %s", parts[1][3:], want)
+	}
+
+	if err := VerifyWebhookSignature(secret, sig, req.body, time.Minute); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+	}{
+		// Client errors are not retried.
+		{"client error", []int{http.StatusBadRequest}, 1},
+		{"attempts exhausted", []int{500, 500, 500, 500}, 3},
+	}
+
+	for _, tt := range tests {
+		s := newWebhookServer(tt.statuses...)
+
+		w := &Webhook{URL: s.URL, Backoff: time.Millisecond}
+		err := w.Post(Status{ID: "m1@postman.test", State: StateDelivered})
+		if err == nil || !strings.HasPrefix(err.Error(), "postman: webhook "+s.URL+": ") {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+
+		reqs := s.received()
+		if len(reqs) != tt.requests {
+			t.Errorf("%s: %d requests received, want %d", tt.name, len(reqs), tt.requests)
+		}
+		// Unsigned without a secret.
+		if sig := reqs[0].header.Get(WebhookSignatureField); sig != "" {
+			t.Errorf("%s: signed %s", tt.name, sig)
+		}
+
+		s.Close()
+	}
+}
+
+// TestWebhookFollow checks that the status changes of a tracker are
+// posted.
+func TestWebhookFollow(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+
+	var tr Tracker
+	w := &Webhook{URL: s.URL}
+	cancel := w.Follow(&tr, func(err error) { t.Error(err) })
+	defer cancel()
+
+	tr.Update("m1@postman.test", StateDelivered, "")
+
+	for deadline := time.Now().Add(5 * time.Second); len(s.received()) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("no event posted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var event Event
+	if err := json.Unmarshal(s.received()[0].body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "message.delivered" || event.Message.ID != "m1@postman.test" {
+		t.Errorf("got event %+v", event)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":"1"}`)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		signature string
+		ok        bool
+	}{
+		{"valid", "t=" + now + ",v1=" + webhookMAC(secret, now, body), true},
+		{"malformed", "v1=" + webhookMAC(secret, now, body), false},
+		{"other secret", "t=" + now + ",v1=" + webhookMAC([]byte("other"), now, body), false},
+		{"other time", "t=" + old + ",v1=" + webhookMAC(secret, now, body), false},
+		{"expired", "t=" + old + ",v1=" + webhookMAC(secret, old, body), false},
+	}
+
+	for _, tt := range tests {
+		err := VerifyWebhookSignature(secret, tt.signature, body, time.Minute)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want success: %t", tt.name, err, tt.ok)
+		}
+	}
+
+	if err := VerifyWebhookSignature(secret, tests[4].signature, []byte(`{"id":"2"}`), 0); err == nil {
+		t.Error("signature of another body accepted")
+	}
+	if err := VerifyWebhookSignature(secret, tests[4].signature, body, 0); err != nil {
+		t.Errorf("old signature without tolerance: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package postman
+
+import (
+	"time"
+)
+
+// SendWindow restricts the delivery of messages to hours of the day, for
+// them not to reach their recipients in the middle of the night:
+//
+//	q.Windows = map[string]*postman.SendWindow{
+//		"newsletter": {
+//			Start: 9 * time.Hour,
+//			End:   18 * time.Hour,
+//			Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//			Location: paris,
+//		},
+//	}
+type SendWindow struct {
+	// Start and End are the times of day the window opens and closes at,
+	// as durations since midnight. A window ending before it starts
+	// spans midnight.
+	Start time.Duration
+	End   time.Duration
+
+	// Days are the days of the week the window opens; empty means every
+	// day.
+	Days []time.Weekday
+
+	// Location is the time zone of the window. Nil means UTC.
+	Location *time.Location
+
+	// RecipientLocation, when set, returns the time zone of a recipient,
+	// the window then opening in the time zone of the recipients of the
+	// messages; nil means Location. Messages to recipients in several
+	// time zones are deferred until the window is open in all of them or,
+	// if it never is, in the one of their first recipient.
+	RecipientLocation func(addr string) *time.Location
+}
+
+// Next returns the first time from t on when the window is open for a
+// message to the given recipients: t itself if it already is.
+func (w *SendWindow) Next(t time.Time, rcpts []string) time.Time {
+	locs := w.locations(rcpts)
+
+	// Windows repeat every week: if they do not overlap within a week,
+	// they never do.
+	limit := t.Add(8 * 24 * time.Hour)
+
+	for next := t; next.Before(limit); {
+		moved := false
+		for _, loc := range locs {
+			if n := w.nextIn(next, loc); n.After(next) {
+				next, moved = n, true
+			}
+		}
+		if !moved {
+			return next
+		}
+	}
+
+	return w.nextIn(t, locs[0])
+}
+
+// locations returns the time zones the window opens in for rcpts.
+func (w *SendWindow) locations(rcpts []string) []*time.Location {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if w.RecipientLocation == nil || len(rcpts) == 0 {
+		return []*time.Location{loc}
+	}
+
+	var locs []*time.Location
+	seen := make(map[string]bool)
+	for _, rcpt := range rcpts {
+		l := w.RecipientLocation(rcpt)
+		if l == nil {
+			l = loc
+		}
+		if !seen[l.String()] {
+			seen[l.String()] = true
+			locs = append(locs, l)
+		}
+	}
+
+	return locs
+}
+
+// nextIn returns the first time from t on when the window is open in
+// the time zone loc.
+func (w *SendWindow) nextIn(t time.Time, loc *time.Location) time.Time {
+	lt := t.In(loc)
+
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	// The window of the previous day may still be open.
+	for d := -1; d <= 7; d++ {
+		day := time.Date(lt.Year(), lt.Month(), lt.Day()+d, 0, 0, 0, 0, loc)
+		if !w.opensOn(day.Weekday()) {
+			continue
+		}
+
+		start := day.Add(w.Start)
+		if end := start.Add(length); lt.Before(end) {
+			if lt.Before(start) {
+				return start
+			}
+			return t
+		}
+	}
+
+	// No day of the week opens the window.
+	return t
+}
+
+func (w *SendWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package postman
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendWindowNext(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		// June 7, 2021 is a Monday.
+		return time.Date(2021, time.June, 6+day, hour, min, 0, 0, time.UTC)
+	}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	office := &SendWindow{Start: 9 * time.Hour, End: 18 * time.Hour, Days: weekdays}
+	zone := func(addr string) *time.Location {
+		switch {
+		case strings.HasSuffix(addr, ".fr"):
+			return time.FixedZone("CET", 3600)
+		case strings.HasSuffix(addr, ".us"):
+			return time.FixedZone("EST", -5*3600)
+		case strings.HasSuffix(addr, ".nz"):
+			return time.FixedZone("NZST", 12*3600)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		w     *SendWindow
+		t     time.Time
+		rcpts []string
+		want  time.Time
+	}{
+		{"open", office, at(1, 10, 0), nil, at(1, 10, 0)},
+		{"before opening", office, at(1, 8, 0), nil, at(1, 9, 0)},
+		{"at closing", office, at(1, 18, 0), nil, at(2, 9, 0)},
+		{"friday evening", office, at(5, 19, 0), nil, at(8, 9, 0)},
+		{"weekend", office, at(6, 12, 0), nil, at(8, 9, 0)},
+		{"overnight, evening", &SendWindow{Start: 22 * time.Hour, End: 6 * time.Hour}, at(1, 23, 0), nil, at(1, 23, 0)},
+		{"overnight, morning", &SendWindow{Start: 22 * time.Hour, End: 6 * time.Hour}, at(2, 3, 0), nil, at(2, 3, 0)},
+		{"overnight, day", &SendWindow{Start: 22 * time.Hour, End: 6 * time.Hour}, at(1, 12, 0), nil, at(1, 22, 0)},
+		{
+			"location",
+			&SendWindow{Start: 9 * time.Hour, End: 18 * time.Hour, Location: time.FixedZone("CET", 3600)},
+			at(1, 7, 30), nil, at(1, 8, 0),
+		},
+		{
+			// Open from 08:00 to 17:00 UTC in Paris and from 14:00 to
+			// 23:00 UTC in New York.
+			"recipient locations",
+			&SendWindow{Start: 9 * time.Hour, End: 18 * time.Hour, RecipientLocation: zone},
+			at(1, 6, 0), []string{"jane@example.fr", "john@example.us"}, at(1, 14, 0),
+		},
+		{
+			"recipient without location",
+			&SendWindow{Start: 9 * time.Hour, End: 18 * time.Hour, RecipientLocation: zone},
+			at(1, 6, 0), []string{"jane@example.com"}, at(1, 9, 0),
+		},
+		{
+			// Open from 09:00 to 10:00 UTC, and from 21:00 to 22:00 UTC in
+			// Wellington: the window of the first recipient is used.
+			"disjoint recipient locations",
+			&SendWindow{Start: 9 * time.Hour, End: 10 * time.Hour, RecipientLocation: zone},
+			at(1, 6, 0), []string{"jane@example.com", "john@example.nz"}, at(1, 9, 0),
+		},
+		{"no day", &SendWindow{Start: 9 * time.Hour, End: 18 * time.Hour, Days: []time.Weekday{}}, at(6, 20, 0), nil, at(7, 9, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.w.Next(tt.t, tt.rcpts); !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestQueueWindow(t *testing.T) {
+	// The window opens in two hours, for one hour.
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := (now.Sub(midnight) + 2*time.Hour) % (24 * time.Hour)
+	w := &SendWindow{Start: start, End: (start + time.Hour) % (24 * time.Hour)}
+
+	q := NewQueue(nil)
+	q.Windows = map[string]*SendWindow{"newsletter": w}
+
+	newsletter := benchMail(1)
+	newsletter.Campaign = "newsletter"
+	if _, err := q.Enqueue(newsletter); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(benchMail(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message of the campaign waits, the other one does not.
+	e, _ := q.pop()
+	if e == nil || e.m.Campaign != "" {
+		t.Fatalf("popped %+v, want the message without campaign", e)
+	}
+
+	before := time.Now()
+	e, next := q.pop()
+	if e != nil {
+		t.Fatalf("popped %+v before the window opens", e.m)
+	}
+	if next.Before(before.Add(time.Hour+59*time.Minute)) || next.After(time.Now().Add(2*time.Hour)) {
+		t.Errorf("due at %s, want in two hours", next)
+	}
+	if q.Len() != 1 {
+		t.Errorf("%d messages queued, want 1", q.Len())
+	}
+}
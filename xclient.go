@@ -0,0 +1,121 @@
+package postman
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// XClient describes the original client of the messages relayed by a
+// Client, for the server to apply its policies to it rather than to the
+// relay. It is sent with the XCLIENT command of Postfix, which the server
+// only accepts from trusted relays. Empty attributes are not sent.
+type XClient struct {
+	// Name is the reverse DNS name of the original client, or
+	// "[UNAVAILABLE]" or "[TEMPUNAVAIL]" when the lookup failed.
+	Name string
+
+	// Addr is the IP address of the original client; IPv6 addresses are
+	// prefixed with "IPV6:".
+	Addr string
+
+	Port int
+
+	// Proto is "SMTP" or "ESMTP".
+	Proto string
+
+	// Helo is the name the original client gave in its HELO or EHLO
+	// command.
+	Helo string
+
+	// Login is the name the original client authenticated as.
+	Login string
+}
+
+// attributes returns the attributes of x, as XCLIENT parameters.
+func (x *XClient) attributes() []string {
+	var attrs []string
+
+	add := func(name, value string) {
+		if value != "" {
+			attrs = append(attrs, name+"="+xtext(value))
+		}
+	}
+
+	add("NAME", x.Name)
+	add("ADDR", x.Addr)
+	if x.Port != 0 {
+		add("PORT", strconv.Itoa(x.Port))
+	}
+	add("PROTO", x.Proto)
+	add("HELO", x.Helo)
+	add("LOGIN", x.Login)
+
+	return attrs
+}
+
+// sendXClient sends the XCLIENT command, after which the server greets the
+// client again and expects a new EHLO.
+func sendXClient(conn *smtp.Client, x *XClient) error {
+	ok, params := conn.Extension("XCLIENT")
+	if !ok {
+		return errors.New("postman: server does not support XCLIENT")
+	}
+
+	supported := make(map[string]bool)
+	for _, name := range strings.Fields(params) {
+		supported[strings.ToUpper(name)] = true
+	}
+
+	attrs := x.attributes()
+	for _, attr := range attrs {
+		if name := attr[:strings.IndexByte(attr, '=')]; !supported[name] {
+			return fmt.Errorf("postman: server does not support the XCLIENT %s attribute", name)
+		}
+	}
+
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	text := conn.Text
+
+	id, err := text.Cmd("XCLIENT %s", strings.Join(attrs, " "))
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(220)
+	text.EndResponse(id)
+	if err != nil {
+		return err
+	}
+
+	// smtp.Client only sends EHLO once; the extensions it recorded are
+	// those of the relay, which the server keeps offering.
+	id, err = text.Cmd("EHLO localhost")
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(250)
+	text.EndResponse(id)
+
+	return err
+}
+
+// xtext encodes s as RFC 3461 section 4 describes.
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
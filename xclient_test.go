@@ -0,0 +1,107 @@
+package postman
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"mail.example.com", "mail.example.com"},
+		{"a b", "a+20b"},
+		{"a+b=c", "a+2Bb+3Dc"},
+		{"tab\there", "tab+09here"},
+		{"é", "+C3+A9"},
+		{"[UNAVAILABLE]", "[UNAVAILABLE]"},
+	}
+
+	for _, tt := range tests {
+		if got := xtext(tt.in); got != tt.want {
+			t.Errorf("xtext(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestXClientAttributes(t *testing.T) {
+	x := &XClient{
+		Name:  "client.example.com",
+		Addr:  "IPV6:2001:db8::1",
+		Port:  4321,
+		Proto: "ESMTP",
+		Helo:  "my helo",
+		Login: "user+tag@example.com",
+	}
+
+	got := strings.Join(x.attributes(), " ")
+	want := "NAME=client.example.com ADDR=IPV6:2001:db8::1 PORT=4321 PROTO=ESMTP HELO=my+20helo LOGIN=user+2Btag@example.com"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if attrs := (&XClient{Addr: "192.0.2.1"}).attributes(); len(attrs) != 1 || attrs[0] != "ADDR=192.0.2.1" {
+		t.Errorf("empty attributes sent: %q", attrs)
+	}
+}
+
+// TestClientXClient checks the XCLIENT exchange: the command follows the
+// first EHLO, and the client greets the server again before the
+// transaction.
+func TestClientXClient(t *testing.T) {
+	s := newTestServer(t, withExtensions("XCLIENT NAME ADDR PORT PROTO HELO LOGIN"))
+	defer s.Close()
+
+	c := NewClient(s.Addr, WithXClient(&XClient{Addr: "192.0.2.1", Helo: "client name", Login: "a=b"}))
+	defer c.Close()
+
+	if err := c.Send(benchMail(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	s.Close()
+
+	var verbs []string
+	for _, cmd := range s.Commands() {
+		verbs = append(verbs, strings.Fields(cmd)[0])
+		if strings.HasPrefix(cmd, "XCLIENT ") {
+			if want := "XCLIENT ADDR=192.0.2.1 HELO=client+20name LOGIN=a+3Db"; cmd != want {
+				t.Errorf("got %q, want %q", cmd, want)
+			}
+		}
+	}
+	if got, want := strings.Join(verbs, " "), "EHLO XCLIENT EHLO MAIL"; !strings.HasPrefix(got, want) {
+		t.Errorf("commands %q, want %q first", verbs, want)
+	}
+
+	if n := len(s.Messages()); n != 1 {
+		t.Errorf("server received %d messages, want 1", n)
+	}
+}
+
+func TestClientXClientUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []testServerOption
+		want string
+	}{
+		{"no XCLIENT", nil, "does not support XCLIENT"},
+		{"attribute", []testServerOption{withExtensions("XCLIENT NAME ADDR")}, "does not support the XCLIENT LOGIN attribute"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.opts...)
+			defer s.Close()
+
+			c := NewClient(s.Addr, WithXClient(&XClient{Addr: "192.0.2.1", Login: "user"}))
+			defer c.Close()
+
+			err := c.Send(benchMail(1))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}